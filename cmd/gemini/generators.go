@@ -15,43 +15,81 @@ package main
 
 import (
 	"github.com/scylladb/gemini/pkg/generators"
+	"github.com/scylladb/gemini/pkg/stop"
 	"github.com/scylladb/gemini/pkg/typedef"
 
 	"go.uber.org/zap"
 )
 
+// minimizationHistorySize is the number of recent mutations kept for every
+// partition key when failures are minimized and no history size is given.
+const minimizationHistorySize = 100
+
 func createGenerators(
 	schema *typedef.Schema,
 	schemaConfig typedef.SchemaConfig,
 	seed, distributionSize uint64,
 	logger *zap.Logger,
 ) (generators.Generators, error) {
-	partitionRangeConfig := schemaConfig.GetPartitionRangeConfig()
-
 	var gs []*generators.Generator
 	for id := range schema.Tables {
-		table := schema.Tables[id]
-		pkVariations := table.PartitionKeys.ValueVariationsNumber(&partitionRangeConfig)
-
-		distFunc, err := createDistributionFunc(partitionKeyDistribution, distributionSize, seed, stdDistMean, oneStdDev)
+		g, err := createGenerator(schema.Tables[id], schemaConfig, seed, distributionSize, logger)
 		if err != nil {
 			return nil, err
 		}
-
-		tablePartConfig := &generators.Config{
-			PartitionsRangeConfig:      partitionRangeConfig,
-			PartitionsCount:            distributionSize,
-			PartitionsDistributionFunc: distFunc,
-			Seed:                       seed,
-			PkUsedBufferSize:           pkBufferReuseSize,
-		}
-		g := generators.NewGenerator(table, tablePartConfig, logger.Named("generators"))
-		if pkVariations < 2^32 {
-			// Low partition key variation can lead to having staled partitions
-			// Let's detect and mark them before running test
-			g.FindAndMarkStalePartitions()
-		}
 		gs = append(gs, g)
 	}
 	return gs, nil
 }
+
+// generatorFactory creates the generators of the tables that are created
+// while the jobs run, they are stopped with the stop flag.
+func generatorFactory(
+	schemaConfig typedef.SchemaConfig,
+	seed, distributionSize uint64,
+	stopFlag *stop.Flag,
+	logger *zap.Logger,
+) generators.Factory {
+	return func(table *typedef.Table) (*generators.Generator, error) {
+		g, err := createGenerator(table, schemaConfig, seed, distributionSize, logger)
+		if err != nil {
+			return nil, err
+		}
+		g.Start(stopFlag)
+		return g, nil
+	}
+}
+
+func createGenerator(
+	table *typedef.Table,
+	schemaConfig typedef.SchemaConfig,
+	seed, distributionSize uint64,
+	logger *zap.Logger,
+) (*generators.Generator, error) {
+	partitionRangeConfig := schemaConfig.GetPartitionRangeConfig()
+	pkVariations := table.PartitionKeys.ValueVariationsNumber(&partitionRangeConfig)
+
+	distFunc, err := createDistributionFunc(partitionKeyDistribution, distributionSize, seed, stdDistMean, oneStdDev)
+	if err != nil {
+		return nil, err
+	}
+
+	tablePartConfig := &generators.Config{
+		PartitionsRangeConfig:      partitionRangeConfig,
+		PartitionsCount:            distributionSize,
+		PartitionsDistributionFunc: distFunc,
+		Seed:                       seed,
+		PkUsedBufferSize:           pkBufferReuseSize,
+		HistorySize:                partitionHistorySize,
+	}
+	if schemaConfig.MinimizeFailures && tablePartConfig.HistorySize == 0 {
+		tablePartConfig.HistorySize = minimizationHistorySize
+	}
+	g := generators.NewGenerator(table, tablePartConfig, logger.Named("generators"))
+	if pkVariations < 2^32 {
+		// Low partition key variation can lead to having staled partitions
+		// Let's detect and mark them before running test
+		g.FindAndMarkStalePartitions()
+	}
+	return g, nil
+}
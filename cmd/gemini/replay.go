@@ -0,0 +1,167 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+
+	"github.com/scylladb/gemini/pkg/stmtlog"
+	"github.com/scylladb/gemini/pkg/utils"
+)
+
+var replayUntil uint64
+
+var replayCmd = &cobra.Command{
+	Use:          "replay",
+	Short:        "Replay a statement log recorded with --statement-log-file against the test and, optionally, the oracle cluster.",
+	RunE:         replay,
+	SilenceUsage: true,
+}
+
+func replay(_ *cobra.Command, _ []string) error {
+	logger := createLogger(level)
+	defer utils.IgnoreError(logger.Sync)
+
+	cons, err := gocql.ParseConsistencyWrapper(consistency)
+	if err != nil {
+		logger.Error("Unable parse consistency, error=%s. Falling back on Quorum", zap.Error(err))
+		cons = gocql.Quorum
+	}
+	testHostSelectionPolicy, err := getHostSelectionPolicy(testClusterHostSelectionPolicy, testClusterHost)
+	if err != nil {
+		return err
+	}
+	oracleHostSelectionPolicy, err := getHostSelectionPolicy(oracleClusterHostSelectionPolicy, oracleClusterHost)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(statementLogFile)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open statement log %s", statementLogFile)
+	}
+	defer utils.IgnoreError(f.Close)
+
+	testCluster, oracleCluster := createClusters(cons, testHostSelectionPolicy, oracleHostSelectionPolicy, logger)
+	testSession, err := testCluster.CreateSession()
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to test cluster")
+	}
+	defer testSession.Close()
+	var oracleSession *gocql.Session
+	if oracleCluster != nil {
+		if oracleSession, err = oracleCluster.CreateSession(); err != nil {
+			return errors.Wrap(err, "failed to connect to oracle cluster")
+		}
+		defer oracleSession.Close()
+	}
+
+	ctx := context.Background()
+	reader := stmtlog.NewReader(f)
+	var replayed, failed uint64
+	for {
+		rec, readErr := reader.Next()
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+		if replayUntil > 0 && rec.Index > replayUntil {
+			break
+		}
+		replayed++
+		if oracleSession != nil {
+			if !replayStatement(ctx, oracleSession, rec, "oracle", logger) {
+				failed++
+			}
+		}
+		if !replayStatement(ctx, testSession, rec, "test", logger) {
+			failed++
+		}
+		if failFast && failed > 0 {
+			break
+		}
+	}
+	fmt.Printf("Replayed %d statements, %d unexpected outcomes\n", replayed, failed)
+	if failed > 0 {
+		return errors.Errorf("replay encountered unexpected outcomes, exiting with non zero status")
+	}
+	return nil
+}
+
+// replayStatement applies a recorded statement with its original client
+// timestamp and reports whether the outcome matched the one recorded for the
+// system. Statements the system did not attempt when recorded are skipped.
+func replayStatement(ctx context.Context, session *gocql.Session, rec *stmtlog.Record, system string, logger *zap.Logger) bool {
+	outcome, recordedErr := rec.OutcomeFor(system == "oracle")
+	if outcome == stmtlog.OutcomeSkipped {
+		return true
+	}
+	query := session.Query(rec.QueryFor(system == "oracle"), rec.Values...).WithContext(ctx)
+	if rec.Timestamp != 0 {
+		query = query.WithTimestamp(rec.Timestamp)
+	} else {
+		query = query.DefaultTimestamp(false)
+	}
+	err := query.Exec()
+	switch {
+	case err == nil && outcome == stmtlog.OutcomeFailed:
+		logger.Warn("statement failed when recorded but was applied on replay",
+			zap.Uint64("index", rec.Index), zap.String("system", system), zap.String("query", rec.Query), zap.String("recorded_error", recordedErr))
+		return false
+	case err != nil && outcome != stmtlog.OutcomeFailed:
+		logger.Error("statement was applied when recorded but failed on replay",
+			zap.Uint64("index", rec.Index), zap.String("system", system), zap.String("query", rec.Query), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+	replayCmd.Flags().StringVarP(&statementLogFile, "statement-log-file", "", "", "Statement log to replay")
+	_ = replayCmd.MarkFlagRequired("statement-log-file")
+	replayCmd.Flags().Uint64VarP(&replayUntil, "until", "", 0, "Index of the last statement to replay, by default the whole log is replayed")
+	replayCmd.Flags().StringSliceVarP(&testClusterHost, "test-cluster", "t", []string{}, "Host names or IPs of the test cluster that is system under test")
+	_ = replayCmd.MarkFlagRequired("test-cluster")
+	replayCmd.Flags().StringVarP(&testClusterUsername, "test-username", "", "", "Username for the test cluster")
+	replayCmd.Flags().StringVarP(&testClusterPassword, "test-password", "", "", "Password for the test cluster")
+	replayCmd.Flags().StringSliceVarP(
+		&oracleClusterHost, "oracle-cluster", "o", []string{},
+		"Host names or IPs of the oracle cluster. If omitted the log is replayed against the test cluster only")
+	replayCmd.Flags().StringVarP(&oracleClusterUsername, "oracle-username", "", "", "Username for the oracle cluster")
+	replayCmd.Flags().StringVarP(&oracleClusterPassword, "oracle-password", "", "", "Password for the oracle cluster")
+	replayCmd.Flags().StringVarP(&consistency, "consistency", "", "QUORUM", "Specify the desired consistency as ANY|ONE|TWO|THREE|QUORUM|LOCAL_QUORUM|EACH_QUORUM|LOCAL_ONE")
+	replayCmd.Flags().StringVarP(
+		&oracleClusterHostSelectionPolicy, "oracle-host-selection-policy", "", "round-robin",
+		"Host selection policy used by the driver for the oracle cluster: round-robin|host-pool|token-aware")
+	replayCmd.Flags().StringVarP(
+		&testClusterHostSelectionPolicy, "test-host-selection-policy", "", "round-robin",
+		"Host selection policy used by the driver for the test cluster: round-robin|host-pool|token-aware")
+	replayCmd.Flags().BoolVarP(&failFast, "fail-fast", "f", false, "Stop on the first unexpected outcome")
+	replayCmd.Flags().StringVarP(&level, "level", "", "info", "Specify the logging level, debug|info|warn|error|dpanic|panic|fatal")
+	replayCmd.Flags().DurationVarP(&requestTimeout, "request-timeout", "", 30*time.Second, "Duration of waiting request execution")
+	replayCmd.Flags().DurationVarP(&connectTimeout, "connect-timeout", "", 30*time.Second, "Duration of waiting connection established")
+}
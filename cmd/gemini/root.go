@@ -74,6 +74,7 @@ var (
 	warmup                           time.Duration
 	replicationStrategy              string
 	tableOptions                     []string
+	valueDistributions               []string
 	oracleReplicationStrategy        string
 	consistency                      string
 	maxTables                        int
@@ -90,18 +91,27 @@ var (
 	maxRetriesMutateSleep            time.Duration
 	maxErrorsToStore                 int
 	pkBufferReuseSize                uint64
+	partitionHistorySize             uint64
 	partitionCount                   uint64
 	partitionKeyDistribution         string
 	normalDistMean                   float64
 	normalDistSigma                  float64
 	tracingOutFile                   string
 	useCounters                      bool
+	useVectors                       bool
 	asyncObjectStabilizationAttempts int
 	asyncObjectStabilizationDelay    time.Duration
 	useLWT                           bool
 	testClusterHostSelectionPolicy   string
 	oracleClusterHostSelectionPolicy string
 	useServerSideTimestamps          bool
+	useInMemoryOracle                bool
+	statementLogFile                 string
+	minimizeFailures                 bool
+	maxTTL                           time.Duration
+	useWriteTimestamps               bool
+	fullScan                         bool
+	fullScanPageSize                 int
 	requestTimeout                   time.Duration
 	connectTimeout                   time.Duration
 	profilingPort                    int
@@ -219,6 +229,9 @@ func run(_ *cobra.Command, _ []string) error {
 		MaxRetriesMutate:        maxRetriesMutate,
 		MaxRetriesMutateSleep:   maxRetriesMutateSleep,
 		UseServerSideTimestamps: useServerSideTimestamps,
+		UseInMemoryOracle:       useInMemoryOracle,
+		StatementLogFile:        statementLogFile,
+		ScanPageSize:            fullScanPageSize,
 	}
 	var tracingFile *os.File
 	if tracingOutFile != "" {
@@ -293,7 +306,7 @@ func run(_ *cobra.Command, _ []string) error {
 
 	if warmup > 0 && !stopFlag.IsHardOrSoft() {
 		jobsList := jobs.ListFromMode(jobs.WarmupMode, warmup, concurrency)
-		if err = jobsList.Run(ctx, schema, schemaConfig, st, pump, gens, globalStatus, logger, intSeed, warmupStopFlag, failFast, verbose); err != nil {
+		if err = jobsList.Run(ctx, schema, schemaConfig, st, pump, gens, nil, globalStatus, logger, intSeed, warmupStopFlag, failFast, verbose); err != nil {
 			logger.Error("warmup encountered an error", zap.Error(err))
 			stopFlag.SetHard(true)
 		}
@@ -301,10 +314,18 @@ func run(_ *cobra.Command, _ []string) error {
 
 	if !stopFlag.IsHardOrSoft() {
 		jobsList := jobs.ListFromMode(mode, duration, concurrency)
-		if err = jobsList.Run(ctx, schema, schemaConfig, st, pump, gens, globalStatus, logger, intSeed, stopFlag.CreateChild("workload"), failFast, verbose); err != nil {
+		newGenerator := generatorFactory(schemaConfig, intSeed, partitionCount, stopFlag, logger)
+		if err = jobsList.Run(ctx, schema, schemaConfig, st, pump, gens, newGenerator, globalStatus, logger, intSeed, stopFlag.CreateChild("workload"), failFast, verbose); err != nil {
 			logger.Debug("error detected", zap.Error(err))
 		}
 	}
+	if fullScan && !stopFlag.IsHard() && !(failFast && globalStatus.HasErrors()) {
+		logger.Info("full scan started")
+		scanCtx := stopFlag.CancelContextOnSignal(context.Background(), stop.SignalHardStop)
+		if err = jobs.FullScan(scanCtx, schema, &schemaConfig, st, globalStatus, logger, failFast); err != nil {
+			logger.Debug("full scan interrupted", zap.Error(err))
+		}
+	}
 	logger.Info("test finished")
 	globalStatus.PrintResult(outFile, schema, version)
 	if globalStatus.HasErrors() {
@@ -487,6 +508,11 @@ func init() {
 		"Specify the desired replication strategy of the oracle cluster as either the coded short hand simple|network to get the default for each "+
 			"type or provide the entire specification in the form {'class':'....'}")
 	rootCmd.Flags().StringArrayVarP(&tableOptions, "table-options", "", []string{}, "Repeatable argument to set table options to be added to the created tables")
+	rootCmd.Flags().StringArrayVarP(
+		&valueDistributions, "value-distribution", "", []string{},
+		"Repeatable argument to set how the values of a type, or of all types, are drawn in the form TYPE:OPTION=VALUE,... "+
+			"The options are boundary, the probability of an edge value, null, the probability of a null, "+
+			"pool, the number of constant values to draw from and zipf, the skew of the draws from the pool")
 	rootCmd.Flags().StringVarP(&consistency, "consistency", "", "QUORUM", "Specify the desired consistency as ANY|ONE|TWO|THREE|QUORUM|LOCAL_QUORUM|EACH_QUORUM|LOCAL_ONE")
 	rootCmd.Flags().IntVarP(&maxTables, "max-tables", "", 1, "Maximum number of generated tables")
 	rootCmd.Flags().IntVarP(&maxPartitionKeys, "max-partition-keys", "", 6, "Maximum number of generated partition keys")
@@ -503,6 +529,10 @@ func init() {
 		&maxRetriesMutateSleep, "max-mutation-retries-backoff", "", 10*time.Millisecond,
 		"Duration between attempts to apply a mutation for example 10ms or 1s")
 	rootCmd.Flags().Uint64VarP(&pkBufferReuseSize, "partition-key-buffer-reuse-size", "", 100, "Number of reused buffered partition keys")
+	rootCmd.Flags().Uint64VarP(
+		&partitionHistorySize, "partition-history-size", "", 0,
+		"Number of recent writes kept for every partition key and reported when its validation fails. "+
+			"By default the history is disabled, unless failures are minimized.")
 	rootCmd.Flags().Uint64VarP(&partitionCount, "token-range-slices", "", 10000, "Number of slices to divide the token space into")
 	rootCmd.Flags().StringVarP(
 		&partitionKeyDistribution, "partition-key-distribution", "", "uniform",
@@ -513,13 +543,14 @@ func init() {
 		&tracingOutFile, "tracing-outfile", "", "",
 		"Specify the file to which tracing information gets written. Two magic names are available, 'stdout' and 'stderr'. By default tracing is disabled.")
 	rootCmd.Flags().BoolVarP(&useCounters, "use-counters", "", false, "Ensure that at least one table is a counter table")
+	rootCmd.Flags().BoolVarP(&useVectors, "use-vectors", "", false, "Add vector<float, N> columns to the tables, the clusters have to support vectors")
 	rootCmd.Flags().IntVarP(
 		&asyncObjectStabilizationAttempts, "async-objects-stabilization-attempts", "", 10,
 		"Maximum number of attempts to validate result sets from MV and SI")
 	rootCmd.Flags().DurationVarP(
 		&asyncObjectStabilizationDelay, "async-objects-stabilization-backoff", "", 10*time.Millisecond,
 		"Duration between attempts to validate result sets from MV and SI for example 10ms or 1s")
-	rootCmd.Flags().BoolVarP(&useLWT, "use-lwt", "", false, "Emit LWT based updates")
+	rootCmd.Flags().BoolVarP(&useLWT, "use-lwt", "", false, "Emit conditional (LWT) inserts, updates, deletes and batches and compare whether they applied on both clusters")
 	rootCmd.Flags().StringVarP(
 		&oracleClusterHostSelectionPolicy, "oracle-host-selection-policy", "", "round-robin",
 		"Host selection policy used by the driver for the oracle cluster: round-robin|host-pool|token-aware")
@@ -527,6 +558,26 @@ func init() {
 		&testClusterHostSelectionPolicy, "test-host-selection-policy", "", "round-robin",
 		"Host selection policy used by the driver for the test cluster: round-robin|host-pool|token-aware")
 	rootCmd.Flags().BoolVarP(&useServerSideTimestamps, "use-server-timestamps", "", false, "Use server-side generated timestamps for writes")
+	rootCmd.Flags().BoolVarP(
+		&useInMemoryOracle, "use-in-memory-oracle", "", false,
+		"Validate the test cluster against an in-memory reference store when no oracle cluster is given")
+	rootCmd.Flags().StringVarP(
+		&statementLogFile, "statement-log-file", "", "",
+		"Specify the file to which every applied mutation and DDL statement gets recorded, so the run can be reproduced with 'gemini replay'. "+
+			"By default statements are not recorded.")
+	rootCmd.Flags().BoolVarP(
+		&minimizeFailures, "minimize-failures", "", false,
+		"Shrink the mutations of a partition that fails validation to a minimal CQL script reproducing the failure")
+	rootCmd.Flags().DurationVarP(
+		&maxTTL, "max-ttl", "", 0,
+		"Maximum TTL of generated inserts and updates, for example 30s or 10m. By default mutations do not expire")
+	rootCmd.Flags().BoolVarP(
+		&useWriteTimestamps, "use-write-timestamps", "", false,
+		"Set explicit, partly colliding, write timestamps on generated mutations with USING TIMESTAMP")
+	rootCmd.Flags().BoolVarP(
+		&fullScan, "full-scan", "", false,
+		"At the end of the run compare the whole content of every table between the clusters by scanning the token ring")
+	rootCmd.Flags().IntVarP(&fullScanPageSize, "full-scan-page-size", "", 1000, "Number of rows fetched per page by the full scan")
 	rootCmd.Flags().DurationVarP(&requestTimeout, "request-timeout", "", 30*time.Second, "Duration of waiting request execution")
 	rootCmd.Flags().DurationVarP(&connectTimeout, "connect-timeout", "", 30*time.Second, "Duration of waiting connection established")
 	rootCmd.Flags().IntVarP(&profilingPort, "profiling-port", "", 0, "If non-zero starts pprof profiler on given port at 'http://0.0.0.0:<port>/profile'")
@@ -32,6 +32,7 @@ func createSchemaConfig(logger *zap.Logger) typedef.SchemaConfig {
 			ReplicationStrategy:              defaultConfig.ReplicationStrategy,
 			OracleReplicationStrategy:        defaultConfig.OracleReplicationStrategy,
 			TableOptions:                     defaultConfig.TableOptions,
+			ValueDistributions:               defaultConfig.ValueDistributions,
 			MaxTables:                        defaultConfig.MaxTables,
 			MaxPartitionKeys:                 defaultConfig.MaxPartitionKeys,
 			MinPartitionKeys:                 defaultConfig.MinPartitionKeys,
@@ -44,10 +45,15 @@ func createSchemaConfig(logger *zap.Logger) typedef.SchemaConfig {
 			MaxBlobLength:                    20,
 			MaxStringLength:                  20,
 			UseCounters:                      defaultConfig.UseCounters,
+			UseVectors:                       defaultConfig.UseVectors,
 			UseLWT:                           defaultConfig.UseLWT,
 			CQLFeature:                       defaultConfig.CQLFeature,
 			AsyncObjectStabilizationAttempts: defaultConfig.AsyncObjectStabilizationAttempts,
 			AsyncObjectStabilizationDelay:    defaultConfig.AsyncObjectStabilizationDelay,
+			MinimizeFailures:                 defaultConfig.MinimizeFailures,
+			MaxTTL:                           defaultConfig.MaxTTL,
+			UseWriteTimestamps:               defaultConfig.UseWriteTimestamps,
+			UseServerSideTimestamps:          defaultConfig.UseServerSideTimestamps,
 		}
 	default:
 		return defaultConfig
@@ -69,6 +75,7 @@ func createDefaultSchemaConfig(logger *zap.Logger) typedef.SchemaConfig {
 		ReplicationStrategy:              rs,
 		OracleReplicationStrategy:        ors,
 		TableOptions:                     tableopts.CreateTableOptions(tableOptions, logger),
+		ValueDistributions:               getValueDistributions(valueDistributions, logger),
 		MaxTables:                        maxTables,
 		MaxPartitionKeys:                 maxPartitionKeys,
 		MinPartitionKeys:                 minPartitionKeys,
@@ -83,9 +90,23 @@ func createDefaultSchemaConfig(logger *zap.Logger) typedef.SchemaConfig {
 		MaxStringLength:                  MaxStringLength,
 		MinStringLength:                  MinStringLength,
 		UseCounters:                      useCounters,
+		UseVectors:                       useVectors,
 		UseLWT:                           useLWT,
 		CQLFeature:                       getCQLFeature(cqlFeatures),
 		AsyncObjectStabilizationAttempts: asyncObjectStabilizationAttempts,
 		AsyncObjectStabilizationDelay:    asyncObjectStabilizationDelay,
+		MinimizeFailures:                 minimizeFailures,
+		MaxTTL:                           maxTTL,
+		UseWriteTimestamps:               useWriteTimestamps,
+		UseServerSideTimestamps:          useServerSideTimestamps,
 	}
 }
+
+func getValueDistributions(specs []string, logger *zap.Logger) *typedef.ValueDistributions {
+	distributions, err := typedef.ParseValueDistributions(specs)
+	if err != nil {
+		logger.Error("unable to parse value distributions, values are drawn uniformly", zap.Strings("distributions", specs), zap.Error(err))
+		return nil
+	}
+	return distributions
+}
@@ -71,7 +71,7 @@ func TestReadExampleSchema(t *testing.T) {
 
 	opts := cmp.Options{
 		cmp.AllowUnexported(typedef.Table{}, typedef.MaterializedView{}),
-		cmpopts.IgnoreUnexported(typedef.Table{}, typedef.MaterializedView{}),
+		cmpopts.IgnoreUnexported(typedef.Schema{}, typedef.Table{}, typedef.MaterializedView{}),
 	}
 
 	testSchemaMarshaled, err := json.MarshalIndent(testSchema, "  ", "  ")
@@ -83,7 +83,7 @@ func TestReadExampleSchema(t *testing.T) {
 		t.Fatalf("unable to unmarshal json, error=%s\n", err)
 	}
 
-	if diff := cmp.Diff(*testSchema, testSchemaUnMarshaled, opts); diff != "" {
+	if diff := cmp.Diff(testSchema, &testSchemaUnMarshaled, opts); diff != "" {
 		t.Errorf("schema not the same after marshal/unmarshal, diff=%s", diff)
 	}
 }
@@ -15,24 +15,66 @@
 package generators
 
 import (
+	"golang.org/x/exp/rand"
+
 	"github.com/scylladb/gemini/pkg/typedef"
 )
 
-func CreateIndexesForColumn(table *typedef.Table, maxIndexes int) []typedef.IndexDef {
-	createdCount := 0
+// CreateIndexesForColumn creates up to maxIndexes secondary indexes on the
+// regular columns and then on the clustering keys of the table. Simple
+// columns get global or local indexes, non-frozen collections are indexed
+// on their keys, values or entries.
+func CreateIndexesForColumn(table *typedef.Table, maxIndexes int, r *rand.Rand) []typedef.IndexDef {
 	indexes := make([]typedef.IndexDef, 0, maxIndexes)
 	for i, col := range table.Columns {
-		if col.Type.Indexable() && typedef.TypesForIndex.Contains(col.Type) {
-			indexes = append(indexes, typedef.IndexDef{
-				IndexName:  GenIndexName(table.Name+"_col", i),
-				ColumnName: table.Columns[i].Name,
-				Column:     table.Columns[i],
-			})
-			createdCount++
+		if len(indexes) == maxIndexes {
+			return indexes
+		}
+		idx := typedef.IndexDef{
+			IndexName:  GenIndexName(table.Name+"_col", i),
+			ColumnName: col.Name,
+			Column:     col,
+		}
+		switch tt := col.Type.(type) {
+		case *typedef.BagType:
+			if tt.Frozen || !typedef.TypesForIndex.Contains(tt.ValueType) {
+				continue
+			}
+			idx.Target = typedef.IndexTargetValues
+		case *typedef.MapType:
+			var targets []typedef.IndexTarget
+			if !tt.Frozen && typedef.TypesForIndex.Contains(tt.KeyType) {
+				targets = append(targets, typedef.IndexTargetKeys)
+			}
+			if !tt.Frozen && typedef.TypesForIndex.Contains(tt.ValueType) {
+				targets = append(targets, typedef.IndexTargetValues)
+			}
+			if len(targets) == 2 {
+				targets = append(targets, typedef.IndexTargetEntries)
+			}
+			if len(targets) == 0 {
+				continue
+			}
+			idx.Target = targets[r.Intn(len(targets))]
+		default:
+			if !col.Type.Indexable() || !typedef.TypesForIndex.Contains(col.Type) {
+				continue
+			}
+			idx.Local = r.Intn(2) == 0
 		}
-		if createdCount == maxIndexes {
+		indexes = append(indexes, idx)
+	}
+	for i, ck := range table.ClusteringKeys {
+		if len(indexes) == maxIndexes {
 			break
 		}
+		if typedef.TypesForIndex.Contains(ck.Type) {
+			indexes = append(indexes, typedef.IndexDef{
+				IndexName:  GenIndexName(table.Name+"_ck", i),
+				ColumnName: ck.Name,
+				Column:     ck,
+			})
+		}
 	}
 	return indexes
 }
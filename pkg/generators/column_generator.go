@@ -20,6 +20,7 @@ import (
 	"golang.org/x/exp/rand"
 
 	"github.com/scylladb/gemini/pkg/typedef"
+	"github.com/scylladb/gemini/pkg/utils"
 )
 
 func GenColumnName(prefix string, idx int) string {
@@ -27,6 +28,9 @@ func GenColumnName(prefix string, idx int) string {
 }
 
 func GenColumnType(numColumns int, sc *typedef.SchemaConfig, r *rand.Rand) typedef.Type {
+	if sc.UseVectors && r.Intn(numColumns+5) == 0 {
+		return GenVectorType(sc, r)
+	}
 	n := r.Intn(numColumns + 5)
 	switch n {
 	case numColumns:
@@ -48,14 +52,46 @@ func GenSimpleType(_ *typedef.SchemaConfig, r *rand.Rand) typedef.SimpleType {
 	return typedef.AllTypes[r.Intn(len(typedef.AllTypes))]
 }
 
+// maxNestingDepth is the number of levels complex types are nested in each
+// other, a list of frozen maps of frozen sets is nested two levels deep.
+const maxNestingDepth = 2
+
+// genElementType generates the type of an element of a complex type at the
+// given nesting depth. Most elements are of a simple type, the others are
+// frozen collections and user defined types. Nested types hold no durations,
+// which are not allowed in sets and map keys at any depth.
+func genElementType(sc *typedef.SchemaConfig, r *rand.Rand, depth int) typedef.Type {
+	if depth < maxNestingDepth && r.Intn(4) == 0 {
+		switch r.Intn(4) {
+		case 0:
+			return genBagType(typedef.TYPE_LIST, sc, r, depth+1, true)
+		case 1:
+			return genBagType(typedef.TYPE_SET, sc, r, depth+1, true)
+		case 2:
+			return genMapType(sc, r, depth+1, true)
+		default:
+			return genUDTType(sc, r, depth+1, true)
+		}
+	}
+	return genSimpleType(sc, r, depth > 0)
+}
+
+func genSimpleType(sc *typedef.SchemaConfig, r *rand.Rand, noDuration bool) typedef.SimpleType {
+	for {
+		if t := GenSimpleType(sc, r); !noDuration || t != typedef.TYPE_DURATION {
+			return t
+		}
+	}
+}
+
 func GenTupleType(sc *typedef.SchemaConfig, r *rand.Rand) typedef.Type {
 	n := r.Intn(sc.MaxTupleParts)
 	if n < 2 {
 		n = 2
 	}
-	typeList := make([]typedef.SimpleType, n)
+	typeList := make([]typedef.Type, n)
 	for i := 0; i < n; i++ {
-		typeList[i] = GenSimpleType(sc, r)
+		typeList[i] = genElementType(sc, r, 0)
 	}
 	return &typedef.TupleType{
 		ComplexType: typedef.TYPE_TUPLE,
@@ -65,46 +101,51 @@ func GenTupleType(sc *typedef.SchemaConfig, r *rand.Rand) typedef.Type {
 }
 
 func GenUDTType(sc *typedef.SchemaConfig, r *rand.Rand) *typedef.UDTType {
+	return genUDTType(sc, r, 0, r.Intn(2) == 0)
+}
+
+func genUDTType(sc *typedef.SchemaConfig, r *rand.Rand, depth int, frozen bool) *typedef.UDTType {
 	udtNum := r.Uint32()
 	typeName := fmt.Sprintf("udt_%d", udtNum)
-	ts := make(map[string]typedef.SimpleType)
+	ts := make(map[string]typedef.Type)
 
 	for i := 0; i < r.Intn(sc.MaxUDTParts)+1; i++ {
-		ts[typeName+fmt.Sprintf("_%d", i)] = GenSimpleType(sc, r)
+		ts[typeName+fmt.Sprintf("_%d", i)] = genElementType(sc, r, depth)
 	}
 
 	return &typedef.UDTType{
 		ComplexType: typedef.TYPE_UDT,
 		ValueTypes:  ts,
 		TypeName:    typeName,
-		Frozen:      true,
+		Frozen:      frozen,
 	}
 }
 
 func GenSetType(sc *typedef.SchemaConfig, r *rand.Rand) *typedef.BagType {
-	return genBagType(typedef.TYPE_SET, sc, r)
+	return genBagType(typedef.TYPE_SET, sc, r, 0, r.Uint32()%2 == 0)
 }
 
 func GenListType(sc *typedef.SchemaConfig, r *rand.Rand) *typedef.BagType {
-	return genBagType(typedef.TYPE_LIST, sc, r)
+	return genBagType(typedef.TYPE_LIST, sc, r, 0, r.Uint32()%2 == 0)
 }
 
-func genBagType(kind string, sc *typedef.SchemaConfig, r *rand.Rand) *typedef.BagType {
-	var t typedef.SimpleType
-	for {
-		t = GenSimpleType(sc, r)
-		if t != typedef.TYPE_DURATION {
-			break
-		}
+func genBagType(kind string, sc *typedef.SchemaConfig, r *rand.Rand, depth int, frozen bool) *typedef.BagType {
+	t := genElementType(sc, r, depth)
+	for t == typedef.TYPE_DURATION {
+		t = genElementType(sc, r, depth)
 	}
 	return &typedef.BagType{
 		ComplexType: kind,
 		ValueType:   t,
-		Frozen:      r.Uint32()%2 == 0,
+		Frozen:      frozen,
 	}
 }
 
 func GenMapType(sc *typedef.SchemaConfig, r *rand.Rand) *typedef.MapType {
+	return genMapType(sc, r, 0, r.Uint32()%2 == 0)
+}
+
+func genMapType(sc *typedef.SchemaConfig, r *rand.Rand, depth int, frozen bool) *typedef.MapType {
 	t := GenSimpleType(sc, r)
 	for {
 		if _, ok := typedef.TypesMapKeyBlacklist[t]; !ok {
@@ -115,8 +156,18 @@ func GenMapType(sc *typedef.SchemaConfig, r *rand.Rand) *typedef.MapType {
 	return &typedef.MapType{
 		ComplexType: typedef.TYPE_MAP,
 		KeyType:     t,
-		ValueType:   GenSimpleType(sc, r),
-		Frozen:      r.Uint32()%2 == 0,
+		ValueType:   genElementType(sc, r, depth),
+		Frozen:      frozen,
+	}
+}
+
+// MaxVectorDimensions bounds the number of dimensions of generated vectors.
+const MaxVectorDimensions = 16
+
+func GenVectorType(_ *typedef.SchemaConfig, r *rand.Rand) *typedef.VectorType {
+	return &typedef.VectorType{
+		ComplexType: typedef.TYPE_VECTOR,
+		Dimensions:  utils.RandInt2(r, 1, MaxVectorDimensions+1),
 	}
 }
 
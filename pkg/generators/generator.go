@@ -15,6 +15,8 @@
 package generators
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"golang.org/x/exp/rand"
@@ -66,6 +68,10 @@ func (g *Generator) PartitionCount() uint64 {
 
 type Generators []*Generator
 
+// Factory creates and starts the generator of a table that is added to the
+// schema while the jobs run.
+type Factory func(table *typedef.Table) (*Generator, error)
+
 func (g Generators) StartAll(stopFlag *stop.Flag) {
 	for _, gen := range g {
 		gen.Start(stopFlag)
@@ -78,15 +84,20 @@ type Config struct {
 	PartitionsCount            uint64
 	Seed                       uint64
 	PkUsedBufferSize           uint64
+	HistorySize                uint64
 }
 
 func NewGenerator(table *typedef.Table, config *Config, logger *zap.Logger) *Generator {
 	wakeUpSignal := make(chan struct{})
+	// Partition keys are drawn uniformly, how they spread over the token ring
+	// is up to the distribution of the partitions.
+	partitionsConfig := config.PartitionsRangeConfig
+	partitionsConfig.ValueDistributions = nil
 	return &Generator{
-		partitions:        NewPartitions(int(config.PartitionsCount), int(config.PkUsedBufferSize), wakeUpSignal),
+		partitions:        NewPartitions(int(config.PartitionsCount), int(config.PkUsedBufferSize), int(config.HistorySize), wakeUpSignal),
 		partitionCount:    config.PartitionsCount,
 		table:             table,
-		partitionsConfig:  config.PartitionsRangeConfig,
+		partitionsConfig:  partitionsConfig,
 		idxFunc:           config.PartitionsDistributionFunc,
 		logger:            logger,
 		wakeUpSignal:      wakeUpSignal,
@@ -135,6 +146,23 @@ func (g *Generator) ReleaseToken(token uint64) {
 	g.GetPartitionForToken(TokenIndex(token)).releaseToken(token)
 }
 
+// RecordMutation adds the statement to the history of every partition key it
+// mutated. Nothing is recorded unless the generator keeps a history.
+func (g *Generator) RecordMutation(stmt *typedef.Stmt) {
+	entry := HistoryEntry{Time: time.Now(), Stmt: stmt}
+	for _, v := range stmt.ValuesWithToken {
+		g.GetPartitionForToken(TokenIndex(v.Token)).recordMutation(v.Token, entry)
+	}
+}
+
+// History returns the recorded mutations of the partition key with the
+// given token, oldest first, and whether older mutations were evicted.
+// The history is kept across validations of the token, only the histories
+// of the least recently mutated tokens of a partition are dropped.
+func (g *Generator) History(token uint64) ([]HistoryEntry, bool) {
+	return g.GetPartitionForToken(TokenIndex(token)).history(token)
+}
+
 func (g *Generator) Start(stopFlag *stop.Flag) {
 	go func() {
 		g.logger.Info("starting partition key generation loop")
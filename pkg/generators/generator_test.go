@@ -18,6 +18,7 @@ import (
 	"sync/atomic"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"go.uber.org/zap"
 
 	"github.com/scylladb/gemini/pkg/generators"
@@ -57,3 +58,56 @@ func TestGenerator(t *testing.T) {
 		}
 	}
 }
+
+func TestGeneratorHistory(t *testing.T) {
+	t.Parallel()
+	table := &typedef.Table{
+		Name:          "tbl",
+		PartitionKeys: generators.CreatePkColumns(1, "pk"),
+	}
+	cfg := &generators.Config{
+		PartitionsRangeConfig: typedef.PartitionRangeConfig{
+			MaxStringLength: 10,
+			MaxBlobLength:   10,
+		},
+		PkUsedBufferSize: 100,
+		PartitionsCount:  10,
+		PartitionsDistributionFunc: func() generators.TokenIndex {
+			return 0
+		},
+		HistorySize: 3,
+	}
+	generator := generators.NewGenerator(table, cfg, zap.NewNop())
+	generator.Start(stop.NewFlag("main_test"))
+	v := generator.Get()
+	stmts := make([]*typedef.Stmt, 5)
+	for i := range stmts {
+		stmts[i] = &typedef.Stmt{ValuesWithToken: []*typedef.ValueWithToken{v}}
+		generator.RecordMutation(stmts[i])
+	}
+
+	entries, evicted := generator.History(v.Token)
+	received := make([]*typedef.Stmt, 0, len(entries))
+	for _, entry := range entries {
+		received = append(received, entry.Stmt)
+	}
+	if diff := cmp.Diff(stmts[2:], received); diff != "" {
+		t.Error(diff)
+	}
+	if !evicted {
+		t.Error("expected the oldest mutations to be reported as evicted")
+	}
+
+	generator.ReleaseToken(v.Token)
+	if entries, _ = generator.History(v.Token); len(entries) != len(received) {
+		t.Errorf("expected the history to be kept after the token is released, got %d entries", len(entries))
+	}
+
+	// Mutating twice PkUsedBufferSize other tokens drops the least recently mutated history.
+	for i := 0; i < 2*int(cfg.PkUsedBufferSize); i++ {
+		generator.RecordMutation(&typedef.Stmt{ValuesWithToken: []*typedef.ValueWithToken{generator.Get()}})
+	}
+	if entries, _ = generator.History(v.Token); len(entries) != 0 {
+		t.Errorf("expected the least recently mutated history to be dropped, got %d entries", len(entries))
+	}
+}
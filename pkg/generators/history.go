@@ -0,0 +1,55 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generators
+
+import (
+	"time"
+
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+// HistoryEntry is a mutation that was applied to a partition key.
+type HistoryEntry struct {
+	Time time.Time
+	Stmt *typedef.Stmt
+}
+
+// history is a ring buffer of the most recent mutations of a partition key.
+type history struct {
+	entries []HistoryEntry
+	next    int
+	evicted bool
+}
+
+func newHistory(size int) *history {
+	return &history{entries: make([]HistoryEntry, 0, size)}
+}
+
+func (h *history) add(entry HistoryEntry) {
+	if len(h.entries) < cap(h.entries) {
+		h.entries = append(h.entries, entry)
+		return
+	}
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	h.evicted = true
+}
+
+// list returns the entries from the oldest to the newest one.
+func (h *history) list() []HistoryEntry {
+	out := make([]HistoryEntry, 0, len(h.entries))
+	out = append(out, h.entries[h.next:]...)
+	return append(out, h.entries[:h.next]...)
+}
@@ -15,6 +15,7 @@
 package generators
 
 import (
+	"container/list"
 	"sync"
 
 	"github.com/scylladb/gemini/pkg/inflight"
@@ -26,8 +27,13 @@ type Partition struct {
 	oldValues    chan *typedef.ValueWithToken
 	inFlight     inflight.InFlight
 	wakeUpSignal chan<- struct{} // wakes up generator
+	histories    map[uint64]*list.Element
+	historyOrder *list.List
+	historySize  int
+	maxHistories int
 	closed       bool
 	lock         sync.RWMutex
+	historyLock  sync.Mutex
 	isStale      bool
 }
 
@@ -82,6 +88,48 @@ func (s *Partition) releaseToken(token uint64) {
 	s.inFlight.Delete(token)
 }
 
+// tokenHistory is the history of a single token in the history order.
+type tokenHistory struct {
+	*history
+	token uint64
+}
+
+// recordMutation adds the statement to the history of the token. The
+// histories outlive the validations of their tokens, only the history of
+// the least recently mutated token is dropped once maxHistories are kept.
+func (s *Partition) recordMutation(token uint64, entry HistoryEntry) {
+	if s.historySize <= 0 {
+		return
+	}
+	s.historyLock.Lock()
+	defer s.historyLock.Unlock()
+	e, ok := s.histories[token]
+	if ok {
+		s.historyOrder.MoveToBack(e)
+	} else {
+		e = s.historyOrder.PushBack(tokenHistory{history: newHistory(s.historySize), token: token})
+		s.histories[token] = e
+		if s.historyOrder.Len() > s.maxHistories {
+			oldest := s.historyOrder.Remove(s.historyOrder.Front()).(tokenHistory)
+			delete(s.histories, oldest.token)
+		}
+	}
+	e.Value.(tokenHistory).add(entry)
+}
+
+// history returns the recorded mutations of the token, oldest first, and
+// whether older mutations were evicted from the history.
+func (s *Partition) history(token uint64) ([]HistoryEntry, bool) {
+	s.historyLock.Lock()
+	defer s.historyLock.Unlock()
+	e, ok := s.histories[token]
+	if !ok {
+		return nil, false
+	}
+	h := e.Value.(tokenHistory)
+	return h.list(), h.evicted
+}
+
 func (s *Partition) wakeUp() {
 	select {
 	case s.wakeUpSignal <- struct{}{}:
@@ -138,7 +186,10 @@ func (p Partitions) CloseAll() {
 	}
 }
 
-func NewPartitions(count, pkBufferSize int, wakeUpSignal chan struct{}) Partitions {
+// NewPartitions creates the partitions of a generator. A partition keeps the
+// history of at most twice pkBufferSize tokens, enough to cover the values
+// waiting in its old values buffer and the ones in-flight.
+func NewPartitions(count, pkBufferSize, historySize int, wakeUpSignal chan struct{}) Partitions {
 	partitions := make(Partitions, count)
 	for i := 0; i < len(partitions); i++ {
 		partitions[i] = &Partition{
@@ -146,6 +197,10 @@ func NewPartitions(count, pkBufferSize int, wakeUpSignal chan struct{}) Partitio
 			oldValues:    make(chan *typedef.ValueWithToken, pkBufferSize),
 			inFlight:     inflight.New(),
 			wakeUpSignal: wakeUpSignal,
+			histories:    make(map[uint64]*list.Element),
+			historyOrder: list.New(),
+			historySize:  historySize,
+			maxHistories: 2 * pkBufferSize,
 		}
 	}
 	return partitions
@@ -16,7 +16,6 @@ package generators
 
 import (
 	"fmt"
-	"strings"
 
 	"golang.org/x/exp/rand"
 
@@ -37,13 +36,15 @@ func GenSchema(sc typedef.SchemaConfig, seed uint64) *typedef.Schema {
 	builder.Keyspace(keyspace)
 	numTables := utils.RandInt2(r, 1, sc.GetMaxTables())
 	for i := 0; i < numTables; i++ {
-		table := genTable(sc, fmt.Sprintf("table%d", i+1), r)
+		table := GenTable(sc, fmt.Sprintf("table%d", i+1), r)
 		builder.Table(table)
 	}
 	return builder.Build()
 }
 
-func genTable(sc typedef.SchemaConfig, tableName string, r *rand.Rand) *typedef.Table {
+// GenTable generates a table with random keys, columns, indexes and views.
+// The table still has to be initialized with the schema it is added to.
+func GenTable(sc typedef.SchemaConfig, tableName string, r *rand.Rand) *typedef.Table {
 	partitionKeys := make(typedef.Columns, utils.RandInt2(r, sc.GetMinPartitionKeys(), sc.GetMaxPartitionKeys()))
 	for i := 0; i < len(partitionKeys); i++ {
 		partitionKeys[i] = &typedef.ColumnDef{Name: GenColumnName("pk", i), Type: GenPartitionKeyColumnType(r)}
@@ -72,6 +73,15 @@ func genTable(sc typedef.SchemaConfig, tableName string, r *rand.Rand) *typedef.
 				},
 			},
 		}
+		// Only the counter tables with clustering keys can have static
+		// counters, which are counters of the partition.
+		if len(clusteringKeys) > 0 && r.Intn(2) == 0 {
+			table.Columns = append(table.Columns, &typedef.ColumnDef{
+				Name:   GenColumnName("col", 1),
+				Type:   &typedef.CounterType{},
+				Static: true,
+			})
+		}
 		return &table
 	}
 	columns := make(typedef.Columns, utils.RandInt2(r, sc.GetMinColumns(), sc.GetMaxColumns()))
@@ -82,7 +92,7 @@ func genTable(sc typedef.SchemaConfig, tableName string, r *rand.Rand) *typedef.
 
 	var indexes []typedef.IndexDef
 	if sc.CQLFeature > typedef.CQL_FEATURE_BASIC && len(columns) > 0 {
-		indexes = CreateIndexesForColumn(&table, utils.RandInt2(r, 1, len(columns)))
+		indexes = CreateIndexesForColumn(&table, utils.RandInt2(r, 1, len(columns)), r)
 	}
 	table.Indexes = indexes
 
@@ -104,37 +114,16 @@ func GetCreateKeyspaces(s *typedef.Schema) (string, string) {
 func GetCreateSchema(s *typedef.Schema) []string {
 	var stmts []string
 
-	for _, t := range s.Tables {
+	for _, t := range s.GetTables() {
 		createTypes := GetCreateTypes(t, s.Keyspace)
 		stmts = append(stmts, createTypes...)
 		createTable := GetCreateTable(t, s.Keyspace)
 		stmts = append(stmts, createTable)
 		for _, idef := range t.Indexes {
-			stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s.%s (%s)", idef.IndexName, s.Keyspace.Name, t.Name, idef.ColumnName))
+			stmts = append(stmts, GetCreateIndex(t, s.Keyspace, idef))
 		}
 		for _, mv := range t.MaterializedViews {
-			var (
-				mvPartitionKeys      []string
-				mvPrimaryKeysNotNull []string
-			)
-			for _, pk := range mv.PartitionKeys {
-				mvPartitionKeys = append(mvPartitionKeys, pk.Name)
-				mvPrimaryKeysNotNull = append(mvPrimaryKeysNotNull, fmt.Sprintf("%s IS NOT NULL", pk.Name))
-			}
-			for _, ck := range mv.ClusteringKeys {
-				mvPrimaryKeysNotNull = append(mvPrimaryKeysNotNull, fmt.Sprintf("%s IS NOT NULL", ck.Name))
-			}
-			var createMaterializedView string
-			if len(mv.PartitionKeys) == 1 {
-				createMaterializedView = "CREATE MATERIALIZED VIEW IF NOT EXISTS %s.%s AS SELECT * FROM %s.%s WHERE %s PRIMARY KEY (%s"
-			} else {
-				createMaterializedView = "CREATE MATERIALIZED VIEW IF NOT EXISTS %s.%s AS SELECT * FROM %s.%s WHERE %s PRIMARY KEY ((%s)"
-			}
-			createMaterializedView += ",%s)"
-			stmts = append(stmts, fmt.Sprintf(createMaterializedView,
-				s.Keyspace.Name, mv.Name, s.Keyspace.Name, t.Name,
-				strings.Join(mvPrimaryKeysNotNull, " AND "),
-				strings.Join(mvPartitionKeys, ","), strings.Join(t.ClusteringKeys.Names(), ",")))
+			stmts = append(stmts, GetCreateMaterializedView(t, s.Keyspace, mv))
 		}
 	}
 	return stmts
@@ -146,27 +135,111 @@ func GetDropSchema(s *typedef.Schema) []string {
 	}
 }
 
+// MaxMaterializedViews bounds the number of views created on a table.
+const MaxMaterializedViews = 3
+
+// CreateMaterializedViews creates up to MaxMaterializedViews views on the
+// table, see CreateMaterializedView.
 func CreateMaterializedViews(c typedef.Columns, tableName string, partitionKeys, clusteringKeys typedef.Columns, r *rand.Rand) []typedef.MaterializedView {
-	validColumns := c.ValidColumnsForPrimaryKey()
+	if len(c.ValidColumnsForPrimaryKey()) == 0 {
+		return nil
+	}
 	var mvs []typedef.MaterializedView
-	numMvs := 1
+	numMvs := utils.RandInt2(r, 1, MaxMaterializedViews+1)
 	for i := 0; i < numMvs; i++ {
+		mvs = append(mvs, CreateMaterializedView(c, fmt.Sprintf("%s_mv_%d", tableName, i), partitionKeys, clusteringKeys, r))
+	}
+	return mvs
+}
+
+// CreateMaterializedView creates a view of the given name on the table. A
+// view is keyed by a regular column followed by the base partition keys, by
+// a regular column clustered by the reordered base primary key or by the
+// reordered base primary key alone. Views may select a subset of the regular
+// columns and filter the base rows on one of them. The table must have a
+// column that is valid in a primary key.
+func CreateMaterializedView(c typedef.Columns, name string, partitionKeys, clusteringKeys typedef.Columns, r *rand.Rand) typedef.MaterializedView {
+	validColumns := c.ValidColumnsForPrimaryKey()
+	mv := typedef.MaterializedView{
+		Name: name,
+	}
+	switch r.Intn(3) {
+	case 0:
 		col := validColumns.Random(r)
-		if col == nil {
-			fmt.Printf("unable to generate valid columns for materialized view")
-			continue
+		mv.PartitionKeys = append(typedef.Columns{col}, partitionKeys...)
+		mv.ClusteringKeys = clusteringKeys
+		mv.NonPrimaryKey = col
+	case 1:
+		col := validColumns.Random(r)
+		mv.PartitionKeys = typedef.Columns{col}
+		mv.ClusteringKeys = shuffledPrimaryKey(partitionKeys, clusteringKeys, r)
+		mv.NonPrimaryKey = col
+	default:
+		keys := shuffledPrimaryKey(partitionKeys, clusteringKeys, r)
+		numPartitionKeys := utils.RandInt2(r, 1, len(partitionKeys)+1)
+		mv.PartitionKeys, mv.ClusteringKeys = keys[:numPartitionKeys], keys[numPartitionKeys:]
+	}
+	if r.Intn(2) == 0 {
+		mv.Filters = createViewFilters(c, r)
+	}
+	if r.Intn(2) == 0 {
+		mv.Columns = selectViewColumns(c, &mv, r)
+	}
+	return mv
+}
+
+func shuffledPrimaryKey(partitionKeys, clusteringKeys typedef.Columns, r *rand.Rand) typedef.Columns {
+	keys := make(typedef.Columns, 0, len(partitionKeys)+len(clusteringKeys))
+	keys = append(append(keys, partitionKeys...), clusteringKeys...)
+	r.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	return keys
+}
+
+// createViewFilters restricts a regular integer column to a random range,
+// it returns no filter if the table has no such column.
+func createViewFilters(c typedef.Columns, r *rand.Rand) []typedef.ViewFilter {
+	var candidates typedef.Columns
+	for _, col := range c {
+		switch col.Type {
+		case typedef.TYPE_TINYINT, typedef.TYPE_SMALLINT, typedef.TYPE_INT, typedef.TYPE_BIGINT:
+			candidates = append(candidates, col)
 		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	col := candidates.Random(r)
+	operators := []string{"<", "<=", ">", ">="}
+	return []typedef.ViewFilter{{
+		Column: col,
+		Op:     operators[r.Intn(len(operators))],
+		Value:  fmt.Sprint(col.Type.GenValue(r, &typedef.PartitionRangeConfig{})[0]),
+	}}
+}
 
-		cols := typedef.Columns{
-			col,
+// selectViewColumns picks the regular columns the view selects, the columns
+// the view is filtered on are always selected. It returns nil if every
+// column is selected.
+func selectViewColumns(c typedef.Columns, mv *typedef.MaterializedView, r *rand.Rand) typedef.Columns {
+	var selected typedef.Columns
+	regular := len(c)
+	for _, col := range c {
+		if mv.HaveNonPrimaryKey() && mv.NonPrimaryKey.Name == col.Name {
+			regular--
+			continue
 		}
-		mv := typedef.MaterializedView{
-			Name:           fmt.Sprintf("%s_mv_%d", tableName, i),
-			PartitionKeys:  append(cols, partitionKeys...),
-			ClusteringKeys: clusteringKeys,
-			NonPrimaryKey:  col,
+		filtered := false
+		for _, f := range mv.Filters {
+			filtered = filtered || f.Column.Name == col.Name
+		}
+		if filtered || r.Intn(2) == 0 {
+			selected = append(selected, col)
 		}
-		mvs = append(mvs, mv)
 	}
-	return mvs
+	if len(selected) == regular {
+		return nil
+	}
+	return selected
 }
@@ -101,6 +101,16 @@ func TestGetCreateSchema(t *testing.T) {
 			want: "CREATE TABLE IF NOT EXISTS ks1.tbl0 (pk0 text,ck0 text, PRIMARY KEY ((pk0), ck0)) WITH compaction = " +
 				"{'class':'LeveledCompactionStrategy','enabled':true,'sstable_size_in_mb':160,'tombstone_compaction_interval':86400,'tombstone_threshold':0.2};",
 		},
+		"single_partition_key_descending_clustering_key_compact": {
+			table: &typedef.Table{
+				Name:           "tbl0",
+				PartitionKeys:  createColumns(1, "pk"),
+				ClusteringKeys: typedef.Columns{{Name: "ck0", Type: typedef.TYPE_TEXT}, {Name: "ck1", Type: typedef.TYPE_TEXT, Descending: true}},
+				TableOptions:   options("compaction = {'class':'LeveledCompactionStrategy'}"),
+			},
+			want: "CREATE TABLE IF NOT EXISTS ks1.tbl0 (pk0 text,ck0 text,ck1 text, PRIMARY KEY ((pk0), ck0,ck1)) WITH CLUSTERING ORDER BY (ck0 ASC,ck1 DESC) AND " +
+				"compaction = {'class':'LeveledCompactionStrategy'};",
+		},
 		"single_partition_key_single_clustering_key_single_column": {
 			table: &typedef.Table{
 				Name:           "tbl0",
@@ -171,6 +181,18 @@ func TestGetCreateSchema(t *testing.T) {
 			},
 			want: "CREATE TABLE IF NOT EXISTS ks1.tbl0 (pk0 text,pk1 text,ck0 text,ck1 text,col0 text,col1 text, PRIMARY KEY ((pk0,pk1), ck0,ck1))",
 		},
+		"static_counter_and_vector": {
+			table: &typedef.Table{
+				Name:           "tbl0",
+				PartitionKeys:  createColumns(1, "pk"),
+				ClusteringKeys: createColumns(1, "ck"),
+				Columns: typedef.Columns{
+					{Name: "col0", Type: &typedef.CounterType{}, Static: true},
+					{Name: "col1", Type: &typedef.VectorType{ComplexType: typedef.TYPE_VECTOR, Dimensions: 3}},
+				},
+			},
+			want: "CREATE TABLE IF NOT EXISTS ks1.tbl0 (pk0 text,ck0 text,col0 counter static,col1 vector<float, 3>, PRIMARY KEY ((pk0), ck0))",
+		},
 	}
 
 	for name := range tests {
@@ -185,6 +207,166 @@ func TestGetCreateSchema(t *testing.T) {
 	}
 }
 
+func TestGetCreateIndex(t *testing.T) {
+	t.Parallel()
+	ks := typedef.Keyspace{Name: "ks1"}
+	table := &typedef.Table{
+		Name:           "tbl0",
+		PartitionKeys:  createColumns(2, "pk"),
+		ClusteringKeys: createColumns(1, "ck"),
+		Columns:        createColumns(2, "col"),
+	}
+	tests := map[string]struct {
+		index typedef.IndexDef
+		want  string
+	}{
+		"global": {
+			index: typedef.IndexDef{IndexName: "idx0", ColumnName: "col0"},
+			want:  "CREATE INDEX IF NOT EXISTS idx0 ON ks1.tbl0 (col0)",
+		},
+		"clustering_key": {
+			index: typedef.IndexDef{IndexName: "idx0", ColumnName: "ck0"},
+			want:  "CREATE INDEX IF NOT EXISTS idx0 ON ks1.tbl0 (ck0)",
+		},
+		"local": {
+			index: typedef.IndexDef{IndexName: "idx0", ColumnName: "col0", Local: true},
+			want:  "CREATE INDEX IF NOT EXISTS idx0 ON ks1.tbl0 ((pk0,pk1),col0)",
+		},
+		"keys": {
+			index: typedef.IndexDef{IndexName: "idx0", ColumnName: "col1", Target: typedef.IndexTargetKeys},
+			want:  "CREATE INDEX IF NOT EXISTS idx0 ON ks1.tbl0 (keys(col1))",
+		},
+		"entries": {
+			index: typedef.IndexDef{IndexName: "idx0", ColumnName: "col1", Target: typedef.IndexTargetEntries},
+			want:  "CREATE INDEX IF NOT EXISTS idx0 ON ks1.tbl0 (entries(col1))",
+		},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(test.want, generators.GetCreateIndex(table, ks, test.index)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGetCreateMaterializedView(t *testing.T) {
+	t.Parallel()
+	ks := typedef.Keyspace{Name: "ks1"}
+	table := &typedef.Table{
+		Name:           "tbl0",
+		PartitionKeys:  createColumns(2, "pk"),
+		ClusteringKeys: createColumns(1, "ck"),
+		Columns:        createColumns(3, "col"),
+	}
+	tests := map[string]struct {
+		mv   typedef.MaterializedView
+		want string
+	}{
+		"regular_column_key": {
+			mv: typedef.MaterializedView{
+				Name:           "mv0",
+				PartitionKeys:  typedef.Columns{table.Columns[0], table.PartitionKeys[0], table.PartitionKeys[1]},
+				ClusteringKeys: table.ClusteringKeys,
+				NonPrimaryKey:  table.Columns[0],
+			},
+			want: "CREATE MATERIALIZED VIEW IF NOT EXISTS ks1.mv0 AS SELECT * FROM ks1.tbl0 " +
+				"WHERE col0 IS NOT NULL AND pk0 IS NOT NULL AND pk1 IS NOT NULL AND ck0 IS NOT NULL PRIMARY KEY ((col0,pk0,pk1),ck0)",
+		},
+		"reordered_key": {
+			mv: typedef.MaterializedView{
+				Name:           "mv0",
+				PartitionKeys:  typedef.Columns{table.ClusteringKeys[0]},
+				ClusteringKeys: typedef.Columns{table.PartitionKeys[1], table.PartitionKeys[0]},
+			},
+			want: "CREATE MATERIALIZED VIEW IF NOT EXISTS ks1.mv0 AS SELECT * FROM ks1.tbl0 " +
+				"WHERE ck0 IS NOT NULL AND pk1 IS NOT NULL AND pk0 IS NOT NULL PRIMARY KEY (ck0,pk1,pk0)",
+		},
+		"filtered_subset": {
+			mv: typedef.MaterializedView{
+				Name:           "mv0",
+				PartitionKeys:  typedef.Columns{table.Columns[0]},
+				ClusteringKeys: typedef.Columns{table.PartitionKeys[0], table.PartitionKeys[1], table.ClusteringKeys[0]},
+				NonPrimaryKey:  table.Columns[0],
+				Columns:        typedef.Columns{table.Columns[2]},
+				Filters:        []typedef.ViewFilter{{Column: table.Columns[2], Op: ">", Value: "'a'"}},
+			},
+			want: "CREATE MATERIALIZED VIEW IF NOT EXISTS ks1.mv0 AS SELECT pk0,pk1,ck0,col0,col2 FROM ks1.tbl0 " +
+				"WHERE col0 IS NOT NULL AND pk0 IS NOT NULL AND pk1 IS NOT NULL AND ck0 IS NOT NULL AND col2 > 'a' PRIMARY KEY (col0,pk0,pk1,ck0)",
+		},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(test.want, generators.GetCreateMaterializedView(table, ks, test.mv)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestCreateMaterializedViews(t *testing.T) {
+	t.Parallel()
+	partitionKeys := createColumns(2, "pk")
+	clusteringKeys := createColumns(2, "ck")
+	columns := typedef.Columns{
+		{Name: "col0", Type: typedef.TYPE_INT},
+		{Name: "col1", Type: typedef.TYPE_TEXT},
+		{Name: "col2", Type: typedef.TYPE_BIGINT},
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		for _, mv := range generators.CreateMaterializedViews(columns, "tbl0", partitionKeys, clusteringKeys, r) {
+			keys := make(map[string]bool)
+			for _, col := range append(append(typedef.Columns{}, mv.PartitionKeys...), mv.ClusteringKeys...) {
+				keys[col.Name] = true
+			}
+			for _, col := range append(append(typedef.Columns{}, partitionKeys...), clusteringKeys...) {
+				if !keys[col.Name] {
+					t.Fatalf("view %s misses the base key column %s", mv.Name, col.Name)
+				}
+			}
+			extra := len(keys) - len(partitionKeys) - len(clusteringKeys)
+			if extra > 1 || (extra == 1) != mv.HaveNonPrimaryKey() {
+				t.Fatalf("view %s has %d regular key columns", mv.Name, extra)
+			}
+			for _, f := range mv.Filters {
+				if !mv.SelectsColumn(f.Column.Name) {
+					t.Fatalf("view %s does not select the filtered column %s", mv.Name, f.Column.Name)
+				}
+			}
+		}
+	}
+}
+
+func TestGetCreateTypesForType(t *testing.T) {
+	t.Parallel()
+	inner := &typedef.UDTType{
+		ComplexType: typedef.TYPE_UDT,
+		TypeName:    "udt_1",
+		ValueTypes:  map[string]typedef.Type{"a": typedef.TYPE_INT},
+		Frozen:      true,
+	}
+	outer := &typedef.UDTType{
+		ComplexType: typedef.TYPE_UDT,
+		TypeName:    "udt_0",
+		ValueTypes: map[string]typedef.Type{
+			"b": &typedef.BagType{ComplexType: typedef.TYPE_LIST, ValueType: inner, Frozen: true},
+			"a": inner,
+		},
+	}
+	expected := []string{
+		"CREATE TYPE IF NOT EXISTS ks1.udt_1 (a int)",
+		"CREATE TYPE IF NOT EXISTS ks1.udt_0 (a frozen<udt_1>,b frozen<list<frozen<udt_1>>>)",
+	}
+	if diff := cmp.Diff(expected, generators.GetCreateTypesForType(outer, "ks1")); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestGenSchema(t *testing.T) {
 	seeds := [10]uint64{
 		uint64(10 + rand.Intn(10)),
@@ -210,7 +392,7 @@ func transformAndDiff(t *testing.T, testSchema *typedef.Schema) {
 	t.Helper()
 	opts := cmp.Options{
 		cmp.AllowUnexported(typedef.Table{}, typedef.MaterializedView{}),
-		cmpopts.IgnoreUnexported(typedef.Table{}, typedef.MaterializedView{}),
+		cmpopts.IgnoreUnexported(typedef.Schema{}, typedef.Table{}, typedef.MaterializedView{}),
 		cmpopts.EquateEmpty(),
 	}
 
@@ -223,7 +405,7 @@ func transformAndDiff(t *testing.T, testSchema *typedef.Schema) {
 		t.Fatalf("unable to unmarshal json, error=%s\n", err)
 	}
 
-	if diff := cmp.Diff(*testSchema, testSchemaTransformed, opts); diff != "" {
+	if diff := cmp.Diff(testSchema, &testSchemaTransformed, opts); diff != "" {
 		t.Fatalf("schema not the same after marshal/unmarshal, diff=%s", diff)
 	}
 }
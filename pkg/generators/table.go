@@ -16,6 +16,7 @@ package generators
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/scylladb/gemini/pkg/typedef"
@@ -39,6 +40,10 @@ func GetCreateTable(t *typedef.Table, ks typedef.Keyspace) string {
 		columns = append(columns, fmt.Sprintf("%s %s", ck.Name, ck.Type.CQLDef()))
 	}
 	for _, cdef := range t.Columns {
+		if cdef.Static {
+			columns = append(columns, fmt.Sprintf("%s %s static", cdef.Name, cdef.Type.CQLDef()))
+			continue
+		}
 		columns = append(columns, fmt.Sprintf("%s %s", cdef.Name, cdef.Type.CQLDef()))
 	}
 
@@ -50,28 +55,132 @@ func GetCreateTable(t *typedef.Table, ks typedef.Keyspace) string {
 			strings.Join(partitionKeys, ","), strings.Join(clusteringKeys, ","))
 	}
 
-	if len(t.TableOptions) > 0 {
-		stmt = stmt + " WITH " + strings.Join(t.TableOptions, " AND ") + ";"
+	options := t.TableOptions
+	if order := clusteringOrder(t.ClusteringKeys); order != "" {
+		options = append([]string{order}, options...)
+	}
+	if len(options) > 0 {
+		stmt = stmt + " WITH " + strings.Join(options, " AND ") + ";"
+	}
+	return stmt
+}
+
+// GetCreateIndex returns the statement creating the secondary index, local
+// indexes name the partition key before the indexed column.
+func GetCreateIndex(t *typedef.Table, ks typedef.Keyspace, idx typedef.IndexDef) string {
+	target := idx.ColumnName
+	switch {
+	case idx.Target != "":
+		target = fmt.Sprintf("%s(%s)", idx.Target, idx.ColumnName)
+	case idx.Local:
+		target = fmt.Sprintf("(%s),%s", strings.Join(t.PartitionKeys.Names(), ","), idx.ColumnName)
+	}
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s.%s (%s)", idx.IndexName, ks.Name, t.Name, target)
+}
+
+// GetCreateMaterializedView returns the statement creating the view, every
+// column of its primary key must be set in the base rows it holds.
+func GetCreateMaterializedView(t *typedef.Table, ks typedef.Keyspace, mv typedef.MaterializedView) string {
+	selected := "*"
+	if len(mv.Columns) > 0 {
+		names := append(t.PartitionKeys.Names(), t.ClusteringKeys.Names()...)
+		if mv.HaveNonPrimaryKey() {
+			names = append(names, mv.NonPrimaryKey.Name)
+		}
+		selected = strings.Join(append(names, mv.Columns.Names()...), ",")
+	}
+	var where []string
+	for _, cols := range []typedef.Columns{mv.PartitionKeys, mv.ClusteringKeys} {
+		for _, col := range cols {
+			where = append(where, col.Name+" IS NOT NULL")
+		}
+	}
+	for _, f := range mv.Filters {
+		where = append(where, fmt.Sprintf("%s %s %s", f.Column.Name, f.Op, f.Value))
+	}
+	key := strings.Join(mv.PartitionKeys.Names(), ",")
+	if len(mv.PartitionKeys) > 1 {
+		key = "(" + key + ")"
+	}
+	if len(mv.ClusteringKeys) > 0 {
+		key += "," + strings.Join(mv.ClusteringKeys.Names(), ",")
+	}
+	stmt := fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s.%s AS SELECT %s FROM %s.%s WHERE %s PRIMARY KEY (%s)",
+		ks.Name, mv.Name, selected, ks.Name, t.Name, strings.Join(where, " AND "), key)
+	if order := clusteringOrder(mv.ClusteringKeys); order != "" {
+		stmt += " WITH " + order
 	}
 	return stmt
 }
 
+// clusteringOrder returns the CLUSTERING ORDER BY clause of the clustering
+// keys, or "" if they are all in ascending order.
+func clusteringOrder(clusteringKeys typedef.Columns) string {
+	var descending bool
+	order := make([]string, 0, len(clusteringKeys))
+	for _, ck := range clusteringKeys {
+		if ck.Descending {
+			descending = true
+			order = append(order, ck.Name+" DESC")
+		} else {
+			order = append(order, ck.Name+" ASC")
+		}
+	}
+	if !descending {
+		return ""
+	}
+	return "CLUSTERING ORDER BY (" + strings.Join(order, ",") + ")"
+}
+
 func GetCreateTypes(t *typedef.Table, keyspace typedef.Keyspace) []string {
 	t.RLock()
 	defer t.RUnlock()
 
 	var stmts []string
+	created := make(map[string]bool)
 	for _, column := range t.Columns {
-		c, ok := column.Type.(*typedef.UDTType)
-		if !ok {
-			continue
+		stmts = appendCreateTypes(stmts, column.Type, keyspace.Name, created)
+	}
+	return stmts
+}
+
+// GetCreateTypesForType returns the statements creating the user defined
+// types the type is made of, the nested types come before the types they
+// are nested in.
+func GetCreateTypesForType(typ typedef.Type, keyspace string) []string {
+	return appendCreateTypes(nil, typ, keyspace, make(map[string]bool))
+}
+
+func appendCreateTypes(stmts []string, typ typedef.Type, keyspace string, created map[string]bool) []string {
+	switch c := typ.(type) {
+	case *typedef.BagType:
+		return appendCreateTypes(stmts, c.ValueType, keyspace, created)
+	case *typedef.MapType:
+		return appendCreateTypes(stmts, c.ValueType, keyspace, created)
+	case *typedef.TupleType:
+		for _, vt := range c.ValueTypes {
+			stmts = appendCreateTypes(stmts, vt, keyspace, created)
+		}
+		return stmts
+	case *typedef.UDTType:
+		if created[c.TypeName] {
+			return stmts
 		}
-		createType := "CREATE TYPE IF NOT EXISTS %s.%s (%s)"
-		var typs []string
-		for name, typ := range c.ValueTypes {
-			typs = append(typs, name+" "+typ.CQLDef())
+		created[c.TypeName] = true
+		// The fields are declared in the order of their names, the order
+		// the values of the type are serialized in.
+		names := make([]string, 0, len(c.ValueTypes))
+		for name := range c.ValueTypes {
+			names = append(names, name)
 		}
-		stmts = append(stmts, fmt.Sprintf(createType, keyspace.Name, c.TypeName, strings.Join(typs, ",")))
+		sort.Strings(names)
+		typs := make([]string, 0, len(names))
+		for _, name := range names {
+			stmts = appendCreateTypes(stmts, c.ValueTypes[name], keyspace, created)
+			typs = append(typs, name+" "+c.ValueTypes[name].CQLDef())
+		}
+		return append(stmts, fmt.Sprintf("CREATE TYPE IF NOT EXISTS %s.%s (%s)", keyspace, c.TypeName, strings.Join(typs, ",")))
+	default:
+		return stmts
 	}
-	return stmts
 }
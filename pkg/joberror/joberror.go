@@ -16,16 +16,32 @@ package joberror
 
 import (
 	"encoding/json"
+	"fmt"
 	"sync/atomic"
 	"time"
 	"unsafe"
 )
 
 type JobError struct {
-	Timestamp time.Time `json:"timestamp"`
-	Message   string    `json:"message"`
-	Query     string    `json:"query"`
-	StmtType  string    `json:"stmt-type"`
+	Timestamp  time.Time `json:"timestamp"`
+	Message    string    `json:"message"`
+	Query      string    `json:"query"`
+	StmtType   string    `json:"stmt-type"`
+	Reproducer string    `json:"reproducer,omitempty"`
+	// RowDiff is set when the failure was a mismatch of the returned rows.
+	RowDiff *RowDiff `json:"row-diff,omitempty"`
+	// PartitionHistory holds the writes applied to the partitions of the
+	// failed query while they were in-flight, oldest first.
+	PartitionHistory []string `json:"partition-history,omitempty"`
+	// PartitionHistoryTruncated is set when older writes were evicted from
+	// the bounded history.
+	PartitionHistoryTruncated bool `json:"partition-history-truncated,omitempty"`
+}
+
+// String formats the error for the text report, the partition history and
+// the reproducer are only part of the JSON one.
+func (e *JobError) String() string {
+	return fmt.Sprintf("%s %s: %s, query: %s", e.Timestamp.Format(time.RFC3339), e.StmtType, e.Message, e.Query)
 }
 
 type ErrorList struct {
@@ -87,6 +87,51 @@ func TestErrorSerialization(t *testing.T) {
 	}
 }
 
+func TestErrorSerializationWithHistory(t *testing.T) {
+	t.Parallel()
+	//nolint:lll
+	expected := []byte(`{"timestamp":"2020-02-01T00:00:00Z","message":"Some Message","query":"Some Query","stmt-type":"Some Type","partition-history":["INSERT 1","DELETE 1"],"partition-history-truncated":true}`)
+	result, err := json.Marshal(joberror.JobError{
+		Timestamp:                 time.Date(2020, 02, 01, 0, 0, 0, 0, time.UTC),
+		Message:                   "Some Message",
+		Query:                     "Some Query",
+		StmtType:                  "Some Type",
+		PartitionHistory:          []string{"INSERT 1", "DELETE 1"},
+		PartitionHistoryTruncated: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(expected, result); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestErrorSerializationWithRowDiff(t *testing.T) {
+	t.Parallel()
+	//nolint:lll
+	expected := []byte(`{"timestamp":"2020-02-01T00:00:00Z","message":"Some Message","query":"Some Query","stmt-type":"Some Type","row-diff":{"primary-key":"pk0=1","missing-in-oracle":["pk0=2"],"columns":[{"name":"col0","oracle":"a","test":"b"}]}}`)
+	result, err := json.Marshal(joberror.JobError{
+		Timestamp: time.Date(2020, 02, 01, 0, 0, 0, 0, time.UTC),
+		Message:   "Some Message",
+		Query:     "Some Query",
+		StmtType:  "Some Type",
+		RowDiff: &joberror.RowDiff{
+			PrimaryKey:      "pk0=1",
+			MissingInOracle: []string{"pk0=2"},
+			Columns:         []joberror.ColumnDiff{{Name: "col0", Oracle: "a", Test: "b"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(expected, result); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestErrorListSerialization(t *testing.T) {
 	t.Parallel()
 	//nolint:lll
@@ -0,0 +1,44 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package joberror
+
+// RowDiff describes how the rows the test and the oracle store returned for
+// the same query differ. Keys are formatted as "col=value" pairs of the
+// partition and clustering key columns.
+type RowDiff struct {
+	// PrimaryKey is the key of the first row whose columns differ.
+	PrimaryKey      string       `json:"primary-key,omitempty"`
+	MissingInTest   []string     `json:"missing-in-test,omitempty"`
+	MissingInOracle []string     `json:"missing-in-oracle,omitempty"`
+	Columns         []ColumnDiff `json:"columns,omitempty"`
+}
+
+// ColumnDiff holds the differing values of a single column.
+type ColumnDiff struct {
+	Name   string `json:"name"`
+	Oracle string `json:"oracle"`
+	Test   string `json:"test"`
+}
+
+// RowDiffError is returned when the test and the oracle store returned
+// different rows, it carries the structured diff along with the message.
+type RowDiffError struct {
+	Diff    *RowDiff
+	Message string
+}
+
+func (e *RowDiffError) Error() string {
+	return e.Message
+}
@@ -16,6 +16,7 @@ package jobs
 
 import (
 	"math"
+	"strings"
 
 	"github.com/scylladb/gocqlx/v2/qb"
 	"golang.org/x/exp/rand"
@@ -25,6 +26,9 @@ import (
 	"github.com/scylladb/gemini/pkg/utils"
 )
 
+// GenCheckStmt generates a check of the table or of one of its views. The
+// caller holds the read lock of the table until the check ran, so that the
+// columns, indexes and views it reads are not changed by DDL in between.
 func GenCheckStmt(
 	s *typedef.Schema,
 	table *typedef.Table,
@@ -42,24 +46,34 @@ func GenCheckStmt(
 
 	switch mvNum {
 	case -1:
+		if (p.MaxTTL > 0 || p.UseWriteTimestamps) && rnd.Intn(4) == 0 {
+			if stmt := genWriteTimeQuery(s, table, g, p); stmt != nil {
+				return stmt
+			}
+		}
+		if rnd.Intn(8) == 0 {
+			if stmt := genFunctionQuery(s, table, g, rnd); stmt != nil {
+				return stmt
+			}
+		}
 		if len(table.Indexes) > 0 {
-			n = rnd.Intn(5)
+			n = rnd.Intn(6)
 		} else {
-			n = rnd.Intn(4)
+			n = rnd.Intn(5)
 		}
 		switch n {
 		case 0:
-			return genSinglePartitionQuery(s, table, g)
+			return shapeRead(genSinglePartitionQuery(s, table, g), rnd, table.ClusteringKeys)
 		case 1:
 			numQueryPKs = utils.RandInt2(rnd, 1, table.PartitionKeys.Len())
 			multiplier := int(math.Pow(float64(numQueryPKs), float64(table.PartitionKeys.Len())))
 			if multiplier > 100 {
 				numQueryPKs = 1
 			}
-			return genMultiplePartitionQuery(s, table, g, numQueryPKs)
+			return shapeRead(genMultiplePartitionQuery(s, table, g, numQueryPKs), rnd, table.ClusteringKeys)
 		case 2:
 			maxClusteringRels = utils.RandInt2(rnd, 0, table.ClusteringKeys.Len())
-			return genClusteringRangeQuery(s, table, g, rnd, p, maxClusteringRels)
+			return shapeRead(genClusteringRangeQuery(s, table, g, rnd, p, maxClusteringRels), rnd, table.ClusteringKeys)
 		case 3:
 			numQueryPKs = utils.RandInt2(rnd, 1, table.PartitionKeys.Len())
 			multiplier := int(math.Pow(float64(numQueryPKs), float64(table.PartitionKeys.Len())))
@@ -67,22 +81,27 @@ func GenCheckStmt(
 				numQueryPKs = 1
 			}
 			maxClusteringRels = utils.RandInt2(rnd, 0, table.ClusteringKeys.Len())
-			return genMultiplePartitionClusteringRangeQuery(s, table, g, rnd, p, numQueryPKs, maxClusteringRels)
+			stmt := genMultiplePartitionClusteringRangeQuery(s, table, g, rnd, p, numQueryPKs, maxClusteringRels)
+			return shapeRead(stmt, rnd, table.ClusteringKeys)
 		case 4:
+			return shapeRead(genFilteringQuery(s, table, g, rnd, p), rnd, table.ClusteringKeys)
+		case 5:
 			// Reducing the probability to hit these since they often take a long time to run
 			switch rnd.Intn(5) {
 			case 0:
 				idxCount := utils.RandInt2(rnd, 1, len(table.Indexes))
-				return genSingleIndexQuery(s, table, g, rnd, p, idxCount)
+				// Only the paging of index reads is varied.
+				return shapeRead(genSingleIndexQuery(s, table, g, rnd, p, idxCount), rnd, nil)
 			default:
-				return genSinglePartitionQuery(s, table, g)
+				return shapeRead(genSinglePartitionQuery(s, table, g), rnd, table.ClusteringKeys)
 			}
 		}
 	default:
+		mvClusteringKeys := table.MaterializedViews[mvNum].ClusteringKeys
 		n = rnd.Intn(4)
 		switch n {
 		case 0:
-			return genSinglePartitionQueryMv(s, table, g, rnd, p, mvNum)
+			return shapeRead(genSinglePartitionQueryMv(s, table, g, rnd, p, mvNum), rnd, mvClusteringKeys)
 		case 1:
 			lenPartitionKeys := table.MaterializedViews[mvNum].PartitionKeys.Len()
 			numQueryPKs = utils.RandInt2(rnd, 1, lenPartitionKeys)
@@ -90,11 +109,11 @@ func GenCheckStmt(
 			if multiplier > 100 {
 				numQueryPKs = 1
 			}
-			return genMultiplePartitionQueryMv(s, table, g, rnd, p, mvNum, numQueryPKs)
+			return shapeRead(genMultiplePartitionQueryMv(s, table, g, rnd, p, mvNum, numQueryPKs), rnd, mvClusteringKeys)
 		case 2:
 			lenClusteringKeys := table.MaterializedViews[mvNum].ClusteringKeys.Len()
 			maxClusteringRels = utils.RandInt2(rnd, 0, lenClusteringKeys)
-			return genClusteringRangeQueryMv(s, table, g, rnd, p, mvNum, maxClusteringRels)
+			return shapeRead(genClusteringRangeQueryMv(s, table, g, rnd, p, mvNum, maxClusteringRels), rnd, mvClusteringKeys)
 		case 3:
 			lenPartitionKeys := table.MaterializedViews[mvNum].PartitionKeys.Len()
 			numQueryPKs = utils.RandInt2(rnd, 1, lenPartitionKeys)
@@ -104,20 +123,62 @@ func GenCheckStmt(
 			}
 			lenClusteringKeys := table.MaterializedViews[mvNum].ClusteringKeys.Len()
 			maxClusteringRels = utils.RandInt2(rnd, 0, lenClusteringKeys)
-			return genMultiplePartitionClusteringRangeQueryMv(s, table, g, rnd, p, mvNum, numQueryPKs, maxClusteringRels)
+			stmt := genMultiplePartitionClusteringRangeQueryMv(s, table, g, rnd, p, mvNum, numQueryPKs, maxClusteringRels)
+			return shapeRead(stmt, rnd, mvClusteringKeys)
 		}
 	}
 
 	return nil
 }
 
+const (
+	// maxReadLimit bounds the LIMIT and PER PARTITION LIMIT of checks.
+	maxReadLimit = 5
+	// maxReadPageSize bounds the page size of checks that are read in
+	// small pages, so that most of them span several pages.
+	maxReadPageSize = 3
+)
+
+// shapeRead randomly turns a check into one of its variants. Reads of a
+// single partition, whose order CQL guarantees, are reversed with ORDER BY
+// and limited with LIMIT. Reads of a table or view with the clustering keys
+// are limited with PER PARTITION LIMIT. Any read is fetched in small pages,
+// possibly every page with a new query resumed from the paging state.
+func shapeRead(stmt *typedef.Stmt, r *rand.Rand, clusteringKeys typedef.Columns) *typedef.Stmt {
+	if stmt == nil {
+		return nil
+	}
+	builder, ok := stmt.Query.(*qb.SelectBuilder)
+	if !ok {
+		return stmt
+	}
+	if stmt.Read.Ordered && len(clusteringKeys) > 0 && r.Intn(4) == 0 {
+		for _, ck := range clusteringKeys {
+			order := qb.DESC
+			if ck.Descending {
+				order = qb.ASC
+			}
+			builder.OrderBy(ck.Name, order)
+		}
+	}
+	if len(clusteringKeys) > 0 && r.Intn(4) == 0 {
+		builder.LimitPerPartition(uint(utils.RandInt2(r, 1, maxReadLimit+1)))
+	}
+	if stmt.Read.Ordered && r.Intn(4) == 0 {
+		builder.Limit(uint(utils.RandInt2(r, 1, maxReadLimit+1)))
+	}
+	if r.Intn(4) == 0 {
+		stmt.Read.PageSize = utils.RandInt2(r, 1, maxReadPageSize+1)
+		stmt.Read.ResumePaging = r.Intn(2) == 0
+	}
+	return stmt
+}
+
 func genSinglePartitionQuery(
 	s *typedef.Schema,
 	t *typedef.Table,
 	g generators.GeneratorInterface,
 ) *typedef.Stmt {
-	t.RLock()
-	defer t.RUnlock()
 	valuesWithToken := g.GetOld()
 	if valuesWithToken == nil {
 		return nil
@@ -138,6 +199,56 @@ func genSinglePartitionQuery(
 		},
 		Values:          values,
 		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Read:            typedef.ReadOptions{Ordered: true},
+	}
+}
+
+// genWriteTimeQuery reads the TTL and, if they are deterministic, the write
+// times of the regular columns of a partition. The TTLs count down on each
+// cluster, they are compared within a second. It returns nil if the table
+// has no column they can be read of.
+func genWriteTimeQuery(
+	s *typedef.Schema,
+	t *typedef.Table,
+	g generators.GeneratorInterface,
+	p *typedef.PartitionRangeConfig,
+) *typedef.Stmt {
+	var columns, ttls []string
+	for _, col := range t.Columns {
+		// Complex columns have a TTL and write time per element or field,
+		// counters have neither.
+		if _, ok := col.Type.(typedef.SimpleType); !ok {
+			continue
+		}
+		columns = append(columns, qb.As("TTL("+col.Name+")", "ttl_"+col.Name))
+		ttls = append(ttls, "ttl_"+col.Name)
+		if p.CompareWriteTimes {
+			columns = append(columns, qb.As("WRITETIME("+col.Name+")", "writetime_"+col.Name))
+		}
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+	valuesWithToken := g.GetOld()
+	if valuesWithToken == nil {
+		return nil
+	}
+	builder := qb.Select(s.Keyspace.Name + "." + t.Name)
+	builder = builder.Columns(append(append(t.PartitionKeys.Names(), t.ClusteringKeys.Names()...), columns...)...)
+	typs := make([]typedef.Type, 0, len(t.PartitionKeys))
+	for _, pk := range t.PartitionKeys {
+		builder = builder.Where(qb.Eq(pk.Name))
+		typs = append(typs, pk.Type)
+	}
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:     builder,
+			Types:     typs,
+			QueryType: typedef.SelectWriteTimeStatementType,
+		},
+		Values:          valuesWithToken.Value.Copy(),
+		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Read:            typedef.ReadOptions{Ordered: true, TTLColumns: ttls},
 	}
 }
 
@@ -149,35 +260,74 @@ func genSinglePartitionQueryMv(
 	p *typedef.PartitionRangeConfig,
 	mvNum int,
 ) *typedef.Stmt {
-	t.RLock()
-	defer t.RUnlock()
 	valuesWithToken := g.GetOld()
 	if valuesWithToken == nil {
 		return nil
 	}
-	mv := t.MaterializedViews[mvNum]
+	mv := &t.MaterializedViews[mvNum]
 	builder := qb.Select(s.Keyspace.Name + "." + mv.Name)
-	typs := make([]typedef.Type, 0, 10)
 	for _, pk := range mv.PartitionKeys {
 		builder = builder.Where(qb.Eq(pk.Name))
-		typs = append(typs, pk.Type)
-	}
-
-	values := valuesWithToken.Value.Copy()
-	if mv.HaveNonPrimaryKey() {
-		var mvValues []interface{}
-		mvValues = append(mvValues, mv.NonPrimaryKey.Type.GenValue(r, p)...)
-		values = append(mvValues, values...)
 	}
+	values, typs := mvPartitionValues(t, mv, valuesWithToken, r, p)
 	return &typedef.Stmt{
 		StmtCache: &typedef.StmtCache{
 			Query:     builder,
 			Types:     typs,
-			QueryType: typedef.SelectStatementType,
+			QueryType: typedef.SelectFromMaterializedViewStatementType,
 		},
 		Values:          values,
 		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Read:            typedef.ReadOptions{Ordered: true},
+	}
+}
+
+// mvPartitionValues returns the values of the view partition key for the
+// base partition. The view partition key columns that are not part of the
+// base partition key take the values of a known row of the partition, or a
+// value known to be written to the regular column, so that the read hits a
+// view partition the base partition has rows in. Only when none is known
+// they get random values.
+func mvPartitionValues(
+	t *typedef.Table,
+	mv *typedef.MaterializedView,
+	vs *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+) (typedef.Values, typedef.Types) {
+	values := make(typedef.Values, 0, len(mv.PartitionKeys))
+	typs := make(typedef.Types, 0, len(mv.PartitionKeys))
+	var row typedef.Values
+	if len(vs.Rows) > 0 {
+		row = vs.Rows[r.Intn(len(vs.Rows))]
+	}
+	for _, pk := range mv.PartitionKeys {
+		typs = append(typs, pk.Type)
+		if base := columnIndex(t.PartitionKeys, pk.Name); base >= 0 {
+			values = append(values, vs.Value[base])
+			continue
+		}
+		if base := columnIndex(t.ClusteringKeys, pk.Name); base >= 0 && row != nil {
+			offset := t.ClusteringKeys[:base].LenValues()
+			values = append(values, row[offset:offset+pk.Type.LenValue()]...)
+			continue
+		}
+		if known := vs.ColumnValues[pk.Name]; len(known) > 0 {
+			values = append(values, known[r.Intn(len(known))])
+			continue
+		}
+		values = append(values, pk.Type.GenValue(r, p)...)
+	}
+	return values, typs
+}
+
+func columnIndex(columns typedef.Columns, name string) int {
+	for i, col := range columns {
+		if col.Name == name {
+			return i
+		}
 	}
+	return -1
 }
 
 func genMultiplePartitionQuery(
@@ -186,8 +336,6 @@ func genMultiplePartitionQuery(
 	g generators.GeneratorInterface,
 	numQueryPKs int,
 ) *typedef.Stmt {
-	t.RLock()
-	defer t.RUnlock()
 	typs := make([]typedef.Type, numQueryPKs*t.PartitionKeys.Len())
 	values := make([]interface{}, numQueryPKs*t.PartitionKeys.Len())
 
@@ -228,14 +376,11 @@ func genMultiplePartitionQueryMv(
 	p *typedef.PartitionRangeConfig,
 	mvNum, numQueryPKs int,
 ) *typedef.Stmt {
-	t.RLock()
-	defer t.RUnlock()
-
-	mv := t.MaterializedViews[mvNum]
+	mv := &t.MaterializedViews[mvNum]
 	typs := make([]typedef.Type, numQueryPKs*mv.PartitionKeys.Len())
 	values := make([]interface{}, numQueryPKs*mv.PartitionKeys.Len())
 
-	builder := qb.Select(s.Keyspace.Name + "." + t.Name)
+	builder := qb.Select(s.Keyspace.Name + "." + mv.Name)
 	tokens := make([]*typedef.ValueWithToken, 0, numQueryPKs)
 
 	for j := 0; j < numQueryPKs; j++ {
@@ -245,32 +390,25 @@ func genMultiplePartitionQueryMv(
 			return nil
 		}
 		tokens = append(tokens, vs)
-		vals := make([]interface{}, mv.PartitionKeys.Len())
-		if mv.HaveNonPrimaryKey() {
-			vals[0] = mv.NonPrimaryKey.Type.GenValue(r, p)
-			copy(vals[1:], vs.Value.Copy())
-		} else {
-			vals = vs.Value.Copy()
-		}
+		vals, valTypes := mvPartitionValues(t, mv, vs, r, p)
 		for i := range vals {
 			values[j+i*numQueryPKs] = vals[i]
-			typs[j+i*numQueryPKs] = mv.PartitionKeys[i].Type
+			typs[j+i*numQueryPKs] = valTypes[i]
 		}
 	}
-	for _, pk := range t.PartitionKeys {
+	for _, pk := range mv.PartitionKeys {
 		builder = builder.Where(qb.InTuple(pk.Name, numQueryPKs))
 	}
 	return &typedef.Stmt{
 		StmtCache: &typedef.StmtCache{
 			Query:     builder,
 			Types:     typs,
-			QueryType: typedef.SelectStatementType,
+			QueryType: typedef.SelectFromMaterializedViewStatementType,
 		},
 		Values:          values,
 		ValuesWithToken: tokens,
 	}
 }
-
 func genClusteringRangeQuery(
 	s *typedef.Schema,
 	t *typedef.Table,
@@ -279,8 +417,6 @@ func genClusteringRangeQuery(
 	p *typedef.PartitionRangeConfig,
 	maxClusteringRels int,
 ) *typedef.Stmt {
-	t.RLock()
-	defer t.RUnlock()
 	vs := g.GetOld()
 	if vs == nil {
 		return nil
@@ -293,19 +429,12 @@ func genClusteringRangeQuery(
 		builder = builder.Where(qb.Eq(pk.Name))
 		allTypes = append(allTypes, pk.Type)
 	}
-	clusteringKeys := t.ClusteringKeys
-	if len(clusteringKeys) > 0 {
-		for i := 0; i < maxClusteringRels; i++ {
-			ck := clusteringKeys[i]
-			builder = builder.Where(qb.Eq(ck.Name))
-			values = append(values, ck.Type.GenValue(r, p)...)
-			allTypes = append(allTypes, ck.Type)
-		}
-		ck := clusteringKeys[maxClusteringRels]
-		builder = builder.Where(qb.Gt(ck.Name)).Where(qb.Lt(ck.Name))
-		values = append(values, ck.Type.GenValue(r, p)...)
-		values = append(values, ck.Type.GenValue(r, p)...)
-		allTypes = append(allTypes, ck.Type, ck.Type)
+	if len(t.ClusteringKeys) > 0 {
+		var typs typedef.Types
+		var vals typedef.Values
+		builder, typs, vals = genClusteringRange(t, builder, vs.Rows, r, p, maxClusteringRels)
+		allTypes = append(allTypes, typs...)
+		values = append(values, vals...)
 	}
 	return &typedef.Stmt{
 		StmtCache: &typedef.StmtCache{
@@ -315,9 +444,45 @@ func genClusteringRangeQuery(
 		},
 		Values:          values,
 		ValuesWithToken: []*typedef.ValueWithToken{vs},
+		Read:            typedef.ReadOptions{Ordered: true},
 	}
 }
 
+// genClusteringRange restricts the first maxClusteringRels clustering keys
+// to equal values and the next one to a slice. If rows of the partitions are
+// known, the values are taken from them and the slice brackets real rows.
+func genClusteringRange(
+	t *typedef.Table,
+	builder *qb.SelectBuilder,
+	rows []typedef.Values,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+	maxClusteringRels int,
+) (*qb.SelectBuilder, typedef.Types, typedef.Values) {
+	prefix, lower, upper, known := knownBounds(t, rows, maxClusteringRels, 1, r)
+	p = p.ForKeys()
+	var types typedef.Types
+	var values typedef.Values
+	for _, ck := range t.ClusteringKeys[:maxClusteringRels] {
+		builder = builder.Where(qb.Eq(ck.Name))
+		types = append(types, ck.Type)
+		if !known {
+			values = append(values, ck.Type.GenValue(r, p)...)
+		}
+	}
+	values = append(values, prefix...)
+	ck := t.ClusteringKeys[maxClusteringRels]
+	types = append(types, ck.Type, ck.Type)
+	if known {
+		builder = builder.Where(qb.GtOrEq(ck.Name)).Where(qb.LtOrEq(ck.Name))
+		return builder, types, append(append(values, lower...), upper...)
+	}
+	builder = builder.Where(qb.Gt(ck.Name)).Where(qb.Lt(ck.Name))
+	values = append(values, ck.Type.GenValue(r, p)...)
+	values = append(values, ck.Type.GenValue(r, p)...)
+	return builder, types, values
+}
+
 func genClusteringRangeQueryMv(
 	s *typedef.Schema,
 	t *typedef.Table,
@@ -326,51 +491,43 @@ func genClusteringRangeQueryMv(
 	p *typedef.PartitionRangeConfig,
 	mvNum, maxClusteringRels int,
 ) *typedef.Stmt {
-	t.RLock()
-	defer t.RUnlock()
 	vs := g.GetOld()
 	if vs == nil {
 		return nil
 	}
-	values := vs.Value.Copy()
-	mv := t.MaterializedViews[mvNum]
-	if mv.HaveNonPrimaryKey() {
-		mvValues := append([]interface{}{}, mv.NonPrimaryKey.Type.GenValue(r, p)...)
-		values = append(mvValues, values...)
-	}
+	mv := &t.MaterializedViews[mvNum]
+	values, allTypes := mvPartitionValues(t, mv, vs, r, p)
 	builder := qb.Select(s.Keyspace.Name + "." + mv.Name)
-
-	var allTypes []typedef.Type
 	for _, pk := range mv.PartitionKeys {
 		builder = builder.Where(qb.Eq(pk.Name))
-		allTypes = append(allTypes, pk.Type)
 	}
 
 	clusteringKeys := mv.ClusteringKeys
 	if len(clusteringKeys) > 0 {
+		kp := p.ForKeys()
 		for i := 0; i < maxClusteringRels; i++ {
 			ck := clusteringKeys[i]
 			builder = builder.Where(qb.Eq(ck.Name))
-			values = append(values, ck.Type.GenValue(r, p)...)
+			values = append(values, ck.Type.GenValue(r, kp)...)
 			allTypes = append(allTypes, ck.Type)
 		}
 		ck := clusteringKeys[maxClusteringRels]
 		builder = builder.Where(qb.Gt(ck.Name)).Where(qb.Lt(ck.Name))
-		values = append(values, t.ClusteringKeys[maxClusteringRels].Type.GenValue(r, p)...)
-		values = append(values, t.ClusteringKeys[maxClusteringRels].Type.GenValue(r, p)...)
+		values = append(values, ck.Type.GenValue(r, kp)...)
+		values = append(values, ck.Type.GenValue(r, kp)...)
 		allTypes = append(allTypes, ck.Type, ck.Type)
 	}
 	return &typedef.Stmt{
 		StmtCache: &typedef.StmtCache{
 			Query:     builder,
-			QueryType: typedef.SelectRangeStatementType,
+			QueryType: typedef.SelectFromMaterializedViewStatementType,
 			Types:     allTypes,
 		},
 		Values:          values,
 		ValuesWithToken: []*typedef.ValueWithToken{vs},
+		Read:            typedef.ReadOptions{Ordered: true},
 	}
 }
-
 func genMultiplePartitionClusteringRangeQuery(
 	s *typedef.Schema,
 	t *typedef.Table,
@@ -379,9 +536,6 @@ func genMultiplePartitionClusteringRangeQuery(
 	p *typedef.PartitionRangeConfig,
 	numQueryPKs, maxClusteringRels int,
 ) *typedef.Stmt {
-	t.RLock()
-	defer t.RUnlock()
-
 	clusteringKeys := t.ClusteringKeys
 	pkValues := t.PartitionKeysLenValues()
 	valuesCount := pkValues*numQueryPKs + clusteringKeys[:maxClusteringRels].LenValues() + clusteringKeys[maxClusteringRels].Type.LenValue()*2
@@ -409,17 +563,11 @@ func genMultiplePartitionClusteringRangeQuery(
 	}
 
 	if len(clusteringKeys) > 0 {
-		for i := 0; i < maxClusteringRels; i++ {
-			ck := clusteringKeys[i]
-			builder = builder.Where(qb.Eq(ck.Name))
-			values = append(values, ck.Type.GenValue(r, p)...)
-			typs = append(typs, ck.Type)
-		}
-		ck := clusteringKeys[maxClusteringRels]
-		builder = builder.Where(qb.Gt(ck.Name)).Where(qb.Lt(ck.Name))
-		values = append(values, ck.Type.GenValue(r, p)...)
-		values = append(values, ck.Type.GenValue(r, p)...)
-		typs = append(typs, ck.Type, ck.Type)
+		var ckTypes typedef.Types
+		var ckValues typedef.Values
+		builder, ckTypes, ckValues = genClusteringRange(t, builder, partitionRows(tokens), r, p, maxClusteringRels)
+		typs = append(typs, ckTypes...)
+		values = append(values, ckValues...)
 	}
 	return &typedef.Stmt{
 		StmtCache: &typedef.StmtCache{
@@ -440,26 +588,11 @@ func genMultiplePartitionClusteringRangeQueryMv(
 	p *typedef.PartitionRangeConfig,
 	mvNum, numQueryPKs, maxClusteringRels int,
 ) *typedef.Stmt {
-	t.RLock()
-	defer t.RUnlock()
-
-	mv := t.MaterializedViews[mvNum]
+	mv := &t.MaterializedViews[mvNum]
 	clusteringKeys := mv.ClusteringKeys
 	pkValues := mv.PartitionKeysLenValues()
-	valuesCount := pkValues*numQueryPKs + clusteringKeys[:maxClusteringRels].LenValues() + clusteringKeys[maxClusteringRels].Type.LenValue()*2
-	mvKey := mv.NonPrimaryKey
-
-	var (
-		mvKeyLen int
-		baseID   int
-	)
-	if mvKey != nil {
-		mvKeyLen = mvKey.Type.LenValue()
-		baseID = 1
-		valuesCount += mv.PartitionKeys.LenValues() * numQueryPKs
-	}
-	values := make(typedef.Values, pkValues*numQueryPKs, valuesCount)
-	typs := make(typedef.Types, pkValues*numQueryPKs, valuesCount)
+	values := make(typedef.Values, pkValues*numQueryPKs)
+	typs := make(typedef.Types, pkValues*numQueryPKs)
 	builder := qb.Select(s.Keyspace.Name + "." + mv.Name)
 	tokens := make([]*typedef.ValueWithToken, 0, numQueryPKs)
 
@@ -467,14 +600,6 @@ func genMultiplePartitionClusteringRangeQueryMv(
 		builder = builder.Where(qb.InTuple(pk.Name, numQueryPKs))
 	}
 
-	if mvKey != nil {
-		// Fill values for Materialized view primary key
-		for j := 0; j < numQueryPKs; j++ {
-			typs[j] = mvKey.Type
-			copy(values[j*mvKeyLen:], mvKey.Type.GenValue(r, p))
-		}
-	}
-
 	for j := 0; j < numQueryPKs; j++ {
 		vs := g.GetOld()
 		if vs == nil {
@@ -482,24 +607,26 @@ func genMultiplePartitionClusteringRangeQueryMv(
 			return nil
 		}
 		tokens = append(tokens, vs)
-		for id := range vs.Value {
-			idx := (baseID+id)*numQueryPKs + j
-			typs[idx] = mv.PartitionKeys[baseID+id].Type
-			values[idx] = vs.Value[id]
+		vals, valTypes := mvPartitionValues(t, mv, vs, r, p)
+		for id := range vals {
+			idx := id*numQueryPKs + j
+			typs[idx] = valTypes[id]
+			values[idx] = vals[id]
 		}
 	}
 
 	if len(clusteringKeys) > 0 {
+		kp := p.ForKeys()
 		for i := 0; i < maxClusteringRels; i++ {
 			ck := clusteringKeys[i]
 			builder = builder.Where(qb.Eq(ck.Name))
-			values = append(values, ck.Type.GenValue(r, p)...)
+			values = append(values, ck.Type.GenValue(r, kp)...)
 			typs = append(typs, ck.Type)
 		}
 		ck := clusteringKeys[maxClusteringRels]
 		builder = builder.Where(qb.Gt(ck.Name)).Where(qb.Lt(ck.Name))
-		values = append(values, ck.Type.GenValue(r, p)...)
-		values = append(values, ck.Type.GenValue(r, p)...)
+		values = append(values, ck.Type.GenValue(r, kp)...)
+		values = append(values, ck.Type.GenValue(r, kp)...)
 		typs = append(typs, ck.Type, ck.Type)
 	}
 	return &typedef.Stmt{
@@ -513,28 +640,89 @@ func genMultiplePartitionClusteringRangeQueryMv(
 	}
 }
 
+// genSingleIndexQuery restricts the first idxCount indexed columns with the
+// relation their index serves. Local indexes need the partition key to be
+// restricted too, and a range on another column may be filtered on the rows
+// the indexes return.
 func genSingleIndexQuery(
 	s *typedef.Schema,
 	t *typedef.Table,
-	_ generators.GeneratorInterface,
+	g generators.GeneratorInterface,
 	r *rand.Rand,
 	p *typedef.PartitionRangeConfig,
 	idxCount int,
 ) *typedef.Stmt {
-	t.RLock()
-	defer t.RUnlock()
-
 	var (
-		values []interface{}
-		typs   []typedef.Type
+		values          []interface{}
+		typs            []typedef.Type
+		valuesWithToken []*typedef.ValueWithToken
 	)
 
 	builder := qb.Select(s.Keyspace.Name + "." + t.Name)
 	builder.AllowFiltering()
-	for i := 0; i < idxCount; i++ {
-		builder = builder.Where(qb.Eq(t.Indexes[i].ColumnName))
-		values = append(values, t.Indexes[i].Column.Type.GenValue(r, p)...)
-		typs = append(typs, t.Indexes[i].Column.Type)
+	indexes := t.Indexes[:idxCount]
+	for _, idx := range indexes {
+		if !idx.Local {
+			continue
+		}
+		vt := g.GetOld()
+		if vt == nil {
+			return nil
+		}
+		for _, pk := range t.PartitionKeys {
+			builder = builder.Where(qb.Eq(pk.Name))
+			typs = append(typs, pk.Type)
+		}
+		values = append(values, vt.Value.Copy()...)
+		valuesWithToken = append(valuesWithToken, vt)
+		break
+	}
+	indexed := make(map[string]struct{}, len(indexes))
+	for _, idx := range indexes {
+		indexed[idx.ColumnName] = struct{}{}
+		var idxTypes typedef.Types
+		switch idx.Target {
+		case typedef.IndexTargetKeys:
+			builder = builder.Where(qb.ContainsKey(idx.ColumnName))
+			idxTypes = typedef.Types{idx.Column.Type.(*typedef.MapType).KeyType}
+		case typedef.IndexTargetValues:
+			builder = builder.Where(qb.Contains(idx.ColumnName))
+			if bt, ok := idx.Column.Type.(*typedef.BagType); ok {
+				idxTypes = typedef.Types{bt.ValueType}
+			} else {
+				idxTypes = typedef.Types{idx.Column.Type.(*typedef.MapType).ValueType}
+			}
+		case typedef.IndexTargetEntries:
+			mt := idx.Column.Type.(*typedef.MapType)
+			builder = builder.Where(qb.EqLit(idx.ColumnName+"[?]", "?"))
+			idxTypes = typedef.Types{mt.KeyType, mt.ValueType}
+		default:
+			builder = builder.Where(qb.Eq(idx.ColumnName))
+			idxTypes = typedef.Types{idx.Column.Type}
+		}
+		for _, typ := range idxTypes {
+			values = append(values, typ.GenValue(r, p)...)
+			typs = append(typs, typ)
+		}
+	}
+
+	// Range relations are not served by the indexes, the rows the indexes
+	// return are filtered on them.
+	var candidates typedef.Columns
+	for _, col := range append(append(typedef.Columns{}, t.ClusteringKeys...), t.Columns...) {
+		if _, ok := indexed[col.Name]; ok {
+			continue
+		}
+		if st, ok := col.Type.(typedef.SimpleType); ok && st != typedef.TYPE_DURATION {
+			candidates = append(candidates, col)
+		}
+	}
+	if len(candidates) > 0 && r.Intn(2) == 0 {
+		col := candidates[r.Intn(len(candidates))]
+		operators := []func(string) qb.Cmp{qb.Lt, qb.LtOrEq, qb.Gt, qb.GtOrEq}
+		builder = builder.Where(operators[r.Intn(len(operators))](col.Name))
+		values = append(values, col.Type.GenValue(r, p)...)
+		typs = append(typs, col.Type)
 	}
 
 	return &typedef.Stmt{
@@ -543,6 +731,258 @@ func genSingleIndexQuery(
 			Types:     typs,
 			QueryType: typedef.SelectByIndexStatementType,
 		},
-		Values: values,
+		Values:          values,
+		ValuesWithToken: valuesWithToken,
+	}
+}
+
+// genFunctionQuery reads a partition through aggregates, functions or as
+// JSON. It returns nil if the table has no column they apply to.
+func genFunctionQuery(
+	s *typedef.Schema,
+	t *typedef.Table,
+	g generators.GeneratorInterface,
+	r *rand.Rand,
+) *typedef.Stmt {
+	switch r.Intn(3) {
+	case 0:
+		return genAggregateQuery(s, t, g, r)
+	case 1:
+		return genScalarFunctionQuery(s, t, g, r)
+	default:
+		return genSelectJSONQuery(s, t, g)
+	}
+}
+
+// genAggregateQuery counts the rows of a partition and aggregates a random
+// subset of its regular columns of the types the aggregates are exact for.
+func genAggregateQuery(
+	s *typedef.Schema,
+	t *typedef.Table,
+	g generators.GeneratorInterface,
+	r *rand.Rand,
+) *typedef.Stmt {
+	columns := []string{qb.As("count(*)", "row_count")}
+	aggregates := []struct {
+		name  string
+		types typedef.SimpleTypes
+	}{
+		{name: "min", types: typedef.TypesForMinMax},
+		{name: "max", types: typedef.TypesForMinMax},
+		{name: "sum", types: typedef.TypesForSum},
+		{name: "avg", types: typedef.TypesForAvg},
+	}
+	for _, col := range t.Columns {
+		st, ok := col.Type.(typedef.SimpleType)
+		if !ok {
+			continue
+		}
+		for _, aggregate := range aggregates {
+			if aggregate.types.Contains(st) && r.Intn(2) == 0 {
+				columns = append(columns, qb.As(aggregate.name+"("+col.Name+")", aggregate.name+"_"+col.Name))
+			}
+		}
+	}
+	builder := qb.Select(s.Keyspace.Name + "." + t.Name).Columns(columns...)
+	return genPartitionFunctionQuery(t, g, builder, typedef.SelectAggregateStatementType)
+}
+
+// genScalarFunctionQuery reads the rows of a partition with their token and
+// a random subset of the conversions of their regular columns.
+func genScalarFunctionQuery(
+	s *typedef.Schema,
+	t *typedef.Table,
+	g generators.GeneratorInterface,
+	r *rand.Rand,
+) *typedef.Stmt {
+	columns := append(t.PartitionKeys.Names(), t.ClusteringKeys.Names()...)
+	columns = append(columns, qb.As("token("+strings.Join(t.PartitionKeys.Names(), ", ")+")", "row_token"))
+	for _, col := range t.Columns {
+		st, ok := col.Type.(typedef.SimpleType)
+		if !ok {
+			continue
+		}
+		if typedef.TypesForJSON.Contains(st) && r.Intn(2) == 0 {
+			columns = append(columns, qb.As("tojson("+col.Name+")", "json_"+col.Name))
+		}
+		if targets := typedef.TypesForCast[st]; len(targets) > 0 && r.Intn(2) == 0 {
+			target := targets[r.Intn(len(targets))]
+			columns = append(columns, qb.As("cast("+col.Name+" as "+string(target)+")", "cast_"+col.Name))
+		}
+		for _, fn := range typedef.TimeFunctions[st] {
+			if r.Intn(2) == 0 {
+				columns = append(columns, qb.As(fn+"("+col.Name+")", fn+"_"+col.Name))
+			}
+		}
+	}
+	builder := qb.Select(s.Keyspace.Name + "." + t.Name).Columns(columns...)
+	return genPartitionFunctionQuery(t, g, builder, typedef.SelectFunctionStatementType)
+}
+
+// genSelectJSONQuery reads the rows of a partition as JSON. Only the columns
+// of types that are formatted the same way by every cluster are selected.
+func genSelectJSONQuery(
+	s *typedef.Schema,
+	t *typedef.Table,
+	g generators.GeneratorInterface,
+) *typedef.Stmt {
+	var columns []string
+	for _, cols := range []typedef.Columns{t.PartitionKeys, t.ClusteringKeys, t.Columns} {
+		for _, col := range cols {
+			if st, ok := col.Type.(typedef.SimpleType); ok && typedef.TypesForJSON.Contains(st) {
+				columns = append(columns, col.Name)
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+	builder := qb.Select(s.Keyspace.Name + "." + t.Name).Json().Columns(columns...)
+	return genPartitionFunctionQuery(t, g, builder, typedef.SelectJSONStatementType)
+}
+
+// genPartitionFunctionQuery restricts the selection to a single partition,
+// the caller holds the read lock of the table.
+func genPartitionFunctionQuery(
+	t *typedef.Table,
+	g generators.GeneratorInterface,
+	builder *qb.SelectBuilder,
+	queryType typedef.StatementType,
+) *typedef.Stmt {
+	valuesWithToken := g.GetOld()
+	if valuesWithToken == nil {
+		return nil
+	}
+	typs := make([]typedef.Type, 0, len(t.PartitionKeys))
+	for _, pk := range t.PartitionKeys {
+		builder = builder.Where(qb.Eq(pk.Name))
+		typs = append(typs, pk.Type)
+	}
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:     builder,
+			Types:     typs,
+			QueryType: queryType,
+		},
+		Values:          valuesWithToken.Value.Copy(),
+		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Read:            typedef.ReadOptions{Ordered: true},
+	}
+}
+
+const (
+	// maxFilteringPartitions bounds the number of partitions a filtering
+	// query reads.
+	maxFilteringPartitions = 3
+	// maxFilteringRestrictions bounds the number of filtered columns.
+	maxFilteringRestrictions = 3
+)
+
+// genFilteringQuery reads the rows of a partition, or of a few partitions,
+// that match restrictions which require ALLOW FILTERING: on the regular
+// columns of simple types, on clustering keys without the keys before them,
+// and on the elements of collections of simple types. Only partitions the
+// validation holds are read, any other one could be mutated while the query
+// runs. It returns nil if the table has no column that can be filtered on.
+func genFilteringQuery(
+	s *typedef.Schema,
+	t *typedef.Table,
+	g generators.GeneratorInterface,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+) *typedef.Stmt {
+	var candidates typedef.Columns
+	if len(t.ClusteringKeys) > 1 {
+		candidates = append(candidates, t.ClusteringKeys[1:]...)
+	}
+	for _, col := range t.Columns {
+		switch tt := col.Type.(type) {
+		case typedef.SimpleType:
+			if tt != typedef.TYPE_DURATION {
+				candidates = append(candidates, col)
+			}
+		case *typedef.BagType:
+			if _, ok := tt.ValueType.(typedef.SimpleType); ok {
+				candidates = append(candidates, col)
+			}
+		case *typedef.MapType:
+			if _, ok := tt.ValueType.(typedef.SimpleType); ok {
+				candidates = append(candidates, col)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	valuesWithToken := g.GetOld()
+	if valuesWithToken == nil {
+		return nil
+	}
+	tokens := []*typedef.ValueWithToken{valuesWithToken}
+	// The partitions of a composite partition key can not be listed without
+	// also reading the other combinations of their values.
+	if len(t.PartitionKeys) == 1 && r.Intn(2) == 0 {
+		for count := utils.RandInt2(r, 2, maxFilteringPartitions+1); len(tokens) < count; {
+			vs := g.GetOld()
+			if vs == nil {
+				break
+			}
+			tokens = append(tokens, vs)
+		}
+	}
+	builder := qb.Select(s.Keyspace.Name + "." + t.Name)
+	var values typedef.Values
+	var typs typedef.Types
+	ordered := len(tokens) == 1
+	if ordered {
+		for _, pk := range t.PartitionKeys {
+			builder = builder.Where(qb.Eq(pk.Name))
+			typs = append(typs, pk.Type)
+		}
+		values = valuesWithToken.Value.Copy()
+	} else {
+		pk := t.PartitionKeys[0]
+		builder = builder.Where(qb.InTuple(pk.Name, len(tokens)))
+		for _, vs := range tokens {
+			values = append(values, vs.Value...)
+			typs = append(typs, pk.Type)
+		}
+	}
+	r.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	restricted := utils.RandInt2(r, 1, len(candidates)+1)
+	if restricted > maxFilteringRestrictions {
+		restricted = maxFilteringRestrictions
+	}
+	for _, col := range candidates[:restricted] {
+		var rel qb.Cmp
+		var typ typedef.Type
+		switch tt := col.Type.(type) {
+		case *typedef.BagType:
+			rel, typ = qb.Contains(col.Name), tt.ValueType
+		case *typedef.MapType:
+			if r.Intn(2) == 0 {
+				rel, typ = qb.ContainsKey(col.Name), tt.KeyType
+			} else {
+				rel, typ = qb.Contains(col.Name), tt.ValueType
+			}
+		default:
+			operators := []func(string) qb.Cmp{qb.Eq, qb.Lt, qb.LtOrEq, qb.Gt, qb.GtOrEq}
+			rel, typ = operators[r.Intn(len(operators))](col.Name), col.Type
+		}
+		builder = builder.Where(rel)
+		values = append(values, typ.GenValue(r, p)...)
+		typs = append(typs, typ)
+	}
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:     builder.AllowFiltering(),
+			Types:     typs,
+			QueryType: typedef.SelectFilteringStatementType,
+		},
+		Values:          values,
+		ValuesWithToken: tokens,
+		Read:            typedef.ReadOptions{Ordered: ordered},
 	}
 }
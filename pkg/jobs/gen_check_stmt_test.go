@@ -19,7 +19,11 @@ import (
 	"path"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/rand"
+
 	"github.com/scylladb/gemini/pkg/testutils"
+	"github.com/scylladb/gemini/pkg/typedef"
 	"github.com/scylladb/gemini/pkg/utils"
 )
 
@@ -35,6 +39,85 @@ func TestGenSinglePartitionQuery(t *testing.T) {
 		})
 }
 
+func TestGenWriteTimeQuery(t *testing.T) {
+	RunStmtTest[results](t, path.Join(checkDataPath, "write_time.json"), genWriteTimeQueryCases,
+		func(subT *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+			schema, gen, _ := testutils.GetAllForTestStmt(subT, caseName)
+			prc := schema.Config.GetPartitionRangeConfig()
+			var received results
+			for _, compareWriteTimes := range []bool{false, true} {
+				prc.CompareWriteTimes = compareWriteTimes
+				stmt := genWriteTimeQuery(schema, schema.Tables[0], gen, &prc)
+				validateStmt(subT, stmt, nil)
+				received = append(received, convertStmtsToResults(stmt)...)
+			}
+			expected.CompareOrStore(subT, caseName, received)
+		})
+}
+
+func TestGenFunctionQuery(t *testing.T) {
+	RunStmtTest[results](t, path.Join(checkDataPath, "function.json"), genFunctionQueryCases,
+		func(subT *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+			schema, gen, _ := testutils.GetAllForTestStmt(subT, caseName)
+			// The aggregated and converted columns are chosen at random.
+			rnd := rand.New(rand.NewSource(1))
+			var received results
+			for _, stmt := range []*typedef.Stmt{
+				genAggregateQuery(schema, schema.Tables[0], gen, rnd),
+				genScalarFunctionQuery(schema, schema.Tables[0], gen, rnd),
+				genSelectJSONQuery(schema, schema.Tables[0], gen),
+			} {
+				validateStmt(subT, stmt, nil)
+				received = append(received, convertStmtsToResults(stmt)...)
+			}
+			expected.CompareOrStore(subT, caseName, received)
+		})
+}
+
+func TestGenFilteringQuery(t *testing.T) {
+	RunStmtTest[results](t, path.Join(checkDataPath, "filtering.json"), genFilteringQueryCases,
+		func(subT *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+			schema, gen, _ := testutils.GetAllForTestStmt(subT, caseName)
+			prc := schema.Config.GetPartitionRangeConfig()
+			// The scope and the restrictions are chosen at random.
+			rnd := rand.New(rand.NewSource(1))
+			var received results
+			for i := 0; i < 5; i++ {
+				stmt := genFilteringQuery(schema, schema.Tables[0], gen, rnd, &prc)
+				validateStmt(subT, stmt, nil)
+				received = append(received, convertStmtsToResults(stmt)...)
+			}
+			expected.CompareOrStore(subT, caseName, received)
+		})
+}
+
+func TestShapeRead(t *testing.T) {
+	RunStmtTest[results](t, path.Join(checkDataPath, "read_shape.json"), shapeReadCases,
+		func(subT *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+			schema, gen, _ := testutils.GetAllForTestStmt(subT, caseName)
+			table := schema.Tables[0]
+			// The variants are chosen at random, a seeded source covers them all.
+			rnd := rand.New(rand.NewSource(1))
+			var received results
+			for i := 0; i < 10; i++ {
+				for _, stmt := range []*typedef.Stmt{
+					shapeRead(genSinglePartitionQuery(schema, table, gen), rnd, table.ClusteringKeys),
+					shapeRead(genMultiplePartitionQuery(schema, table, gen, 2), rnd, table.ClusteringKeys),
+				} {
+					validateStmt(subT, stmt, nil)
+					if stmt.Read.PageSize < 0 || stmt.Read.PageSize > maxReadPageSize {
+						subT.Errorf("unexpected page size %d", stmt.Read.PageSize)
+					}
+					if stmt.Read.ResumePaging && stmt.Read.PageSize == 0 {
+						subT.Error("paging is resumed without a page size")
+					}
+					received = append(received, convertStmtsToResults(stmt)...)
+				}
+			}
+			expected.CompareOrStore(subT, caseName, received)
+		})
+}
+
 func TestGenSinglePartitionQueryMv(t *testing.T) {
 	RunStmtTest[results](t, path.Join(checkDataPath, "single_partition_mv.json"), genSinglePartitionQueryMvCases,
 		func(subT *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
@@ -46,6 +129,40 @@ func TestGenSinglePartitionQueryMv(t *testing.T) {
 		})
 }
 
+func TestMvPartitionValues(t *testing.T) {
+	t.Parallel()
+	col := &typedef.ColumnDef{Name: "col0", Type: typedef.TYPE_INT}
+	table := &typedef.Table{
+		Name:           "table1",
+		PartitionKeys:  typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+		ClusteringKeys: typedef.Columns{{Name: "ck0", Type: typedef.TYPE_INT}, {Name: "ck1", Type: typedef.TYPE_INT}},
+		Columns:        typedef.Columns{col},
+	}
+	rnd := rand.New(rand.NewSource(1))
+	prc := typedef.PartitionRangeConfig{MaxStringLength: 10}
+	vs := &typedef.ValueWithToken{Value: typedef.Values{int32(1)}}
+	vs.AddRow(typedef.Values{int32(2), int32(3)})
+	vs.AddColumnValue(col.Name, int32(4))
+
+	byClusteringKey := &typedef.MaterializedView{
+		PartitionKeys:  typedef.Columns{table.ClusteringKeys[1], table.PartitionKeys[0]},
+		ClusteringKeys: typedef.Columns{table.ClusteringKeys[0]},
+	}
+	values, _ := mvPartitionValues(table, byClusteringKey, vs, rnd, &prc)
+	if diff := cmp.Diff(typedef.Values{int32(3), int32(1)}, values); diff != "" {
+		t.Error(diff)
+	}
+	byColumn := &typedef.MaterializedView{
+		PartitionKeys:  typedef.Columns{col},
+		ClusteringKeys: typedef.Columns{table.PartitionKeys[0], table.ClusteringKeys[0], table.ClusteringKeys[1]},
+		NonPrimaryKey:  col,
+	}
+	values, _ = mvPartitionValues(table, byColumn, vs, rnd, &prc)
+	if diff := cmp.Diff(typedef.Values{int32(4)}, values); diff != "" {
+		t.Error(diff)
+	}
+}
+
 func TestGenMultiplePartitionQuery(t *testing.T) {
 	RunStmtTest[results](t, path.Join(checkDataPath, "multiple_partition.json"), genMultiplePartitionQueryCases,
 		func(subT *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
@@ -61,8 +178,11 @@ func TestGenMultiplePartitionQueryMv(t *testing.T) {
 	RunStmtTest[results](t, path.Join(checkDataPath, "multiple_partition_mv.json"), genMultiplePartitionQueryMvCases,
 		func(subT *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
 			options := testutils.GetOptionsFromCaseName(caseName)
-			schema, gen, _ := testutils.GetAllForTestStmt(subT, caseName)
-			stmt := genMultiplePartitionQuery(schema, schema.Tables[0], gen, GetPkCountFromOptions(options, len(schema.Tables[0].PartitionKeys)))
+			schema, gen, rnd := testutils.GetAllForTestStmt(subT, caseName)
+			prc := schema.Config.GetPartitionRangeConfig()
+			stmt := genMultiplePartitionQueryMv(schema, schema.Tables[0], gen, rnd, &prc,
+				len(schema.Tables[0].MaterializedViews)-1,
+				GetPkCountFromOptions(options, len(schema.Tables[0].PartitionKeys)))
 			validateStmt(subT, stmt, nil)
 			expected.CompareOrStore(subT, caseName, convertStmtsToResults(stmt))
 		})
@@ -149,6 +269,23 @@ func TestGenSingleIndexQuery(t *testing.T) {
 		})
 }
 
+func TestGenIndexFilteringQuery(t *testing.T) {
+	RunStmtTest[results](t, path.Join(checkDataPath, "index_filtering.json"), genIndexFilteringQueryCases,
+		func(subT *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+			schema, gen, _ := testutils.GetAllForTestStmt(subT, caseName)
+			prc := schema.Config.GetPartitionRangeConfig()
+			// The range filtered on the indexed rows is chosen at random.
+			rnd := rand.New(rand.NewSource(1))
+			var received results
+			for i := 0; i < 4; i++ {
+				stmt := genSingleIndexQuery(schema, schema.Tables[0], gen, rnd, &prc, len(schema.Tables[0].Indexes))
+				validateStmt(subT, stmt, nil)
+				received = append(received, convertStmtsToResults(stmt)...)
+			}
+			expected.CompareOrStore(subT, caseName, received)
+		})
+}
+
 func BenchmarkGenSinglePartitionQuery(t *testing.B) {
 	utils.SetUnderTest()
 	for idx := range genSinglePartitionQueryCases {
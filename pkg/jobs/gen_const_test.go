@@ -42,6 +42,34 @@ var (
 		"pk3_ck3_col3cr",
 	}
 
+	genLWTStmtCases = []string{
+		"pk1_ck0_col0",
+		"pk1_ck1_col1",
+		"pk3_ck3_col5",
+		"pkAll_ckAll_colAll",
+	}
+
+	genUsingStmtCases = []string{
+		"pk1_ck0_col0",
+		"pk1_ck1_col1",
+		"pk3_ck3_col5",
+		"pkAll_ckAll_colAll",
+	}
+
+	genPartialUpdateStmtCases = []string{
+		"pk1_ck0_col1",
+		"pk1_ck1_col4nf",
+		"pk3_ck3_col5",
+	}
+
+	genBatchStmtCases = []string{
+		"pk1_ck0_col0",
+		"pk1_ck1_col1",
+		"pk3_ck3_col5",
+		"pkAll_ckAll_colAll",
+		"pk1_ck1_col1cr",
+	}
+
 	genDeleteStmtCases = []string{
 		"pk1_ck0_col1",
 		"pk1_ck1_col1",
@@ -50,6 +78,13 @@ var (
 		"pk1_ck1_col1cr",
 		"pk3_ck3_col3cr",
 	}
+
+	genDeleteKindsStmtCases = []string{
+		"pk1_ck0_col1",
+		"pk1_ck1_col4nf",
+		"pk3_ck3_col5",
+		"pk1_ck1_col1cr",
+	}
 )
 
 var (
@@ -61,6 +96,26 @@ var (
 		"pk1_ck1_col1cr",
 		"pk3_ck3_col3cr",
 	}
+	genWriteTimeQueryCases = []string{
+		"pk1_ck1_col1",
+		"pk3_ck3_col5",
+		"pkAll_ckAll_colAll",
+	}
+	genFunctionQueryCases = []string{
+		"pk1_ck1_col1",
+		"pk3_ck3_col5",
+		"pkAll_ckAll_colAll",
+	}
+	genFilteringQueryCases = []string{
+		"pk1_ck1_col1",
+		"pk3_ck3_col5",
+		"pkAll_ckAll_colAll",
+	}
+	shapeReadCases = []string{
+		"pk1_ck0_col0",
+		"pk1_ck1_col1",
+		"pk3_ck3_col5",
+	}
 	genSinglePartitionQueryMvCases = []string{
 		"pk1_ck0_col0_mv",
 		"pk1_ck1_col1_mv",
@@ -171,6 +226,13 @@ var (
 		"pk1_ck0_col1_idx1",
 		"pk3_ck3_col5_idx1",
 		"pkAll_ckAll_colAll_idxAll",
+		"pk1_ck1_col5_idxMix",
+		"pk3_ck3_col4nf_idxMix",
+	}
+
+	genIndexFilteringQueryCases = []string{
+		"pk1_ck1_col5_idx1",
+		"pk1_ck1_col5_idxMix",
 	}
 )
 
@@ -200,4 +262,14 @@ var (
 		"pk1_ck1_col1.addSt17",
 		"pk1_ck1_col1.addSt18",
 	}
+	genCreateIndexStmtCases = []string{
+		"pk1_ck1_col5",
+		"pk3_ck3_col5_idx1",
+		"pkAll_ckAll_colAll",
+	}
+	genCreateMaterializedViewStmtCases = []string{
+		"pk1_ck1_col5",
+		"pk3_ck3_col5_mv",
+		"pkAll_ckAll_colAll_mvNp",
+	}
 )
@@ -16,33 +16,100 @@ package jobs
 
 import (
 	"fmt"
-	"strings"
 
 	"golang.org/x/exp/rand"
 
 	"github.com/scylladb/gemini/pkg/builders"
 	"github.com/scylladb/gemini/pkg/generators"
+	"github.com/scylladb/gemini/pkg/tableopts"
 	"github.com/scylladb/gemini/pkg/typedef"
 )
 
+// alterableTableOptions are the options tables are altered with, none of
+// them changes the results of reads.
+var alterableTableOptions = []string{
+	"comment = 'altered by gemini'",
+	"gc_grace_seconds = 172800",
+	"gc_grace_seconds = 864000",
+	"bloom_filter_fp_chance = 0.01",
+	"bloom_filter_fp_chance = 0.1",
+	"compaction = {'class':'SizeTieredCompactionStrategy'}",
+	"compaction = {'class':'LeveledCompactionStrategy'}",
+	"compaction = {'class':'TimeWindowCompactionStrategy'}",
+	"caching = {'keys':'ALL','rows_per_partition':'ALL'}",
+	"caching = {'keys':'ALL','rows_per_partition':'NONE'}",
+	"compression = {'sstable_compression':'LZ4Compressor'}",
+	"compression = {'sstable_compression':'SnappyCompressor'}",
+	"compression = {'sstable_compression':'DeflateCompressor'}",
+	"speculative_retry = 'NONE'",
+	"speculative_retry = '99.0PERCENTILE'",
+}
+
+// maxUDTFields bounds the number of fields a user defined type is altered to.
+const maxUDTFields = 16
+
+// GenDDLStmt generates a schema change of the table: a column is added or
+// dropped, a field is added to a user defined type, an index or a
+// materialized view is created or dropped, the table options are altered or
+// the table is truncated. A column is added when the change that was picked
+// does not apply to the table.
 func GenDDLStmt(s *typedef.Schema, t *typedef.Table, r *rand.Rand, _ *typedef.PartitionRangeConfig, sc *typedef.SchemaConfig) (*typedef.Stmts, error) {
-	maxVariant := 1
-	validCols := t.ValidColumnsForDelete()
-	if validCols.Len() > 0 {
-		maxVariant = 2
+	var validCols typedef.Columns
+	if len(t.MaterializedViews) == 0 {
+		// Views depend on the columns they select and keep the others as
+		// virtual columns, the columns of a table with views are not dropped.
+		validCols = t.ValidColumnsForDelete()
 	}
-	switch n := r.Intn(maxVariant + 2); n {
+	var stmts *typedef.Stmts
+	var err error
+	switch r.Intn(10) {
 	// case 0: // Alter column not supported in Cassandra from 3.0.11
 	//	return t.alterColumn(s.Keyspace.Name)
+	case 1:
+		if validCols.Len() > 0 {
+			stmts, err = genDropColumnStmt(t, s.Keyspace.Name, validCols.Random(r))
+		}
 	case 2:
-		return genDropColumnStmt(t, s.Keyspace.Name, validCols.Random(r))
-	default:
-		column := typedef.ColumnDef{
-			Name: generators.GenColumnName("col", len(t.Columns)+1),
-			Type: generators.GenColumnType(len(t.Columns)+1, sc, r),
+		stmts, err = genCreateIndexStmt(t, s.Keyspace, r)
+	case 3:
+		if len(t.Indexes) > 0 {
+			stmts, err = genDropIndexStmt(t, s.Keyspace.Name, t.Indexes[r.Intn(len(t.Indexes))])
+		}
+	case 4:
+		stmts, err = genCreateMaterializedViewStmt(t, s.Keyspace, r)
+	case 5:
+		if len(t.MaterializedViews) > 0 {
+			stmts, err = genDropMaterializedViewStmt(t, s.Keyspace.Name, t.MaterializedViews[r.Intn(len(t.MaterializedViews))])
+		}
+	case 6:
+		var option tableopts.Option
+		if option, err = tableopts.FromCQL(alterableTableOptions[r.Intn(len(alterableTableOptions))]); err == nil {
+			stmts, err = genAlterTableOptionsStmt(t, s.Keyspace.Name, option)
 		}
-		return genAddColumnStmt(t, s.Keyspace.Name, &column)
+	case 7:
+		var udts []*typedef.UDTType
+		for _, col := range t.Columns {
+			if udt, ok := col.Type.(*typedef.UDTType); ok && len(udt.ValueTypes) < maxUDTFields {
+				udts = append(udts, udt)
+			}
+		}
+		if len(udts) > 0 {
+			stmts, err = genAlterTypeStmt(t, s.Keyspace.Name, udts[r.Intn(len(udts))], generators.GenSimpleType(sc, r))
+		}
+	case 8:
+		stmts, err = genTruncateStmt(t, s.Keyspace.Name)
+	}
+	if stmts != nil || err != nil {
+		return stmts, err
 	}
+	column := typedef.ColumnDef{Name: generators.GenColumnName("col", len(t.Columns)+1)}
+	if t.IsCounterTable() {
+		// A counter table can not have columns other than counters.
+		column.Type = &typedef.CounterType{}
+	} else {
+		column.Type = generators.GenColumnType(len(t.Columns)+1, sc, r)
+	}
+	return genAddColumnStmt(t, s.Keyspace.Name, &column)
 }
 
 func appendValue(columnType typedef.Type, r *rand.Rand, p *typedef.PartitionRangeConfig, values []interface{}) []interface{} {
@@ -51,13 +118,7 @@ func appendValue(columnType typedef.Type, r *rand.Rand, p *typedef.PartitionRang
 
 func genAddColumnStmt(t *typedef.Table, keyspace string, column *typedef.ColumnDef) (*typedef.Stmts, error) {
 	var stmts []*typedef.Stmt
-	if c, ok := column.Type.(*typedef.UDTType); ok {
-		createType := "CREATE TYPE IF NOT EXISTS %s.%s (%s);"
-		var typs []string
-		for name, typ := range c.ValueTypes {
-			typs = append(typs, name+" "+typ.CQLDef())
-		}
-		stmt := fmt.Sprintf(createType, keyspace, c.TypeName, strings.Join(typs, ","))
+	for _, stmt := range generators.GetCreateTypesForType(column.Type, keyspace) {
 		stmts = append(stmts, &typedef.Stmt{
 			StmtCache: &typedef.StmtCache{
 				Query: &builders.AlterTableBuilder{
@@ -105,3 +166,190 @@ func genDropColumnStmt(t *typedef.Table, keyspace string, column *typedef.Column
 		},
 	}, nil
 }
+
+// genCreateIndexStmt indexes a column that has no index yet, it returns nil
+// if every column that can be indexed already is. The index is only read
+// once the clusters built it.
+func genCreateIndexStmt(t *typedef.Table, keyspace typedef.Keyspace, r *rand.Rand) (*typedef.Stmts, error) {
+	var candidates []typedef.IndexDef
+	for _, idx := range generators.CreateIndexesForColumn(t, len(t.Columns)+len(t.ClusteringKeys), r) {
+		indexed := false
+		for _, existing := range t.Indexes {
+			indexed = indexed || existing.ColumnName == idx.ColumnName || existing.IndexName == idx.IndexName
+		}
+		if !indexed {
+			candidates = append(candidates, idx)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	idx := candidates[r.Intn(len(candidates))]
+	return &typedef.Stmts{
+		List: []*typedef.Stmt{{
+			StmtCache: &typedef.StmtCache{
+				Query: &builders.AlterTableBuilder{
+					Stmt: generators.GetCreateIndex(t, keyspace, idx),
+				},
+				QueryType: typedef.CreateIndexStatementType,
+			},
+		}},
+		QueryType: typedef.CreateIndexStatementType,
+		Builds:    idx.IndexName,
+		PostStmtHook: func() {
+			t.Indexes = append(t.Indexes[:len(t.Indexes):len(t.Indexes)], idx)
+			t.ResetQueryCache()
+		},
+	}, nil
+}
+
+func genDropIndexStmt(t *typedef.Table, keyspace string, idx typedef.IndexDef) (*typedef.Stmts, error) {
+	return &typedef.Stmts{
+		List: []*typedef.Stmt{{
+			StmtCache: &typedef.StmtCache{
+				Query: &builders.AlterTableBuilder{
+					Stmt: "DROP INDEX " + keyspace + "." + idx.IndexName,
+				},
+				QueryType: typedef.DropIndexStatementType,
+			},
+		}},
+		QueryType: typedef.DropIndexStatementType,
+		PostStmtHook: func() {
+			indexes := make([]typedef.IndexDef, 0, len(t.Indexes))
+			for _, existing := range t.Indexes {
+				if existing.IndexName != idx.IndexName {
+					indexes = append(indexes, existing)
+				}
+			}
+			t.Indexes = indexes
+			t.ResetQueryCache()
+		},
+	}, nil
+}
+
+// genCreateMaterializedViewStmt creates a view under a name that no other
+// view of the table has. It returns nil if the table can not have views or
+// already has generators.MaxMaterializedViews of them. The view is only read
+// once the clusters built it.
+func genCreateMaterializedViewStmt(t *typedef.Table, keyspace typedef.Keyspace, r *rand.Rand) (*typedef.Stmts, error) {
+	if len(t.MaterializedViews) >= generators.MaxMaterializedViews || t.IsCounterTable() ||
+		len(t.ClusteringKeys) == 0 || t.Columns.ValidColumnsForPrimaryKey().Len() == 0 {
+		return nil, nil
+	}
+	var name string
+	for i := 0; name == ""; i++ {
+		name = fmt.Sprintf("%s_mv_%d", t.Name, i)
+		for _, existing := range t.MaterializedViews {
+			if existing.Name == name {
+				name = ""
+				break
+			}
+		}
+	}
+	mv := generators.CreateMaterializedView(t.Columns, name, t.PartitionKeys, t.ClusteringKeys, r)
+	return &typedef.Stmts{
+		List: []*typedef.Stmt{{
+			StmtCache: &typedef.StmtCache{
+				Query: &builders.AlterTableBuilder{
+					Stmt: generators.GetCreateMaterializedView(t, keyspace, mv),
+				},
+				QueryType: typedef.CreateMaterializedViewStatementType,
+			},
+		}},
+		QueryType: typedef.CreateMaterializedViewStatementType,
+		Builds:    mv.Name,
+		PostStmtHook: func() {
+			t.MaterializedViews = append(t.MaterializedViews[:len(t.MaterializedViews):len(t.MaterializedViews)], mv)
+			t.ResetQueryCache()
+		},
+	}, nil
+}
+
+func genDropMaterializedViewStmt(t *typedef.Table, keyspace string, mv typedef.MaterializedView) (*typedef.Stmts, error) {
+	return &typedef.Stmts{
+		List: []*typedef.Stmt{{
+			StmtCache: &typedef.StmtCache{
+				Query: &builders.AlterTableBuilder{
+					Stmt: "DROP MATERIALIZED VIEW " + keyspace + "." + mv.Name,
+				},
+				QueryType: typedef.DropMaterializedViewStatementType,
+			},
+		}},
+		QueryType: typedef.DropMaterializedViewStatementType,
+		PostStmtHook: func() {
+			mvs := make([]typedef.MaterializedView, 0, len(t.MaterializedViews))
+			for _, existing := range t.MaterializedViews {
+				if existing.Name != mv.Name {
+					mvs = append(mvs, existing)
+				}
+			}
+			t.MaterializedViews = mvs
+			t.ResetQueryCache()
+		},
+	}, nil
+}
+
+// genAlterTableOptionsStmt sets the option, it replaces the option of the
+// same name among the options the table was created with.
+func genAlterTableOptionsStmt(t *typedef.Table, keyspace string, option tableopts.Option) (*typedef.Stmts, error) {
+	return &typedef.Stmts{
+		List: []*typedef.Stmt{{
+			StmtCache: &typedef.StmtCache{
+				Query: &builders.AlterTableBuilder{
+					Stmt: "ALTER TABLE " + keyspace + "." + t.Name + " WITH " + option.ToCQL(),
+				},
+				QueryType: typedef.AlterTableOptionsStatementType,
+			},
+		}},
+		QueryType: typedef.AlterTableOptionsStatementType,
+		PostStmtHook: func() {
+			options := make([]string, 0, len(t.TableOptions)+1)
+			for _, existing := range t.TableOptions {
+				if o, err := tableopts.FromCQL(existing); err != nil || o.Key() != option.Key() {
+					options = append(options, existing)
+				}
+			}
+			t.TableOptions = append(options, option.ToCQL())
+			t.ResetQueryCache()
+		},
+	}, nil
+}
+
+// genAlterTypeStmt adds a field of the given type to the user defined type.
+// The values written before read the field as missing on both clusters.
+func genAlterTypeStmt(t *typedef.Table, keyspace string, udt *typedef.UDTType, fieldType typedef.SimpleType) (*typedef.Stmts, error) {
+	name := udt.NewFieldName()
+	return &typedef.Stmts{
+		List: []*typedef.Stmt{{
+			StmtCache: &typedef.StmtCache{
+				Query: &builders.AlterTableBuilder{
+					Stmt: "ALTER TYPE " + keyspace + "." + udt.TypeName + " ADD " + name + " " + fieldType.CQLDef(),
+				},
+				QueryType: typedef.AlterTypeStatementType,
+			},
+		}},
+		QueryType: typedef.AlterTypeStatementType,
+		PostStmtHook: func() {
+			udt.ValueTypes[name] = fieldType
+			t.ResetQueryCache()
+		},
+	}, nil
+}
+
+// genTruncateStmt removes the data of the table and of its views. DDL waits
+// for the mutations in flight, so no mutation is applied to one of the
+// clusters before the table is truncated and to the other one after.
+func genTruncateStmt(t *typedef.Table, keyspace string) (*typedef.Stmts, error) {
+	return &typedef.Stmts{
+		List: []*typedef.Stmt{{
+			StmtCache: &typedef.StmtCache{
+				Query: &builders.AlterTableBuilder{
+					Stmt: "TRUNCATE TABLE " + keyspace + "." + t.Name,
+				},
+				QueryType: typedef.TruncateStatementType,
+			},
+		}},
+		QueryType:    typedef.TruncateStatementType,
+		PostStmtHook: func() {},
+	}, nil
+}
@@ -21,7 +21,11 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/rand"
+
 	"github.com/scylladb/gemini/pkg/generators"
+	"github.com/scylladb/gemini/pkg/tableopts"
 	"github.com/scylladb/gemini/pkg/testutils"
 	"github.com/scylladb/gemini/pkg/typedef"
 	"github.com/scylladb/gemini/pkg/utils"
@@ -51,6 +55,115 @@ func TestGenAddColumnStmt(t *testing.T) {
 	})
 }
 
+func TestGenCreateIndexStmt(t *testing.T) {
+	RunStmtTest[results](t, path.Join(ddlDataPath, "create_index.json"), genCreateIndexStmtCases, func(subT *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+		schema, _, _ := testutils.GetAllForTestStmt(subT, caseName)
+		stmt, err := genCreateIndexStmt(schema.Tables[0], schema.Keyspace, rand.New(rand.NewSource(1)))
+		validateStmt(subT, stmt, err)
+		expected.CompareOrStore(subT, caseName, convertStmtsToResults(stmt))
+	})
+}
+
+func TestGenCreateMaterializedViewStmt(t *testing.T) {
+	RunStmtTest[results](t, path.Join(ddlDataPath, "create_mv.json"), genCreateMaterializedViewStmtCases, func(subT *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+		schema, _, _ := testutils.GetAllForTestStmt(subT, caseName)
+		stmt, err := genCreateMaterializedViewStmt(schema.Tables[0], schema.Keyspace, rand.New(rand.NewSource(1)))
+		validateStmt(subT, stmt, err)
+		expected.CompareOrStore(subT, caseName, convertStmtsToResults(stmt))
+	})
+}
+
+func TestGenDDLStmtHooks(t *testing.T) {
+	utils.SetUnderTest()
+	t.Parallel()
+	schema, _, _ := testutils.GetAllForTestStmt(t, "pk3_ck3_col5_idx1_mv")
+	table := schema.Tables[0]
+	ks := schema.Keyspace
+	r := rand.New(rand.NewSource(1))
+
+	apply := func(stmts *typedef.Stmts, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stmts == nil {
+			t.Fatal("no statement generated")
+		}
+		stmts.PostStmtHook()
+	}
+
+	apply(genCreateIndexStmt(table, ks, r))
+	if len(table.Indexes) != 2 || table.Indexes[0].ColumnName == table.Indexes[1].ColumnName {
+		t.Fatalf("expected a second index on another column, got %v", table.Indexes)
+	}
+	apply(genDropIndexStmt(table, ks.Name, table.Indexes[0]))
+	if len(table.Indexes) != 1 {
+		t.Fatalf("expected the index to be dropped, got %v", table.Indexes)
+	}
+
+	apply(genCreateMaterializedViewStmt(table, ks, r))
+	if len(table.MaterializedViews) != 2 || table.MaterializedViews[1].Name != table.Name+"_mv_0" {
+		t.Fatalf("expected the view %s_mv_0 to be created, got %v", table.Name, table.MaterializedViews)
+	}
+	for len(table.MaterializedViews) < generators.MaxMaterializedViews {
+		apply(genCreateMaterializedViewStmt(table, ks, r))
+	}
+	if stmts, _ := genCreateMaterializedViewStmt(table, ks, r); stmts != nil {
+		t.Fatalf("expected no more than %d views to be created", generators.MaxMaterializedViews)
+	}
+	apply(genDropMaterializedViewStmt(table, ks.Name, table.MaterializedViews[0]))
+	for _, mv := range table.MaterializedViews {
+		if mv.Name == table.Name+"_mv_1" {
+			t.Fatalf("expected the view %s to be dropped", mv.Name)
+		}
+	}
+
+	table.TableOptions = []string{"comment = 'created'", "gc_grace_seconds = 1"}
+	option, err := tableopts.FromCQL("comment = 'altered'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apply(genAlterTableOptionsStmt(table, ks.Name, option))
+	if diff := cmp.Diff([]string{"gc_grace_seconds = 1", "comment = 'altered'"}, table.TableOptions); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGenAlterTypeStmt(t *testing.T) {
+	utils.SetUnderTest()
+	t.Parallel()
+	schema, _, _ := testutils.GetAllForTestStmt(t, "pk1_ck1_col1")
+	table := schema.Tables[0]
+	udt := &typedef.UDTType{
+		ComplexType: typedef.TYPE_UDT,
+		TypeName:    "udt_1",
+		ValueTypes:  map[string]typedef.Type{"udt_1_0": typedef.TYPE_INT},
+		Frozen:      true,
+	}
+	table.Columns = append(table.Columns, &typedef.ColumnDef{Name: "col1", Type: udt})
+
+	stmts, err := genAlterTypeStmt(table, schema.Keyspace.Name, udt, typedef.TYPE_TEXT)
+	validateStmt(t, stmts, err)
+	if query, _ := stmts.List[0].Query.ToCql(); query != "ALTER TYPE ks1.udt_1 ADD udt_1_0_1 text" {
+		t.Errorf("unexpected query %s", query)
+	}
+	stmts.PostStmtHook()
+	if udt.ValueTypes["udt_1_0_1"] != typedef.TYPE_TEXT || len(udt.ValueTypes) != 2 {
+		t.Errorf("expected the field udt_1_0_1 to be added, got %v", udt.ValueTypes)
+	}
+}
+
+func TestGenTruncateStmt(t *testing.T) {
+	utils.SetUnderTest()
+	t.Parallel()
+	schema, _, _ := testutils.GetAllForTestStmt(t, "pk1_ck1_col1")
+	stmts, err := genTruncateStmt(schema.Tables[0], schema.Keyspace.Name)
+	validateStmt(t, stmts, err)
+	if query, _ := stmts.List[0].Query.ToCql(); query != "TRUNCATE TABLE ks1.pk1_ck1_col1" {
+		t.Errorf("unexpected query %s", query)
+	}
+}
+
 func BenchmarkGenDropColumnStmt(t *testing.B) {
 	utils.SetUnderTest()
 	for idx := range genDropColumnStmtCases {
@@ -17,8 +17,11 @@ package jobs
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/gocql/gocql"
 	"github.com/scylladb/gocqlx/v2/qb"
 	"golang.org/x/exp/rand"
 
@@ -26,65 +29,556 @@ import (
 	"github.com/scylladb/gemini/pkg/typedef"
 )
 
+// GenMutateStmt generates a mutation of the table. The caller holds the read
+// lock of the table until the mutation was applied, see GenCheckStmt.
 func GenMutateStmt(s *typedef.Schema, t *typedef.Table, g generators.GeneratorInterface, r *rand.Rand, p *typedef.PartitionRangeConfig, deletes bool) (*typedef.Stmt, error) {
-	t.RLock()
-	defer t.RUnlock()
-
-	valuesWithToken := g.Get()
+	// Half of the mutations revisit a partition that was mutated before, so
+	// that partitions get several rows and existing rows are mutated again.
+	var valuesWithToken *typedef.ValueWithToken
+	if r.Intn(2) == 0 {
+		valuesWithToken = g.GetOld()
+	} else {
+		valuesWithToken = g.Get()
+	}
 	if valuesWithToken == nil {
 		return nil, nil
 	}
-	useLWT := false
-	if p.UseLWT && r.Uint32()%10 == 0 {
-		useLWT = true
+	if p.UseLWT && !t.IsCounterTable() && r.Uint32()%10 == 0 {
+		return genLWTStmt(s, t, valuesWithToken, r, p, deletes)
+	}
+
+	if r.Intn(20) == 0 {
+		return genBatchStmt(s, t, g, valuesWithToken, r, p, deletes)
+	}
+
+	stmt, err := genSingleMutateStmt(s, t, valuesWithToken, r, p, deletes)
+	if err != nil || stmt == nil {
+		return stmt, err
 	}
+	return genUsing(t, stmt, r, p, time.Now()), nil
+}
 
+func genSingleMutateStmt(
+	s *typedef.Schema,
+	t *typedef.Table,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+	deletes bool,
+) (*typedef.Stmt, error) {
 	if !deletes {
-		return genInsertOrUpdateStmt(s, t, valuesWithToken, r, p, useLWT)
+		return genInsertOrUpdateStmt(s, t, valuesWithToken, r, p)
 	}
 	switch n := r.Intn(1000); n {
 	case 10, 100:
-		return genDeleteRows(s, t, valuesWithToken, r, p)
+		return genDeleteStmt(s, t, valuesWithToken, r, p), nil
 	default:
 		switch r.Intn(2) {
 		case 0:
 			if t.KnownIssues[typedef.KnownIssuesJSONWithTuples] {
-				return genInsertOrUpdateStmt(s, t, valuesWithToken, r, p, useLWT)
+				return genInsertOrUpdateStmt(s, t, valuesWithToken, r, p)
 			}
 			return genInsertJSONStmt(s, t, valuesWithToken, r, p)
 		default:
-			return genInsertOrUpdateStmt(s, t, valuesWithToken, r, p, useLWT)
+			return genInsertOrUpdateStmt(s, t, valuesWithToken, r, p)
 		}
 	}
 }
 
+// genUsing returns the statement with a random TTL and write timestamp, as
+// far as they are enabled and the statement can carry them.
+func genUsing(t *typedef.Table, stmt *typedef.Stmt, r *rand.Rand, p *typedef.PartitionRangeConfig, now time.Time) *typedef.Stmt {
+	if len(t.Columns.NonCounters()) != len(t.Columns) {
+		// Counter updates can have neither.
+		return stmt
+	}
+	var ttl, timestamp int64
+	if p.MaxTTL > 0 && r.Intn(2) == 0 {
+		ttl = 1 + r.Int63n(int64(p.MaxTTL))
+	}
+	if p.UseWriteTimestamps && r.Intn(2) == 0 {
+		timestamp = genWriteTimestamp(r, now)
+	}
+	return withUsing(stmt, ttl, timestamp)
+}
+
+// withUsing returns the statement with the TTL, in seconds, and the write
+// timestamp, in microseconds, unless they are 0.
+func withUsing(stmt *typedef.Stmt, ttl, timestamp int64) *typedef.Stmt {
+	cache := *stmt.StmtCache
+	// The USING values are bound before the SET and WHERE clauses, but after
+	// the inserted values and the keys of the deleted elements.
+	typesAt, valuesAt := 0, 0
+	switch b := stmt.Query.(type) {
+	case *qb.InsertBuilder:
+		builder := *b
+		if ttl != 0 {
+			builder.TTLNamed("ttl")
+		}
+		if timestamp != 0 {
+			builder.TimestampNamed("ts")
+		}
+		cache.Query = &builder
+		typesAt, valuesAt = len(stmt.Types), len(stmt.Values)
+	case *qb.UpdateBuilder:
+		builder := *b
+		if ttl != 0 {
+			builder.TTLNamed("ttl")
+		}
+		if timestamp != 0 {
+			builder.TimestampNamed("ts")
+		}
+		cache.Query = &builder
+	case *qb.DeleteBuilder:
+		// Deletions can not expire.
+		ttl = 0
+		builder := *b
+		if timestamp != 0 {
+			builder.TimestampNamed("ts")
+		}
+		cache.Query = &builder
+		query, _ := b.ToCql()
+		columns, _, _ := strings.Cut(query, " FROM ")
+		typesAt = strings.Count(columns, "?")
+		valuesAt = typesAt
+	default:
+		return stmt
+	}
+
+	var using typedef.Types
+	var usingValues typedef.Values
+	if ttl != 0 {
+		using, usingValues = append(using, typedef.TYPE_INT), append(usingValues, int32(ttl))
+	}
+	if timestamp != 0 {
+		using, usingValues = append(using, typedef.TYPE_BIGINT), append(usingValues, timestamp)
+	}
+	if len(using) == 0 {
+		return stmt
+	}
+	types := make(typedef.Types, 0, len(stmt.Types)+len(using))
+	types = append(append(append(types, stmt.Types[:typesAt]...), using...), stmt.Types[typesAt:]...)
+	values := make(typedef.Values, 0, len(stmt.Values)+len(usingValues))
+	values = append(append(append(values, stmt.Values[:valuesAt]...), usingValues...), stmt.Values[valuesAt:]...)
+	cache.Types = types
+	return &typedef.Stmt{
+		StmtCache:       &cache,
+		ValuesWithToken: stmt.ValuesWithToken,
+		Values:          values,
+	}
+}
+
+// genWriteTimestamp returns a write timestamp, in microseconds, for a
+// mutation generated at now. A quarter of the timestamps lie up to a second
+// in the past, so that the mutation loses against more recent writes, and
+// a quarter is truncated to the second, so that writes to the same row
+// collide and the tie breaking rules decide which one wins.
+func genWriteTimestamp(r *rand.Rand, now time.Time) int64 {
+	ts := now.UnixMicro()
+	switch r.Intn(4) {
+	case 0:
+		return ts - r.Int63n(int64(time.Second/time.Microsecond))
+	case 1:
+		return now.Truncate(time.Second).UnixMicro()
+	default:
+		return ts
+	}
+}
+
 func genInsertOrUpdateStmt(
 	s *typedef.Schema,
 	t *typedef.Table,
 	valuesWithToken *typedef.ValueWithToken,
 	r *rand.Rand,
 	p *typedef.PartitionRangeConfig,
-	useLWT bool,
 ) (*typedef.Stmt, error) {
 	if t.IsCounterTable() {
+		if stmt := genStaticCounterUpdateStmt(s, t, valuesWithToken); stmt != nil && r.Intn(4) == 0 {
+			return stmt, nil
+		}
 		return genUpdateStmt(s, t, valuesWithToken, r, p)
 	}
-	return genInsertStmt(s, t, valuesWithToken, r, p, useLWT)
+	if len(t.Columns) > 0 && r.Intn(3) == 0 {
+		return genPartialUpdateStmt(s, t, valuesWithToken, r, p), nil
+	}
+	return genInsertStmt(s, t, valuesWithToken, r, p, false)
+}
+
+// genPartialUpdateStmt generates an UPDATE of a part of the row. It either
+// sets a random subset of the columns, modifies the elements of a non frozen
+// collection or sets a field of a non frozen UDT.
+func genPartialUpdateStmt(
+	s *typedef.Schema,
+	t *typedef.Table,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+) *typedef.Stmt {
+	var collections, udts typedef.Columns
+	for _, col := range t.Columns {
+		switch typ := col.Type.(type) {
+		case *typedef.BagType:
+			if !typ.Frozen {
+				collections = append(collections, col)
+			}
+		case *typedef.MapType:
+			if !typ.Frozen {
+				collections = append(collections, col)
+			}
+		case *typedef.UDTType:
+			if !typ.Frozen {
+				udts = append(udts, col)
+			}
+		}
+	}
+
+	builder := qb.Update(s.Keyspace.Name + "." + t.Name)
+	var types typedef.Types
+	var values typedef.Values
+	queryType := typedef.PartialUpdateStatementType
+	switch kind := r.Intn(3); {
+	case kind == 1 && len(collections) > 0:
+		col := collections[r.Intn(len(collections))]
+		builder, types, values = genCollectionUpdate(builder, col, r, p)
+		queryType = typedef.CollectionUpdateStatementType
+	case kind == 2 && len(udts) > 0:
+		col := udts[r.Intn(len(udts))]
+		udt := col.Type.(*typedef.UDTType)
+		fields := make([]string, 0, len(udt.ValueTypes))
+		for name := range udt.ValueTypes {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+		field := fields[r.Intn(len(fields))]
+		builder = builder.Set(col.Name + "." + field)
+		types = append(types, udt.ValueTypes[field])
+		values = appendValue(udt.ValueTypes[field], r, p, values)
+		queryType = typedef.UDTFieldUpdateStatementType
+	default:
+		first := r.Intn(len(t.Columns))
+		for i, col := range t.Columns {
+			if i != first && r.Intn(2) == 0 {
+				continue
+			}
+			if tuple, ok := col.Type.(*typedef.TupleType); ok {
+				builder = builder.SetTuple(col.Name, len(tuple.ValueTypes))
+			} else {
+				builder = builder.Set(col.Name)
+			}
+			types = append(types, col.Type)
+			colValues := genColumnValue(col, r, p)
+			rememberViewKey(t, valuesWithToken, col, colValues)
+			values = append(values, colValues...)
+		}
+	}
+
+	for _, pk := range t.PartitionKeys {
+		builder = builder.Where(qb.Eq(pk.Name))
+		types = append(types, pk.Type)
+	}
+	values = append(values, valuesWithToken.Value...)
+	for _, ck := range t.ClusteringKeys {
+		builder = builder.Where(qb.Eq(ck.Name))
+		types = append(types, ck.Type)
+	}
+	values = genClusteringValues(t, valuesWithToken, r, p, values)
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:     builder,
+			Types:     types,
+			QueryType: queryType,
+		},
+		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Values:          values,
+	}
+}
+
+// genCollectionUpdate adds an element operation on the non frozen collection
+// column to the update: an append, prepend or removal of elements, or the
+// assignment of a single map entry.
+func genCollectionUpdate(
+	builder *qb.UpdateBuilder,
+	col *typedef.ColumnDef,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+) (*qb.UpdateBuilder, typedef.Types, typedef.Values) {
+	switch typ := col.Type.(type) {
+	case *typedef.MapType:
+		switch r.Intn(3) {
+		case 0:
+			builder = builder.Add(col.Name)
+		case 1:
+			keys := &typedef.BagType{ComplexType: typedef.TYPE_SET, ValueType: typ.KeyType}
+			return builder.Remove(col.Name), typedef.Types{keys}, keys.GenValue(r, p)
+		default:
+			builder = builder.Set(col.Name + "[?]")
+			values := append(typ.KeyType.GenValue(r, p), typ.ValueType.GenValue(r, p)...)
+			return builder, typedef.Types{typ.KeyType, typ.ValueType}, values
+		}
+	case *typedef.BagType:
+		switch n := r.Intn(3); {
+		case n == 0:
+			builder = builder.Add(col.Name)
+		case n == 1 && typ.ComplexType == typedef.TYPE_LIST:
+			builder = builder.SetLit(col.Name, "?+"+col.Name)
+		default:
+			builder = builder.Remove(col.Name)
+		}
+	}
+	return builder, typedef.Types{col.Type}, col.Type.GenValue(r, p)
+}
+
+// missingValueRatio is the inverse probability that a regular column is
+// written without a value, see genColumnValue.
+const missingValueRatio = 10
+
+// genColumnValue generates the value of a regular column. Now and then the
+// column is written without a value: an explicit null, an unset value that
+// leaves the column as it was or an empty collection, which a non frozen
+// collection stores as null.
+func genColumnValue(col *typedef.ColumnDef, r *rand.Rand, p *typedef.PartitionRangeConfig) typedef.Values {
+	if _, ok := col.Type.(*typedef.TupleType); ok || r.Intn(missingValueRatio) != 0 {
+		return col.GenValue(r, p)
+	}
+	switch r.Intn(3) {
+	case 0:
+		return typedef.Values{nil}
+	case 1:
+		return typedef.Values{gocql.UnsetValue}
+	}
+	switch col.Type.(type) {
+	case *typedef.BagType:
+		return typedef.Values{[]interface{}{}}
+	case *typedef.MapType:
+		return typedef.Values{map[interface{}]interface{}{}}
+	}
+	return typedef.Values{nil}
+}
+
+// rememberViewKey remembers the value written to a regular column that is in
+// the partition key of a view, reads of the view look up the view partitions
+// of the base partition by it.
+func rememberViewKey(t *typedef.Table, vs *typedef.ValueWithToken, col *typedef.ColumnDef, values typedef.Values) {
+	if len(values) != 1 || values[0] == nil || values[0] == gocql.UnsetValue {
+		return
+	}
+	for i := range t.MaterializedViews {
+		if mv := &t.MaterializedViews[i]; mv.HaveNonPrimaryKey() && mv.NonPrimaryKey.Name == col.Name {
+			vs.AddColumnValue(col.Name, values[0])
+			return
+		}
+	}
 }
 
 func genUpdateStmt(_ *typedef.Schema, t *typedef.Table, valuesWithToken *typedef.ValueWithToken, r *rand.Rand, p *typedef.PartitionRangeConfig) (*typedef.Stmt, error) {
-	stmtCache := t.GetQueryCache(typedef.CacheUpdate)
+	return genUpdateStmtFromCache(t, typedef.CacheUpdate, valuesWithToken, r, p), nil
+}
+
+func genUpdateStmtFromCache(
+	t *typedef.Table,
+	cacheType typedef.StatementCacheType,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+) *typedef.Stmt {
+	stmtCache := t.GetQueryCache(cacheType)
 	nonCounters := t.Columns.NonCounters()
 	values := make(typedef.Values, 0, t.PartitionKeys.LenValues()+t.ClusteringKeys.LenValues()+nonCounters.LenValues())
 	for _, cdef := range nonCounters {
-		values = appendValue(cdef.Type, r, p, values)
+		colValues := genColumnValue(cdef, r, p)
+		rememberViewKey(t, valuesWithToken, cdef, colValues)
+		values = append(values, colValues...)
+	}
+	values = values.CopyFrom(valuesWithToken.Value)
+	values = genClusteringValues(t, valuesWithToken, r, p, values)
+	return &typedef.Stmt{
+		StmtCache:       stmtCache,
+		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Values:          values,
+	}
+}
+
+// genStaticCounterUpdateStmt increments the static counters of the partition
+// without touching any of its rows. It returns nil if the table has no static
+// counters.
+func genStaticCounterUpdateStmt(s *typedef.Schema, t *typedef.Table, valuesWithToken *typedef.ValueWithToken) *typedef.Stmt {
+	builder := qb.Update(s.Keyspace.Name + "." + t.Name)
+	static := false
+	for _, col := range t.Columns {
+		if col.Static {
+			builder = builder.SetLit(col.Name, col.Name+"+1")
+			static = true
+		}
+	}
+	if !static {
+		return nil
+	}
+	types := make(typedef.Types, 0, len(t.PartitionKeys))
+	for _, pk := range t.PartitionKeys {
+		builder = builder.Where(qb.Eq(pk.Name))
+		types = append(types, pk.Type)
+	}
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:     builder,
+			Types:     types,
+			QueryType: typedef.UpdateStatementType,
+		},
+		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Values:          valuesWithToken.Value.Copy(),
+	}
+}
+
+// genLWTStmt generates a conditional mutation of the partition, either a
+// single statement or a batch of them.
+func genLWTStmt(
+	s *typedef.Schema,
+	t *typedef.Table,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+	deletes bool,
+) (*typedef.Stmt, error) {
+	if r.Intn(4) == 0 && len(t.ClusteringKeys) > 0 {
+		return genLWTBatchStmt(s, t, valuesWithToken, r, p, deletes)
+	}
+	return genLWTSingleStmt(s, t, valuesWithToken, r, p, deletes)
+}
+
+func genLWTSingleStmt(
+	s *typedef.Schema,
+	t *typedef.Table,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+	deletes bool,
+) (*typedef.Stmt, error) {
+	n := 3
+	if deletes {
+		n++
+	}
+	switch kind := r.Intn(n); {
+	case kind == 1 && len(t.Columns) > 0:
+		return genUpdateStmtFromCache(t, typedef.CacheUpdateIfExists, valuesWithToken, r, p), nil
+	case kind == 2:
+		if stmt := genUpdateIfStmt(s, t, valuesWithToken, r, p); stmt != nil {
+			return stmt, nil
+		}
+	case kind == 3:
+		return genDeleteIfExistsStmt(t, valuesWithToken, r, p), nil
+	}
+	return genInsertStmt(s, t, valuesWithToken, r, p, true)
+}
+
+// genUpdateIfStmt generates a compare and set of a single column. The
+// condition compares against null or a random value, so that it holds for
+// rows where the column is not set and almost never otherwise.
+// It returns nil if the table has no column that can be compared.
+func genUpdateIfStmt(
+	s *typedef.Schema,
+	t *typedef.Table,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+) *typedef.Stmt {
+	var candidates typedef.Columns
+	for _, col := range t.Columns {
+		if typ, ok := col.Type.(typedef.SimpleType); ok && typ != typedef.TYPE_DURATION {
+			candidates = append(candidates, col)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	col := candidates[r.Intn(len(candidates))]
+
+	builder := qb.Update(s.Keyspace.Name + "." + t.Name).Set(col.Name)
+	types := make(typedef.Types, 0, len(t.PartitionKeys)+len(t.ClusteringKeys)+2)
+	types = append(types, col.Type)
+	values := make(typedef.Values, 0, t.PartitionKeys.LenValues()+t.ClusteringKeys.LenValues()+2)
+	values = append(values, col.GenValue(r, p)...)
+	for _, pk := range t.PartitionKeys {
+		builder = builder.Where(qb.Eq(pk.Name))
+		types = append(types, pk.Type)
 	}
 	values = values.CopyFrom(valuesWithToken.Value)
 	for _, ck := range t.ClusteringKeys {
-		values = appendValue(ck.Type, r, p, values)
+		builder = builder.Where(qb.Eq(ck.Name))
+		types = append(types, ck.Type)
+	}
+	values = genClusteringValues(t, valuesWithToken, r, p, values)
+	builder = builder.If(qb.Eq(col.Name))
+	types = append(types, col.Type)
+	if r.Intn(2) == 0 {
+		values = append(values, nil)
+	} else {
+		values = appendValue(col.Type, r, p, values)
 	}
 	return &typedef.Stmt{
-		StmtCache:       stmtCache,
+		StmtCache: &typedef.StmtCache{
+			Query:      builder,
+			Types:      types,
+			QueryType:  typedef.UpdateIfStatementType,
+			Conditions: []string{col.Name},
+		},
+		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Values:          values,
+	}
+}
+
+func genDeleteIfExistsStmt(
+	t *typedef.Table,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+) *typedef.Stmt {
+	values := genClusteringValues(t, valuesWithToken, r, p, valuesWithToken.Value.Copy())
+	return &typedef.Stmt{
+		StmtCache:       t.GetQueryCache(typedef.CacheDeleteIfExists),
+		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Values:          values,
+	}
+}
+
+// genLWTBatchStmt generates a batch of conditional statements for distinct
+// rows of the partition. Conditions of a batch have to be on the same
+// partition and a row can not be both conditioned on and required to not
+// exist, hence distinct rows.
+func genLWTBatchStmt(
+	s *typedef.Schema,
+	t *typedef.Table,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+	deletes bool,
+) (*typedef.Stmt, error) {
+	builder := qb.Batch()
+	var types typedef.Types
+	var values typedef.Values
+	var conditions []string
+	rows := make(map[string]struct{})
+	for i, count := 0, 2+r.Intn(2); i < count; i++ {
+		stmt, err := genLWTSingleStmt(s, t, valuesWithToken, r, p, deletes)
+		if err != nil {
+			return nil, err
+		}
+		row := clusteringValues(t, stmt)
+		if _, ok := rows[row]; ok {
+			continue
+		}
+		rows[row] = struct{}{}
+		builder = builder.Add(stmt.Query)
+		types = append(types, stmt.Types...)
+		values = append(values, stmt.Values...)
+		conditions = append(conditions, stmt.Conditions...)
+	}
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:      builder,
+			Types:      types,
+			QueryType:  typedef.BatchIfStatementType,
+			Conditions: conditions,
+		},
 		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
 		Values:          values,
 	}, nil
@@ -100,11 +594,11 @@ func genInsertStmt(
 ) (*typedef.Stmt, error) {
 	values := make(typedef.Values, 0, t.PartitionKeys.LenValues()+t.ClusteringKeys.LenValues()+t.Columns.LenValues())
 	values = values.CopyFrom(valuesWithToken.Value)
-	for _, ck := range t.ClusteringKeys {
-		values = append(values, ck.Type.GenValue(r, p)...)
-	}
+	values = genClusteringValues(t, valuesWithToken, r, p, values)
 	for _, col := range t.Columns {
-		values = append(values, col.Type.GenValue(r, p)...)
+		colValues := genColumnValue(col, r, p)
+		rememberViewKey(t, valuesWithToken, col, colValues)
+		values = append(values, colValues...)
 	}
 	cacheType := typedef.CacheInsert
 	if useLWT {
@@ -118,6 +612,135 @@ func genInsertStmt(
 	}, nil
 }
 
+// clusteringValues formats the clustering key values a single row
+// statement was generated with.
+func clusteringValues(t *typedef.Table, stmt *typedef.Stmt) string {
+	pkLen := len(stmt.ValuesWithToken[0].Value)
+	ckLen := t.ClusteringKeys.LenValues()
+	var ckValues typedef.Values
+	switch stmt.QueryType {
+	case typedef.InsertStatementType, typedef.InsertIfNotExistsStatementType:
+		ckValues = stmt.Values[pkLen : pkLen+ckLen]
+	case typedef.UpdateIfStatementType:
+		// The condition value follows the clustering key.
+		ckValues = stmt.Values[len(stmt.Values)-ckLen-1 : len(stmt.Values)-1]
+	default:
+		ckValues = stmt.Values[len(stmt.Values)-ckLen:]
+	}
+	return fmt.Sprintf("%v", ckValues)
+}
+
+// genBatchStmt generates a batch of mutations. Logged batches group the
+// mutations of one partition, unlogged and counter batches span several.
+func genBatchStmt(
+	s *typedef.Schema,
+	t *typedef.Table,
+	g generators.GeneratorInterface,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+	deletes bool,
+) (*typedef.Stmt, error) {
+	kind := typedef.BatchStatementType
+	switch {
+	case t.IsCounterTable():
+		kind = typedef.CounterBatchStatementType
+	case r.Intn(2) == 0:
+		kind = typedef.UnloggedBatchStatementType
+	}
+	var timestamp int64
+	if kind != typedef.CounterBatchStatementType && r.Intn(2) == 0 {
+		// Counter updates can not carry a timestamp.
+		timestamp = time.Now().UnixNano() / 1000
+	}
+	return genBatch(s, t, g, valuesWithToken, r, p, kind, deletes, timestamp)
+}
+
+// genBatch generates a batch of the given kind, with USING TIMESTAMP unless
+// timestamp is 0.
+func genBatch(
+	s *typedef.Schema,
+	t *typedef.Table,
+	g generators.GeneratorInterface,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+	kind typedef.StatementType,
+	deletes bool,
+	timestamp int64,
+) (*typedef.Stmt, error) {
+	partitions := []*typedef.ValueWithToken{valuesWithToken}
+	if kind != typedef.BatchStatementType || len(t.ClusteringKeys) == 0 {
+		for i, count := 0, 1+r.Intn(3); i < count; i++ {
+			v := g.Get()
+			if v == nil {
+				break
+			}
+			partitions = append(partitions, v)
+		}
+	}
+
+	builder := qb.Batch()
+	var types typedef.Types
+	var values typedef.Values
+	switch kind {
+	case typedef.UnloggedBatchStatementType:
+		builder = builder.UnLogged()
+	case typedef.CounterBatchStatementType:
+		builder = builder.Counter()
+	}
+	if timestamp != 0 {
+		builder = builder.TimestampNamed("ts")
+		types = append(types, typedef.TYPE_BIGINT)
+		values = append(values, timestamp)
+	}
+
+	// Rows are written at most once, so that the outcome of the batch does
+	// not depend on how the writes of a row are merged.
+	rows := make(map[string]struct{})
+	for i, count := 0, 2+r.Intn(3); i < count; i++ {
+		partition := partitions[i%len(partitions)]
+		var stmt *typedef.Stmt
+		var err error
+		n := 2
+		if deletes {
+			n++
+		}
+		switch kind := r.Intn(n); {
+		case t.IsCounterTable():
+			stmt, err = genUpdateStmt(s, t, partition, r, p)
+		case kind == 0 || len(t.Columns) == 0:
+			stmt, err = genInsertStmt(s, t, partition, r, p, false)
+		case kind == 1:
+			stmt, err = genUpdateStmt(s, t, partition, r, p)
+		default:
+			stmt, err = genDeleteRows(s, t, partition, r, p)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if stmt.QueryType != typedef.DeleteStatementType && !t.IsCounterTable() {
+			row := fmt.Sprintf("%d %s", partition.Token, clusteringValues(t, stmt))
+			if _, ok := rows[row]; ok {
+				continue
+			}
+			rows[row] = struct{}{}
+		}
+		builder = builder.Add(stmt.Query)
+		types = append(types, stmt.Types...)
+		values = append(values, stmt.Values...)
+	}
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:     builder,
+			Types:     types,
+			QueryType: kind,
+		},
+		ValuesWithToken: partitions,
+		Values:          values,
+	}, nil
+}
+
 func genInsertJSONStmt(
 	s *typedef.Schema,
 	table *typedef.Table,
@@ -145,7 +768,7 @@ func genInsertJSONStmt(
 			panic(fmt.Sprintf("unknown type: %s", t.Name()))
 		}
 	}
-	values = table.ClusteringKeys.ToJSONMap(values, r, p)
+	values = table.ClusteringKeys.ToJSONMap(values, r, p.ForKeys())
 	values = table.Columns.ToJSONMap(values, r, p)
 
 	jsonString, err := json.Marshal(values)
@@ -169,9 +792,13 @@ func genDeleteRows(_ *typedef.Schema, t *typedef.Table, valuesWithToken *typedef
 	stmtCache := t.GetQueryCache(typedef.CacheDelete)
 	values := valuesWithToken.Value.Copy()
 	if len(t.ClusteringKeys) > 0 {
-		ck := t.ClusteringKeys[0]
-		values = appendValue(ck.Type, r, p, values)
-		values = appendValue(ck.Type, r, p, values)
+		if _, lower, upper, ok := knownBounds(t, valuesWithToken.Rows, 0, 1, r); ok {
+			values = append(append(values, lower...), upper...)
+		} else {
+			ck, kp := t.ClusteringKeys[0], p.ForKeys()
+			values = appendValue(ck.Type, r, kp, values)
+			values = appendValue(ck.Type, r, kp, values)
+		}
 	}
 	return &typedef.Stmt{
 		StmtCache:       stmtCache,
@@ -180,6 +807,196 @@ func genDeleteRows(_ *typedef.Schema, t *typedef.Table, valuesWithToken *typedef
 	}, nil
 }
 
+// genDeleteStmt generates a delete of the whole partition, of a single row,
+// of some of the columns or of a map element of a row, or of a clustering
+// range of the partition.
+func genDeleteStmt(s *typedef.Schema, t *typedef.Table, valuesWithToken *typedef.ValueWithToken, r *rand.Rand, p *typedef.PartitionRangeConfig) *typedef.Stmt {
+	switch r.Intn(5) {
+	case 0:
+		return genDeletePartition(s, t, valuesWithToken)
+	case 1:
+		if len(t.ClusteringKeys) > 0 {
+			return genDeleteFromRow(t, qb.Delete(s.Keyspace.Name+"."+t.Name), nil, nil, valuesWithToken, r, p, typedef.DeleteRowStatementType)
+		}
+	case 2:
+		if stmt := genDeleteColumns(s, t, valuesWithToken, r, p); stmt != nil {
+			return stmt
+		}
+	case 3:
+		if stmt := genDeleteElement(s, t, valuesWithToken, r, p); stmt != nil {
+			return stmt
+		}
+	}
+	if len(t.ClusteringKeys) == 0 {
+		return genDeletePartition(s, t, valuesWithToken)
+	}
+	return genDeleteRange(s, t, valuesWithToken, r, p)
+}
+
+func genDeletePartition(s *typedef.Schema, t *typedef.Table, valuesWithToken *typedef.ValueWithToken) *typedef.Stmt {
+	builder := qb.Delete(s.Keyspace.Name + "." + t.Name)
+	var types typedef.Types
+	for _, pk := range t.PartitionKeys {
+		builder = builder.Where(qb.Eq(pk.Name))
+		types = append(types, pk.Type)
+	}
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:     builder,
+			Types:     types,
+			QueryType: typedef.DeletePartitionStatementType,
+		},
+		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Values:          valuesWithToken.Value.Copy(),
+	}
+}
+
+// genDeleteColumns deletes a random subset of the columns of a single row.
+// Counter tables are left alone, counters can not be written after they are
+// deleted.
+func genDeleteColumns(s *typedef.Schema, t *typedef.Table, valuesWithToken *typedef.ValueWithToken, r *rand.Rand, p *typedef.PartitionRangeConfig) *typedef.Stmt {
+	if len(t.Columns) == 0 || len(t.Columns.NonCounters()) != len(t.Columns) {
+		return nil
+	}
+	first := r.Intn(len(t.Columns))
+	var columns []string
+	for i, col := range t.Columns {
+		if i == first || r.Intn(2) == 0 {
+			columns = append(columns, col.Name)
+		}
+	}
+	builder := qb.Delete(s.Keyspace.Name + "." + t.Name).Columns(columns...)
+	return genDeleteFromRow(t, builder, nil, nil, valuesWithToken, r, p, typedef.DeleteColumnsStatementType)
+}
+
+// genDeleteElement deletes a single element of a non frozen map of a row.
+func genDeleteElement(s *typedef.Schema, t *typedef.Table, valuesWithToken *typedef.ValueWithToken, r *rand.Rand, p *typedef.PartitionRangeConfig) *typedef.Stmt {
+	var maps typedef.Columns
+	for _, col := range t.Columns {
+		if typ, ok := col.Type.(*typedef.MapType); ok && !typ.Frozen {
+			maps = append(maps, col)
+		}
+	}
+	if len(maps) == 0 {
+		return nil
+	}
+	col := maps[r.Intn(len(maps))]
+	keyType := col.Type.(*typedef.MapType).KeyType
+	builder := qb.Delete(s.Keyspace.Name + "." + t.Name).Columns(col.Name + "[?]")
+	types := typedef.Types{keyType}
+	return genDeleteFromRow(t, builder, types, keyType.GenValue(r, p), valuesWithToken, r, p, typedef.DeleteElementStatementType)
+}
+
+// genDeleteFromRow restricts the delete to a single row of the partition.
+func genDeleteFromRow(
+	t *typedef.Table,
+	builder *qb.DeleteBuilder,
+	types typedef.Types,
+	values typedef.Values,
+	valuesWithToken *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+	queryType typedef.StatementType,
+) *typedef.Stmt {
+	for _, pk := range t.PartitionKeys {
+		builder = builder.Where(qb.Eq(pk.Name))
+		types = append(types, pk.Type)
+	}
+	values = append(values, valuesWithToken.Value...)
+	for _, ck := range t.ClusteringKeys {
+		builder = builder.Where(qb.Eq(ck.Name))
+		types = append(types, ck.Type)
+	}
+	values = genClusteringValues(t, valuesWithToken, r, p, values)
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:     builder,
+			Types:     types,
+			QueryType: queryType,
+		},
+		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Values:          values,
+	}
+}
+
+// genDeleteRange deletes a clustering range of the partition. The range is
+// either a slice of a single clustering key that follows equality
+// restrictions on the ones before it, or a slice of a multi-column prefix of
+// the clustering key. Each bound is open or closed, or missing. If rows of
+// the partition are known, the range brackets some of them.
+func genDeleteRange(s *typedef.Schema, t *typedef.Table, valuesWithToken *typedef.ValueWithToken, r *rand.Rand, p *typedef.PartitionRangeConfig) *typedef.Stmt {
+	builder := qb.Delete(s.Keyspace.Name + "." + t.Name)
+	var types typedef.Types
+	for _, pk := range t.PartitionKeys {
+		builder = builder.Where(qb.Eq(pk.Name))
+		types = append(types, pk.Type)
+	}
+	values := valuesWithToken.Value.Copy()
+
+	var slice typedef.Columns
+	eq := 0
+	if r.Intn(2) == 0 {
+		eq = r.Intn(len(t.ClusteringKeys))
+		slice = t.ClusteringKeys[eq : eq+1]
+	} else {
+		slice = t.ClusteringKeys[:1+r.Intn(len(t.ClusteringKeys))]
+	}
+	prefix, lower, upper, known := knownBounds(t, valuesWithToken.Rows, eq, len(slice), r)
+	p = p.ForKeys()
+	for _, ck := range t.ClusteringKeys[:eq] {
+		builder = builder.Where(qb.Eq(ck.Name))
+		types = append(types, ck.Type)
+		if !known {
+			values = appendValue(ck.Type, r, p, values)
+		}
+	}
+	values = append(values, prefix...)
+	column, marker := slice[0].Name, "?"
+	if len(slice) > 1 {
+		names := make([]string, len(slice))
+		for i, ck := range slice {
+			names[i] = ck.Name
+		}
+		column = "(" + strings.Join(names, ",") + ")"
+		marker = "(" + strings.TrimSuffix(strings.Repeat("?,", len(slice)), ",") + ")"
+	}
+	bounds := []struct {
+		open, closed func(string, string) qb.Cmp
+		known        typedef.Values
+	}{
+		{qb.GtLit, qb.GtOrEqLit, lower},
+		{qb.LtLit, qb.LtOrEqLit, upper},
+	}
+	// Either one of the bounds or both of them.
+	skip := r.Intn(3)
+	for i, bound := range bounds {
+		if i == skip {
+			continue
+		}
+		cmp := bound.open
+		if known || r.Intn(2) == 0 {
+			cmp = bound.closed
+		}
+		builder = builder.Where(cmp(column, marker))
+		for _, ck := range slice {
+			types = append(types, ck.Type)
+			if !known {
+				values = appendValue(ck.Type, r, p, values)
+			}
+		}
+		values = append(values, bound.known...)
+	}
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:     builder,
+			Types:     types,
+			QueryType: typedef.DeleteRangeStatementType,
+		},
+		ValuesWithToken: []*typedef.ValueWithToken{valuesWithToken},
+		Values:          values,
+	}
+}
+
 func convertForJSON(vType typedef.Type, value interface{}) interface{} {
 	switch vType {
 	case typedef.TYPE_BLOB:
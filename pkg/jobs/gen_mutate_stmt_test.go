@@ -18,8 +18,13 @@ package jobs
 import (
 	"path"
 	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"golang.org/x/exp/rand"
 
 	"github.com/scylladb/gemini/pkg/testutils"
+	"github.com/scylladb/gemini/pkg/typedef"
 	"github.com/scylladb/gemini/pkg/utils"
 )
 
@@ -66,6 +71,175 @@ func TestGenDeleteRows(t *testing.T) {
 	})
 }
 
+func TestGenDeleteStmt(t *testing.T) {
+	RunStmtTest[results](t, path.Join(mutateDataPath, "delete_kinds.json"), genDeleteKindsStmtCases, func(t *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+		schema, gen, _ := testutils.GetAllForTestStmt(t, caseName)
+		prc := schema.Config.GetPartitionRangeConfig()
+		// The kinds of deletes are chosen at random, a seeded source covers them all.
+		rnd := rand.New(rand.NewSource(1))
+		var received results
+		for i := 0; i < 20; i++ {
+			stmt := genDeleteStmt(schema, schema.Tables[0], gen.Get(), rnd, &prc)
+			validateStmt(t, stmt, nil)
+			// The keys of deleted elements are bound before USING.
+			received = append(received, convertStmtsToResults(stmt)...)
+			received = append(received, convertStmtsToResults(withUsing(stmt, 0, 1600000000000000))...)
+		}
+		expected.CompareOrStore(t, caseName, received)
+	})
+}
+
+func TestGenLWTStmt(t *testing.T) {
+	RunStmtTest[results](t, path.Join(mutateDataPath, "lwt.json"), genLWTStmtCases, func(t *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+		schema, gen, rnd := testutils.GetAllForTestStmt(t, caseName)
+		prc := schema.Config.GetPartitionRangeConfig()
+		table := schema.Tables[0]
+		stmts := []*typedef.Stmt{
+			genDeleteIfExistsStmt(table, gen.Get(), rnd, &prc),
+		}
+		if len(table.Columns) > 0 {
+			stmts = append(stmts,
+				genUpdateStmtFromCache(table, typedef.CacheUpdateIfExists, gen.Get(), rnd, &prc),
+				genUpdateIfStmt(schema, table, gen.Get(), rnd, &prc))
+		}
+		if len(table.ClusteringKeys) > 0 {
+			stmt, err := genLWTBatchStmt(schema, table, gen.Get(), rnd, &prc, true)
+			validateStmt(t, stmt, err)
+			stmts = append(stmts, stmt)
+		}
+		var received results
+		for _, stmt := range stmts {
+			validateStmt(t, stmt, nil)
+			if !stmt.QueryType.IsLWT() {
+				t.Errorf("expected a conditional statement, got %s", stmt.QueryType.ToString())
+			}
+			received = append(received, convertStmtsToResults(stmt)...)
+		}
+		expected.CompareOrStore(t, caseName, received)
+	})
+}
+
+func TestGenPartialUpdateStmt(t *testing.T) {
+	RunStmtTest[results](t, path.Join(mutateDataPath, "partial_update.json"), genPartialUpdateStmtCases, func(t *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+		schema, gen, _ := testutils.GetAllForTestStmt(t, caseName)
+		prc := schema.Config.GetPartitionRangeConfig()
+		// The operations are chosen at random, a seeded source covers them all.
+		rnd := rand.New(rand.NewSource(1))
+		var received results
+		for i := 0; i < 12; i++ {
+			stmt := genPartialUpdateStmt(schema, schema.Tables[0], gen.Get(), rnd, &prc)
+			validateStmt(t, stmt, nil)
+			received = append(received, convertStmtsToResults(stmt)...)
+		}
+		expected.CompareOrStore(t, caseName, received)
+	})
+}
+
+func TestGenUsingStmt(t *testing.T) {
+	RunStmtTest[results](t, path.Join(mutateDataPath, "using.json"), genUsingStmtCases, func(t *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+		schema, gen, rnd := testutils.GetAllForTestStmt(t, caseName)
+		prc := schema.Config.GetPartitionRangeConfig()
+		table := schema.Tables[0]
+		var stmts []*typedef.Stmt
+		insert, err := genInsertStmt(schema, table, gen.Get(), rnd, &prc, false)
+		validateStmt(t, insert, err)
+		stmts = append(stmts, withUsing(insert, 60, 0), withUsing(insert, 60, 1600000000000000))
+		insertJSON, err := genInsertJSONStmt(schema, table, gen.Get(), rnd, &prc)
+		validateStmt(t, insertJSON, err)
+		stmts = append(stmts, withUsing(insertJSON, 0, 1600000000000000))
+		if len(table.Columns) > 0 {
+			update, updateErr := genUpdateStmt(schema, table, gen.Get(), rnd, &prc)
+			validateStmt(t, update, updateErr)
+			stmts = append(stmts, withUsing(update, 60, 1600000000000000))
+		}
+		deleteRows, err := genDeleteRows(schema, table, gen.Get(), rnd, &prc)
+		validateStmt(t, deleteRows, err)
+		stmts = append(stmts, withUsing(deleteRows, 60, 1600000000000000))
+		var received results
+		for _, stmt := range stmts {
+			validateStmt(t, stmt, nil)
+			received = append(received, convertStmtsToResults(stmt)...)
+		}
+		expected.CompareOrStore(t, caseName, received)
+	})
+}
+
+func TestGenWriteTimestamp(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(1))
+	now := time.Date(2020, 2, 1, 0, 0, 1, 500000000, time.UTC)
+	collisions := 0
+	for i := 0; i < 100; i++ {
+		ts := genWriteTimestamp(r, now)
+		if ts > now.UnixMicro() || ts <= now.Add(-time.Second).UnixMicro() {
+			t.Fatalf("timestamp %d is not within a second before %d", ts, now.UnixMicro())
+		}
+		if ts == now.Truncate(time.Second).UnixMicro() {
+			collisions++
+		}
+	}
+	if collisions == 0 {
+		t.Error("expected some of the timestamps to collide")
+	}
+}
+
+func TestGenColumnValue(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(1))
+	p := &typedef.PartitionRangeConfig{MaxStringLength: 10}
+	list := &typedef.ColumnDef{Name: "col0", Type: &typedef.BagType{ComplexType: typedef.TYPE_LIST, ValueType: typedef.TYPE_INT}}
+	tuple := &typedef.ColumnDef{Name: "col1", Type: &typedef.TupleType{ValueTypes: []typedef.Type{typedef.TYPE_INT, typedef.TYPE_TEXT}}}
+	var nulls, unsets, empties int
+	for i := 0; i < 1000; i++ {
+		switch v := genColumnValue(list, r, p)[0].(type) {
+		case nil:
+			nulls++
+		case []interface{}:
+			if len(v) == 0 {
+				empties++
+			}
+		default:
+			if v == gocql.UnsetValue {
+				unsets++
+			}
+		}
+		for _, v := range genColumnValue(tuple, r, p) {
+			if v == nil || v == gocql.UnsetValue {
+				t.Fatalf("expected the tuple elements to have values, got %v", v)
+			}
+		}
+	}
+	if nulls == 0 || unsets == 0 || empties == 0 {
+		t.Errorf("expected nulls, unset values and empty lists, got %d, %d and %d", nulls, unsets, empties)
+	}
+}
+
+func TestGenBatchStmt(t *testing.T) {
+	RunStmtTest[results](t, path.Join(mutateDataPath, "batch.json"), genBatchStmtCases, func(t *testing.T, caseName string, expected *testutils.ExpectedStore[results]) {
+		schema, gen, rnd := testutils.GetAllForTestStmt(t, caseName)
+		prc := schema.Config.GetPartitionRangeConfig()
+		table := schema.Tables[0]
+		kinds := []typedef.StatementType{typedef.BatchStatementType, typedef.UnloggedBatchStatementType}
+		timestamps := []int64{0, 1600000000000000}
+		if table.IsCounterTable() {
+			kinds = []typedef.StatementType{typedef.CounterBatchStatementType}
+			timestamps = []int64{0}
+		}
+		var received results
+		for _, kind := range kinds {
+			for _, timestamp := range timestamps {
+				stmt, err := genBatch(schema, table, gen, gen.Get(), rnd, &prc, kind, true, timestamp)
+				validateStmt(t, stmt, err)
+				if stmt.QueryType != kind {
+					t.Errorf("expected a %s, got %s", kind.ToString(), stmt.QueryType.ToString())
+				}
+				received = append(received, convertStmtsToResults(stmt)...)
+			}
+		}
+		expected.CompareOrStore(t, caseName, received)
+	})
+}
+
 func BenchmarkGenInsertStmt(t *testing.B) {
 	utils.SetUnderTest()
 	for idx := range genInsertStmtCases {
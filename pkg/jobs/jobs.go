@@ -19,9 +19,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"sync"
 	"time"
 
+	"github.com/scylladb/gemini/pkg/builders"
 	"github.com/scylladb/gemini/pkg/generators"
+	"github.com/scylladb/gemini/pkg/querycache"
 	"github.com/scylladb/gemini/pkg/store"
 	"github.com/scylladb/gemini/pkg/typedef"
 
@@ -72,6 +76,8 @@ type job struct {
 		*typedef.PartitionRangeConfig,
 		*generators.Generator,
 		*status.GlobalStatus,
+		*tableCreator,
+		*failures,
 		*zap.Logger,
 		*stop.Flag,
 		bool,
@@ -80,6 +86,14 @@ type job struct {
 	name string
 }
 
+// tableCreator adds new tables to the schema while the jobs run. Every new
+// table gets its own generator and the jobs of the list are started on it.
+type tableCreator struct {
+	newGenerator generators.Factory
+	start        func(*typedef.Table, *generators.Generator)
+	mu           sync.Mutex
+}
+
 func ListFromMode(mode string, duration time.Duration, workers uint64) List {
 	jobs := make([]job, 0, 2)
 	name := "work cycle"
@@ -108,7 +122,8 @@ func (l List) Run(
 	schemaConfig typedef.SchemaConfig,
 	s store.Store,
 	pump <-chan time.Duration,
-	generators []*generators.Generator,
+	gens []*generators.Generator,
+	newGenerator generators.Factory,
 	globalStatus *status.GlobalStatus,
 	logger *zap.Logger,
 	seed uint64,
@@ -124,21 +139,37 @@ func (l List) Run(
 	})
 
 	partitionRangeConfig := schemaConfig.GetPartitionRangeConfig()
-	logger.Info("start jobs")
-	for j := range schema.Tables {
-		gen := generators[j]
-		table := schema.Tables[j]
+	tc := &tableCreator{newGenerator: newGenerator}
+	pending := &failures{}
+	tc.start = func(table *typedef.Table, gen *generators.Generator) {
 		for i := 0; i < int(l.workers); i++ {
 			for idx := range l.jobs {
 				jobF := l.jobs[idx].function
-				r := rand.New(rand.NewSource(seed))
+				r := rand.New(rand.NewSource(jobSeed(seed, table.Name, i, idx)))
 				g.Go(func() error {
-					return jobF(gCtx, pump, schema, schemaConfig, table, s, r, &partitionRangeConfig, gen, globalStatus, logger, stopFlag, failFast, verbose)
+					return jobF(gCtx, pump, schema, schemaConfig, table, s, r, &partitionRangeConfig, gen, globalStatus, tc, pending, logger, stopFlag, failFast, verbose)
 				})
 			}
 		}
 	}
-	return g.Wait()
+	logger.Info("start jobs")
+	// The started jobs can add tables to the schema right away.
+	tables := schema.GetTables()
+	for j := range tables {
+		tc.start(tables[j], gens[j])
+	}
+	err := g.Wait()
+	pending.minimize(ctx, schema, &schemaConfig, s, logger)
+	return err
+}
+
+// jobSeed derives the seed of a job from the seed of the run, the table and
+// the worker it runs for, jobs do not draw the same sequence and do not, for
+// example, all alter their tables at the same step.
+func jobSeed(seed uint64, table string, worker, job int) uint64 {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%d/%s/%d/%d", seed, table, worker, job)
+	return h.Sum64()
 }
 
 // mutationJob continuously applies mutations against the database
@@ -154,6 +185,8 @@ func mutationJob(
 	p *typedef.PartitionRangeConfig,
 	g *generators.Generator,
 	globalStatus *status.GlobalStatus,
+	tc *tableCreator,
+	_ *failures,
 	logger *zap.Logger,
 	stopFlag *stop.Flag,
 	failFast, verbose bool,
@@ -176,9 +209,12 @@ func mutationJob(
 			time.Sleep(hb)
 		}
 		ind := r.Intn(1000000)
-		if ind%100000 == 0 {
+		switch {
+		case ind%100000 == 0 && r.Intn(5) == 0:
+			_ = createTable(ctx, schema, schemaConfig, s, r, tc, globalStatus, logger)
+		case ind%100000 == 0:
 			_ = ddl(ctx, schema, schemaConfig, table, s, r, p, globalStatus, logger, verbose)
-		} else {
+		default:
 			_ = mutation(ctx, schema, schemaConfig, table, s, r, p, g, globalStatus, true, logger)
 		}
 		if failFast && globalStatus.HasErrors() {
@@ -201,6 +237,8 @@ func validationJob(
 	p *typedef.PartitionRangeConfig,
 	g *generators.Generator,
 	globalStatus *status.GlobalStatus,
+	_ *tableCreator,
+	pending *failures,
 	logger *zap.Logger,
 	stopFlag *stop.Flag,
 	failFast, _ bool,
@@ -222,12 +260,21 @@ func validationJob(
 		case hb := <-pump:
 			time.Sleep(hb)
 		}
+		// DDL waits for the checks in flight, a check never reads an index,
+		// a view or a column that was dropped after it was generated.
+		table.RLock()
 		stmt := GenCheckStmt(schema, table, g, r, p)
 		if stmt == nil {
+			table.RUnlock()
 			logger.Info("Validation. No statement generated from GenCheckStmt.")
 			continue
 		}
 		err := validation(ctx, schemaConfig, table, s, stmt, logger)
+		table.RUnlock()
+		var jobErr *joberror.JobError
+		if err != nil && !errors.Is(err, context.Canceled) {
+			jobErr = validationError(schemaConfig, table, g, stmt, err, pending)
+		}
 		if stmt.ValuesWithToken != nil {
 			for _, token := range stmt.ValuesWithToken {
 				g.ReleaseToken(token.Token)
@@ -239,12 +286,7 @@ func validationJob(
 		case errors.Is(err, context.Canceled):
 			return nil
 		default:
-			globalStatus.AddReadError(&joberror.JobError{
-				Timestamp: time.Now(),
-				StmtType:  stmt.QueryType.ToString(),
-				Message:   "Validation failed: " + err.Error(),
-				Query:     stmt.PrettyCQL(),
-			})
+			globalStatus.AddReadError(jobErr)
 		}
 
 		if failFast && globalStatus.HasErrors() {
@@ -254,6 +296,39 @@ func validationJob(
 	}
 }
 
+// validationError describes a failed check together with the recent
+// mutations of the partitions it read. The failure is queued for
+// minimization when the reproducers are asked for.
+func validationError(
+	schemaConfig *typedef.SchemaConfig,
+	table *typedef.Table,
+	g *generators.Generator,
+	stmt *typedef.Stmt,
+	err error,
+	pending *failures,
+) *joberror.JobError {
+	history, evicted := partitionHistory(g, stmt)
+	jobErr := &joberror.JobError{
+		Timestamp:                 time.Now(),
+		StmtType:                  stmt.QueryType.ToString(),
+		Message:                   "Validation failed: " + err.Error(),
+		Query:                     stmt.PrettyCQL(),
+		PartitionHistory:          make([]string, 0, len(history)),
+		PartitionHistoryTruncated: evicted,
+	}
+	for _, mutation := range history {
+		jobErr.PartitionHistory = append(jobErr.PartitionHistory, mutation.PrettyCQL())
+	}
+	var diffErr *joberror.RowDiffError
+	if errors.As(err, &diffErr) {
+		jobErr.RowDiff = diffErr.Diff
+	}
+	if schemaConfig.MinimizeFailures {
+		pending.add(jobErr, table, stmt, err, history)
+	}
+	return jobErr
+}
+
 // warmupJob continuously applies mutations against the database
 // for as long as the pump is active or the supplied duration expires.
 func warmupJob(
@@ -267,6 +342,8 @@ func warmupJob(
 	p *typedef.PartitionRangeConfig,
 	g *generators.Generator,
 	globalStatus *status.GlobalStatus,
+	_ *tableCreator,
+	_ *failures,
 	logger *zap.Logger,
 	stopFlag *stop.Flag,
 	failFast, _ bool,
@@ -307,10 +384,6 @@ func ddl(
 		logger.Debug("ddl statements disabled")
 		return nil
 	}
-	if len(table.MaterializedViews) > 0 {
-		// Scylla does not allow changing the DDL of a table with materialized views.
-		return nil
-	}
 	table.Lock()
 	defer table.Unlock()
 	ddlStmts, err := GenDDLStmt(schema, table, r, p, sc)
@@ -343,6 +416,18 @@ func ddl(
 		}
 		globalStatus.WriteOps.Add(1)
 	}
+	if ddlStmts.Builds != "" {
+		// The table already has rows, the clusters build the new view or
+		// index in the background. The table stays locked until both built
+		// it, no check reads it half built.
+		index := ddlStmts.QueryType == typedef.CreateIndexStatementType
+		if err = s.AwaitBuilt(ctx, schema.Keyspace.Name, ddlStmts.Builds, index); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			logger.Warn("unable to wait for the build, it is read right away", zap.String("name", ddlStmts.Builds), zap.Error(err))
+		}
+	}
 	ddlStmts.PostStmtHook()
 	if verbose {
 		jsonSchema, _ := json.MarshalIndent(schema, "", "    ")
@@ -351,6 +436,60 @@ func ddl(
 	return nil
 }
 
+// createTable adds a new table to the schema while it has fewer tables than
+// the maximum, creates it on both clusters and starts the jobs on it.
+func createTable(
+	ctx context.Context,
+	schema *typedef.Schema,
+	sc *typedef.SchemaConfig,
+	s store.Store,
+	r *rand.Rand,
+	tc *tableCreator,
+	globalStatus *status.GlobalStatus,
+	logger *zap.Logger,
+) error {
+	if sc.CQLFeature != typedef.CQL_FEATURE_ALL || tc.newGenerator == nil {
+		return nil
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tables := len(schema.GetTables())
+	if tables >= sc.GetMaxTables() {
+		return nil
+	}
+	table := generators.GenTable(*sc, fmt.Sprintf("table%d", tables+1), r)
+	table.Init(schema, querycache.New(schema))
+	tableSchema := &typedef.Schema{Keyspace: schema.Keyspace, Tables: []*typedef.Table{table}}
+	for _, stmt := range generators.GetCreateSchema(tableSchema) {
+		if w := logger.Check(zap.DebugLevel, "ddl statement"); w != nil {
+			w.Write(zap.String("pretty_cql", stmt))
+		}
+		if err := s.Mutate(ctx, &builders.AlterTableBuilder{Stmt: stmt}); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			globalStatus.AddWriteError(&joberror.JobError{
+				Timestamp: time.Now(),
+				StmtType:  typedef.CreateTableStatementType.ToString(),
+				Message:   "DDL failed: " + err.Error(),
+				Query:     stmt,
+			})
+			return err
+		}
+		globalStatus.WriteOps.Add(1)
+	}
+	gen, err := tc.newGenerator(table)
+	if err != nil {
+		logger.Error("Failed! Generator creation for a new table failed", zap.String("table", table.Name), zap.Error(err))
+		globalStatus.WriteErrors.Add(1)
+		return err
+	}
+	schema.AddTable(table)
+	logger.Info("table created", zap.String("table", table.Name))
+	tc.start(table, gen)
+	return nil
+}
+
 func mutation(
 	ctx context.Context,
 	schema *typedef.Schema,
@@ -364,6 +503,10 @@ func mutation(
 	deletes bool,
 	logger *zap.Logger,
 ) error {
+	// DDL waits for the mutations in flight, both clusters apply a mutation
+	// to the same schema and a TRUNCATE never falls in between.
+	table.RLock()
+	defer table.RUnlock()
 	mutateStmt, err := GenMutateStmt(schema, table, g, r, p, deletes)
 	if err != nil {
 		logger.Error("Failed! Mutation statement generation failed", zap.Error(err))
@@ -382,18 +525,39 @@ func mutation(
 	if w := logger.Check(zap.DebugLevel, "mutation statement"); w != nil {
 		w.Write(zap.String("pretty_cql", mutateStmt.PrettyCQL()))
 	}
-	if err = s.Mutate(ctx, mutateQuery, mutateValues...); err != nil {
-		if errors.Is(err, context.Canceled) {
-			return nil
-		}
+	if mutateStmt.QueryType.IsLWT() {
+		err = s.MutateLWT(ctx, table, mutateQuery, mutateStmt.Conditions, mutateValues...)
+	} else {
+		err = s.Mutate(ctx, mutateQuery, mutateValues...)
+	}
+	var diffErr *joberror.RowDiffError
+	switch {
+	case err == nil:
+		globalStatus.WriteOps.Add(1)
+		g.RecordMutation(mutateStmt)
+		g.GiveOlds(mutateStmt.ValuesWithToken)
+	case errors.Is(err, context.Canceled):
+		return nil
+	case errors.As(err, &diffErr):
+		// Both clusters applied the statement but with a different outcome.
+		globalStatus.AddWriteError(&joberror.JobError{
+			Timestamp: time.Now(),
+			StmtType:  mutateStmt.QueryType.ToString(),
+			Message:   "Mutation outcome differs: " + err.Error(),
+			Query:     mutateStmt.PrettyCQL(),
+			RowDiff:   diffErr.Diff,
+		})
+		g.RecordMutation(mutateStmt)
+		g.GiveOlds(mutateStmt.ValuesWithToken)
+	default:
 		globalStatus.AddWriteError(&joberror.JobError{
 			Timestamp: time.Now(),
 			StmtType:  mutateStmt.QueryType.ToString(),
 			Message:   "Mutation failed: " + err.Error(),
 			Query:     mutateStmt.PrettyCQL(),
 		})
-	} else {
-		globalStatus.WriteOps.Add(1)
+		// The partitions stay known, half of them were taken from the old
+		// values and are validated and mutated again.
 		g.GiveOlds(mutateStmt.ValuesWithToken)
 	}
 	return nil
@@ -420,14 +584,24 @@ func validation(
 		}
 		delay = sc.AsyncObjectStabilizationDelay
 	}
+	if sc.MaxTTL > 0 && maxAttempts < 2 {
+		// Cells can expire between the reads of the two clusters, a second
+		// later they expired on both.
+		maxAttempts = 2
+		delay = time.Second
+	}
 
 	var lastErr, err error
+	var stats store.CheckStats
 	attempt := 1
 	for {
 		lastErr = err
-		err = s.Check(ctx, table, stmt.Query, attempt == maxAttempts, stmt.Values...)
+		stats, err = s.Check(ctx, table, stmt.Query, stmt.Read, attempt == maxAttempts, stmt.Values...)
 
 		if err == nil {
+			if w := logger.Check(zap.DebugLevel, "validation compared"); w != nil {
+				w.Write(zap.Int("rows", stats.Rows), zap.Int("oracle_pages", stats.OraclePages), zap.Int("test_pages", stats.TestPages))
+			}
 			if attempt > 1 {
 				logger.Info(fmt.Sprintf("Validation successfully completed on %d attempt.", attempt))
 			}
@@ -461,6 +635,8 @@ func validation(
 	} else {
 		logger.Info(fmt.Sprintf("Validation failed. Error: %s", err))
 	}
+	logger.Info("validation diverged",
+		zap.Int("equal_rows", stats.Rows), zap.Int("oracle_pages", stats.OraclePages), zap.Int("test_pages", stats.TestPages))
 
 	return err
 }
@@ -0,0 +1,139 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/scylladb/gocqlx/v2/qb"
+	"go.uber.org/zap"
+	"golang.org/x/exp/rand"
+
+	"github.com/scylladb/gemini/pkg/generators"
+	"github.com/scylladb/gemini/pkg/status"
+	"github.com/scylladb/gemini/pkg/store"
+	"github.com/scylladb/gemini/pkg/testutils"
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+// recordingStore records the statements that are applied to it.
+type recordingStore struct {
+	queries []string
+	mu      sync.Mutex
+}
+
+func (rs *recordingStore) Create(ctx context.Context, builder, _ qb.Builder) error {
+	return rs.Mutate(ctx, builder)
+}
+
+func (rs *recordingStore) Mutate(_ context.Context, builder qb.Builder, _ ...interface{}) error {
+	query, _ := builder.ToCql()
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.queries = append(rs.queries, query)
+	return nil
+}
+
+func (rs *recordingStore) MutateLWT(ctx context.Context, _ *typedef.Table, builder qb.Builder, _ []string, values ...interface{}) error {
+	return rs.Mutate(ctx, builder, values...)
+}
+
+func (rs *recordingStore) Check(context.Context, *typedef.Table, qb.Builder, typedef.ReadOptions, bool, ...interface{}) (store.CheckStats, error) {
+	return store.CheckStats{}, nil
+}
+
+func (rs *recordingStore) CheckScan(context.Context, *typedef.Table, qb.Builder, ...interface{}) (store.CheckStats, error) {
+	return store.CheckStats{}, nil
+}
+
+func (rs *recordingStore) AwaitBuilt(context.Context, string, string, bool) error {
+	return nil
+}
+
+func (rs *recordingStore) Close() error {
+	return nil
+}
+
+func TestCreateTable(t *testing.T) {
+	t.Parallel()
+	schema, _, _ := testutils.GetAllForTestStmt(t, "pk1_ck1_col1")
+	sc := schema.Config
+	sc.MaxTables = 2
+	sc.CQLFeature = typedef.CQL_FEATURE_ALL
+	sc.MinPartitionKeys, sc.MaxPartitionKeys = 1, 2
+	sc.MinClusteringKeys, sc.MaxClusteringKeys = 1, 2
+	sc.MinColumns, sc.MaxColumns = 1, 4
+
+	s := &recordingStore{}
+	var started []*typedef.Table
+	tc := &tableCreator{
+		newGenerator: func(table *typedef.Table) (*generators.Generator, error) {
+			return generators.NewGenerator(table, &generators.Config{
+				PartitionsRangeConfig: sc.GetPartitionRangeConfig(),
+				PartitionsCount:       1,
+				PartitionsDistributionFunc: func() generators.TokenIndex {
+					return 0
+				},
+			}, zap.NewNop()), nil
+		},
+		start: func(table *typedef.Table, _ *generators.Generator) {
+			started = append(started, table)
+		},
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2; i++ {
+		if err := createTable(context.Background(), schema, &sc, s, r, tc, status.NewGlobalStatus(1), zap.NewNop()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(schema.Tables) != 2 || schema.Tables[1].Name != "table2" {
+		t.Fatalf("expected the table table2 to be added once, got %d tables", len(schema.Tables))
+	}
+	if len(started) != 1 || started[0] != schema.Tables[1] {
+		t.Fatalf("expected the jobs to be started on table2 once, got %d", len(started))
+	}
+	created := false
+	for _, query := range s.queries {
+		created = created || strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS ks1.table2 ")
+	}
+	if !created {
+		t.Errorf("expected table2 to be created, got %v", s.queries)
+	}
+}
+
+func TestJobSeed(t *testing.T) {
+	t.Parallel()
+	seeds := make(map[uint64]string)
+	for _, table := range []string{"table1", "table2"} {
+		for worker := 0; worker < 3; worker++ {
+			for job := 0; job < 2; job++ {
+				name := fmt.Sprintf("%s/%d/%d", table, worker, job)
+				seed := jobSeed(1, table, worker, job)
+				if other, ok := seeds[seed]; ok {
+					t.Errorf("jobs %s and %s have the same seed", other, name)
+				}
+				seeds[seed] = name
+				if jobSeed(1, table, worker, job) != seed {
+					t.Errorf("the seed of job %s is not stable", name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+
+	"github.com/scylladb/gemini/pkg/builders"
+	"github.com/scylladb/gemini/pkg/generators"
+	"github.com/scylladb/gemini/pkg/joberror"
+	"github.com/scylladb/gemini/pkg/store"
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+// maxMinimizationAttempts bounds the number of times a candidate history is
+// replayed while a failure is minimized.
+const maxMinimizationAttempts = 100
+
+var minimizationRuns atomic.Uint64
+
+// minimizer shrinks the history of the partitions read by a failed check to
+// a minimal sequence of mutations that still makes the check fail the same
+// way. Every candidate sequence is replayed against a fresh keyspace on both
+// clusters.
+type minimizer struct {
+	store    store.Store
+	check    *typedef.Stmt
+	failure  error
+	logger   *zap.Logger
+	sc       *typedef.SchemaConfig
+	table    *typedef.Table
+	from     string
+	keyspace string
+	schema   []string
+	history  []*typedef.Stmt
+	attempts int
+}
+
+// failure is a failed check waiting to be minimized.
+type failure struct {
+	jobErr  *joberror.JobError
+	table   *typedef.Table
+	check   *typedef.Stmt
+	err     error
+	history []*typedef.Stmt
+}
+
+// failures collects the failed checks of a job list. They are minimized
+// once its jobs have ended, the replays neither hold up the validations nor
+// keep the table locked against DDL.
+type failures struct {
+	pending []failure
+	mu      sync.Mutex
+}
+
+func (f *failures) add(jobErr *joberror.JobError, table *typedef.Table, check *typedef.Stmt, err error, history []*typedef.Stmt) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pending = append(f.pending, failure{jobErr: jobErr, table: table, check: check, err: err, history: history})
+}
+
+// minimize attaches a reproducer to the error of every queued failure.
+func (f *failures) minimize(ctx context.Context, schema *typedef.Schema, sc *typedef.SchemaConfig, s store.Store, logger *zap.Logger) {
+	f.mu.Lock()
+	pending := f.pending
+	f.pending = nil
+	f.mu.Unlock()
+	for _, p := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+		p.jobErr.Reproducer = reproduceFailure(ctx, schema, sc, p.table, s, p.check, p.err, p.history, logger)
+	}
+}
+
+// partitionHistory merges the recorded mutations of the partitions the
+// statement reads, in the order they were applied.
+func partitionHistory(g *generators.Generator, stmt *typedef.Stmt) (history []*typedef.Stmt, evicted bool) {
+	var entries []generators.HistoryEntry
+	seen := make(map[*typedef.Stmt]struct{})
+	for _, v := range stmt.ValuesWithToken {
+		tokenEntries, tokenEvicted := g.History(v.Token)
+		evicted = evicted || tokenEvicted
+		for _, entry := range tokenEntries {
+			if _, ok := seen[entry.Stmt]; !ok {
+				seen[entry.Stmt] = struct{}{}
+				entries = append(entries, entry)
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	for _, entry := range entries {
+		history = append(history, entry.Stmt)
+	}
+	return history, evicted
+}
+
+// reproduceFailure minimizes the history of the partitions the failed check
+// read and returns the reproducer script, or "" if none could be produced.
+func reproduceFailure(
+	ctx context.Context,
+	schema *typedef.Schema,
+	sc *typedef.SchemaConfig,
+	table *typedef.Table,
+	s store.Store,
+	check *typedef.Stmt,
+	failure error,
+	history []*typedef.Stmt,
+	logger *zap.Logger,
+) string {
+	script, err := minimizeFailure(ctx, schema, sc, table, s, check, failure, history, logger)
+	if err != nil {
+		logger.Info("unable to minimize failure", zap.String("query", check.PrettyCQL()), zap.Error(err))
+		return ""
+	}
+	return script
+}
+
+// minimizeFailure returns a self-contained CQL script that reproduces the
+// failure of the check with as few of the history mutations as it could find.
+func minimizeFailure(
+	ctx context.Context,
+	schema *typedef.Schema,
+	sc *typedef.SchemaConfig,
+	table *typedef.Table,
+	s store.Store,
+	check *typedef.Stmt,
+	failure error,
+	history []*typedef.Stmt,
+	logger *zap.Logger,
+) (string, error) {
+	if len(history) == 0 {
+		return "", errors.New("no mutations were recorded for the partition")
+	}
+	m := &minimizer{
+		store:    s,
+		check:    check,
+		failure:  failure,
+		logger:   logger.Named("minimizer"),
+		sc:       sc,
+		table:    table,
+		from:     schema.Keyspace.Name,
+		keyspace: fmt.Sprintf("%s_min_%d", schema.Keyspace.Name, minimizationRuns.Add(1)),
+		history:  history,
+	}
+	minSchema := &typedef.Schema{
+		Keyspace: schema.Keyspace,
+		Tables:   []*typedef.Table{table},
+		Config:   schema.Config,
+	}
+	minSchema.Keyspace.Name = m.keyspace
+	table.RLock()
+	testKeyspace, oracleKeyspace := generators.GetCreateKeyspaces(minSchema)
+	m.schema = append([]string{testKeyspace}, generators.GetCreateSchema(minSchema)...)
+	table.RUnlock()
+
+	if err := m.store.Create(ctx, &builders.AlterTableBuilder{Stmt: testKeyspace}, &builders.AlterTableBuilder{Stmt: oracleKeyspace}); err != nil {
+		return "", errors.Wrap(err, "unable to create minimization keyspace")
+	}
+	defer func() {
+		if err := m.store.Mutate(context.Background(), &builders.AlterTableBuilder{Stmt: "DROP KEYSPACE IF EXISTS " + m.keyspace}); err != nil {
+			m.logger.Warn("unable to drop minimization keyspace", zap.String("keyspace", m.keyspace), zap.Error(err))
+		}
+	}()
+	for _, stmt := range m.schema[1:] {
+		if err := m.store.Mutate(ctx, &builders.AlterTableBuilder{Stmt: stmt}); err != nil {
+			return "", errors.Wrap(err, "unable to create minimization schema")
+		}
+	}
+
+	all := make([]int, len(history))
+	for i := range all {
+		all[i] = i
+	}
+	if !m.fails(ctx, all) {
+		return "", errors.Errorf("failure does not reproduce from the %d recorded mutations", len(history))
+	}
+	minimal := minimizeSequence(all, func(candidate []int) bool {
+		if m.attempts >= maxMinimizationAttempts || ctx.Err() != nil {
+			return false
+		}
+		return m.fails(ctx, candidate)
+	})
+	m.logger.Info("failure minimized",
+		zap.Int("mutations", len(history)), zap.Int("minimized", len(minimal)), zap.Int("attempts", m.attempts))
+	return m.script(minimal), nil
+}
+
+// fails replays the selected mutations on an empty table and reports
+// whether the check still fails the way it originally did afterwards.
+func (m *minimizer) fails(ctx context.Context, selected []int) bool {
+	m.attempts++
+	truncate := fmt.Sprintf("TRUNCATE TABLE %s.%s", m.keyspace, m.table.Name)
+	if err := m.store.Mutate(ctx, &builders.AlterTableBuilder{Stmt: truncate}); err != nil {
+		m.logger.Debug("unable to truncate minimization table", zap.Error(err))
+		return false
+	}
+	for _, idx := range selected {
+		stmt := m.rewrite(m.history[idx])
+		if err := m.store.Mutate(ctx, stmt.Query, stmt.Values...); err != nil {
+			// A mutation that no longer applies, for example because its
+			// column was dropped since, can not be part of a reproducer.
+			return false
+		}
+	}
+	return sameFailure(m.failure, validation(ctx, m.sc, m.table, m.store, m.rewrite(m.check), zap.NewNop()))
+}
+
+// sameFailure reports whether the check failed on the replay the way it
+// originally did. Row mismatches have to show the same rows missing on each
+// side, or the same columns of the same row differing, other failures the
+// same message. A read error or a view that has not caught up yet does not
+// reproduce a mismatch.
+func sameFailure(original, replayed error) bool {
+	if replayed == nil {
+		return false
+	}
+	var originalDiff, replayedDiff *joberror.RowDiffError
+	if !errors.As(original, &originalDiff) {
+		return !errors.As(replayed, &replayedDiff) && unWrapErr(original).Error() == unWrapErr(replayed).Error()
+	}
+	if !errors.As(replayed, &replayedDiff) || originalDiff.Diff == nil || replayedDiff.Diff == nil {
+		return false
+	}
+	o, r := originalDiff.Diff, replayedDiff.Diff
+	return o.PrimaryKey == r.PrimaryKey &&
+		slices.Equal(o.MissingInTest, r.MissingInTest) &&
+		slices.Equal(o.MissingInOracle, r.MissingInOracle) &&
+		slices.Equal(columnNames(o.Columns), columnNames(r.Columns))
+}
+
+func columnNames(columns []joberror.ColumnDiff) []string {
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+// rewrite returns a copy of the statement that operates on the minimization keyspace.
+func (m *minimizer) rewrite(stmt *typedef.Stmt) *typedef.Stmt {
+	query, _ := stmt.Query.ToCql()
+	cache := *stmt.StmtCache
+	cache.Query = &builders.AlterTableBuilder{Stmt: strings.ReplaceAll(query, m.from+".", m.keyspace+".")}
+	return &typedef.Stmt{
+		StmtCache:       &cache,
+		ValuesWithToken: stmt.ValuesWithToken,
+		Values:          stmt.Values,
+		Read:            stmt.Read,
+	}
+}
+
+func (m *minimizer) script(selected []int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "-- %d of %d recorded mutations reproduce the failure\n", len(selected), len(m.history))
+	for _, stmt := range m.schema {
+		sb.WriteString(stmt + ";\n")
+	}
+	for _, idx := range selected {
+		sb.WriteString(m.rewrite(m.history[idx]).PrettyCQL() + ";\n")
+	}
+	sb.WriteString(m.rewrite(m.check).PrettyCQL() + ";\n")
+	return sb.String()
+}
+
+// minimizeSequence implements delta debugging, it returns a subsequence of
+// the items, as small as it could find, for which fails still returns true.
+// fails must return true for the whole sequence.
+func minimizeSequence(items []int, fails func([]int) bool) []int {
+	granularity := 2
+	for len(items) >= 2 {
+		chunks := splitSequence(items, granularity)
+		reduced := false
+		for _, chunk := range chunks {
+			if fails(chunk) {
+				items, granularity, reduced = chunk, 2, true
+				break
+			}
+		}
+		if !reduced && granularity > 2 {
+			for i := range chunks {
+				complement := make([]int, 0, len(items)-len(chunks[i]))
+				for j := range chunks {
+					if j != i {
+						complement = append(complement, chunks[j]...)
+					}
+				}
+				if fails(complement) {
+					items, granularity, reduced = complement, granularity-1, true
+					break
+				}
+			}
+		}
+		if !reduced {
+			if granularity >= len(items) {
+				break
+			}
+			granularity *= 2
+			if granularity > len(items) {
+				granularity = len(items)
+			}
+		}
+	}
+	return items
+}
+
+func splitSequence(items []int, n int) [][]int {
+	chunks := make([][]int, 0, n)
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + (len(items)-start)/(n-i)
+		chunks = append(chunks, items[start:end])
+		start = end
+	}
+	return chunks
+}
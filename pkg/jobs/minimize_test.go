@@ -0,0 +1,122 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/scylladb/gemini/pkg/joberror"
+)
+
+func TestMinimizeSequence(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		culprits []int
+		size     int
+	}{
+		"single":   {culprits: []int{5}, size: 10},
+		"pair":     {culprits: []int{3, 7}, size: 10},
+		"edges":    {culprits: []int{0, 99}, size: 100},
+		"triple":   {culprits: []int{1, 2, 40}, size: 64},
+		"all":      {culprits: []int{0, 1, 2}, size: 3},
+		"only one": {culprits: []int{0}, size: 1},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			items := make([]int, test.size)
+			for i := range items {
+				items[i] = i
+			}
+			fails := func(candidate []int) bool {
+				present := make(map[int]bool, len(candidate))
+				for _, idx := range candidate {
+					present[idx] = true
+				}
+				for _, idx := range test.culprits {
+					if !present[idx] {
+						return false
+					}
+				}
+				return true
+			}
+			if diff := cmp.Diff(test.culprits, minimizeSequence(items, fails)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestSameFailure(t *testing.T) {
+	t.Parallel()
+	missing := &joberror.RowDiffError{Message: "row count differ", Diff: &joberror.RowDiff{MissingInTest: []string{"pk0=1"}}}
+	columns := &joberror.RowDiffError{Message: "rows differ", Diff: &joberror.RowDiff{
+		PrimaryKey: "pk0=1",
+		Columns:    []joberror.ColumnDiff{{Name: "col0", Oracle: "1", Test: "2"}},
+	}}
+	misordered := errors.New("test and oracle store return the rows in a different order")
+	tests := map[string]struct {
+		original error
+		replayed error
+		expected bool
+	}{
+		"no failure": {original: missing, replayed: nil},
+		"same missing rows": {
+			original: missing,
+			replayed: &joberror.RowDiffError{Message: "row count differ", Diff: &joberror.RowDiff{MissingInTest: []string{"pk0=1"}}},
+			expected: true,
+		},
+		"other missing rows": {
+			original: missing,
+			replayed: &joberror.RowDiffError{Message: "row count differ", Diff: &joberror.RowDiff{MissingInTest: []string{"pk0=2"}}},
+		},
+		"rows missing on the other side": {
+			original: missing,
+			replayed: &joberror.RowDiffError{Message: "row count differ", Diff: &joberror.RowDiff{MissingInOracle: []string{"pk0=1"}}},
+		},
+		"same columns with other values": {
+			original: columns,
+			replayed: &joberror.RowDiffError{Message: "rows differ", Diff: &joberror.RowDiff{
+				PrimaryKey: "pk0=1",
+				Columns:    []joberror.ColumnDiff{{Name: "col0", Oracle: "3", Test: "4"}},
+			}},
+			expected: true,
+		},
+		"other columns": {
+			original: columns,
+			replayed: &joberror.RowDiffError{Message: "rows differ", Diff: &joberror.RowDiff{
+				PrimaryKey: "pk0=1",
+				Columns:    []joberror.ColumnDiff{{Name: "col1", Oracle: "1", Test: "2"}},
+			}},
+		},
+		"read error instead of a diff": {original: columns, replayed: errors.New("unable to load check data from the test store")},
+		"same message":                 {original: misordered, replayed: fmt.Errorf("attempt 1: %w", misordered), expected: true},
+		"diff instead of the message":  {original: misordered, replayed: missing},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if received := sameFailure(test.original, test.replayed); received != test.expected {
+				t.Errorf("expected %t, received %t", test.expected, received)
+			}
+		})
+	}
+}
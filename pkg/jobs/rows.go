@@ -0,0 +1,171 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/exp/constraints"
+	"golang.org/x/exp/rand"
+	"gopkg.in/inf.v0"
+
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+// genClusteringValues appends the clustering key of a row of the partition
+// to the values. Half of the time it is a row that a mutation was generated
+// for before, so that existing rows are overwritten, updated and deleted,
+// otherwise it is a new row that is remembered for the later statements.
+func genClusteringValues(
+	t *typedef.Table,
+	vs *typedef.ValueWithToken,
+	r *rand.Rand,
+	p *typedef.PartitionRangeConfig,
+	values typedef.Values,
+) typedef.Values {
+	if len(t.ClusteringKeys) == 0 {
+		return values
+	}
+	if len(vs.Rows) > 0 && r.Intn(2) == 0 {
+		return append(values, vs.Rows[r.Intn(len(vs.Rows))]...)
+	}
+	row := make(typedef.Values, 0, t.ClusteringKeys.LenValues())
+	kp := p.ForKeys()
+	for _, ck := range t.ClusteringKeys {
+		row = appendValue(ck.Type, r, kp, row)
+	}
+	vs.AddRow(row)
+	return append(values, row...)
+}
+
+// knownBounds picks two of the known rows that agree on the first eq
+// clustering keys and returns the values of these keys and, for the lower
+// and the upper of the rows, the values of the next width clustering keys.
+// A slice from lower to upper, bounds included, brackets real rows. If the
+// clustering keys can not be ordered, both bounds are taken from one row.
+// It returns false if no row is known.
+func knownBounds(
+	t *typedef.Table,
+	rows []typedef.Values,
+	eq, width int,
+	r *rand.Rand,
+) (prefix, lower, upper typedef.Values, ok bool) {
+	if len(rows) == 0 {
+		return nil, nil, nil, false
+	}
+	prefixLen := t.ClusteringKeys[:eq].LenValues()
+	sliceLen := t.ClusteringKeys[eq : eq+width].LenValues()
+	anchor := rows[r.Intn(len(rows))]
+	prefix = anchor[:prefixLen]
+	var candidates []typedef.Values
+	for _, row := range rows {
+		if fmt.Sprint(row[:prefixLen]) == fmt.Sprint(prefix) {
+			candidates = append(candidates, row[prefixLen:prefixLen+sliceLen])
+		}
+	}
+	lower = candidates[r.Intn(len(candidates))]
+	upper = candidates[r.Intn(len(candidates))]
+	c, orderable := compareRows(t.ClusteringKeys[eq:eq+width], lower, upper)
+	switch {
+	case !orderable:
+		upper = lower
+	case c > 0:
+		lower, upper = upper, lower
+	}
+	return prefix, lower, upper, true
+}
+
+// partitionRows returns the known rows of all the partitions.
+func partitionRows(tokens []*typedef.ValueWithToken) []typedef.Values {
+	var rows []typedef.Values
+	for _, vs := range tokens {
+		rows = append(rows, vs.Rows...)
+	}
+	return rows
+}
+
+// compareRows orders the values of the columns the way the clusters order
+// the clustering keys, it returns false if it can not order them.
+func compareRows(columns typedef.Columns, a, b typedef.Values) (int, bool) {
+	for _, col := range columns {
+		if col.Type.LenValue() != 1 {
+			return 0, false
+		}
+		c, ok := compareValues(col.Type, a[0], b[0])
+		if !ok || c != 0 {
+			return c, ok
+		}
+		a, b = a[1:], b[1:]
+	}
+	return 0, true
+}
+
+// compareValues orders two generated values of the type, it returns false
+// for the types whose values it can not order.
+func compareValues(t typedef.Type, a, b interface{}) (int, bool) {
+	switch t {
+	case typedef.TYPE_ASCII, typedef.TYPE_TEXT, typedef.TYPE_VARCHAR, typedef.TYPE_BLOB, typedef.TYPE_DATE:
+		// Blobs are hex encoded and dates formatted as yyyy-mm-dd, both
+		// preserve the order.
+		x, okA := a.(string)
+		y, okB := b.(string)
+		return strings.Compare(x, y), okA && okB
+	case typedef.TYPE_BIGINT, typedef.TYPE_TIME, typedef.TYPE_TIMESTAMP:
+		return compareOrdered[int64](a, b)
+	case typedef.TYPE_INT:
+		return compareOrdered[int32](a, b)
+	case typedef.TYPE_SMALLINT:
+		return compareOrdered[int16](a, b)
+	case typedef.TYPE_TINYINT:
+		return compareOrdered[int8](a, b)
+	case typedef.TYPE_DOUBLE:
+		return compareOrdered[float64](a, b)
+	case typedef.TYPE_FLOAT:
+		return compareOrdered[float32](a, b)
+	case typedef.TYPE_DECIMAL:
+		x, okA := a.(*inf.Dec)
+		y, okB := b.(*inf.Dec)
+		if !okA || !okB {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	case typedef.TYPE_VARINT:
+		x, okA := a.(*big.Int)
+		y, okB := b.(*big.Int)
+		if !okA || !okB {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	default:
+		return 0, false
+	}
+}
+
+func compareOrdered[T constraints.Ordered](a, b interface{}) (int, bool) {
+	x, okA := a.(T)
+	y, okB := b.(T)
+	switch {
+	case !okA || !okB:
+		return 0, false
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/exp/rand"
+
+	"github.com/scylladb/gemini/pkg/testutils"
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+func TestGenClusteringValues(t *testing.T) {
+	t.Parallel()
+	schema, gen, rnd := testutils.GetAllForTestStmt(t, "pk1_ck3_col1")
+	table := schema.Tables[0]
+	prc := schema.Config.GetPartitionRangeConfig()
+	vs := gen.Get()
+
+	revisited := 0
+	for i := 0; i < 200; i++ {
+		known := make(map[string]bool, len(vs.Rows))
+		for _, row := range vs.Rows {
+			known[fmt.Sprint(row)] = true
+		}
+		row := genClusteringValues(table, vs, rnd, &prc, nil)
+		if len(row) != table.ClusteringKeys.LenValues() {
+			t.Fatalf("expected %d clustering key values, got %v", table.ClusteringKeys.LenValues(), row)
+		}
+		if known[fmt.Sprint(row)] {
+			revisited++
+		}
+		if len(vs.Rows) > typedef.MaxKnownRows {
+			t.Fatalf("expected at most %d known rows, got %d", typedef.MaxKnownRows, len(vs.Rows))
+		}
+	}
+	if revisited == 0 {
+		t.Error("expected some of the rows to be revisited")
+	}
+}
+
+func TestKnownBounds(t *testing.T) {
+	t.Parallel()
+	table := &typedef.Table{
+		Name: "table1",
+		ClusteringKeys: typedef.Columns{
+			{Name: "ck0", Type: typedef.TYPE_INT},
+			{Name: "ck1", Type: typedef.TYPE_TEXT},
+		},
+	}
+	rows := []typedef.Values{
+		{int32(1), "b"},
+		{int32(1), "a"},
+		{int32(2), "c"},
+		{int32(1), "d"},
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		prefix, lower, upper, ok := knownBounds(table, rows, 1, 1, r)
+		if !ok {
+			t.Fatal("expected bounds of the known rows")
+		}
+		if c, _ := compareValues(typedef.TYPE_TEXT, lower[0], upper[0]); c > 0 {
+			t.Fatalf("expected lower bound %v to not exceed the upper bound %v", lower, upper)
+		}
+		for _, bound := range []typedef.Values{lower, upper} {
+			found := false
+			for _, row := range rows {
+				found = found || fmt.Sprint(row) == fmt.Sprint(append(prefix.Copy(), bound...))
+			}
+			if !found {
+				t.Fatalf("expected bound %v to be a known row of the partition %v", bound, prefix)
+			}
+		}
+
+		_, lower, upper, _ = knownBounds(table, rows, 0, 2, r)
+		if c, _ := compareRows(table.ClusteringKeys, lower, upper); c > 0 {
+			t.Fatalf("expected lower bound %v to not exceed the upper bound %v", lower, upper)
+		}
+	}
+	if _, _, _, ok := knownBounds(table, nil, 0, 1, r); ok {
+		t.Error("expected no bounds without known rows")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/scylladb/gocqlx/v2/qb"
+	"go.uber.org/zap"
+
+	"github.com/scylladb/gemini/pkg/joberror"
+	"github.com/scylladb/gemini/pkg/status"
+	"github.com/scylladb/gemini/pkg/store"
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+// fullScanSlices is the number of token ranges the token ring is scanned in.
+// Every range is a query of its own, so a failure points to a narrow range.
+const fullScanSlices = 256
+
+// FullScan compares every table as a whole between the clusters. The token
+// ring is walked range by range, the rows of each range are paged through on
+// both clusters and merged as they arrive.
+func FullScan(
+	ctx context.Context,
+	schema *typedef.Schema,
+	sc *typedef.SchemaConfig,
+	s store.Store,
+	globalStatus *status.GlobalStatus,
+	logger *zap.Logger,
+	failFast bool,
+) error {
+	logger = logger.Named("full_scan")
+	for _, table := range schema.GetTables() {
+		var rows, pages int
+		for _, bounds := range tokenRanges(fullScanSlices) {
+			stmt := genScanStmt(schema, table, bounds[0], bounds[1])
+			stats, err := scanValidation(ctx, sc, table, s, stmt, logger)
+			rows += stats.Rows
+			pages += stats.TestPages
+			switch {
+			case err == nil:
+				globalStatus.ReadOps.Add(1)
+			case errors.Is(err, context.Canceled):
+				return err
+			default:
+				jobErr := &joberror.JobError{
+					Timestamp: time.Now(),
+					StmtType:  stmt.QueryType.ToString(),
+					Message:   "Full scan validation failed: " + err.Error(),
+					Query:     stmt.PrettyCQL(),
+				}
+				var diffErr *joberror.RowDiffError
+				if errors.As(err, &diffErr) {
+					jobErr.RowDiff = diffErr.Diff
+				}
+				globalStatus.AddReadError(jobErr)
+				if failFast {
+					return nil
+				}
+			}
+		}
+		logger.Info("table scanned", zap.String("table", table.Name), zap.Int("rows", rows), zap.Int("pages", pages))
+	}
+	return nil
+}
+
+// scanValidation compares a token range, when cells expire the comparison
+// is retried once a second later, like validation does.
+func scanValidation(
+	ctx context.Context,
+	sc *typedef.SchemaConfig,
+	table *typedef.Table,
+	s store.Store,
+	stmt *typedef.Stmt,
+	logger *zap.Logger,
+) (store.CheckStats, error) {
+	if w := logger.Check(zap.DebugLevel, "scan statement"); w != nil {
+		w.Write(zap.String("pretty_cql", stmt.PrettyCQL()))
+	}
+	stats, err := s.CheckScan(ctx, table, stmt.Query, stmt.Values...)
+	if err == nil || sc.MaxTTL == 0 || errors.Is(err, context.Canceled) {
+		return stats, err
+	}
+	select {
+	case <-time.After(time.Second):
+	case <-ctx.Done():
+		return stats, ctx.Err()
+	}
+	return s.CheckScan(ctx, table, stmt.Query, stmt.Values...)
+}
+
+// tokenRanges splits the token ring into n consecutive ranges, each given by
+// its exclusive lower and inclusive upper bound.
+func tokenRanges(n int) [][2]int64 {
+	step := math.MaxUint64 / uint64(n)
+	out := make([][2]int64, 0, n)
+	lower := int64(math.MinInt64)
+	for i := 1; i <= n; i++ {
+		upper := int64(math.MaxInt64)
+		if i < n {
+			upper = lower + int64(step)
+		}
+		out = append(out, [2]int64{lower, upper})
+		lower = upper
+	}
+	return out
+}
+
+// genScanStmt reads the rows of the table whose token is in the range
+// (lower, upper], together with the token they belong to.
+func genScanStmt(s *typedef.Schema, t *typedef.Table, lower, upper int64) *typedef.Stmt {
+	names := make([]string, 0, len(t.PartitionKeys))
+	for _, pk := range t.PartitionKeys {
+		names = append(names, pk.Name)
+	}
+	token := "token(" + strings.Join(names, ",") + ")"
+	columns := []string{token + " AS " + store.TokenColumn}
+	for _, cols := range []typedef.Columns{t.PartitionKeys, t.ClusteringKeys, t.Columns} {
+		for _, col := range cols {
+			columns = append(columns, col.Name)
+		}
+	}
+	builder := qb.Select(s.Keyspace.Name+"."+t.Name).
+		Columns(columns...).
+		Where(qb.GtLit(token, "?"), qb.LtOrEqLit(token, "?"))
+	return &typedef.Stmt{
+		StmtCache: &typedef.StmtCache{
+			Query:     builder,
+			Types:     typedef.Types{typedef.TYPE_BIGINT, typedef.TYPE_BIGINT},
+			QueryType: typedef.ScanStatementType,
+		},
+		Values: typedef.Values{lower, upper},
+	}
+}
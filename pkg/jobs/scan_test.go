@@ -0,0 +1,61 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"math"
+	"testing"
+
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+func TestTokenRanges(t *testing.T) {
+	t.Parallel()
+	for _, n := range []int{1, 3, 256} {
+		ranges := tokenRanges(n)
+		if len(ranges) != n {
+			t.Fatalf("expected %d ranges, got %d", n, len(ranges))
+		}
+		if ranges[0][0] != math.MinInt64 || ranges[n-1][1] != math.MaxInt64 {
+			t.Errorf("ranges %v do not cover the token ring", ranges)
+		}
+		for i, r := range ranges {
+			if r[0] >= r[1] {
+				t.Errorf("range %d is empty: %v", i, r)
+			}
+			if i > 0 && ranges[i-1][1] != r[0] {
+				t.Errorf("range %d does not follow range %d: %v %v", i, i-1, ranges[i-1], r)
+			}
+		}
+	}
+}
+
+func TestGenScanStmt(t *testing.T) {
+	t.Parallel()
+	schema := &typedef.Schema{
+		Keyspace: typedef.Keyspace{Name: "ks1"},
+		Tables: []*typedef.Table{{
+			Name:           "tb0",
+			PartitionKeys:  typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}, {Name: "pk1", Type: typedef.TYPE_TEXT}},
+			ClusteringKeys: typedef.Columns{{Name: "ck0", Type: typedef.TYPE_INT}},
+			Columns:        typedef.Columns{{Name: "col0", Type: typedef.TYPE_TEXT}},
+		}},
+	}
+	stmt := genScanStmt(schema, schema.Tables[0], -10, 10)
+	expected := "SELECT token(pk0,pk1) AS scan_token,pk0,pk1,ck0,col0 FROM ks1.tb0 WHERE token(pk0,pk1)>-10 AND token(pk0,pk1)<=10 "
+	if received := stmt.PrettyCQL(); received != expected {
+		t.Errorf("expected %q, got %q", expected, received)
+	}
+}
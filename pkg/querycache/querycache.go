@@ -95,6 +95,8 @@ var CacheBuilders = CacheBuilderFnMap{
 	typedef.CacheInsertIfNotExists: genInsertIfNotExistsStmtCache,
 	typedef.CacheDelete:            genDeleteStmtCache,
 	typedef.CacheUpdate:            genUpdateStmtCache,
+	typedef.CacheUpdateIfExists:    genUpdateIfExistsStmtCache,
+	typedef.CacheDeleteIfExists:    genDeleteIfExistsStmtCache,
 }.ToList()
 
 func genInsertStmtCache(
@@ -133,6 +135,7 @@ func genInsertIfNotExistsStmtCache(
 ) *typedef.StmtCache {
 	out := genInsertStmtCache(s, t)
 	out.Query = out.Query.(*qb.InsertBuilder).Unique()
+	out.QueryType = typedef.InsertIfNotExistsStatementType
 	return out
 }
 
@@ -187,3 +190,30 @@ func genDeleteStmtCache(s *typedef.Schema, t *typedef.Table) *typedef.StmtCache
 		QueryType: typedef.DeleteStatementType,
 	}
 }
+
+func genUpdateIfExistsStmtCache(s *typedef.Schema, t *typedef.Table) *typedef.StmtCache {
+	out := genUpdateStmtCache(s, t)
+	out.Query = out.Query.(*qb.UpdateBuilder).Existing()
+	out.QueryType = typedef.UpdateIfStatementType
+	return out
+}
+
+// genDeleteIfExistsStmtCache deletes a single row, conditional deletes have
+// to restrict the whole primary key.
+func genDeleteIfExistsStmtCache(s *typedef.Schema, t *typedef.Table) *typedef.StmtCache {
+	var allTypes []typedef.Type
+	builder := qb.Delete(s.Keyspace.Name + "." + t.Name)
+	for _, pk := range t.PartitionKeys {
+		builder = builder.Where(qb.Eq(pk.Name))
+		allTypes = append(allTypes, pk.Type)
+	}
+	for _, ck := range t.ClusteringKeys {
+		builder = builder.Where(qb.Eq(ck.Name))
+		allTypes = append(allTypes, ck.Type)
+	}
+	return &typedef.StmtCache{
+		Query:     builder.Existing(),
+		Types:     allTypes,
+		QueryType: typedef.DeleteIfStatementType,
+	}
+}
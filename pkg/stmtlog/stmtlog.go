@@ -0,0 +1,183 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stmtlog records the statements gemini applies to the clusters so
+// that a run can be replayed deterministically afterwards.
+package stmtlog
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	OutcomeApplied = "applied"
+	OutcomeFailed  = "failed"
+	OutcomeSkipped = "skipped"
+)
+
+// Record is a single entry of the statement log. Timestamp is the client
+// timestamp, in microseconds, the statement was first attempted with. It
+// is zero when the write timestamps are generated by the server. Outcome
+// and Error describe the test cluster, OracleOutcome and OracleError the
+// oracle, a cluster can apply a statement the other one failed.
+type Record struct {
+	Values        Values    `json:"values,omitempty"`
+	Time          time.Time `json:"time"`
+	Table         string    `json:"table,omitempty"`
+	Query         string    `json:"query"`
+	OracleQuery   string    `json:"oracle_query,omitempty"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error,omitempty"`
+	OracleOutcome string    `json:"oracle_outcome"`
+	OracleError   string    `json:"oracle_error,omitempty"`
+	Index         uint64    `json:"index"`
+	Timestamp     int64     `json:"timestamp,omitempty"`
+}
+
+// QueryFor returns the query that was applied to the given side. Statements
+// that differ between the clusters, such as keyspace creation, carry a
+// separate oracle query.
+func (r *Record) QueryFor(oracle bool) string {
+	if oracle && r.OracleQuery != "" {
+		return r.OracleQuery
+	}
+	return r.Query
+}
+
+// OutcomeFor returns the recorded outcome, and error if it failed, of the
+// given side.
+func (r *Record) OutcomeFor(oracle bool) (string, string) {
+	if oracle {
+		return r.OracleOutcome, r.OracleError
+	}
+	return r.Outcome, r.Error
+}
+
+// Logger appends records as JSON lines to a file. Every record is written
+// with a single write so the log stays usable if gemini is killed.
+type Logger struct {
+	file  *os.File
+	enc   *json.Encoder
+	mu    sync.Mutex
+	index uint64
+}
+
+func NewLogger(fname string) (*Logger, error) {
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open statement log file %s", fname)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	return &Logger{
+		file: f,
+		enc:  enc,
+	}, nil
+}
+
+// Log assigns the next index to the record and appends it to the log.
+func (l *Logger) Log(rec *Record) error {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	if rec.Table == "" {
+		rec.Table = tableName(rec.Query)
+	}
+	if rec.Outcome == "" {
+		rec.Outcome = outcome(rec.Error)
+	}
+	if rec.OracleOutcome == "" {
+		rec.OracleOutcome = outcome(rec.OracleError)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.index++
+	rec.Index = l.index
+	if err := l.enc.Encode(rec); err != nil {
+		return errors.Wrapf(err, "unable to write statement %d to the log", rec.Index)
+	}
+	return nil
+}
+
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+func outcome(errMsg string) string {
+	if errMsg != "" {
+		return OutcomeFailed
+	}
+	return OutcomeApplied
+}
+
+// Reader reads back the records written by a Logger.
+type Reader struct {
+	dec *json.Decoder
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next record of the log or io.EOF when the log is exhausted.
+func (r *Reader) Next() (*Record, error) {
+	var rec Record
+	if err := r.dec.Decode(&rec); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, errors.Wrap(err, "unable to read statement log")
+	}
+	return &rec, nil
+}
+
+// tableName extracts the, possibly keyspace qualified, table a statement
+// operates on. Statements that are not bound to a table return "".
+func tableName(query string) string {
+	fields := strings.Fields(query)
+	for i := 0; i < len(fields)-1; i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "INTO", "UPDATE", "FROM", "TABLE", "TRUNCATE", "ON":
+		default:
+			continue
+		}
+		j := i + 1
+		if strings.EqualFold(fields[j], "IF") {
+			// Skip IF EXISTS and IF NOT EXISTS.
+			for j < len(fields) && !strings.EqualFold(fields[j], "EXISTS") {
+				j++
+			}
+			j++
+		}
+		if j >= len(fields) {
+			return ""
+		}
+		if strings.EqualFold(fields[j], "TABLE") {
+			// TRUNCATE TABLE, the name follows the TABLE keyword.
+			continue
+		}
+		name, _, _ := strings.Cut(fields[j], "(")
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stmtlog_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/inf.v0"
+
+	"github.com/scylladb/gemini/pkg/stmtlog"
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+func readAll(t *testing.T, fname string) []*stmtlog.Record {
+	t.Helper()
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []*stmtlog.Record
+	reader := stmtlog.NewReader(f)
+	for {
+		rec, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestLoggerRecords(t *testing.T) {
+	t.Parallel()
+	fname := filepath.Join(t.TempDir(), "statements.log")
+	logger, err := stmtlog.NewLogger(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	when := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+	input := []stmtlog.Record{
+		{Time: when, Query: "CREATE KEYSPACE IF NOT EXISTS ks1 WITH REPLICATION = {}", OracleQuery: "CREATE KEYSPACE IF NOT EXISTS ks1 WITH REPLICATION = {'x':1}"},
+		{Time: when, Query: "CREATE TABLE IF NOT EXISTS ks1.table1 (pk0 int, PRIMARY KEY ((pk0)))"},
+		{Time: when, Query: "INSERT INTO ks1.table1 (pk0) VALUES (?)", Values: stmtlog.Values{int32(1)}, Timestamp: 10},
+		{Time: when, Query: "UPDATE ks1.table1 SET col0=? WHERE pk0=?", Values: stmtlog.Values{nil, int32(1)}, Timestamp: 11, Error: "timeout"},
+		{Time: when, Query: "DELETE col0 FROM ks1.table1 WHERE pk0=?", Values: stmtlog.Values{int32(1)}, Timestamp: 12, OracleError: "timeout"},
+		{Time: when, Query: "ALTER TABLE ks1.table1 DROP col0"},
+		{Time: when, Query: "TRUNCATE TABLE ks1.table1", Outcome: stmtlog.OutcomeSkipped, OracleError: "unavailable"},
+		{Time: when, Query: "UPDATE ks1.table1 SET col0=?,col1=? WHERE pk0=?", Values: stmtlog.Values{gocql.UnsetValue, []interface{}{}, int32(1)}, Timestamp: 13},
+	}
+	for i := range input {
+		rec := input[i]
+		if err = logger.Log(&rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*stmtlog.Record{
+		{Index: 1, Time: when, Outcome: stmtlog.OutcomeApplied, OracleOutcome: stmtlog.OutcomeApplied, Query: input[0].Query, OracleQuery: input[0].OracleQuery},
+		{Index: 2, Time: when, Outcome: stmtlog.OutcomeApplied, OracleOutcome: stmtlog.OutcomeApplied, Table: "ks1.table1", Query: input[1].Query},
+		{Index: 3, Time: when, Outcome: stmtlog.OutcomeApplied, OracleOutcome: stmtlog.OutcomeApplied, Table: "ks1.table1", Query: input[2].Query, Values: input[2].Values, Timestamp: 10},
+		{Index: 4, Time: when, Outcome: stmtlog.OutcomeFailed, OracleOutcome: stmtlog.OutcomeApplied, Table: "ks1.table1", Query: input[3].Query, Values: input[3].Values, Timestamp: 11, Error: "timeout"},
+		{Index: 5, Time: when, Outcome: stmtlog.OutcomeApplied, OracleOutcome: stmtlog.OutcomeFailed, Table: "ks1.table1", Query: input[4].Query, Values: input[4].Values, Timestamp: 12, OracleError: "timeout"},
+		{Index: 6, Time: when, Outcome: stmtlog.OutcomeApplied, OracleOutcome: stmtlog.OutcomeApplied, Table: "ks1.table1", Query: input[5].Query},
+		{Index: 7, Time: when, Outcome: stmtlog.OutcomeSkipped, OracleOutcome: stmtlog.OutcomeFailed, Table: "ks1.table1", Query: input[6].Query, OracleError: "unavailable"},
+		{Index: 8, Time: when, Outcome: stmtlog.OutcomeApplied, OracleOutcome: stmtlog.OutcomeApplied, Table: "ks1.table1", Query: input[7].Query, Values: input[7].Values, Timestamp: 13},
+	}
+	if diff := cmp.Diff(expected, readAll(t, fname)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestValuesRoundTrip(t *testing.T) {
+	t.Parallel()
+	uuid, _ := gocql.ParseUUID("bd43b2b4-0bbb-11ee-be56-0242ac120002")
+	tests := map[string]struct {
+		typ   gocql.TypeInfo
+		value interface{}
+	}{
+		"ascii":     {typedef.TYPE_ASCII.CQLType(), "abc"},
+		"bigint":    {typedef.TYPE_BIGINT.CQLType(), int64(-1 << 62)},
+		"blob":      {typedef.TYPE_BLOB.CQLType(), "0a0b"},
+		"boolean":   {typedef.TYPE_BOOLEAN.CQLType(), true},
+		"date":      {typedef.TYPE_DATE.CQLType(), "2020-02-01"},
+		"decimal":   {typedef.TYPE_DECIMAL.CQLType(), inf.NewDec(-12345, 3)},
+		"double":    {typedef.TYPE_DOUBLE.CQLType(), 0.1},
+		"duration":  {typedef.TYPE_DURATION.CQLType(), "5m0s"},
+		"float":     {typedef.TYPE_FLOAT.CQLType(), float32(0.3)},
+		"inet":      {typedef.TYPE_INET.CQLType(), "192.168.0.1"},
+		"int":       {typedef.TYPE_INT.CQLType(), int32(-7)},
+		"smallint":  {typedef.TYPE_SMALLINT.CQLType(), int16(300)},
+		"time":      {typedef.TYPE_TIME.CQLType(), int64(3600000000000)},
+		"timestamp": {typedef.TYPE_TIMESTAMP.CQLType(), time.Date(2020, 2, 1, 1, 2, 3, 4000000, time.UTC)},
+		"timeuuid":  {typedef.TYPE_TIMEUUID.CQLType(), uuid},
+		"tinyint":   {typedef.TYPE_TINYINT.CQLType(), int8(-3)},
+		"varint":    {typedef.TYPE_VARINT.CQLType(), big.NewInt(1 << 40)},
+		"list": {
+			gocql.CollectionType{NativeType: gocql.NewNativeType(4, gocql.TypeList, ""), Elem: typedef.TYPE_INT.CQLType()},
+			[]interface{}{int32(1), int32(2)},
+		},
+		"map": {
+			gocql.CollectionType{NativeType: gocql.NewNativeType(4, gocql.TypeMap, ""), Key: typedef.TYPE_VARINT.CQLType(), Elem: typedef.TYPE_DOUBLE.CQLType()},
+			map[*big.Int]float64{big.NewInt(1): 1.5},
+		},
+		"udt": {
+			gocql.UDTTypeInfo{
+				NativeType: gocql.NewNativeType(4, gocql.TypeUDT, ""),
+				Name:       "udt",
+				Elements: []gocql.UDTField{
+					{Name: "a", Type: typedef.TYPE_INT.CQLType()},
+					{Name: "b", Type: typedef.TYPE_TEXT.CQLType()},
+				},
+			},
+			map[string]interface{}{"a": int32(3), "b": "x"},
+		},
+		"cql duration": {
+			typedef.TYPE_DURATION.CQLType(),
+			gocql.Duration{Months: -14, Days: -3, Nanoseconds: -5},
+		},
+		"vector": {
+			(&typedef.VectorType{ComplexType: typedef.TYPE_VECTOR, Dimensions: 3}).CQLType(),
+			typedef.Vector{0.5, -1.25, 3},
+		},
+		"null": {typedef.TYPE_INT.CQLType(), nil},
+		"empty list": {
+			gocql.CollectionType{NativeType: gocql.NewNativeType(4, gocql.TypeList, ""), Elem: typedef.TYPE_INT.CQLType()},
+			[]interface{}{},
+		},
+		"empty map": {
+			gocql.CollectionType{NativeType: gocql.NewNativeType(4, gocql.TypeMap, ""), Key: typedef.TYPE_INT.CQLType(), Elem: typedef.TYPE_INT.CQLType()},
+			map[interface{}]interface{}{},
+		},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			data, err := stmtlog.Values{test.value}.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded stmtlog.Values
+			if err = decoded.UnmarshalJSON(data); err != nil {
+				t.Fatal(err)
+			}
+			expected, err := gocql.Marshal(test.typ, test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			received, err := gocql.Marshal(test.typ, decoded[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(expected, received) {
+				t.Errorf("value %v was replayed as %v", test.value, decoded[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stmtlog
+
+import (
+	"encoding/json"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"gopkg.in/inf.v0"
+
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+// Values are the bound values of a statement. They are serialized together
+// with their Go type so that a replayed statement binds exactly the same
+// values as the recorded one.
+type Values []interface{}
+
+type typedValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type durationValue struct {
+	Months      int32 `json:"months"`
+	Days        int32 `json:"days"`
+	Nanoseconds int64 `json:"nanoseconds"`
+}
+
+func (v Values) MarshalJSON() ([]byte, error) {
+	out := make([]typedValue, len(v))
+	for i, value := range v {
+		tv, err := encodeValue(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to encode value %d", i)
+		}
+		out[i] = tv
+	}
+	return json.Marshal(out)
+}
+
+func (v *Values) UnmarshalJSON(data []byte) error {
+	var in []typedValue
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	out := make(Values, len(in))
+	for i := range in {
+		value, err := decodeValue(in[i])
+		if err != nil {
+			return errors.Wrapf(err, "unable to decode value %d", i)
+		}
+		out[i] = value
+	}
+	*v = out
+	return nil
+}
+
+func encodeValue(value interface{}) (typedValue, error) {
+	var (
+		typ string
+		raw interface{}
+	)
+	if value == gocql.UnsetValue {
+		return typedValue{Type: "unset"}, nil
+	}
+	switch v := value.(type) {
+	case nil:
+		return typedValue{Type: "null"}, nil
+	case string:
+		typ, raw = "string", v
+	case []byte:
+		typ, raw = "bytes", v
+	case bool:
+		typ, raw = "bool", v
+	case int:
+		typ, raw = "int", strconv.FormatInt(int64(v), 10)
+	case int8:
+		typ, raw = "int8", strconv.FormatInt(int64(v), 10)
+	case int16:
+		typ, raw = "int16", strconv.FormatInt(int64(v), 10)
+	case int32:
+		typ, raw = "int32", strconv.FormatInt(int64(v), 10)
+	case int64:
+		typ, raw = "int64", strconv.FormatInt(v, 10)
+	case float32:
+		typ, raw = "float32", strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		typ, raw = "float64", strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		typ, raw = "time", v.Format(time.RFC3339Nano)
+	case time.Duration:
+		typ, raw = "duration", strconv.FormatInt(int64(v), 10)
+	case gocql.Duration:
+		typ, raw = "cql_duration", durationValue{Months: v.Months, Days: v.Days, Nanoseconds: v.Nanoseconds}
+	case typedef.Vector:
+		if v == nil {
+			return typedValue{Type: "null"}, nil
+		}
+		typ, raw = "vector", []float32(v)
+	case gocql.UUID:
+		typ, raw = "uuid", v.String()
+	case net.IP:
+		typ, raw = "inet", v.String()
+	case *big.Int:
+		if v == nil {
+			return typedValue{Type: "null"}, nil
+		}
+		typ, raw = "varint", v.String()
+	case *inf.Dec:
+		if v == nil {
+			return typedValue{Type: "null"}, nil
+		}
+		typ, raw = "decimal", v.String()
+	default:
+		return encodeComplexValue(reflect.ValueOf(value))
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return typedValue{}, err
+	}
+	return typedValue{Type: typ, Value: data}, nil
+}
+
+func encodeComplexValue(rv reflect.Value) (typedValue, error) {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return typedValue{Type: "null"}, nil
+		}
+		elems := make([]typedValue, rv.Len())
+		for i := range elems {
+			elem, err := encodeValue(rv.Index(i).Interface())
+			if err != nil {
+				return typedValue{}, err
+			}
+			elems[i] = elem
+		}
+		data, err := json.Marshal(elems)
+		return typedValue{Type: "list", Value: data}, err
+	case reflect.Map:
+		if rv.IsNil() {
+			return typedValue{Type: "null"}, nil
+		}
+		pairs := make([][2]typedValue, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := encodeValue(iter.Key().Interface())
+			if err != nil {
+				return typedValue{}, err
+			}
+			elem, err := encodeValue(iter.Value().Interface())
+			if err != nil {
+				return typedValue{}, err
+			}
+			pairs = append(pairs, [2]typedValue{key, elem})
+		}
+		data, err := json.Marshal(pairs)
+		return typedValue{Type: "map", Value: data}, err
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return typedValue{Type: "null"}, nil
+		}
+		return encodeValue(rv.Elem().Interface())
+	default:
+		return typedValue{}, errors.Errorf("unsupported value type %s", rv.Type())
+	}
+}
+
+func decodeValue(tv typedValue) (interface{}, error) {
+	switch tv.Type {
+	case "null":
+		return nil, nil
+	case "unset":
+		return gocql.UnsetValue, nil
+	case "list":
+		var elems []typedValue
+		if err := json.Unmarshal(tv.Value, &elems); err != nil {
+			return nil, err
+		}
+		out := make([]interface{}, len(elems))
+		for i := range elems {
+			elem, err := decodeValue(elems[i])
+			if err != nil {
+				return nil, err
+			}
+			out[i] = elem
+		}
+		return out, nil
+	case "map":
+		return decodeMap(tv.Value)
+	case "bytes":
+		var out []byte
+		err := json.Unmarshal(tv.Value, &out)
+		return out, err
+	case "bool":
+		var out bool
+		err := json.Unmarshal(tv.Value, &out)
+		return out, err
+	case "cql_duration":
+		var out durationValue
+		if err := json.Unmarshal(tv.Value, &out); err != nil {
+			return nil, err
+		}
+		return gocql.Duration{Months: out.Months, Days: out.Days, Nanoseconds: out.Nanoseconds}, nil
+	case "vector":
+		var out []float32
+		err := json.Unmarshal(tv.Value, &out)
+		return typedef.Vector(out), err
+	}
+	var s string
+	if err := json.Unmarshal(tv.Value, &s); err != nil {
+		return nil, err
+	}
+	return decodeScalar(tv.Type, s)
+}
+
+func decodeScalar(typ, s string) (interface{}, error) {
+	switch typ {
+	case "string":
+		return s, nil
+	case "int":
+		v, err := strconv.ParseInt(s, 10, 64)
+		return int(v), err
+	case "int8":
+		v, err := strconv.ParseInt(s, 10, 8)
+		return int8(v), err
+	case "int16":
+		v, err := strconv.ParseInt(s, 10, 16)
+		return int16(v), err
+	case "int32":
+		v, err := strconv.ParseInt(s, 10, 32)
+		return int32(v), err
+	case "int64":
+		return strconv.ParseInt(s, 10, 64)
+	case "float32":
+		v, err := strconv.ParseFloat(s, 32)
+		return float32(v), err
+	case "float64":
+		return strconv.ParseFloat(s, 64)
+	case "time":
+		return time.Parse(time.RFC3339Nano, s)
+	case "duration":
+		v, err := strconv.ParseInt(s, 10, 64)
+		return time.Duration(v), err
+	case "uuid":
+		return gocql.ParseUUID(s)
+	case "inet":
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, errors.Errorf("invalid inet value %q", s)
+		}
+		return ip, nil
+	case "varint":
+		v, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, errors.Errorf("invalid varint value %q", s)
+		}
+		return v, nil
+	case "decimal":
+		v, ok := new(inf.Dec).SetString(s)
+		if !ok {
+			return nil, errors.Errorf("invalid decimal value %q", s)
+		}
+		return v, nil
+	default:
+		return nil, errors.Errorf("unsupported value type %q", typ)
+	}
+}
+
+// decodeMap rebuilds a map value. Maps keyed by strings are returned as
+// map[string]interface{} since that is what the driver expects for UDTs,
+// the driver marshals any other map through reflection.
+func decodeMap(data json.RawMessage) (interface{}, error) {
+	var pairs [][2]typedValue
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return nil, err
+	}
+	keys := make([]interface{}, len(pairs))
+	elems := make([]interface{}, len(pairs))
+	stringKeys := true
+	for i := range pairs {
+		key, err := decodeValue(pairs[i][0])
+		if err != nil {
+			return nil, err
+		}
+		elem, err := decodeValue(pairs[i][1])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := key.(string); !ok {
+			stringKeys = false
+		}
+		keys[i], elems[i] = key, elem
+	}
+	if stringKeys {
+		out := make(map[string]interface{}, len(pairs))
+		for i := range keys {
+			out[keys[i].(string)] = elems[i]
+		}
+		return out, nil
+	}
+	out := make(map[interface{}]interface{}, len(pairs))
+	for i := range keys {
+		out[keys[i]] = elems[i]
+	}
+	return out, nil
+}
@@ -45,12 +45,15 @@ func (cs *cqlStore) name() string {
 	return cs.system
 }
 
-func (cs *cqlStore) mutate(ctx context.Context, builder qb.Builder, values ...interface{}) (err error) {
+func (cs *cqlStore) mutate(ctx context.Context, builder qb.Builder, ts time.Time, values ...interface{}) (err error) {
 	var i int
 	for i = 0; i < cs.maxRetriesMutate; i++ {
-		// retry with new timestamp as list modification with the same ts
-		// will produce duplicated values, see https://github.com/scylladb/scylladb/issues/7937
-		err = cs.doMutate(ctx, builder, time.Now(), values...)
+		if i > 0 {
+			// retry with new timestamp as list modification with the same ts
+			// will produce duplicated values, see https://github.com/scylladb/scylladb/issues/7937
+			ts = time.Now()
+		}
+		err = cs.doMutate(ctx, builder, ts, values...)
 		if err == nil {
 			cs.ops.WithLabelValues(cs.system, opType(builder)).Inc()
 			return nil
@@ -90,11 +93,91 @@ func (cs *cqlStore) doMutate(ctx context.Context, builder qb.Builder, ts time.Ti
 	return nil
 }
 
-func (cs *cqlStore) load(ctx context.Context, builder qb.Builder, values []interface{}) (result []map[string]interface{}, err error) {
-	query, _ := builder.ToCql()
-	iter := cs.session.Query(query, values...).WithContext(ctx).Iter()
+// mutateLWT applies a conditional statement once. Unlike the other mutations
+// it is not retried, a retry could observe the effects of its own timed out
+// attempt and report a different outcome.
+func (cs *cqlStore) mutateLWT(ctx context.Context, builder qb.Builder, ts time.Time, values ...interface{}) (*lwtResult, error) {
+	queryBody, _ := builder.ToCql()
+
+	query := cs.session.Query(queryBody, values...).WithContext(ctx)
+	if cs.useServerSideTimestamps {
+		query = query.DefaultTimestamp(false)
+	} else {
+		query = query.WithTimestamp(ts.UnixNano() / 1000)
+	}
+
+	// The result is read the way MapScanCAS does, with mapScan in place of
+	// MapScan which can not read vectors.
+	previous := make(map[string]interface{})
+	iter := query.NoSkipMetadata().Iter()
+	found := mapScan(iter, previous)
+	err := iter.Close()
+	if err == nil && !found {
+		err = gocql.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cluster = %s, query = '%s']", cs.system, queryBody)
+	}
+	applied, _ := previous["[applied]"].(bool)
+	delete(previous, "[applied]")
 	cs.ops.WithLabelValues(cs.system, opType(builder)).Inc()
-	return loadSet(iter), iter.Close()
+	return &lwtResult{applied: applied, previous: previous}, nil
+}
+
+// scan queries with the given page size, or the page size of the session if
+// it is not positive. The next page is only fetched once the rows of the
+// previous one were consumed.
+func (cs *cqlStore) scan(ctx context.Context, builder qb.Builder, values []interface{}, read typedef.ReadOptions) rowIterator {
+	query, _ := builder.ToCql()
+	newQuery := func() *gocql.Query {
+		cs.ops.WithLabelValues(cs.system, opType(builder)).Inc()
+		q := cs.session.Query(query, values...).WithContext(ctx)
+		if read.PageSize > 0 {
+			q = q.PageSize(read.PageSize)
+		}
+		return q
+	}
+	if read.ResumePaging {
+		return &resumingRowIterator{
+			iter:   newQuery().PageState(nil).Iter(),
+			system: cs.system,
+			query:  query,
+			page: func(state []byte) *gocql.Iter {
+				return newQuery().PageState(state).Iter()
+			},
+			pageCount: 1,
+		}
+	}
+	return &cqlRowIterator{iter: newQuery().Iter(), system: cs.system, query: query, pageCount: 1}
+}
+
+// built reads the build status of a view from view_build_status, it is built
+// once every node reports it built. Indexes are listed in IndexInfo once the
+// coordinator finished building them.
+func (cs *cqlStore) built(ctx context.Context, keyspace, name string, index bool) (bool, error) {
+	if index {
+		var indexName string
+		err := cs.session.Query(`SELECT index_name FROM system."IndexInfo" WHERE table_name = ? AND index_name = ?`, keyspace, name).
+			WithContext(ctx).Scan(&indexName)
+		if errs.Is(err, gocql.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	iter := cs.session.Query("SELECT status FROM system_distributed.view_build_status WHERE keyspace_name = ? AND view_name = ?", keyspace, name).
+		WithContext(ctx).Iter()
+	var status string
+	nodes, built := 0, 0
+	for iter.Scan(&status) {
+		nodes++
+		if status == "SUCCESS" {
+			built++
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return false, err
+	}
+	return nodes > 0 && built == nodes, nil
 }
 
 func (cs cqlStore) close() error {
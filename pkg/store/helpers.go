@@ -16,9 +16,7 @@ package store
 
 import (
 	"fmt"
-	"math/big"
 	"strings"
-	"time"
 
 	"github.com/gocql/gocql"
 
@@ -43,55 +41,35 @@ func extractRowValues(values []string, columns typedef.Columns, row map[string]i
 	return values
 }
 
-func lt(mi, mj map[string]interface{}) bool {
-	switch mis := mi["pk0"].(type) {
-	case []byte:
-		mjs, _ := mj["pk0"].([]byte)
-		return string(mis) < string(mjs)
-	case string:
-		mjs, _ := mj["pk0"].(string)
-		return mis < mjs
-	case int:
-		mjs, _ := mj["pk0"].(int)
-		return mis < mjs
-	case int8:
-		mjs, _ := mj["pk0"].(int8)
-		return mis < mjs
-	case int16:
-		mjs, _ := mj["pk0"].(int16)
-		return mis < mjs
-	case int32:
-		mjs, _ := mj["pk0"].(int32)
-		return mis < mjs
-	case int64:
-		mjs, _ := mj["pk0"].(int64)
-		return mis < mjs
-	case gocql.UUID:
-		mjs, _ := mj["pk0"].(gocql.UUID)
-		return mis.String() < mjs.String()
-	case time.Time:
-		mjs, _ := mj["pk0"].(time.Time)
-		return mis.UnixNano() < mjs.UnixNano()
-	case *big.Int:
-		mjs, _ := mj["pk0"].(*big.Int)
-		return mis.Cmp(mjs) < 0
-	case nil:
-		return true
-	default:
-		msg := fmt.Sprintf("unhandled type %T\n", mis)
-		time.Sleep(time.Second)
-		panic(msg)
+// compareRowKeys orders rows by every partition and clustering key of the
+// table. The keys are compared with the CQL ordering of their types, in
+// reverse for descending clustering keys. Keys a row does not have, because
+// they were not selected, sort first.
+func compareRowKeys(t *typedef.Table, a, b map[string]interface{}) int {
+	for _, pk := range t.PartitionKeys {
+		if c := compareCQLValues(pk.Type, a[pk.Name], b[pk.Name]); c != 0 {
+			return c
+		}
+	}
+	for _, ck := range t.ClusteringKeys {
+		if c := compareCQLValues(ck.Type, a[ck.Name], b[ck.Name]); c != 0 {
+			if ck.Descending {
+				return -c
+			}
+			return c
+		}
 	}
+	return 0
 }
 
-func loadSet(iter *gocql.Iter) []map[string]interface{} {
-	var rows []map[string]interface{}
-	for {
-		row := make(map[string]interface{})
-		if !iter.MapScan(row) {
-			break
-		}
-		rows = append(rows, row)
+// compareCQLValues compares values as the driver returns them. They are
+// serialized and compared like the in-memory store compares its cells, values
+// that can not be serialized as the type are compared by their text form.
+func compareCQLValues(typ typedef.Type, a, b interface{}) int {
+	aData, aErr := gocql.Marshal(typ.CQLType(), a)
+	bData, bErr := gocql.Marshal(typ.CQLType(), b)
+	if aErr != nil || bErr != nil {
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
 	}
-	return rows
+	return compareValues(typ, aData, bData)
 }
@@ -0,0 +1,1803 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scylladb/gocqlx/v2/qb"
+
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+// memStore is a reference implementation of the CQL semantics gemini relies on.
+// It keeps the data in memory and can replace the oracle cluster, mutations
+// are reconciled by write timestamp the same way the database does it.
+type memStore struct {
+	schema *typedef.Schema
+	ops    *prometheus.CounterVec
+	tables map[string]*memTable
+	// views maps the keyspace qualified names of the materialized views to
+	// the names of their base tables.
+	views  map[string]string
+	system string
+	seq    uint64
+	mu     sync.RWMutex
+}
+
+func newMemStore(schema *typedef.Schema, ops *prometheus.CounterVec, system string) *memStore {
+	views := make(map[string]string)
+	for _, t := range schema.GetTables() {
+		for _, mv := range t.MaterializedViews {
+			views[schema.Keyspace.Name+"."+mv.Name] = schema.Keyspace.Name + "." + t.Name
+		}
+	}
+	return &memStore{
+		schema: schema,
+		ops:    ops,
+		system: system,
+		tables: make(map[string]*memTable),
+		views:  views,
+	}
+}
+
+type memTable struct {
+	partitions map[string]*memPartition
+}
+
+type memPartition struct {
+	rows map[string]*memRow
+	// static holds the cells of the static columns, shared by all the rows.
+	static    *memRow
+	pk        [][]byte
+	ranges    []memRangeTombstone
+	token     int64
+	deletedAt int64
+}
+
+type memRangeTombstone struct {
+	where []boundRelation
+	ts    int64
+}
+
+type memRow struct {
+	cells       map[string]*memCell
+	collections map[string]*memCollection
+	marker      *memCell
+	ck          [][]byte
+	deletedAt   int64
+}
+
+type memCell struct {
+	expires time.Time
+	value   []byte
+	ts      int64
+	deleted bool
+}
+
+type memCollection struct {
+	elements  map[string]*memElement
+	deletedAt int64
+}
+
+type memElement struct {
+	key []byte
+	memCell
+}
+
+func (c *memCell) live(now time.Time, shadow int64) bool {
+	return c != nil && !c.deleted && c.ts > shadow && (c.expires.IsZero() || now.Before(c.expires))
+}
+
+// supersededBy reports whether the write n wins over the existing cell,
+// on timestamp ties deletions win, then the bigger value and then the
+// later expiration.
+func (c *memCell) supersededBy(n *memCell) bool {
+	if n.ts != c.ts {
+		return n.ts > c.ts
+	}
+	if n.deleted != c.deleted {
+		return n.deleted
+	}
+	if order := bytes.Compare(n.value, c.value); order != 0 {
+		return order > 0
+	}
+	// Of equal values an expiring cell wins and then the one expiring last.
+	if n.expires.IsZero() != c.expires.IsZero() {
+		return c.expires.IsZero()
+	}
+	return n.expires.After(c.expires)
+}
+
+// writeContext holds the timestamp and expiration of a single write.
+type writeContext struct {
+	now     time.Time
+	expires time.Time
+	ts      int64
+}
+
+func (w writeContext) cell(value []byte) *memCell {
+	if value == nil {
+		return w.tombstone()
+	}
+	return &memCell{value: value, ts: w.ts, expires: w.expires}
+}
+
+func (w writeContext) tombstone() *memCell {
+	return &memCell{ts: w.ts, deleted: true}
+}
+
+func (ms *memStore) name() string {
+	return ms.system
+}
+
+// built reports every view and index as built, their rows are derived from
+// the base table when they are read.
+func (ms *memStore) built(context.Context, string, string, bool) (bool, error) {
+	return true, nil
+}
+
+func (ms *memStore) close() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.tables = make(map[string]*memTable)
+	return nil
+}
+
+func (ms *memStore) mutate(_ context.Context, builder qb.Builder, ts time.Time, values ...interface{}) error {
+	query, _ := builder.ToCql()
+	stmt, err := parseCQL(query)
+	if err != nil {
+		return err
+	}
+	ms.mu.Lock()
+	err = ms.apply(stmt, values, ts.UnixNano()/1000)
+	ms.mu.Unlock()
+	if err != nil {
+		return errors.Wrapf(err, "[cluster = %s, query = '%s']", ms.system, query)
+	}
+	ms.ops.WithLabelValues(ms.system, opType(builder)).Inc()
+	return nil
+}
+
+// mutateLWT applies a conditional statement. The in-memory store only
+// reports whether it was applied, not the current values.
+func (ms *memStore) mutateLWT(_ context.Context, builder qb.Builder, ts time.Time, values ...interface{}) (*lwtResult, error) {
+	query, _ := builder.ToCql()
+	stmt, err := parseCQL(query)
+	if err != nil {
+		return nil, err
+	}
+	ms.mu.Lock()
+	applied, err := ms.conditionsHold(stmt, values, time.Now())
+	if err == nil && applied {
+		err = ms.apply(stmt, values, ts.UnixNano()/1000)
+	}
+	ms.mu.Unlock()
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cluster = %s, query = '%s']", ms.system, query)
+	}
+	ms.ops.WithLabelValues(ms.system, opType(builder)).Inc()
+	return &lwtResult{applied: applied}, nil
+}
+
+func (ms *memStore) load(_ context.Context, builder qb.Builder, values []interface{}) ([]map[string]interface{}, error) {
+	query, _ := builder.ToCql()
+	stmt, err := parseCQL(query)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.kind != cqlSelect {
+		return nil, errors.Errorf("unable to load data with '%s'", query)
+	}
+	ms.mu.RLock()
+	rows, err := ms.selectRows(stmt, values)
+	ms.mu.RUnlock()
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cluster = %s, query = '%s']", ms.system, query)
+	}
+	ms.ops.WithLabelValues(ms.system, opType(builder)).Inc()
+	return rows, nil
+}
+
+func (ms *memStore) scan(ctx context.Context, builder qb.Builder, values []interface{}, read typedef.ReadOptions) rowIterator {
+	rows, err := ms.load(ctx, builder, values)
+	return &sliceRowIterator{rows: rows, err: err, pageSize: read.PageSize}
+}
+
+// findTable resolves an optionally keyspace qualified name to the table
+// and, if the name is one of its views, the materialized view. Only the views
+// of the base table of a view are looked at, their reader holds the lock of
+// that table while schema changes of the other tables replace their views.
+func (ms *memStore) findTable(name string) (*typedef.Table, *typedef.MaterializedView) {
+	base, isView := ms.views[name]
+	if !isView {
+		base = name
+	}
+	base = base[strings.LastIndexByte(base, '.')+1:]
+	name = name[strings.LastIndexByte(name, '.')+1:]
+	for _, t := range ms.schema.GetTables() {
+		if t.Name != base {
+			continue
+		}
+		if !isView {
+			return t, nil
+		}
+		for i := range t.MaterializedViews {
+			if t.MaterializedViews[i].Name == name {
+				return t, &t.MaterializedViews[i]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// dataKey returns the key the data of the table is stored under, tables are
+// kept per keyspace so that statements against different keyspaces do not mix.
+func dataKey(name string, t *typedef.Table) string {
+	return name[:strings.LastIndexByte(name, '.')+1] + t.Name
+}
+
+func (ms *memStore) data(name string) *memTable {
+	mt, ok := ms.tables[name]
+	if !ok {
+		mt = &memTable{partitions: make(map[string]*memPartition)}
+		ms.tables[name] = mt
+	}
+	return mt
+}
+
+func (ms *memStore) writeContext(stmt *cqlStmt, values []interface{}, ts int64) (writeContext, error) {
+	w := writeContext{now: time.Now(), ts: ts}
+	if stmt.timestamp != nil {
+		v, err := intExpr(*stmt.timestamp, values)
+		if err != nil {
+			return w, errors.Wrap(err, "invalid timestamp")
+		}
+		w.ts = v
+	}
+	if stmt.ttl != nil {
+		v, err := intExpr(*stmt.ttl, values)
+		if err != nil {
+			return w, errors.Wrap(err, "invalid ttl")
+		}
+		if v > 0 {
+			w.expires = w.now.Add(time.Duration(v) * time.Second)
+		}
+	}
+	return w, nil
+}
+
+func intExpr(e cqlExpr, values []interface{}) (int64, error) {
+	data, err := marshalExpr(typedef.TYPE_BIGINT, e, values)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, errors.New("not a bigint")
+	}
+	return int64(binary.BigEndian.Uint64(data)), nil
+}
+
+// apply executes the statement with the client timestamp ts, in microseconds,
+// the test cluster receives the same one so both reconcile writes alike.
+func (ms *memStore) apply(stmt *cqlStmt, values []interface{}, ts int64) error {
+	switch stmt.kind {
+	case cqlDDL:
+		return ms.applyDDL(stmt.ddl)
+	case cqlBatch:
+		return ms.applyBatch(stmt, values, ts)
+	default:
+		w, err := ms.writeContext(stmt, values, ts)
+		if err != nil {
+			return err
+		}
+		return ms.applyMutation(stmt, values, w, true)
+	}
+}
+
+func (ms *memStore) applyBatch(stmt *cqlStmt, values []interface{}, ts int64) error {
+	w, err := ms.writeContext(stmt, values, ts)
+	if err != nil {
+		return err
+	}
+	if ok, condErr := ms.conditionsHold(stmt, values, w.now); condErr != nil || !ok {
+		return condErr
+	}
+	for _, child := range stmt.batch {
+		cw := w
+		if child.timestamp != nil || child.ttl != nil {
+			if cw, err = ms.writeContext(child, values, w.ts); err != nil {
+				return err
+			}
+		}
+		if err = ms.applyMutation(child, values, cw, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *memStore) applyDDL(query string) error {
+	fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	for i := range fields {
+		fields[i] = strings.Trim(fields[i], "\"")
+	}
+	upper := strings.ToUpper(strings.Join(fields, " "))
+	last := fields[len(fields)-1]
+	switch {
+	case strings.HasPrefix(upper, "DROP KEYSPACE"):
+		for name := range ms.tables {
+			if strings.HasPrefix(name, last+".") {
+				delete(ms.tables, name)
+			}
+		}
+		for name := range ms.views {
+			if strings.HasPrefix(name, last+".") {
+				delete(ms.views, name)
+			}
+		}
+	case strings.HasPrefix(upper, "CREATE MATERIALIZED VIEW"):
+		name := fields[3]
+		if strings.HasPrefix(upper, "CREATE MATERIALIZED VIEW IF NOT EXISTS") {
+			name = fields[6]
+		}
+		for i := 4; i < len(fields)-1; i++ {
+			if strings.EqualFold(fields[i], "FROM") {
+				ms.views[name] = fields[i+1]
+				break
+			}
+		}
+	case strings.HasPrefix(upper, "DROP MATERIALIZED VIEW"):
+		delete(ms.views, last)
+	case strings.HasPrefix(upper, "DROP TABLE"), strings.HasPrefix(upper, "TRUNCATE"):
+		delete(ms.tables, last)
+	case strings.HasPrefix(upper, "ALTER TABLE") && len(fields) >= 5 && strings.EqualFold(fields[3], "DROP"):
+		if mt, ok := ms.tables[fields[2]]; ok {
+			for _, column := range fields[4:] {
+				mt.dropColumn(strings.Trim(column, "(),"))
+			}
+		}
+	}
+	return nil
+}
+
+func (mt *memTable) dropColumn(column string) {
+	for _, p := range mt.partitions {
+		for _, r := range p.rows {
+			delete(r.cells, column)
+			delete(r.collections, column)
+		}
+		if p.static != nil {
+			delete(p.static.cells, column)
+			delete(p.static.collections, column)
+		}
+	}
+}
+
+// columnDef looks up a column of the table, kind is one of 'p'artition key,
+// 'c'lustering key or 'r'egular column.
+func columnDef(t *typedef.Table, name string) (*typedef.ColumnDef, byte) {
+	for _, c := range t.PartitionKeys {
+		if c.Name == name {
+			return c, 'p'
+		}
+	}
+	for _, c := range t.ClusteringKeys {
+		if c.Name == name {
+			return c, 'c'
+		}
+	}
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c, 'r'
+		}
+	}
+	return nil, 0
+}
+
+func (ms *memStore) applyMutation(stmt *cqlStmt, values []interface{}, w writeContext, checkConditions bool) error {
+	table, mv := ms.findTable(stmt.table)
+	if table == nil || mv != nil {
+		return errors.Errorf("unknown table %s", stmt.table)
+	}
+	if checkConditions && (stmt.ifExists || stmt.ifNotExist || len(stmt.conditions) > 0) {
+		ok, err := ms.checkConditions(stmt, values, w.now)
+		if err != nil || !ok {
+			return err
+		}
+	}
+	mt := ms.data(dataKey(stmt.table, table))
+	switch stmt.kind {
+	case cqlInsert:
+		cells := make(map[string][]byte, len(stmt.columns))
+		for i, name := range stmt.columns {
+			col, _ := columnDef(table, name)
+			if col == nil {
+				return errors.Errorf("unknown column %s", name)
+			}
+			if isUnset(stmt.values[i], values) {
+				continue
+			}
+			data, err := marshalExpr(col.Type, stmt.values[i], values)
+			if err != nil {
+				return err
+			}
+			cells[name] = data
+		}
+		return ms.insert(table, mt, cells, w)
+	case cqlInsertJSON:
+		cells, err := jsonCells(table, *stmt.json, values)
+		if err != nil {
+			return err
+		}
+		return ms.insert(table, mt, cells, w)
+	case cqlUpdate:
+		return ms.update(table, mt, stmt, values, w)
+	case cqlDelete:
+		return ms.delete(table, mt, stmt, values, w)
+	default:
+		return errors.Errorf("unsupported mutation of %s", stmt.table)
+	}
+}
+
+func jsonCells(table *typedef.Table, e cqlExpr, values []interface{}) (map[string][]byte, error) {
+	data, err := marshalExpr(typedef.TYPE_TEXT, e, values)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	doc := make(map[string]interface{})
+	if err = decoder.Decode(&doc); err != nil {
+		return nil, errors.Wrap(err, "invalid json")
+	}
+	cells := make(map[string][]byte, len(doc))
+	for _, cols := range []typedef.Columns{table.PartitionKeys, table.ClusteringKeys, table.Columns} {
+		for _, col := range cols {
+			v, convErr := jsonColumnValue(col.Type, doc[col.Name])
+			if convErr != nil {
+				return nil, errors.Wrapf(convErr, "invalid json value of %s", col.Name)
+			}
+			if cells[col.Name], err = marshalValue(col.Type, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return cells, nil
+}
+
+func keyParts(cols typedef.Columns, cells map[string][]byte) ([][]byte, error) {
+	parts := make([][]byte, len(cols))
+	for i, col := range cols {
+		v, ok := cells[col.Name]
+		if !ok || v == nil {
+			return nil, errors.Errorf("missing value of key column %s", col.Name)
+		}
+		parts[i] = v
+	}
+	return parts, nil
+}
+
+func (mt *memTable) partition(pk [][]byte) *memPartition {
+	key := compositeKey(pk)
+	p, ok := mt.partitions[key]
+	if !ok {
+		p = &memPartition{pk: pk, token: token(pk), rows: make(map[string]*memRow)}
+		mt.partitions[key] = p
+	}
+	return p
+}
+
+func (mt *memTable) row(pk, ck [][]byte) *memRow {
+	p := mt.partition(pk)
+	ckKey := compositeKey(ck)
+	r, ok := p.rows[ckKey]
+	if !ok {
+		r = &memRow{ck: ck, cells: make(map[string]*memCell), collections: make(map[string]*memCollection)}
+		p.rows[ckKey] = r
+	}
+	return r
+}
+
+func (ms *memStore) insert(table *typedef.Table, mt *memTable, cells map[string][]byte, w writeContext) error {
+	pk, err := keyParts(table.PartitionKeys, cells)
+	if err != nil {
+		return err
+	}
+	ck, err := keyParts(table.ClusteringKeys, cells)
+	if err != nil && !onlyStatic(table, cells) {
+		return err
+	}
+	var r *memRow
+	if err == nil {
+		r = mt.row(pk, ck)
+		marker := &memCell{ts: w.ts, expires: w.expires}
+		if r.marker == nil || r.marker.supersededBy(marker) {
+			r.marker = marker
+		}
+	}
+	for _, col := range table.Columns {
+		data, ok := cells[col.Name]
+		if !ok {
+			continue
+		}
+		target := r
+		if col.Static {
+			target = mt.partition(pk).staticRow()
+		}
+		if err = ms.setColumn(target, col, data, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onlyStatic reports whether the regular columns set by an insert are all
+// static, such an insert does not need a clustering key.
+func onlyStatic(table *typedef.Table, cells map[string][]byte) bool {
+	static := false
+	for _, col := range table.Columns {
+		if _, ok := cells[col.Name]; ok {
+			if !col.Static {
+				return false
+			}
+			static = true
+		}
+	}
+	return static
+}
+
+func (p *memPartition) staticRow() *memRow {
+	if p.static == nil {
+		p.static = &memRow{cells: make(map[string]*memCell), collections: make(map[string]*memCollection)}
+	}
+	return p.static
+}
+
+func (r *memRow) setCell(name string, c *memCell) {
+	if old, ok := r.cells[name]; !ok || old.supersededBy(c) {
+		r.cells[name] = c
+	}
+}
+
+func (r *memRow) collection(name string) *memCollection {
+	c, ok := r.collections[name]
+	if !ok {
+		c = &memCollection{elements: make(map[string]*memElement)}
+		r.collections[name] = c
+	}
+	return c
+}
+
+func (c *memCollection) set(key []byte, cell *memCell) {
+	if old, ok := c.elements[string(key)]; ok && !old.supersededBy(cell) {
+		return
+	}
+	c.elements[string(key)] = &memElement{key: key, memCell: *cell}
+}
+
+func (c *memCollection) clear(ts int64) {
+	if ts > c.deletedAt {
+		c.deletedAt = ts
+	}
+	for k, e := range c.elements {
+		if e.ts <= c.deletedAt {
+			delete(c.elements, k)
+		}
+	}
+}
+
+// setColumn overwrites the whole column value, non frozen collections are
+// cleared with a tombstone just below the write timestamp.
+func (ms *memStore) setColumn(r *memRow, col *typedef.ColumnDef, data []byte, w writeContext) error {
+	if !isMultiCell(col.Type) {
+		r.setCell(col.Name, w.cell(data))
+		return nil
+	}
+	c := r.collection(col.Name)
+	if data == nil {
+		c.clear(w.ts)
+		return nil
+	}
+	c.clear(w.ts - 1)
+	return ms.addElements(c, col.Type, data, w, false)
+}
+
+func (ms *memStore) addElements(c *memCollection, t typedef.Type, data []byte, w writeContext, prepend bool) error {
+	if udt, ok := t.(*typedef.UDTType); ok {
+		for _, name := range udtFieldNames(udt) {
+			var field []byte
+			field, data = readElement(data)
+			if field != nil {
+				c.set([]byte(name), w.cell(field))
+			}
+		}
+		return nil
+	}
+	_, isMap := t.(*typedef.MapType)
+	elems, err := splitCollection(data, isMap)
+	if err != nil {
+		return err
+	}
+	if isMap {
+		for i := 0; i < len(elems); i += 2 {
+			c.set(elems[i], w.cell(elems[i+1]))
+		}
+		return nil
+	}
+	if t.(*typedef.BagType).ComplexType == typedef.TYPE_SET {
+		for _, e := range elems {
+			c.set(e, w.cell([]byte{}))
+		}
+		return nil
+	}
+	if prepend {
+		for i := len(elems) - 1; i >= 0; i-- {
+			c.set(ms.listKey(true), w.cell(elems[i]))
+		}
+		return nil
+	}
+	for _, e := range elems {
+		c.set(ms.listKey(false), w.cell(e))
+	}
+	return nil
+}
+
+// listKey returns the position of a new list element, prepended elements
+// sort before all the existing ones and appended ones after them.
+func (ms *memStore) listKey(prepend bool) []byte {
+	ms.seq++
+	pos := uint64(1<<62) + ms.seq
+	if prepend {
+		pos = uint64(1<<62) - ms.seq
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, pos)
+	return key
+}
+
+// keyCandidates expands equality and IN restrictions of the key columns
+// into all the key combinations they select.
+func keyCandidates(cols typedef.Columns, where []boundRelation) ([][][]byte, bool) {
+	out := [][][]byte{{}}
+	for _, col := range cols {
+		var options [][]byte
+		for _, rel := range where {
+			if rel.isToken || len(rel.columns) != 1 || rel.columns[0].Name != col.Name {
+				continue
+			}
+			if rel.op == opEq || rel.op == opIn {
+				options = rel.values
+			}
+		}
+		if options == nil {
+			return nil, false
+		}
+		next := make([][][]byte, 0, len(out)*len(options))
+		for _, prefix := range out {
+			for _, o := range options {
+				key := append(append(make([][]byte, 0, len(prefix)+1), prefix...), o)
+				next = append(next, key)
+			}
+		}
+		out = next
+	}
+	return out, true
+}
+
+func (ms *memStore) update(table *typedef.Table, mt *memTable, stmt *cqlStmt, values []interface{}, w writeContext) error {
+	where, err := bindRelations(table, stmt.where, values)
+	if err != nil {
+		return err
+	}
+	pks, ok := keyCandidates(table.PartitionKeys, where)
+	if !ok {
+		return errors.New("partition key is not fully restricted")
+	}
+	var static, regular []cqlAssignment
+	for _, a := range stmt.assigns {
+		if col, _ := columnDef(table, a.column); col != nil && col.Static {
+			static = append(static, a)
+		} else {
+			regular = append(regular, a)
+		}
+	}
+	cks, ok := keyCandidates(table.ClusteringKeys, where)
+	if !ok && len(regular) > 0 {
+		return errors.New("clustering key is not fully restricted")
+	}
+	for _, pk := range pks {
+		// The static columns are updated once per partition, whatever the
+		// number of rows the clustering key selects.
+		for _, a := range static {
+			if err = ms.assign(mt.partition(pk).staticRow(), table, a, values, w); err != nil {
+				return err
+			}
+		}
+		if len(regular) == 0 {
+			continue
+		}
+		for _, ck := range cks {
+			r := mt.row(pk, ck)
+			for _, a := range regular {
+				if err = ms.assign(r, table, a, values, w); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (ms *memStore) assign(r *memRow, table *typedef.Table, a cqlAssignment, values []interface{}, w writeContext) error {
+	col, kind := columnDef(table, a.column)
+	if col == nil || kind != 'r' {
+		return errors.Errorf("column %s can not be updated", a.column)
+	}
+	if isUnset(a.value, values) {
+		return nil
+	}
+	switch a.kind {
+	case assignSet:
+		data, err := marshalExpr(col.Type, a.value, values)
+		if err != nil {
+			return err
+		}
+		return ms.setColumn(r, col, data, w)
+	case assignAppend, assignPrepend, assignRemove:
+		if _, ok := col.Type.(*typedef.CounterType); ok {
+			return ms.addCounter(r, col, a, values, w)
+		}
+		if !isMultiCell(col.Type) {
+			return errors.Errorf("invalid operation on frozen column %s", col.Name)
+		}
+		c := r.collection(col.Name)
+		if a.kind != assignRemove {
+			data, err := marshalExpr(col.Type, a.value, values)
+			if err != nil {
+				return err
+			}
+			return ms.addElements(c, col.Type, data, w, a.kind == assignPrepend)
+		}
+		return ms.removeElements(c, col.Type, a.value, values, w)
+	case assignElement:
+		return ms.setElement(r, col, a, values, w)
+	case assignField:
+		return ms.setField(r, col, a, values, w)
+	default:
+		return errors.Errorf("unsupported assignment to %s", col.Name)
+	}
+}
+
+func (ms *memStore) addCounter(r *memRow, col *typedef.ColumnDef, a cqlAssignment, values []interface{}, w writeContext) error {
+	delta, err := intExpr(a.value, values)
+	if err != nil {
+		return errors.Wrapf(err, "invalid counter update of %s", col.Name)
+	}
+	if a.kind == assignRemove {
+		delta = -delta
+	}
+	var current int64
+	if c := r.cells[col.Name]; c.live(w.now, r.deletedAt) && len(c.value) == 8 {
+		current = int64(binary.BigEndian.Uint64(c.value))
+	}
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(current+delta))
+	r.cells[col.Name] = &memCell{value: data, ts: w.ts}
+	return nil
+}
+
+func (ms *memStore) removeElements(c *memCollection, t typedef.Type, e cqlExpr, values []interface{}, w writeContext) error {
+	switch tt := t.(type) {
+	case *typedef.MapType:
+		keys, err := marshalExpr(&typedef.BagType{ComplexType: typedef.TYPE_SET, ValueType: tt.KeyType}, e, values)
+		if err != nil {
+			return err
+		}
+		elems, err := splitCollection(keys, false)
+		if err != nil {
+			return err
+		}
+		for _, k := range elems {
+			c.set(k, w.tombstone())
+		}
+	case *typedef.BagType:
+		data, err := marshalExpr(t, e, values)
+		if err != nil {
+			return err
+		}
+		elems, err := splitCollection(data, false)
+		if err != nil {
+			return err
+		}
+		for _, v := range elems {
+			if tt.ComplexType == typedef.TYPE_SET {
+				c.set(v, w.tombstone())
+				continue
+			}
+			for _, el := range c.elements {
+				if el.live(w.now, c.deletedAt) && bytes.Equal(el.value, v) {
+					c.set(el.key, w.tombstone())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (ms *memStore) setElement(r *memRow, col *typedef.ColumnDef, a cqlAssignment, values []interface{}, w writeContext) error {
+	if !isMultiCell(col.Type) {
+		return errors.Errorf("invalid element update of frozen column %s", col.Name)
+	}
+	c := r.collection(col.Name)
+	switch tt := col.Type.(type) {
+	case *typedef.MapType:
+		key, err := marshalExpr(tt.KeyType, a.key, values)
+		if err != nil {
+			return err
+		}
+		value, err := marshalExpr(tt.ValueType, a.value, values)
+		if err != nil {
+			return err
+		}
+		c.set(key, w.cell(value))
+	case *typedef.BagType:
+		if tt.ComplexType != typedef.TYPE_LIST {
+			return errors.Errorf("invalid element update of set %s", col.Name)
+		}
+		el, err := c.listElement(a.key, values, w.now)
+		if err != nil {
+			return err
+		}
+		value, err := marshalExpr(tt.ValueType, a.value, values)
+		if err != nil {
+			return err
+		}
+		c.set(el.key, w.cell(value))
+	}
+	return nil
+}
+
+func (c *memCollection) listElement(idx cqlExpr, values []interface{}, now time.Time) (*memElement, error) {
+	pos, err := marshalExpr(typedef.TYPE_INT, idx, values)
+	if err != nil {
+		return nil, err
+	}
+	if len(pos) != 4 {
+		return nil, errors.New("invalid list index")
+	}
+	i := int(int32(binary.BigEndian.Uint32(pos)))
+	live := c.sorted(typedef.TYPE_BLOB, now, 0)
+	if i < 0 || i >= len(live) {
+		return nil, errors.Errorf("list index %d out of bound, list has size %d", i, len(live))
+	}
+	return live[i], nil
+}
+
+func (ms *memStore) setField(r *memRow, col *typedef.ColumnDef, a cqlAssignment, values []interface{}, w writeContext) error {
+	udt, ok := col.Type.(*typedef.UDTType)
+	if !ok {
+		return errors.Errorf("column %s is not a user defined type", col.Name)
+	}
+	ft, ok := udt.ValueTypes[a.field]
+	if !ok {
+		return errors.Errorf("unknown field %s of %s", a.field, col.Name)
+	}
+	var field []byte
+	if a.value.bind >= 0 || a.value.isNull || a.value.literal != "" {
+		data, err := marshalExpr(ft, a.value, values)
+		if err != nil {
+			return err
+		}
+		field = data
+	}
+	if isMultiCell(udt) {
+		r.collection(col.Name).set([]byte(a.field), w.cell(field))
+		return nil
+	}
+	return ms.rewriteField(r, col, udt, a.field, field, w)
+}
+
+// rewriteField replaces a single field of a UDT value.
+func (ms *memStore) rewriteField(r *memRow, col *typedef.ColumnDef, udt *typedef.UDTType, name string, field []byte, w writeContext) error {
+	var current []byte
+	if c := r.cells[col.Name]; c.live(w.now, r.deletedAt) {
+		current = c.value
+	}
+	var buf bytes.Buffer
+	for _, fn := range udtFieldNames(udt) {
+		var v []byte
+		v, current = readElement(current)
+		if fn == name {
+			v = field
+		}
+		writeBytes(&buf, v)
+	}
+	r.setCell(col.Name, w.cell(buf.Bytes()))
+	return nil
+}
+
+func (ms *memStore) delete(table *typedef.Table, mt *memTable, stmt *cqlStmt, values []interface{}, w writeContext) error {
+	where, err := bindRelations(table, stmt.where, values)
+	if err != nil {
+		return err
+	}
+	pks, ok := keyCandidates(table.PartitionKeys, where)
+	if !ok {
+		return errors.New("partition key is not fully restricted")
+	}
+	var ckWhere []boundRelation
+	for _, rel := range where {
+		if _, kind := columnDef(table, rel.columns[0].Name); kind == 'c' {
+			ckWhere = append(ckWhere, rel)
+		}
+	}
+	cks, fullCK := keyCandidates(table.ClusteringKeys, ckWhere)
+	for _, pk := range pks {
+		switch {
+		case len(stmt.deletes) > 0:
+			for _, sel := range stmt.deletes {
+				if col, _ := columnDef(table, sel.column); col != nil && col.Static {
+					if err = ms.deleteColumn(mt.partition(pk).staticRow(), table, sel, values, w); err != nil {
+						return err
+					}
+					continue
+				}
+				if !fullCK {
+					return errors.New("clustering key is not fully restricted")
+				}
+				for _, ck := range cks {
+					if err = ms.deleteColumn(mt.row(pk, ck), table, sel, values, w); err != nil {
+						return err
+					}
+				}
+			}
+		case len(ckWhere) == 0:
+			p := mt.partition(pk)
+			if w.ts > p.deletedAt {
+				p.deletedAt = w.ts
+			}
+		case fullCK && len(table.ClusteringKeys) > 0:
+			for _, ck := range cks {
+				r := mt.row(pk, ck)
+				if w.ts > r.deletedAt {
+					r.deletedAt = w.ts
+				}
+			}
+		default:
+			p := mt.partition(pk)
+			p.ranges = append(p.ranges, memRangeTombstone{where: ckWhere, ts: w.ts})
+		}
+	}
+	return nil
+}
+
+func (ms *memStore) deleteColumn(r *memRow, table *typedef.Table, sel cqlSelector, values []interface{}, w writeContext) error {
+	col, kind := columnDef(table, sel.column)
+	if col == nil || kind != 'r' {
+		return errors.Errorf("column %s can not be deleted", sel.column)
+	}
+	switch {
+	case sel.field != "":
+		udt, ok := col.Type.(*typedef.UDTType)
+		if !ok {
+			return errors.Errorf("column %s is not a user defined type", col.Name)
+		}
+		if isMultiCell(udt) {
+			r.collection(col.Name).set([]byte(sel.field), w.tombstone())
+			return nil
+		}
+		return ms.rewriteField(r, col, udt, sel.field, nil, w)
+	case sel.key != nil:
+		if !isMultiCell(col.Type) {
+			return errors.Errorf("invalid element deletion of frozen column %s", col.Name)
+		}
+		c := r.collection(col.Name)
+		switch tt := col.Type.(type) {
+		case *typedef.MapType:
+			key, err := marshalExpr(tt.KeyType, *sel.key, values)
+			if err != nil {
+				return err
+			}
+			c.set(key, w.tombstone())
+		case *typedef.BagType:
+			if tt.ComplexType == typedef.TYPE_SET {
+				key, err := marshalExpr(tt.ValueType, *sel.key, values)
+				if err != nil {
+					return err
+				}
+				c.set(key, w.tombstone())
+				return nil
+			}
+			el, err := c.listElement(*sel.key, values, w.now)
+			if err != nil {
+				return err
+			}
+			c.set(el.key, w.tombstone())
+		}
+		return nil
+	case isMultiCell(col.Type):
+		r.collection(col.Name).clear(w.ts)
+		return nil
+	default:
+		r.setCell(col.Name, w.tombstone())
+		return nil
+	}
+}
+
+// conditionsHold reports whether all the conditions of the statement, or of
+// all the statements of a batch, hold.
+func (ms *memStore) conditionsHold(stmt *cqlStmt, values []interface{}, now time.Time) (bool, error) {
+	stmts := []*cqlStmt{stmt}
+	if stmt.kind == cqlBatch {
+		stmts = stmt.batch
+	}
+	for _, s := range stmts {
+		if !s.ifExists && !s.ifNotExist && len(s.conditions) == 0 {
+			continue
+		}
+		if ok, err := ms.checkConditions(s, values, now); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// checkConditions evaluates the IF clause of a statement against the current data.
+func (ms *memStore) checkConditions(stmt *cqlStmt, values []interface{}, now time.Time) (bool, error) {
+	table, mv := ms.findTable(stmt.table)
+	if table == nil || mv != nil {
+		return false, errors.Errorf("unknown table %s", stmt.table)
+	}
+	var where []boundRelation
+	var err error
+	if stmt.kind == cqlInsertJSON {
+		cells, jsonErr := jsonCells(table, *stmt.json, values)
+		if jsonErr != nil {
+			return false, jsonErr
+		}
+		where = eqRelations(table, cells)
+	} else if stmt.kind == cqlInsert {
+		cells := make(map[string][]byte)
+		for i, name := range stmt.columns {
+			col, _ := columnDef(table, name)
+			if col == nil {
+				return false, errors.Errorf("unknown column %s", name)
+			}
+			if cells[name], err = marshalExpr(col.Type, stmt.values[i], values); err != nil {
+				return false, err
+			}
+		}
+		where = eqRelations(table, cells)
+	} else if where, err = bindRelations(table, stmt.where, values); err != nil {
+		return false, err
+	}
+	views := ms.tableViews(table, ms.data(dataKey(stmt.table, table)), where, now)
+	switch {
+	case stmt.ifNotExist:
+		return len(views) == 0, nil
+	case stmt.ifExists:
+		return len(views) > 0, nil
+	}
+	conditions, err := bindRelations(table, stmt.conditions, values)
+	if err != nil {
+		return false, err
+	}
+	if len(views) == 0 {
+		views = []*memView{{values: map[string][]byte{}}}
+	}
+	for _, v := range views {
+		for _, cond := range conditions {
+			if !cond.matches(v) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+func eqRelations(table *typedef.Table, cells map[string][]byte) []boundRelation {
+	var out []boundRelation
+	for _, cols := range []typedef.Columns{table.PartitionKeys, table.ClusteringKeys} {
+		for _, col := range cols {
+			out = append(out, boundRelation{op: opEq, columns: []*typedef.ColumnDef{col}, values: [][]byte{cells[col.Name]}})
+		}
+	}
+	return out
+}
+
+// memView is a live row of a table or materialized view with all the
+// column values serialized.
+type memView struct {
+	values map[string][]byte
+	cells  map[string]*memCell
+	keys   [][]byte
+	token  int64
+}
+
+func (c *memCollection) sorted(elemType typedef.Type, now time.Time, shadow int64) []*memElement {
+	if c.deletedAt > shadow {
+		shadow = c.deletedAt
+	}
+	out := make([]*memElement, 0, len(c.elements))
+	for _, e := range c.elements {
+		if e.live(now, shadow) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return compareValues(elemType, out[i].key, out[j].key) < 0
+	})
+	return out
+}
+
+func (c *memCollection) value(t typedef.Type, now time.Time, shadow int64) []byte {
+	switch tt := t.(type) {
+	case *typedef.UDTType:
+		if c.deletedAt > shadow {
+			shadow = c.deletedAt
+		}
+		var buf bytes.Buffer
+		live := false
+		for _, name := range udtFieldNames(tt) {
+			var field []byte
+			if e, ok := c.elements[name]; ok && e.live(now, shadow) {
+				field, live = e.value, true
+			}
+			writeBytes(&buf, field)
+		}
+		if !live {
+			return nil
+		}
+		return buf.Bytes()
+	case *typedef.MapType:
+		live := c.sorted(tt.KeyType, now, shadow)
+		elems := make([][]byte, 0, len(live)*2)
+		for _, e := range live {
+			elems = append(elems, e.key, e.value)
+		}
+		return joinCollection(elems, true)
+	case *typedef.BagType:
+		if tt.ComplexType == typedef.TYPE_SET {
+			live := c.sorted(tt.ValueType, now, shadow)
+			elems := make([][]byte, 0, len(live))
+			for _, e := range live {
+				elems = append(elems, e.key)
+			}
+			return joinCollection(elems, false)
+		}
+		live := c.sorted(typedef.TYPE_BLOB, now, shadow)
+		elems := make([][]byte, 0, len(live))
+		for _, e := range live {
+			elems = append(elems, e.value)
+		}
+		return joinCollection(elems, false)
+	}
+	return nil
+}
+
+// view returns the serialized live values of the row or nil if the row is dead.
+func (r *memRow) view(table *typedef.Table, p *memPartition, now time.Time) *memView {
+	shadow := r.deletedAt
+	if p.deletedAt > shadow {
+		shadow = p.deletedAt
+	}
+	if len(p.ranges) > 0 {
+		ckView := &memView{values: make(map[string][]byte, len(table.ClusteringKeys))}
+		for i, col := range table.ClusteringKeys {
+			ckView.values[col.Name] = r.ck[i]
+		}
+		for _, rt := range p.ranges {
+			if rt.ts > shadow && matchesAll(rt.where, ckView) {
+				shadow = rt.ts
+			}
+		}
+	}
+	v := &memView{
+		values: make(map[string][]byte, len(table.PartitionKeys)+len(table.ClusteringKeys)+len(table.Columns)),
+		cells:  make(map[string]*memCell, len(table.Columns)),
+		token:  p.token,
+	}
+	live := r.marker.live(now, shadow)
+	for _, col := range table.Columns {
+		// The static cells are read along with every row, but do not keep
+		// a row alive.
+		if col.Static {
+			if p.static != nil {
+				p.static.setValue(v, col, now, p.deletedAt)
+			}
+			continue
+		}
+		if r.setValue(v, col, now, shadow) {
+			live = true
+		}
+	}
+	if !live {
+		return nil
+	}
+	for i, col := range table.PartitionKeys {
+		v.values[col.Name] = p.pk[i]
+	}
+	for i, col := range table.ClusteringKeys {
+		v.values[col.Name] = r.ck[i]
+	}
+	v.keys = r.ck
+	return v
+}
+
+// setValue puts the live value of the column of the row into the view and
+// reports whether there is one.
+func (r *memRow) setValue(v *memView, col *typedef.ColumnDef, now time.Time, shadow int64) bool {
+	if isMultiCell(col.Type) {
+		c, ok := r.collections[col.Name]
+		if !ok {
+			return false
+		}
+		data := c.value(col.Type, now, shadow)
+		if data == nil {
+			return false
+		}
+		v.values[col.Name] = data
+		return true
+	}
+	c := r.cells[col.Name]
+	if !c.live(now, shadow) {
+		return false
+	}
+	v.values[col.Name] = c.value
+	v.cells[col.Name] = c
+	return true
+}
+
+// staticView returns the row a partition without live rows reads as when
+// its static columns are set, its clustering key is null.
+func (p *memPartition) staticView(table *typedef.Table, now time.Time) *memView {
+	if p.static == nil {
+		return nil
+	}
+	v := &memView{
+		values: make(map[string][]byte, len(table.PartitionKeys)+len(table.Columns)),
+		cells:  make(map[string]*memCell, len(table.Columns)),
+		keys:   make([][]byte, len(table.ClusteringKeys)),
+		token:  p.token,
+	}
+	live := false
+	for _, col := range table.Columns {
+		if col.Static && p.static.setValue(v, col, now, p.deletedAt) {
+			live = true
+		}
+	}
+	if !live {
+		return nil
+	}
+	for i, col := range table.PartitionKeys {
+		v.values[col.Name] = p.pk[i]
+	}
+	return v
+}
+
+// restrictsClusteringKey reports whether any of the relations is on the
+// clustering key, the static row of a partition never matches them.
+func restrictsClusteringKey(table *typedef.Table, where []boundRelation) bool {
+	for _, rel := range where {
+		if rel.isToken {
+			continue
+		}
+		if _, kind := columnDef(table, rel.columns[0].Name); kind == 'c' {
+			return true
+		}
+	}
+	return false
+}
+
+// tableViews returns the live rows of the table matching the restrictions.
+func (ms *memStore) tableViews(table *typedef.Table, mt *memTable, where []boundRelation, now time.Time) []*memView {
+	var partitions []*memPartition
+	if pks, ok := keyCandidates(table.PartitionKeys, where); ok {
+		for _, pk := range pks {
+			if p, exists := mt.partitions[compositeKey(pk)]; exists {
+				partitions = append(partitions, p)
+			}
+		}
+	} else {
+		for _, p := range mt.partitions {
+			partitions = append(partitions, p)
+		}
+	}
+	var out []*memView
+	for _, p := range partitions {
+		rows := false
+		for _, r := range p.rows {
+			v := r.view(table, p, now)
+			if v == nil {
+				continue
+			}
+			rows = true
+			if matchesAll(where, v) {
+				out = append(out, v)
+			}
+		}
+		if rows || restrictsClusteringKey(table, where) {
+			continue
+		}
+		if v := p.staticView(table, now); v != nil && matchesAll(where, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// mvViews derives the rows of the materialized view from the base table.
+func (ms *memStore) mvViews(table *typedef.Table, mv *typedef.MaterializedView, mt *memTable, where []boundRelation, now time.Time) ([]*memView, error) {
+	filters, err := bindRelations(table, viewFilters(mv), nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid filter of view %s", mv.Name)
+	}
+	var out []*memView
+	for _, v := range ms.tableViews(table, mt, filters, now) {
+		pk := make([][]byte, len(mv.PartitionKeys))
+		ck := make([][]byte, len(mv.ClusteringKeys))
+		missing := false
+		for i, col := range mv.PartitionKeys {
+			if pk[i] = v.values[col.Name]; pk[i] == nil {
+				missing = true
+			}
+		}
+		for i, col := range mv.ClusteringKeys {
+			if ck[i] = v.values[col.Name]; ck[i] == nil {
+				missing = true
+			}
+		}
+		if missing {
+			continue
+		}
+		v.token = token(pk)
+		v.keys = ck
+		if matchesAll(where, v) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// viewHolds tells whether the rows of the view hold the base column.
+func viewHolds(mv *typedef.MaterializedView, col *typedef.ColumnDef) bool {
+	for _, cols := range []typedef.Columns{mv.PartitionKeys, mv.ClusteringKeys} {
+		for _, key := range cols {
+			if key.Name == col.Name {
+				return true
+			}
+		}
+	}
+	return mv.SelectsColumn(col.Name)
+}
+
+func viewFilters(mv *typedef.MaterializedView) []cqlRelation {
+	relations := make([]cqlRelation, 0, len(mv.Filters))
+	for _, f := range mv.Filters {
+		relations = append(relations, cqlRelation{
+			op:      cqlOp(f.Op),
+			columns: []string{f.Column.Name},
+			values:  []cqlExpr{{bind: -1, literal: f.Value}},
+		})
+	}
+	return relations
+}
+
+func (ms *memStore) selectRows(stmt *cqlStmt, values []interface{}) ([]map[string]interface{}, error) {
+	table, mv := ms.findTable(stmt.table)
+	if table == nil {
+		return nil, errors.Errorf("unknown table %s", stmt.table)
+	}
+	where, err := bindRelations(table, stmt.where, values)
+	if err != nil {
+		return nil, err
+	}
+	mt, ok := ms.tables[dataKey(stmt.table, table)]
+	if !ok {
+		return nil, nil
+	}
+	now := time.Now()
+	pkTypes, ckTypes := table.PartitionKeys, table.ClusteringKeys
+	var views []*memView
+	if mv != nil {
+		if views, err = ms.mvViews(table, mv, mt, where, now); err != nil {
+			return nil, err
+		}
+		pkTypes, ckTypes = mv.PartitionKeys, mv.ClusteringKeys
+	} else {
+		views = ms.tableViews(table, mt, where, now)
+	}
+	reversed, err := reversedOrder(ckTypes, stmt.orderBy)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].token != views[j].token {
+			return views[i].token < views[j].token
+		}
+		for k, col := range ckTypes {
+			if c := compareValues(col.Type, views[i].keys[k], views[j].keys[k]); c != 0 {
+				return c < 0 != (col.Descending != reversed)
+			}
+		}
+		return false
+	})
+	if isAggregate(stmt.selections) {
+		row, aggErr := aggregateRow(table, stmt.selections, views)
+		if aggErr != nil {
+			return nil, aggErr
+		}
+		return []map[string]interface{}{row}, nil
+	}
+	if views, err = limitViews(pkTypes, stmt, views, values); err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]interface{}, 0, len(views))
+	for _, v := range views {
+		if stmt.jsonResult {
+			row, jsonErr := jsonRow(table, stmt.selections, v, now)
+			if jsonErr != nil {
+				return nil, jsonErr
+			}
+			rows = append(rows, row)
+			continue
+		}
+		if len(stmt.selections) > 0 {
+			row, selErr := selectedRow(table, stmt.selections, v, now)
+			if selErr != nil {
+				return nil, selErr
+			}
+			rows = append(rows, row)
+			continue
+		}
+		row := make(map[string]interface{}, len(v.values))
+		for _, cols := range []typedef.Columns{table.PartitionKeys, table.ClusteringKeys, table.Columns} {
+			for _, col := range cols {
+				if mv != nil && !viewHolds(mv, col) {
+					continue
+				}
+				if err = unmarshalColumn(row, col, v.values[col.Name]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// reversedOrder tells whether ORDER BY reverses the clustering order. The
+// clustering columns have to be ordered in sequence, all in their clustering
+// order or all in reverse.
+func reversedOrder(ckTypes typedef.Columns, orderBy []cqlOrdering) (bool, error) {
+	if len(orderBy) == 0 {
+		return false, nil
+	}
+	if len(orderBy) > len(ckTypes) {
+		return false, errors.New("ORDER BY lists more columns than the clustering key has")
+	}
+	reversed := orderBy[0].desc != ckTypes[0].Descending
+	for i, o := range orderBy {
+		if o.column != ckTypes[i].Name || (o.desc != ckTypes[i].Descending) != reversed {
+			return false, errors.Errorf("unsupported ORDER BY %s", o.column)
+		}
+	}
+	return reversed, nil
+}
+
+// limitViews applies PER PARTITION LIMIT and then LIMIT to the ordered views.
+func limitViews(pkTypes typedef.Columns, stmt *cqlStmt, views []*memView, values []interface{}) ([]*memView, error) {
+	if stmt.partitionLimit != nil {
+		limit, err := limitExpr(*stmt.partitionLimit, values)
+		if err != nil {
+			return nil, err
+		}
+		limited := views[:0]
+		var inPartition int64
+		for i, v := range views {
+			if i == 0 || !samePartition(pkTypes, views[i-1], v) {
+				inPartition = 0
+			}
+			if inPartition++; inPartition <= limit {
+				limited = append(limited, v)
+			}
+		}
+		views = limited
+	}
+	if stmt.limit != nil {
+		limit, err := limitExpr(*stmt.limit, values)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(views)) > limit {
+			views = views[:limit]
+		}
+	}
+	return views, nil
+}
+
+func limitExpr(e cqlExpr, values []interface{}) (int64, error) {
+	limit, err := intExpr(e, values)
+	if err == nil && limit <= 0 {
+		err = errors.Errorf("LIMIT must be strictly positive, got %d", limit)
+	}
+	return limit, err
+}
+
+func samePartition(pkTypes typedef.Columns, a, b *memView) bool {
+	if a.token != b.token {
+		return false
+	}
+	for _, col := range pkTypes {
+		if !bytes.Equal(a.values[col.Name], b.values[col.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectedRow returns the selected columns of the row.
+func selectedRow(table *typedef.Table, selections []cqlSelection, v *memView, now time.Time) (map[string]interface{}, error) {
+	row := make(map[string]interface{}, len(selections))
+	for _, sel := range selections {
+		typ, data, err := selectedValue(table, sel, v, now)
+		if err != nil {
+			return nil, err
+		}
+		if err = unmarshalColumn(row, &typedef.ColumnDef{Name: sel.name(), Type: typ}, data); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+// selectedValue evaluates a selection on the row, it returns the type of the
+// result and the result serialized. TTL and WRITETIME are read of the cells
+// of regular columns and are null for unset cells.
+func selectedValue(table *typedef.Table, sel cqlSelection, v *memView, now time.Time) (typedef.Type, []byte, error) {
+	if sel.fn == "TOKEN" {
+		data, err := marshalValue(typedef.TYPE_BIGINT, v.token)
+		return typedef.TYPE_BIGINT, data, err
+	}
+	col, kind := columnDef(table, sel.column)
+	if col == nil {
+		return nil, nil, errors.Errorf("unknown column %s", sel.column)
+	}
+	data := v.values[col.Name]
+	switch sel.fn {
+	case "":
+		return col.Type, data, nil
+	case "TTL", "WRITETIME":
+		if kind != 'r' || isMultiCell(col.Type) {
+			return nil, nil, errors.Errorf("%s is not supported on column %s", sel.fn, col.Name)
+		}
+		var value interface{}
+		c := v.cells[col.Name]
+		out := typedef.TYPE_INT
+		if sel.fn == "WRITETIME" {
+			out = typedef.TYPE_BIGINT
+			if c != nil {
+				value = c.ts
+			}
+		} else if c != nil && !c.expires.IsZero() {
+			value = int32(c.expires.Unix() - now.Unix())
+		}
+		data, err := marshalValue(out, value)
+		return out, data, err
+	default:
+		return scalarFunction(sel, col, data)
+	}
+}
+
+// boundRelation is a restriction with its values serialized.
+type boundRelation struct {
+	op      cqlOp
+	columns []*typedef.ColumnDef
+	values  [][]byte
+	key     []byte
+	token   int64
+	isToken bool
+	isEntry bool
+}
+
+func bindRelations(table *typedef.Table, relations []cqlRelation, values []interface{}) ([]boundRelation, error) {
+	out := make([]boundRelation, 0, len(relations))
+	for _, rel := range relations {
+		b := boundRelation{op: rel.op, isToken: rel.token}
+		for _, name := range rel.columns {
+			col, _ := columnDef(table, name)
+			if col == nil {
+				return nil, errors.Errorf("unknown column %s", name)
+			}
+			b.columns = append(b.columns, col)
+		}
+		if rel.rawToken {
+			tok, err := intExpr(rel.values[0], values)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid token")
+			}
+			b.token = tok
+			out = append(out, b)
+			continue
+		}
+		if rel.key != nil {
+			mt, ok := b.columns[0].Type.(*typedef.MapType)
+			if !ok || rel.op != opEq {
+				return nil, errors.Errorf("unsupported relation on an element of %s", b.columns[0].Name)
+			}
+			key, err := marshalExpr(mt.KeyType, *rel.key, values)
+			if err != nil {
+				return nil, err
+			}
+			value, err := marshalExpr(mt.ValueType, rel.values[0], values)
+			if err != nil {
+				return nil, err
+			}
+			b.key, b.values, b.isEntry = key, [][]byte{value}, true
+			out = append(out, b)
+			continue
+		}
+		if rel.token {
+			parts := make([][]byte, len(rel.values))
+			for i, e := range rel.values {
+				data, err := marshalExpr(b.columns[i].Type, e, values)
+				if err != nil {
+					return nil, err
+				}
+				parts[i] = data
+			}
+			b.token = token(parts)
+			out = append(out, b)
+			continue
+		}
+		for i, e := range rel.values {
+			t := b.columns[0].Type
+			if len(b.columns) > 1 {
+				t = b.columns[i].Type
+			}
+			switch tt := t.(type) {
+			case *typedef.BagType:
+				if rel.op == opContains {
+					t = tt.ValueType
+				}
+			case *typedef.MapType:
+				if rel.op == opContains {
+					t = tt.ValueType
+				} else if rel.op == opContainsKey {
+					t = tt.KeyType
+				}
+			}
+			data, err := marshalExpr(t, e, values)
+			if err != nil {
+				return nil, err
+			}
+			b.values = append(b.values, data)
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+func matchesAll(relations []boundRelation, v *memView) bool {
+	for i := range relations {
+		if !relations[i].matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *boundRelation) matches(v *memView) bool {
+	if b.isToken {
+		c := 0
+		switch {
+		case v.token < b.token:
+			c = -1
+		case v.token > b.token:
+			c = 1
+		}
+		return opMatches(b.op, c)
+	}
+	if len(b.columns) > 1 {
+		c := 0
+		for i, col := range b.columns {
+			if c = compareValues(col.Type, v.values[col.Name], b.values[i]); c != 0 {
+				break
+			}
+		}
+		return opMatches(b.op, c)
+	}
+	col := b.columns[0]
+	value := v.values[col.Name]
+	if b.isEntry {
+		elems, err := splitCollection(value, true)
+		if err != nil {
+			return false
+		}
+		for i := 0; i+1 < len(elems); i += 2 {
+			if bytes.Equal(elems[i], b.key) {
+				return bytes.Equal(elems[i+1], b.values[0])
+			}
+		}
+		return false
+	}
+	switch b.op {
+	case opIn:
+		for _, candidate := range b.values {
+			if value != nil && compareValues(col.Type, value, candidate) == 0 {
+				return true
+			}
+		}
+		return false
+	case opContains, opContainsKey:
+		_, isMap := col.Type.(*typedef.MapType)
+		elems, err := splitCollection(value, isMap)
+		if err != nil {
+			return false
+		}
+		for i, e := range elems {
+			if isMap && (i%2 == 0) != (b.op == opContainsKey) {
+				continue
+			}
+			if bytes.Equal(e, b.values[0]) {
+				return true
+			}
+		}
+		return false
+	case opEq:
+		if value == nil || b.values[0] == nil {
+			// null only equals null, unlike an empty value.
+			return value == nil && b.values[0] == nil
+		}
+		return bytes.Equal(value, b.values[0]) || compareValues(col.Type, value, b.values[0]) == 0
+	case opNotEq:
+		return !bytes.Equal(value, b.values[0])
+	default:
+		if value == nil || b.values[0] == nil {
+			return false
+		}
+		return opMatches(b.op, compareValues(col.Type, value, b.values[0]))
+	}
+}
+
+func opMatches(op cqlOp, c int) bool {
+	switch op {
+	case opEq:
+		return c == 0
+	case opNotEq:
+		return c != 0
+	case opLt:
+		return c < 0
+	case opLtOrEq:
+		return c <= 0
+	case opGt:
+		return c > 0
+	case opGtOrEq:
+		return c >= 0
+	default:
+		return false
+	}
+}
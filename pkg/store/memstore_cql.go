@@ -0,0 +1,951 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The in-memory store understands the subset of CQL that gemini itself
+// generates. Statements are parsed into cqlStmt and every bind marker is
+// numbered in order of appearance, which is the order of the bound values.
+
+type cqlStmtKind int
+
+const (
+	cqlInsert cqlStmtKind = iota
+	cqlInsertJSON
+	cqlUpdate
+	cqlDelete
+	cqlSelect
+	cqlBatch
+	cqlDDL
+)
+
+type cqlOp string
+
+const (
+	opEq          cqlOp = "="
+	opNotEq       cqlOp = "!="
+	opLt          cqlOp = "<"
+	opLtOrEq      cqlOp = "<="
+	opGt          cqlOp = ">"
+	opGtOrEq      cqlOp = ">="
+	opIn          cqlOp = "IN"
+	opContains    cqlOp = "CONTAINS"
+	opContainsKey cqlOp = "CONTAINS KEY"
+)
+
+// cqlExpr is either a bind marker, a literal or a tuple of expressions.
+type cqlExpr struct {
+	literal string
+	tuple   []cqlExpr
+	bind    int
+	isTuple bool
+	isNull  bool
+}
+
+type cqlRelation struct {
+	op      cqlOp
+	columns []string
+	values  []cqlExpr
+	token   bool
+	// rawToken is set when the token is compared to a bigint rather than
+	// to the token of partition key values.
+	rawToken bool
+	// key is set on relations on a single map entry, col[key] = value.
+	key *cqlExpr
+}
+
+type cqlAssignKind int
+
+const (
+	assignSet cqlAssignKind = iota
+	assignAppend
+	assignPrepend
+	assignRemove
+	assignElement
+	assignField
+)
+
+type cqlAssignment struct {
+	column string
+	field  string
+	key    cqlExpr
+	value  cqlExpr
+	kind   cqlAssignKind
+}
+
+// cqlSelector is a column of a DELETE statement, optionally restricted to
+// a single collection element or UDT field.
+type cqlSelector struct {
+	column string
+	field  string
+	key    *cqlExpr
+}
+
+// cqlSelection is a selected column, fn is either empty or the upper case
+// name of the function or aggregate applied to it. The column of the TOKEN
+// of the row lists the partition keys, that of COUNT(*) is "*". cast is the
+// type the column is cast to by CAST.
+type cqlSelection struct {
+	fn     string
+	column string
+	alias  string
+	cast   string
+}
+
+// name returns the name the selection is returned under.
+func (s cqlSelection) name() string {
+	switch {
+	case s.alias != "":
+		return s.alias
+	case s.fn == "CAST":
+		return "cast(" + s.column + " as " + s.cast + ")"
+	case s.fn != "":
+		return strings.ToLower(s.fn) + "(" + s.column + ")"
+	default:
+		return s.column
+	}
+}
+
+// cqlOrdering is a column of the ORDER BY clause of a SELECT.
+type cqlOrdering struct {
+	column string
+	desc   bool
+}
+
+type cqlStmt struct {
+	ttl            *cqlExpr
+	timestamp      *cqlExpr
+	json           *cqlExpr
+	limit          *cqlExpr
+	partitionLimit *cqlExpr
+	ddl            string
+	table          string
+	columns        []string
+	values         []cqlExpr
+	assigns        []cqlAssignment
+	deletes        []cqlSelector
+	where          []cqlRelation
+	conditions     []cqlRelation
+	batch          []*cqlStmt
+	selections     []cqlSelection
+	orderBy        []cqlOrdering
+	kind           cqlStmtKind
+	ifExists       bool
+	ifNotExist     bool
+	filtering      bool
+	jsonResult     bool
+}
+
+type cqlTokenKind int
+
+const (
+	tokIdent cqlTokenKind = iota
+	tokBind
+	tokNumber
+	tokString
+	tokSymbol
+	tokEOF
+)
+
+type cqlToken struct {
+	text string
+	kind cqlTokenKind
+}
+
+func lexCQL(query string) ([]cqlToken, error) {
+	var out []cqlToken
+	i := 0
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '?':
+			out = append(out, cqlToken{kind: tokBind, text: "?"})
+			i++
+		case c == '\'':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(query); j++ {
+				if query[j] == '\'' {
+					if j+1 < len(query) && query[j+1] == '\'' {
+						sb.WriteByte('\'')
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteByte(query[j])
+			}
+			if j >= len(query) {
+				return nil, errors.Errorf("unterminated string literal in '%s'", query)
+			}
+			out = append(out, cqlToken{kind: tokString, text: sb.String()})
+			i = j + 1
+		case c == '"':
+			j := strings.IndexByte(query[i+1:], '"')
+			if j < 0 {
+				return nil, errors.Errorf("unterminated quoted identifier in '%s'", query)
+			}
+			out = append(out, cqlToken{kind: tokIdent, text: query[i+1 : i+1+j]})
+			i += j + 2
+		case isIdentStart(c):
+			j := i
+			for j < len(query) && (isIdentStart(query[j]) || isDigit(query[j]) || query[j] == '.') {
+				j++
+			}
+			out = append(out, cqlToken{kind: tokIdent, text: query[i:j]})
+			i = j
+		case isDigit(c) || (c == '-' && i+1 < len(query) && isDigit(query[i+1]) && !lastIsOperand(out)):
+			j := i + 1
+			for j < len(query) && (isDigit(query[j]) || query[j] == '.' || query[j] == 'e' || query[j] == 'E') {
+				j++
+			}
+			out = append(out, cqlToken{kind: tokNumber, text: query[i:j]})
+			i = j
+		case (c == '<' || c == '>' || c == '!') && i+1 < len(query) && query[i+1] == '=':
+			out = append(out, cqlToken{kind: tokSymbol, text: query[i : i+2]})
+			i += 2
+		case strings.IndexByte("(),=<>+-[]{}:;*", c) >= 0:
+			out = append(out, cqlToken{kind: tokSymbol, text: string(c)})
+			i++
+		default:
+			return nil, errors.Errorf("unexpected character %q in '%s'", c, query)
+		}
+	}
+	return append(out, cqlToken{kind: tokEOF}), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func lastIsOperand(tokens []cqlToken) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind != tokSymbol || last.text == ")" || last.text == "]"
+}
+
+type cqlParser struct {
+	query  string
+	tokens []cqlToken
+	pos    int
+	binds  int
+}
+
+func parseCQL(query string) (*cqlStmt, error) {
+	tokens, err := lexCQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &cqlParser{query: query, tokens: tokens}
+	stmt, err := p.statement()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse '%s'", query)
+	}
+	return stmt, nil
+}
+
+func (p *cqlParser) peek() cqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *cqlParser) next() cqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *cqlParser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *cqlParser) acceptKeyword(kw string) bool {
+	if p.isKeyword(kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *cqlParser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		return errors.Errorf("expected %s, got '%s'", kw, p.peek().text)
+	}
+	return nil
+}
+
+func (p *cqlParser) isSymbol(s string) bool {
+	t := p.peek()
+	return t.kind == tokSymbol && t.text == s
+}
+
+func (p *cqlParser) acceptSymbol(s string) bool {
+	if p.isSymbol(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *cqlParser) expectSymbol(s string) error {
+	if !p.acceptSymbol(s) {
+		return errors.Errorf("expected '%s', got '%s'", s, p.peek().text)
+	}
+	return nil
+}
+
+func (p *cqlParser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", errors.Errorf("expected identifier, got '%s'", t.text)
+	}
+	return t.text, nil
+}
+
+// tableName returns the, optionally keyspace qualified, table name.
+func (p *cqlParser) tableName() (string, error) {
+	return p.ident()
+}
+
+func (p *cqlParser) statement() (*cqlStmt, error) {
+	switch {
+	case p.acceptKeyword("INSERT"):
+		return p.insert()
+	case p.acceptKeyword("UPDATE"):
+		return p.update()
+	case p.acceptKeyword("DELETE"):
+		return p.delete()
+	case p.acceptKeyword("SELECT"):
+		return p.selectStmt()
+	case p.acceptKeyword("BEGIN"):
+		return p.batch()
+	case p.isKeyword("CREATE"), p.isKeyword("ALTER"), p.isKeyword("DROP"), p.isKeyword("TRUNCATE"):
+		return &cqlStmt{kind: cqlDDL, ddl: p.query}, nil
+	default:
+		return nil, errors.Errorf("unsupported statement '%s'", p.peek().text)
+	}
+}
+
+func (p *cqlParser) expr() (cqlExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokBind:
+		e := cqlExpr{bind: p.binds}
+		p.binds++
+		return e, nil
+	case tokNumber, tokString:
+		return cqlExpr{bind: -1, literal: t.text}, nil
+	case tokIdent:
+		if strings.EqualFold(t.text, "null") {
+			return cqlExpr{bind: -1, isNull: true}, nil
+		}
+		if strings.EqualFold(t.text, "true") || strings.EqualFold(t.text, "false") {
+			return cqlExpr{bind: -1, literal: strings.ToLower(t.text)}, nil
+		}
+	case tokSymbol:
+		if t.text == "(" {
+			items, err := p.exprList(")")
+			if err != nil {
+				return cqlExpr{}, err
+			}
+			return cqlExpr{bind: -1, isTuple: true, tuple: items}, nil
+		}
+	}
+	return cqlExpr{}, errors.Errorf("unexpected '%s' in expression", t.text)
+}
+
+// exprList parses comma separated expressions up to the closing symbol,
+// the opening symbol must already be consumed.
+func (p *cqlParser) exprList(closing string) ([]cqlExpr, error) {
+	var out []cqlExpr
+	if p.acceptSymbol(closing) {
+		return out, nil
+	}
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+		if p.acceptSymbol(closing) {
+			return out, nil
+		}
+		if err = p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *cqlParser) identList() ([]string, error) {
+	var out []string
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, name)
+		if p.acceptSymbol(")") {
+			return out, nil
+		}
+		if err = p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *cqlParser) using(stmt *cqlStmt) error {
+	if !p.acceptKeyword("USING") {
+		return nil
+	}
+	for {
+		switch {
+		case p.acceptKeyword("TTL"):
+			e, err := p.expr()
+			if err != nil {
+				return err
+			}
+			stmt.ttl = &e
+		case p.acceptKeyword("TIMESTAMP"):
+			e, err := p.expr()
+			if err != nil {
+				return err
+			}
+			stmt.timestamp = &e
+		case p.acceptKeyword("TIMEOUT"):
+			if _, err := p.expr(); err != nil {
+				return err
+			}
+		default:
+			return errors.Errorf("unexpected '%s' in USING clause", p.peek().text)
+		}
+		if !p.acceptKeyword("AND") {
+			return nil
+		}
+	}
+}
+
+func (p *cqlParser) insert() (*cqlStmt, error) {
+	if err := p.expectKeyword("INTO"); err != nil {
+		return nil, err
+	}
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &cqlStmt{kind: cqlInsert, table: table}
+	if p.acceptKeyword("JSON") {
+		stmt.kind = cqlInsertJSON
+		e, jsonErr := p.expr()
+		if jsonErr != nil {
+			return nil, jsonErr
+		}
+		stmt.json = &e
+		p.acceptKeyword("DEFAULT")
+		p.acceptKeyword("NULL")
+	} else {
+		if err = p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		if stmt.columns, err = p.identList(); err != nil {
+			return nil, err
+		}
+		if err = p.expectKeyword("VALUES"); err != nil {
+			return nil, err
+		}
+		if err = p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		if stmt.values, err = p.exprList(")"); err != nil {
+			return nil, err
+		}
+		if len(stmt.values) != len(stmt.columns) {
+			return nil, errors.Errorf("%d columns and %d values", len(stmt.columns), len(stmt.values))
+		}
+	}
+	if p.acceptKeyword("IF") {
+		if err = p.expectKeyword("NOT"); err != nil {
+			return nil, err
+		}
+		if err = p.expectKeyword("EXISTS"); err != nil {
+			return nil, err
+		}
+		stmt.ifNotExist = true
+	}
+	if err = p.using(stmt); err != nil {
+		return nil, err
+	}
+	return stmt, p.end()
+}
+
+func (p *cqlParser) update() (*cqlStmt, error) {
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &cqlStmt{kind: cqlUpdate, table: table}
+	if err = p.using(stmt); err != nil {
+		return nil, err
+	}
+	if err = p.expectKeyword("SET"); err != nil {
+		return nil, err
+	}
+	for {
+		a, assignErr := p.assignment()
+		if assignErr != nil {
+			return nil, assignErr
+		}
+		stmt.assigns = append(stmt.assigns, a)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if stmt.where, err = p.where(); err != nil {
+		return nil, err
+	}
+	if err = p.conditions(stmt); err != nil {
+		return nil, err
+	}
+	return stmt, p.end()
+}
+
+func (p *cqlParser) assignment() (cqlAssignment, error) {
+	column, err := p.ident()
+	if err != nil {
+		return cqlAssignment{}, err
+	}
+	a := cqlAssignment{column: column, kind: assignSet}
+	if idx := strings.IndexByte(column, '.'); idx >= 0 {
+		a.column, a.field, a.kind = column[:idx], column[idx+1:], assignField
+	}
+	if p.acceptSymbol("[") {
+		if a.key, err = p.expr(); err != nil {
+			return a, err
+		}
+		if err = p.expectSymbol("]"); err != nil {
+			return a, err
+		}
+		a.kind = assignElement
+	}
+	if err = p.expectSymbol("="); err != nil {
+		return a, err
+	}
+	// col = col + ?, col = col - ? and col = ? + col
+	if t := p.peek(); t.kind == tokIdent && t.text == a.column && a.kind == assignSet {
+		p.next()
+		switch {
+		case p.acceptSymbol("+"):
+			a.kind = assignAppend
+		case p.acceptSymbol("-"):
+			a.kind = assignRemove
+		default:
+			return a, errors.Errorf("unexpected '%s' in assignment of %s", p.peek().text, column)
+		}
+		a.value, err = p.expr()
+		return a, err
+	}
+	if a.value, err = p.expr(); err != nil {
+		return a, err
+	}
+	if a.kind == assignSet && p.acceptSymbol("+") {
+		name, identErr := p.ident()
+		if identErr != nil {
+			return a, identErr
+		}
+		if name != a.column {
+			return a, errors.Errorf("unexpected '%s' in prepend to %s", name, a.column)
+		}
+		a.kind = assignPrepend
+	}
+	return a, nil
+}
+
+func (p *cqlParser) delete() (*cqlStmt, error) {
+	stmt := &cqlStmt{kind: cqlDelete}
+	for !p.isKeyword("FROM") {
+		column, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		sel := cqlSelector{column: column}
+		if idx := strings.IndexByte(column, '.'); idx >= 0 {
+			sel.column, sel.field = column[:idx], column[idx+1:]
+		}
+		if p.acceptSymbol("[") {
+			key, keyErr := p.expr()
+			if keyErr != nil {
+				return nil, keyErr
+			}
+			sel.key = &key
+			if err = p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+		}
+		stmt.deletes = append(stmt.deletes, sel)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	var err error
+	if stmt.table, err = p.tableName(); err != nil {
+		return nil, err
+	}
+	if err = p.using(stmt); err != nil {
+		return nil, err
+	}
+	if stmt.where, err = p.where(); err != nil {
+		return nil, err
+	}
+	if err = p.conditions(stmt); err != nil {
+		return nil, err
+	}
+	return stmt, p.end()
+}
+
+func (p *cqlParser) selectStmt() (*cqlStmt, error) {
+	var selections []cqlSelection
+	jsonResult := p.acceptKeyword("JSON")
+	if !p.acceptSymbol("*") {
+		var err error
+		if selections, err = p.selections(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &cqlStmt{kind: cqlSelect, table: table, selections: selections, jsonResult: jsonResult}
+	if p.isKeyword("WHERE") {
+		if stmt.where, err = p.where(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("ORDER") {
+		if stmt.orderBy, err = p.orderBy(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("PER") {
+		if err = p.expectKeyword("PARTITION"); err != nil {
+			return nil, err
+		}
+		if stmt.partitionLimit, err = p.limit(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isKeyword("LIMIT") {
+		if stmt.limit, err = p.limit(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("ALLOW") {
+		if err = p.expectKeyword("FILTERING"); err != nil {
+			return nil, err
+		}
+		stmt.filtering = true
+	}
+	return stmt, p.end()
+}
+
+func (p *cqlParser) orderBy() ([]cqlOrdering, error) {
+	if err := p.expectKeyword("BY"); err != nil {
+		return nil, err
+	}
+	var out []cqlOrdering
+	for {
+		column, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		desc := p.acceptKeyword("DESC")
+		if !desc {
+			p.acceptKeyword("ASC")
+		}
+		out = append(out, cqlOrdering{column: column, desc: desc})
+		if !p.acceptSymbol(",") {
+			return out, nil
+		}
+	}
+}
+
+func (p *cqlParser) limit() (*cqlExpr, error) {
+	if err := p.expectKeyword("LIMIT"); err != nil {
+		return nil, err
+	}
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (p *cqlParser) selections() ([]cqlSelection, error) {
+	var out []cqlSelection
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		sel := cqlSelection{column: name}
+		if p.acceptSymbol("(") {
+			sel.fn = strings.ToUpper(name)
+			switch sel.fn {
+			case "TTL", "WRITETIME":
+				if sel.column, err = p.ident(); err != nil {
+					return nil, err
+				}
+				if err = p.expectSymbol(")"); err != nil {
+					return nil, err
+				}
+			case "TOKEN":
+				// The token is that of the row, the partition key columns
+				// it is computed of are not checked.
+				columns, listErr := p.identList()
+				if listErr != nil {
+					return nil, listErr
+				}
+				sel.column = strings.Join(columns, ", ")
+			case "COUNT":
+				if err = p.expectSymbol("*"); err != nil {
+					return nil, err
+				}
+				sel.column = "*"
+				if err = p.expectSymbol(")"); err != nil {
+					return nil, err
+				}
+			case "CAST":
+				if sel.column, err = p.ident(); err != nil {
+					return nil, err
+				}
+				if err = p.expectKeyword("AS"); err != nil {
+					return nil, err
+				}
+				if sel.cast, err = p.ident(); err != nil {
+					return nil, err
+				}
+				sel.cast = strings.ToLower(sel.cast)
+				if err = p.expectSymbol(")"); err != nil {
+					return nil, err
+				}
+			case "MIN", "MAX", "SUM", "AVG", "TOJSON", "TODATE", "TOTIMESTAMP", "TOUNIXTIMESTAMP":
+				if sel.column, err = p.ident(); err != nil {
+					return nil, err
+				}
+				if err = p.expectSymbol(")"); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, errors.Errorf("unsupported function '%s'", name)
+			}
+		}
+		if p.acceptKeyword("AS") {
+			if sel.alias, err = p.ident(); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, sel)
+		if !p.acceptSymbol(",") {
+			return out, nil
+		}
+	}
+}
+
+func (p *cqlParser) batch() (*cqlStmt, error) {
+	stmt := &cqlStmt{kind: cqlBatch}
+	p.acceptKeyword("UNLOGGED")
+	p.acceptKeyword("COUNTER")
+	if err := p.expectKeyword("BATCH"); err != nil {
+		return nil, err
+	}
+	if err := p.using(stmt); err != nil {
+		return nil, err
+	}
+	for !p.acceptKeyword("APPLY") {
+		if p.peek().kind == tokEOF {
+			return nil, errors.New("unterminated batch")
+		}
+		var (
+			child *cqlStmt
+			err   error
+		)
+		switch {
+		case p.acceptKeyword("INSERT"):
+			child, err = p.insert()
+		case p.acceptKeyword("UPDATE"):
+			child, err = p.update()
+		case p.acceptKeyword("DELETE"):
+			child, err = p.delete()
+		default:
+			err = errors.Errorf("unsupported batch statement '%s'", p.peek().text)
+		}
+		if err != nil {
+			return nil, err
+		}
+		stmt.batch = append(stmt.batch, child)
+	}
+	if err := p.expectKeyword("BATCH"); err != nil {
+		return nil, err
+	}
+	return stmt, p.end()
+}
+
+func (p *cqlParser) where() ([]cqlRelation, error) {
+	if err := p.expectKeyword("WHERE"); err != nil {
+		return nil, err
+	}
+	var out []cqlRelation
+	for {
+		rel, err := p.relation()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rel)
+		if !p.acceptKeyword("AND") {
+			return out, nil
+		}
+	}
+}
+
+func (p *cqlParser) conditions(stmt *cqlStmt) error {
+	if !p.acceptKeyword("IF") {
+		return nil
+	}
+	if p.acceptKeyword("EXISTS") {
+		stmt.ifExists = true
+		return nil
+	}
+	for {
+		rel, err := p.relation()
+		if err != nil {
+			return err
+		}
+		stmt.conditions = append(stmt.conditions, rel)
+		if !p.acceptKeyword("AND") {
+			return nil
+		}
+	}
+}
+
+func (p *cqlParser) relation() (cqlRelation, error) {
+	var rel cqlRelation
+	var err error
+	multiColumn := false
+	switch {
+	case p.acceptKeyword("token"):
+		if err = p.expectSymbol("("); err != nil {
+			return rel, err
+		}
+		if rel.columns, err = p.identList(); err != nil {
+			return rel, err
+		}
+		rel.token = true
+	case p.acceptSymbol("("):
+		if rel.columns, err = p.identList(); err != nil {
+			return rel, err
+		}
+		multiColumn = true
+	default:
+		name, identErr := p.ident()
+		if identErr != nil {
+			return rel, identErr
+		}
+		rel.columns = []string{name}
+		if p.acceptSymbol("[") {
+			key, exprErr := p.expr()
+			if exprErr != nil {
+				return rel, exprErr
+			}
+			if err = p.expectSymbol("]"); err != nil {
+				return rel, err
+			}
+			rel.key = &key
+		}
+	}
+	t := p.next()
+	switch {
+	case t.kind == tokSymbol && (t.text == "=" || t.text == "<" || t.text == ">" || t.text == "<=" || t.text == ">=" || t.text == "!="):
+		rel.op = cqlOp(t.text)
+	case t.kind == tokIdent && strings.EqualFold(t.text, "IN"):
+		rel.op = opIn
+		if err = p.expectSymbol("("); err != nil {
+			return rel, err
+		}
+		rel.values, err = p.exprList(")")
+		return rel, err
+	case t.kind == tokIdent && strings.EqualFold(t.text, "CONTAINS"):
+		rel.op = opContains
+		if p.acceptKeyword("KEY") {
+			rel.op = opContainsKey
+		}
+	default:
+		return rel, errors.Errorf("unexpected operator '%s'", t.text)
+	}
+	if rel.token {
+		if !p.acceptKeyword("token") {
+			value, exprErr := p.expr()
+			rel.values, rel.rawToken = []cqlExpr{value}, true
+			return rel, exprErr
+		}
+		if err = p.expectSymbol("("); err != nil {
+			return rel, err
+		}
+		values, listErr := p.exprList(")")
+		if listErr != nil {
+			return rel, listErr
+		}
+		rel.values = values
+		return rel, nil
+	}
+	value, err := p.expr()
+	if err != nil {
+		return rel, err
+	}
+	if multiColumn {
+		rel.values = value.tuple
+	} else {
+		rel.values = []cqlExpr{value}
+	}
+	return rel, nil
+}
+
+func (p *cqlParser) end() error {
+	p.acceptSymbol(";")
+	if t := p.peek(); t.kind != tokEOF && !(t.kind == tokIdent && (strings.EqualFold(t.text, "APPLY") ||
+		strings.EqualFold(t.text, "INSERT") || strings.EqualFold(t.text, "UPDATE") || strings.EqualFold(t.text, "DELETE"))) {
+		return errors.Errorf("unexpected trailing '%s'", t.text)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"gopkg.in/inf.v0"
+
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+const (
+	millisPerDay = 24 * 60 * 60 * 1000
+	// uuidEpochOffset is the number of 100ns intervals between the start of
+	// the Gregorian calendar, the epoch of time UUIDs, and the Unix epoch.
+	uuidEpochOffset = 0x01B21DD213814000
+)
+
+func isAggregate(selections []cqlSelection) bool {
+	for _, sel := range selections {
+		switch sel.fn {
+		case "COUNT", "MIN", "MAX", "SUM", "AVG":
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateRow computes the single row an aggregate query returns, null
+// values are skipped by every aggregate but COUNT(*).
+func aggregateRow(table *typedef.Table, selections []cqlSelection, views []*memView) (map[string]interface{}, error) {
+	row := make(map[string]interface{}, len(selections))
+	for _, sel := range selections {
+		out := &typedef.ColumnDef{Name: sel.name(), Type: typedef.TYPE_BIGINT}
+		var data []byte
+		var err error
+		if sel.fn == "COUNT" {
+			data, err = marshalValue(out.Type, int64(len(views)))
+		} else {
+			col, _ := columnDef(table, sel.column)
+			if col == nil {
+				return nil, errors.Errorf("unknown column %s", sel.column)
+			}
+			values := make([][]byte, 0, len(views))
+			for _, v := range views {
+				if value := v.values[col.Name]; value != nil {
+					values = append(values, value)
+				}
+			}
+			out.Type = col.Type
+			data, err = aggregate(sel.fn, col.Type, values)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = unmarshalColumn(row, out, data); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+func aggregate(fn string, t typedef.Type, values [][]byte) ([]byte, error) {
+	switch fn {
+	case "MIN", "MAX":
+		var out []byte
+		for _, value := range values {
+			c := compareValues(t, value, out)
+			if out == nil || (fn == "MIN" && c < 0) || (fn == "MAX" && c > 0) {
+				out = value
+			}
+		}
+		return out, nil
+	case "SUM", "AVG":
+		if t == typedef.TYPE_DECIMAL {
+			if fn == "AVG" {
+				return nil, errors.New("AVG is not supported on decimal")
+			}
+			sum := inf.NewDec(0, 0)
+			for _, value := range values {
+				dec, err := decodeValue(t, value)
+				if err != nil {
+					return nil, err
+				}
+				sum.Add(sum, dec.(*inf.Dec))
+			}
+			return marshalValue(t, sum)
+		}
+		// The clusters sum up in a wider type and narrow the result, which
+		// wraps the sum around and keeps the average exact.
+		sum := new(big.Int)
+		for _, value := range values {
+			n, err := decodeInteger(t, value)
+			if err != nil {
+				return nil, err
+			}
+			sum.Add(sum, n)
+		}
+		if fn == "AVG" && len(values) > 0 {
+			sum.Quo(sum, big.NewInt(int64(len(values))))
+		}
+		return marshalInteger(t, sum)
+	default:
+		return nil, errors.Errorf("unsupported aggregate %s", fn)
+	}
+}
+
+// scalarFunction applies a function other than TTL, WRITETIME and TOKEN to
+// a column value, it returns the type of the result and the result.
+func scalarFunction(sel cqlSelection, col *typedef.ColumnDef, data []byte) (typedef.Type, []byte, error) {
+	st, ok := col.Type.(typedef.SimpleType)
+	if !ok {
+		return nil, nil, errors.Errorf("%s is not supported on column %s", sel.fn, col.Name)
+	}
+	switch sel.fn {
+	case "TOJSON":
+		text, err := jsonText(st, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		return typedef.TYPE_TEXT, []byte(text), nil
+	case "CAST":
+		target := typedef.SimpleType(sel.cast)
+		if data == nil {
+			return target, nil, nil
+		}
+		out, err := castValue(st, target, data)
+		return target, out, err
+	case "TODATE", "TOTIMESTAMP", "TOUNIXTIMESTAMP":
+		target := map[string]typedef.SimpleType{
+			"TODATE":          typedef.TYPE_DATE,
+			"TOTIMESTAMP":     typedef.TYPE_TIMESTAMP,
+			"TOUNIXTIMESTAMP": typedef.TYPE_BIGINT,
+		}[sel.fn]
+		if data == nil {
+			return target, nil, nil
+		}
+		millis, err := timeMillis(st, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		return target, marshalMillis(target, millis), nil
+	default:
+		return nil, nil, errors.Errorf("unsupported function %s", sel.fn)
+	}
+}
+
+// jsonRow returns the row of a SELECT JSON query, the selected values as a
+// JSON object in the [json] column.
+func jsonRow(table *typedef.Table, selections []cqlSelection, v *memView, now time.Time) (map[string]interface{}, error) {
+	if len(selections) == 0 {
+		return nil, errors.New("SELECT JSON * is not supported")
+	}
+	fields := make([]string, 0, len(selections))
+	for _, sel := range selections {
+		typ, data, err := selectedValue(table, sel, v, now)
+		if err != nil {
+			return nil, err
+		}
+		st, ok := typ.(typedef.SimpleType)
+		if !ok {
+			return nil, errors.Errorf("SELECT JSON is not supported on %s", typ.CQLDef())
+		}
+		text, err := jsonText(st, data)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, strconv.Quote(sel.name())+": "+text)
+	}
+	return map[string]interface{}{"[json]": "{" + strings.Join(fields, ", ") + "}"}, nil
+}
+
+// jsonText formats a value the way the clusters format it as JSON.
+func jsonText(t typedef.SimpleType, data []byte) (string, error) {
+	if data == nil {
+		return "null", nil
+	}
+	value, err := decodeValue(t, data)
+	if err != nil {
+		return "", err
+	}
+	switch t {
+	case typedef.TYPE_ASCII, typedef.TYPE_TEXT, typedef.TYPE_VARCHAR:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err = enc.Encode(value); err != nil {
+			return "", errors.Wrapf(err, "unable to format %v as JSON", value)
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	case typedef.TYPE_BLOB:
+		return `"0x` + hex.EncodeToString(data) + `"`, nil
+	case typedef.TYPE_BIGINT, typedef.TYPE_BOOLEAN, typedef.TYPE_INT, typedef.TYPE_SMALLINT,
+		typedef.TYPE_TINYINT, typedef.TYPE_VARINT:
+		return fmt.Sprint(value), nil
+	case typedef.TYPE_DATE, typedef.TYPE_INET, typedef.TYPE_TIMEUUID, typedef.TYPE_UUID:
+		text, textErr := textValue(t, value)
+		return `"` + text + `"`, textErr
+	default:
+		return "", errors.Errorf("JSON is not supported for %s", t)
+	}
+}
+
+// castValue converts a value as CAST does.
+func castValue(from, to typedef.SimpleType, data []byte) ([]byte, error) {
+	switch to {
+	case typedef.TYPE_TEXT:
+		value, err := decodeValue(from, data)
+		if err != nil {
+			return nil, err
+		}
+		text, err := textValue(from, value)
+		return []byte(text), err
+	case typedef.TYPE_TIMESTAMP, typedef.TYPE_DATE:
+		millis, err := timeMillis(from, data)
+		if err != nil {
+			return nil, err
+		}
+		return marshalMillis(to, millis), nil
+	case typedef.TYPE_DOUBLE:
+		n, err := decodeInteger(from, data)
+		if err != nil {
+			return nil, err
+		}
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return marshalValue(to, f)
+	default:
+		n, err := decodeInteger(from, data)
+		if err != nil {
+			return nil, err
+		}
+		return marshalInteger(to, n)
+	}
+}
+
+// textValue formats a value of the type as text, as CAST(... AS text) does.
+func textValue(t typedef.SimpleType, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int8, int16, int, int32, int64, *big.Int:
+		return fmt.Sprint(v), nil
+	case gocql.UUID:
+		return v.String(), nil
+	case net.IP:
+		return v.String(), nil
+	case time.Time:
+		if t == typedef.TYPE_DATE {
+			return v.UTC().Format("2006-01-02"), nil
+		}
+	}
+	return "", errors.Errorf("unable to format %v of type %s as text", value, t)
+}
+
+// timeMillis returns the milliseconds since the Unix epoch of a timestamp,
+// date or time UUID.
+func timeMillis(t typedef.SimpleType, data []byte) (int64, error) {
+	switch {
+	case t == typedef.TYPE_TIMESTAMP && len(data) == 8:
+		return int64(binary.BigEndian.Uint64(data)), nil
+	case t == typedef.TYPE_DATE && len(data) == 4:
+		return (int64(binary.BigEndian.Uint32(data)) - 1<<31) * millisPerDay, nil
+	case t == typedef.TYPE_TIMEUUID && len(data) == 16:
+		return (int64(uuidTime(data)) - uuidEpochOffset) / 10000, nil
+	default:
+		return 0, errors.Errorf("unable to read a time of %s", t)
+	}
+}
+
+// marshalMillis serializes milliseconds since the Unix epoch as a timestamp,
+// bigint or the date they fall on.
+func marshalMillis(t typedef.SimpleType, millis int64) []byte {
+	if t == typedef.TYPE_DATE {
+		days := millis / millisPerDay
+		if millis%millisPerDay < 0 {
+			days--
+		}
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint32(data, uint32(days+1<<31))
+		return data
+	}
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(millis))
+	return data
+}
+
+func decodeValue(t typedef.Type, data []byte) (interface{}, error) {
+	info := typeInfo(t)
+	dest := newValue(info)
+	if err := gocql.Unmarshal(info, data, dest); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal %s", t.CQLDef())
+	}
+	return reflect.Indirect(reflect.ValueOf(dest)).Interface(), nil
+}
+
+func decodeInteger(t typedef.Type, data []byte) (*big.Int, error) {
+	switch t {
+	case typedef.TYPE_TINYINT, typedef.TYPE_SMALLINT, typedef.TYPE_INT, typedef.TYPE_BIGINT, typedef.TYPE_VARINT:
+		return varint(data), nil
+	default:
+		return nil, errors.Errorf("%s is not an integer type", t.CQLDef())
+	}
+}
+
+// marshalInteger serializes the integer as the type, integers that do not
+// fit the type wrap around.
+func marshalInteger(t typedef.Type, n *big.Int) ([]byte, error) {
+	if t == typedef.TYPE_VARINT {
+		return marshalValue(t, n)
+	}
+	wrapped := new(big.Int).And(n, new(big.Int).SetUint64(1<<64-1)).Uint64()
+	switch t {
+	case typedef.TYPE_TINYINT:
+		return marshalValue(t, int8(wrapped))
+	case typedef.TYPE_SMALLINT:
+		return marshalValue(t, int16(wrapped))
+	case typedef.TYPE_INT:
+		return marshalValue(t, int32(wrapped))
+	case typedef.TYPE_BIGINT:
+		return marshalValue(t, int64(wrapped))
+	default:
+		return nil, errors.Errorf("%s is not an integer type", t.CQLDef())
+	}
+}
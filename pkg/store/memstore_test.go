@@ -0,0 +1,791 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scylladb/gocqlx/v2/qb"
+
+	"github.com/scylladb/gemini/pkg/builders"
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+func newTestMemStore() *memStore {
+	schema := &typedef.Schema{
+		Keyspace: typedef.Keyspace{Name: "ks"},
+		Tables: []*typedef.Table{
+			{
+				Name:           "tbl",
+				PartitionKeys:  typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+				ClusteringKeys: typedef.Columns{{Name: "ck0", Type: typedef.TYPE_INT}},
+				Columns: typedef.Columns{
+					{Name: "col0", Type: typedef.TYPE_TEXT},
+					{Name: "col1", Type: &typedef.BagType{ComplexType: typedef.TYPE_LIST, ValueType: typedef.TYPE_INT}},
+					{Name: "col2", Type: &typedef.BagType{ComplexType: typedef.TYPE_SET, ValueType: typedef.TYPE_INT}},
+					{Name: "col3", Type: &typedef.MapType{KeyType: typedef.TYPE_INT, ValueType: typedef.TYPE_TEXT}},
+					{Name: "col4", Type: &typedef.TupleType{ValueTypes: []typedef.Type{typedef.TYPE_INT, typedef.TYPE_TEXT}}},
+				},
+			},
+			{
+				Name:           "rng",
+				PartitionKeys:  typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+				ClusteringKeys: typedef.Columns{{Name: "ck0", Type: typedef.TYPE_INT}, {Name: "ck1", Type: typedef.TYPE_INT}},
+				Columns:        typedef.Columns{{Name: "col0", Type: typedef.TYPE_TEXT}, {Name: "col1", Type: typedef.TYPE_INT}},
+				MaterializedViews: []typedef.MaterializedView{{
+					Name:           "rng_mv",
+					PartitionKeys:  typedef.Columns{{Name: "ck1", Type: typedef.TYPE_INT}},
+					ClusteringKeys: typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}, {Name: "ck0", Type: typedef.TYPE_INT, Descending: true}},
+					Columns:        typedef.Columns{{Name: "col1", Type: typedef.TYPE_INT}},
+					Filters:        []typedef.ViewFilter{{Column: &typedef.ColumnDef{Name: "col1", Type: typedef.TYPE_INT}, Op: ">=", Value: "5"}},
+				}},
+			},
+			{
+				Name:           "fn",
+				PartitionKeys:  typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+				ClusteringKeys: typedef.Columns{{Name: "ck0", Type: typedef.TYPE_INT}},
+				Columns: typedef.Columns{
+					{Name: "col0", Type: typedef.TYPE_TINYINT},
+					{Name: "col1", Type: typedef.TYPE_VARINT},
+					{Name: "col2", Type: typedef.TYPE_TIMEUUID},
+					{Name: "col3", Type: typedef.TYPE_TEXT},
+					{Name: "col4", Type: typedef.TYPE_DATE},
+				},
+			},
+			{
+				Name:          "nst",
+				PartitionKeys: typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+				Columns: typedef.Columns{
+					{Name: "col0", Type: &typedef.BagType{
+						ComplexType: typedef.TYPE_SET,
+						ValueType:   &typedef.BagType{ComplexType: typedef.TYPE_LIST, ValueType: typedef.TYPE_INT, Frozen: true},
+					}},
+					{Name: "col1", Type: &typedef.UDTType{
+						ComplexType: typedef.TYPE_UDT,
+						TypeName:    "udt0",
+						ValueTypes:  map[string]typedef.Type{"a": typedef.TYPE_INT, "b": typedef.TYPE_TEXT},
+					}},
+				},
+			},
+			{
+				Name:          "cnt",
+				PartitionKeys: typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+				Columns:       typedef.Columns{{Name: "col0", Type: &typedef.CounterType{}}},
+			},
+			{
+				Name:           "scnt",
+				PartitionKeys:  typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+				ClusteringKeys: typedef.Columns{{Name: "ck0", Type: typedef.TYPE_INT}},
+				Columns: typedef.Columns{
+					{Name: "col0", Type: &typedef.CounterType{}},
+					{Name: "col1", Type: &typedef.CounterType{}, Static: true},
+				},
+			},
+			{
+				Name:          "vec",
+				PartitionKeys: typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+				Columns: typedef.Columns{
+					{Name: "col0", Type: &typedef.VectorType{ComplexType: typedef.TYPE_VECTOR, Dimensions: 3}},
+					{Name: "col1", Type: typedef.TYPE_DURATION},
+				},
+			},
+		},
+	}
+	ops := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_cql_requests"}, []string{"system", "method"})
+	return newMemStore(schema, ops, "oracle")
+}
+
+// lastTimestamp makes the test mutations strictly ordered, consecutive
+// mutations could otherwise share a microsecond and tie.
+var lastTimestamp atomic.Int64
+
+func nextTimestamp() time.Time {
+	for {
+		last := lastTimestamp.Load()
+		ts := time.Now().UnixNano() / 1000
+		if ts <= last {
+			ts = last + 1
+		}
+		if lastTimestamp.CompareAndSwap(last, ts) {
+			return time.UnixMicro(ts)
+		}
+	}
+}
+
+type rawBuilder string
+
+func (b rawBuilder) ToCql() (string, []string) {
+	return string(b), nil
+}
+
+func mustMutate(t *testing.T, ms *memStore, builder qb.Builder, values ...interface{}) {
+	t.Helper()
+	if err := ms.mutate(context.Background(), builder, nextTimestamp(), values...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustLoad(t *testing.T, ms *memStore, builder qb.Builder, values ...interface{}) []map[string]interface{} {
+	t.Helper()
+	rows, err := ms.load(context.Background(), builder, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestMemStoreInsertAndSelect(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0", "col1", "col2", "col3").TupleColumn("col4", 2)
+	mustMutate(t, ms, insert, 1, 2, "a", []int{3, 1}, []int{3, 1}, map[int]string{1: "x"}, 5, "y")
+	mustMutate(t, ms, insert, 1, -1, nil, nil, nil, nil, nil, nil)
+
+	rows := mustLoad(t, ms, qb.Select("ks.tbl").Where(qb.Eq("pk0")), 1)
+	expected := []map[string]interface{}{
+		{
+			"pk0": 1, "ck0": -1, "col0": "", "col1": []int(nil), "col2": []int(nil), "col3": map[int]string(nil),
+			"col4[0]": 0, "col4[1]": "",
+		},
+		{
+			"pk0": 1, "ck0": 2, "col0": "a", "col1": []int{3, 1}, "col2": []int{1, 3}, "col3": map[int]string{1: "x"},
+			"col4[0]": 5, "col4[1]": "y",
+		},
+	}
+	if diff := cmp.Diff(expected, rows); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMemStoreCollectionUpdates(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	mustMutate(t, ms, qb.Insert("ks.tbl").Columns("pk0", "ck0", "col1", "col2", "col3"), 1, 1, []int{2}, []int{2}, map[int]string{2: "b"})
+	mustMutate(t, ms, qb.Update("ks.tbl").AddNamed("col1", "a").AddNamed("col2", "b").Where(qb.Eq("pk0"), qb.Eq("ck0")),
+		[]int{3}, []int{1}, 1, 1)
+	mustMutate(t, ms, rawBuilder("UPDATE ks.tbl SET col1=?+col1,col3[?]=? WHERE pk0=? AND ck0=?"), []int{1}, 1, "a", 1, 1)
+	mustMutate(t, ms, rawBuilder("DELETE col3[?] FROM ks.tbl WHERE pk0=? AND ck0=?"), 2, 1, 1)
+
+	rows := mustLoad(t, ms, qb.Select("ks.tbl").Where(qb.Eq("pk0"), qb.Eq("ck0")), 1, 1)
+	if len(rows) != 1 {
+		t.Fatalf("expected one row, got %d", len(rows))
+	}
+	if diff := cmp.Diff([]int{1, 2, 3}, rows[0]["col1"]); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]int{1, 2}, rows[0]["col2"]); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(map[int]string{1: "a"}, rows[0]["col3"]); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMemStoreUnsetAndEmptyValues(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0", "col1", "col3")
+	mustMutate(t, ms, insert, 1, 1, "a", []int{1}, map[int]string{1: "x"})
+	mustMutate(t, ms, insert, 1, 1, gocql.UnsetValue, []int{}, gocql.UnsetValue)
+	mustMutate(t, ms, qb.Update("ks.tbl").Set("col0", "col3").Where(qb.Eq("pk0"), qb.Eq("ck0")), gocql.UnsetValue, map[int]string{}, 1, 1)
+	mustMutate(t, ms, qb.Update("ks.tbl").Set("col1").Where(qb.Eq("pk0"), qb.Eq("ck0")), []int{}, 2, 1)
+
+	selectRow := qb.Select("ks.tbl").Columns("pk0", "ck0", "col0", "col1", "col3").Where(qb.Eq("pk0"))
+	if rows := mustLoad(t, ms, selectRow, 2); len(rows) != 0 {
+		t.Errorf("expected an empty collection to not create a row, got %v", rows)
+	}
+	rows := mustLoad(t, ms, selectRow, 1)
+	expected := []map[string]interface{}{
+		{"pk0": 1, "ck0": 1, "col0": "a", "col1": []int(nil), "col3": map[int]string(nil)},
+	}
+	if diff := cmp.Diff(expected, rows); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMemStoreNestedTypes(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	mustMutate(t, ms, qb.Insert("ks.nst").Columns("pk0", "col0", "col1"),
+		1, [][]int{{2}, {1, 3}, {2}}, map[string]interface{}{"a": 1, "b": "x"})
+	mustMutate(t, ms, rawBuilder("UPDATE ks.nst SET col1.b=? WHERE pk0=?"), "y", 1)
+	mustMutate(t, ms, qb.Insert("ks.nst").Columns("pk0", "col1"), 2, map[string]interface{}{"a": 1, "b": "x"})
+	mustMutate(t, ms, rawBuilder("UPDATE ks.nst SET col1.a=?,col1.b=? WHERE pk0=?"), nil, nil, 2)
+
+	rows := mustLoad(t, ms, qb.Select("ks.nst").Where(qb.Eq("pk0")), 1)
+	if len(rows) != 1 {
+		t.Fatalf("expected one row, got %d", len(rows))
+	}
+	if diff := cmp.Diff([][]int{{1, 3}, {2}}, rows[0]["col0"]); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(map[string]interface{}{"a": 1, "b": "y"}, rows[0]["col1"]); diff != "" {
+		t.Error(diff)
+	}
+	rows = mustLoad(t, ms, qb.Select("ks.nst").Columns("col1").Where(qb.Eq("pk0")), 2)
+	if diff := cmp.Diff([]map[string]interface{}{{"col1": map[string]interface{}(nil)}}, rows); diff != "" {
+		t.Errorf("expected a user defined type without live fields to be null: %s", diff)
+	}
+}
+
+func TestMemStoreDeletes(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0")
+	for ck := 0; ck < 5; ck++ {
+		mustMutate(t, ms, insert, 1, ck, "v")
+	}
+	mustMutate(t, ms, qb.Delete("ks.tbl").Where(qb.Eq("pk0"), qb.GtOrEq("ck0"), qb.LtOrEq("ck0")), 1, 1, 3)
+	mustMutate(t, ms, insert, 1, 2, "w")
+
+	rows := mustLoad(t, ms, qb.Select("ks.tbl").Where(qb.Eq("pk0")), 1)
+	var cks []int
+	for _, row := range rows {
+		cks = append(cks, row["ck0"].(int))
+	}
+	if diff := cmp.Diff([]int{0, 2, 4}, cks); diff != "" {
+		t.Error(diff)
+	}
+
+	mustMutate(t, ms, qb.Delete("ks.tbl").Where(qb.Eq("pk0")), 1)
+	if rows = mustLoad(t, ms, qb.Select("ks.tbl").Where(qb.Eq("pk0")), 1); len(rows) != 0 {
+		t.Errorf("expected partition to be deleted, got %v", rows)
+	}
+}
+
+func TestMemStoreRangeAndCellDeletes(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := qb.Insert("ks.rng").Columns("pk0", "ck0", "ck1", "col0", "col1")
+	for ck0 := 0; ck0 < 3; ck0++ {
+		for ck1 := 0; ck1 < 3; ck1++ {
+			mustMutate(t, ms, insert, 1, ck0, ck1, "v", ck1)
+		}
+	}
+	keys := func() [][2]int {
+		var out [][2]int
+		for _, row := range mustLoad(t, ms, qb.Select("ks.rng").Where(qb.Eq("pk0")), 1) {
+			out = append(out, [2]int{row["ck0"].(int), row["ck1"].(int)})
+		}
+		return out
+	}
+
+	mustMutate(t, ms, rawBuilder("DELETE FROM ks.rng WHERE pk0=? AND (ck0,ck1)>(?,?) AND (ck0,ck1)<=(?,?)"), 1, 0, 1, 1, 1)
+	mustMutate(t, ms, rawBuilder("DELETE FROM ks.rng WHERE pk0=? AND ck0=? AND ck1>=?"), 1, 2, 2)
+	mustMutate(t, ms, rawBuilder("DELETE FROM ks.rng WHERE pk0=? AND ck0=? AND ck1=?"), 1, 2, 0)
+	expected := [][2]int{{0, 0}, {0, 1}, {1, 2}, {2, 1}}
+	if diff := cmp.Diff(expected, keys()); diff != "" {
+		t.Error(diff)
+	}
+
+	mustMutate(t, ms, rawBuilder("DELETE col0 FROM ks.rng USING TIMESTAMP ? WHERE pk0=? AND ck0=? AND ck1=?"), nextTimestamp().UnixMicro(), 1, 1, 2)
+	rows := mustLoad(t, ms, qb.Select("ks.rng").Where(qb.Eq("pk0"), qb.Eq("ck0"), qb.Eq("ck1")), 1, 1, 2)
+	if len(rows) != 1 || rows[0]["col0"] != "" || rows[0]["col1"] != 2 {
+		t.Errorf("expected only col0 to be deleted, got %v", rows)
+	}
+
+	mustMutate(t, ms, rawBuilder("DELETE FROM ks.rng WHERE pk0=? AND (ck0)<(?)"), 1, 2)
+	if diff := cmp.Diff([][2]int{{2, 1}}, keys()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMemStoreOrderAndLimits(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := qb.Insert("ks.rng").Columns("pk0", "ck0", "ck1")
+	for pk := 1; pk <= 2; pk++ {
+		for ck0 := 0; ck0 < 2; ck0++ {
+			for ck1 := 0; ck1 < 2; ck1++ {
+				mustMutate(t, ms, insert, pk, ck0, ck1)
+			}
+		}
+	}
+	tests := map[string]struct {
+		query    string
+		values   []interface{}
+		expected [][3]int
+	}{
+		"order by": {
+			query:    "SELECT * FROM ks.rng WHERE pk0=? ORDER BY ck0 DESC",
+			values:   []interface{}{1},
+			expected: [][3]int{{1, 1, 1}, {1, 1, 0}, {1, 0, 1}, {1, 0, 0}},
+		},
+		"order by every clustering column": {
+			query:    "SELECT * FROM ks.rng WHERE pk0=? AND ck0>=? ORDER BY ck0 DESC, ck1 DESC LIMIT 3",
+			values:   []interface{}{2, 0},
+			expected: [][3]int{{2, 1, 1}, {2, 1, 0}, {2, 0, 1}},
+		},
+		"order by clustering order": {
+			query:    "SELECT * FROM ks.rng WHERE pk0=? ORDER BY ck0 ASC LIMIT ?",
+			values:   []interface{}{1, 1},
+			expected: [][3]int{{1, 0, 0}},
+		},
+		"per partition limit": {
+			query:    "SELECT * FROM ks.rng WHERE pk0 IN (?,?) PER PARTITION LIMIT 3 LIMIT 5",
+			values:   []interface{}{1, 2},
+			expected: [][3]int{{1, 0, 0}, {1, 0, 1}, {1, 1, 0}, {2, 0, 0}, {2, 0, 1}},
+		},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var received [][3]int
+			for _, row := range mustLoad(t, ms, rawBuilder(test.query), test.values...) {
+				received = append(received, [3]int{row["pk0"].(int), row["ck0"].(int), row["ck1"].(int)})
+			}
+			if diff := cmp.Diff(test.expected, received); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+	for _, query := range []string{
+		"SELECT * FROM ks.rng WHERE pk0=? ORDER BY ck1 DESC",
+		"SELECT * FROM ks.rng WHERE pk0=? ORDER BY ck0 DESC, ck1 ASC",
+		"SELECT * FROM ks.rng WHERE pk0=? LIMIT 0",
+	} {
+		if _, err := ms.load(context.Background(), rawBuilder(query), []interface{}{1}); err == nil {
+			t.Errorf("expected %s to fail", query)
+		}
+	}
+}
+
+func TestMemStoreMaterializedViews(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := qb.Insert("ks.rng").Columns("pk0", "ck0", "ck1", "col0", "col1")
+	mustMutate(t, ms, insert, 1, 0, 7, "a", 5)
+	mustMutate(t, ms, insert, 1, 1, 7, "b", 6)
+	mustMutate(t, ms, insert, 2, 0, 7, "c", 4)
+	mustMutate(t, ms, insert, 2, 1, 8, "d", 9)
+
+	rows := mustLoad(t, ms, qb.Select("ks.rng_mv").Where(qb.Eq("ck1")), 7)
+	expected := []map[string]interface{}{
+		{"pk0": 1, "ck0": 1, "ck1": 7, "col1": 6},
+		{"pk0": 1, "ck0": 0, "ck1": 7, "col1": 5},
+	}
+	if diff := cmp.Diff(expected, rows); diff != "" {
+		t.Error(diff)
+	}
+
+	// Views are resolved by the DDL the store applied, in their keyspace.
+	mustMutate(t, ms, &builders.AlterTableBuilder{Stmt: "DROP MATERIALIZED VIEW ks.rng_mv"})
+	if _, err := ms.load(context.Background(), qb.Select("ks.rng_mv").Where(qb.Eq("ck1")), []interface{}{7}); err == nil {
+		t.Error("expected a dropped view to be unknown")
+	}
+	mustMutate(t, ms, &builders.AlterTableBuilder{
+		Stmt: "CREATE MATERIALIZED VIEW IF NOT EXISTS ks.rng_mv AS SELECT * FROM ks.rng WHERE ck1 IS NOT NULL AND pk0 IS NOT NULL AND ck0 IS NOT NULL PRIMARY KEY (ck1,pk0,ck0)",
+	})
+	if diff := cmp.Diff(expected, mustLoad(t, ms, qb.Select("ks.rng_mv").Where(qb.Eq("ck1")), 7)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMemStoreFiltering(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0", "col2", "col3")
+	mustMutate(t, ms, insert, 1, 1, "a", []int{1, 2}, map[int]string{1: "x"})
+	mustMutate(t, ms, insert, 1, 2, "b", []int{2, 3}, map[int]string{2: "y"})
+	mustMutate(t, ms, insert, 2, 1, "c", []int{3}, map[int]string{1: "y"})
+	tests := map[string]struct {
+		query    string
+		values   []interface{}
+		expected [][2]int
+	}{
+		"regular column": {
+			query:    "SELECT * FROM ks.tbl WHERE pk0=? AND col0>=? ALLOW FILTERING",
+			values:   []interface{}{1, "b"},
+			expected: [][2]int{{1, 2}},
+		},
+		"contains": {
+			query:    "SELECT * FROM ks.tbl WHERE pk0=? AND col2 CONTAINS ? ALLOW FILTERING",
+			values:   []interface{}{1, 2},
+			expected: [][2]int{{1, 1}, {1, 2}},
+		},
+		"contains in partitions": {
+			query:    "SELECT * FROM ks.tbl WHERE pk0 IN (?,?) AND col2 CONTAINS ? ALLOW FILTERING",
+			values:   []interface{}{1, 2, 3},
+			expected: [][2]int{{1, 2}, {2, 1}},
+		},
+		"contains key in a token range": {
+			query:    "SELECT * FROM ks.tbl WHERE token(pk0)>? AND token(pk0)<=? AND col3 CONTAINS KEY ? ALLOW FILTERING",
+			values:   []interface{}{int64(math.MinInt64), int64(math.MaxInt64), 1},
+			expected: [][2]int{{1, 1}, {2, 1}},
+		},
+		"contains value": {
+			query:    "SELECT * FROM ks.tbl WHERE token(pk0)>? AND token(pk0)<=? AND col3 CONTAINS ? AND ck0<? ALLOW FILTERING",
+			values:   []interface{}{int64(math.MinInt64), int64(math.MaxInt64), "y", 2},
+			expected: [][2]int{{2, 1}},
+		},
+		"map entry": {
+			query:    "SELECT * FROM ks.tbl WHERE col3[?]=? ALLOW FILTERING",
+			values:   []interface{}{1, "y"},
+			expected: [][2]int{{2, 1}},
+		},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var received [][2]int
+			for _, row := range mustLoad(t, ms, rawBuilder(test.query), test.values...) {
+				received = append(received, [2]int{row["pk0"].(int), row["ck0"].(int)})
+			}
+			sort.Slice(received, func(i, j int) bool {
+				return received[i][0] < received[j][0] || (received[i][0] == received[j][0] && received[i][1] < received[j][1])
+			})
+			if diff := cmp.Diff(test.expected, received); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestMemStoreTimestampsAndConditions(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	mustMutate(t, ms, qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0"), 1, 1, "new")
+	old := time.Now().Add(-time.Hour).UnixNano() / 1000
+	mustMutate(t, ms, qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0").TimestampNamed("ts"), 1, 1, "old", old)
+	mustMutate(t, ms, qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0").Unique(), 1, 1, "lwt")
+	mustMutate(t, ms, qb.Update("ks.tbl").Set("col0").Where(qb.Eq("pk0"), qb.Eq("ck0")).If(qb.Eq("col0")), "cond", 1, 1, "other")
+
+	rows := mustLoad(t, ms, qb.Select("ks.tbl").Where(qb.Eq("pk0")), 1)
+	if len(rows) != 1 || rows[0]["col0"] != "new" {
+		t.Errorf("expected the newest unconditional write to win, got %v", rows)
+	}
+}
+
+func TestMemStoreCountersAndJSON(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	for i := 0; i < 3; i++ {
+		mustMutate(t, ms, rawBuilder("UPDATE ks.cnt SET col0=col0+1 WHERE pk0=?"), 7)
+	}
+	rows := mustLoad(t, ms, qb.Select("ks.cnt").Where(qb.Eq("pk0")), 7)
+	if len(rows) != 1 || rows[0]["col0"] != int64(3) {
+		t.Errorf("expected counter to be 3, got %v", rows)
+	}
+
+	mustMutate(t, ms, qb.Insert("ks.tbl").Json(), `{"pk0":3,"ck0":4,"col0":"j","col3":{"5":"v"},"col4":[6,"t"]}`)
+	rows = mustLoad(t, ms, qb.Select("ks.tbl").Where(qb.Eq("pk0")), 3)
+	expected := []map[string]interface{}{
+		{
+			"pk0": 3, "ck0": 4, "col0": "j", "col1": []int(nil), "col2": []int(nil), "col3": map[int]string{5: "v"},
+			"col4[0]": 6, "col4[1]": "t",
+		},
+	}
+	if diff := cmp.Diff(expected, rows); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMemStoreStaticCounters(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	for i := 0; i < 2; i++ {
+		mustMutate(t, ms, rawBuilder("UPDATE ks.scnt SET col1=col1+1 WHERE pk0=?"), 1)
+	}
+	rows := mustLoad(t, ms, qb.Select("ks.scnt").Where(qb.Eq("pk0")), 1)
+	expected := []map[string]interface{}{{"pk0": 1, "ck0": 0, "col0": int64(0), "col1": int64(2)}}
+	if diff := cmp.Diff(expected, rows); diff != "" {
+		t.Error(diff)
+	}
+	if rows = mustLoad(t, ms, qb.Select("ks.scnt").Where(qb.Eq("pk0"), qb.Eq("ck0")), 1, 1); len(rows) != 0 {
+		t.Errorf("expected no row of the clustering key, got %v", rows)
+	}
+
+	mustMutate(t, ms, rawBuilder("UPDATE ks.scnt SET col0=col0+1, col1=col1+1 WHERE pk0=? AND ck0 IN (1, 2)"), 1)
+	rows = mustLoad(t, ms, qb.Select("ks.scnt").Where(qb.Eq("pk0")), 1)
+	expected = []map[string]interface{}{
+		{"pk0": 1, "ck0": 1, "col0": int64(1), "col1": int64(3)},
+		{"pk0": 1, "ck0": 2, "col0": int64(1), "col1": int64(3)},
+	}
+	if diff := cmp.Diff(expected, rows); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMemStoreVectorsAndDurations(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	duration := gocql.Duration{Months: -14, Days: -3, Nanoseconds: -5}
+	mustMutate(t, ms, qb.Insert("ks.vec").Columns("pk0", "col0", "col1"), 1, typedef.Vector{0.5, -1, 2}, duration)
+	mustMutate(t, ms, qb.Insert("ks.vec").Json(), `{"pk0":2,"col0":[1.5,0,-3],"col1":"1y2mo3w4d5h6m7s8ms9us10ns"}`)
+	rows := mustLoad(t, ms, qb.Select("ks.vec").Where(qb.Eq("pk0")), 1)
+	rows = append(rows, mustLoad(t, ms, qb.Select("ks.vec").Where(qb.Eq("pk0")), 2)...)
+	expected := []map[string]interface{}{
+		{"pk0": 1, "col0": typedef.Vector{0.5, -1, 2}, "col1": duration},
+		{"pk0": 2, "col0": typedef.Vector{1.5, 0, -3}, "col1": gocql.Duration{
+			Months:      14,
+			Days:        25,
+			Nanoseconds: int64(5*time.Hour + 6*time.Minute + 7*time.Second + 8*time.Millisecond + 9*time.Microsecond + 10),
+		}},
+	}
+	if diff := cmp.Diff(expected, rows); diff != "" {
+		t.Error(diff)
+	}
+
+	if err := ms.mutate(context.Background(), qb.Insert("ks.vec").Json(), nextTimestamp(), `{"pk0":3,"col0":[1,2]}`); err == nil {
+		t.Error("expected a vector of the wrong dimension to be rejected")
+	}
+	for _, literal := range []string{"", "-", "1", "1x", "mo"} {
+		if _, err := parseDuration(literal); err == nil {
+			t.Errorf("expected duration %q to be rejected", literal)
+		}
+	}
+}
+
+func TestMemStoreKeyspaces(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := "INSERT INTO %s.tbl (pk0,ck0,col0) VALUES (?,?,?)"
+	col0 := func(keyspace string) []interface{} {
+		var values []interface{}
+		for _, row := range mustLoad(t, ms, qb.Select(keyspace+".tbl").Where(qb.Eq("pk0")), 1) {
+			values = append(values, row["col0"])
+		}
+		return values
+	}
+	mustMutate(t, ms, rawBuilder(fmt.Sprintf(insert, "ks")), 1, 1, "a")
+	mustMutate(t, ms, rawBuilder(fmt.Sprintf(insert, "ks_min_1")), 1, 1, "b")
+	if diff := cmp.Diff([]interface{}{"b"}, col0("ks_min_1")); diff != "" {
+		t.Error(diff)
+	}
+
+	mustMutate(t, ms, rawBuilder("TRUNCATE TABLE ks_min_1.tbl"))
+	if diff := cmp.Diff([]interface{}(nil), col0("ks_min_1")); diff != "" {
+		t.Error(diff)
+	}
+
+	mustMutate(t, ms, rawBuilder(fmt.Sprintf(insert, "ks_min_1")), 1, 1, "c")
+	mustMutate(t, ms, rawBuilder("DROP KEYSPACE IF EXISTS ks_min_1"))
+	if diff := cmp.Diff([]interface{}(nil), col0("ks_min_1")); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]interface{}{"a"}, col0("ks")); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMemStoreLWT(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	mutateLWT := func(builder qb.Builder, values ...interface{}) bool {
+		t.Helper()
+		result, err := ms.mutateLWT(context.Background(), builder, nextTimestamp(), values...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.applied
+	}
+	insert := qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0").Unique()
+	updateIf := qb.Update("ks.tbl").Set("col0").Where(qb.Eq("pk0"), qb.Eq("ck0")).If(qb.Eq("col0"))
+	deleteIf := qb.Delete("ks.tbl").Where(qb.Eq("pk0"), qb.Eq("ck0")).Existing()
+
+	steps := []struct {
+		builder qb.Builder
+		values  []interface{}
+		applied bool
+	}{
+		{builder: updateIf, values: []interface{}{"a", 1, 1, nil}, applied: true},
+		{builder: insert, values: []interface{}{1, 1, "b"}, applied: false},
+		{builder: updateIf, values: []interface{}{"c", 1, 1, nil}, applied: false},
+		{builder: updateIf, values: []interface{}{"c", 1, 1, "a"}, applied: true},
+		{builder: deleteIf, values: []interface{}{1, 2}, applied: false},
+		{
+			builder: qb.Batch().Add(insert).Add(updateIf),
+			values:  []interface{}{1, 2, "d", "e", 1, 1, "c"},
+			applied: true,
+		},
+		{
+			builder: qb.Batch().Add(insert).Add(deleteIf),
+			values:  []interface{}{1, 3, "f", 1, 2},
+			applied: true,
+		},
+		{builder: deleteIf, values: []interface{}{1, 1}, applied: true},
+	}
+	for i, step := range steps {
+		if applied := mutateLWT(step.builder, step.values...); applied != step.applied {
+			t.Errorf("step %d: expected applied %t, got %t", i, step.applied, applied)
+		}
+	}
+	var received []interface{}
+	for _, row := range mustLoad(t, ms, qb.Select("ks.tbl").Where(qb.Eq("pk0")), 1) {
+		received = append(received, row["col0"])
+	}
+	if diff := cmp.Diff([]interface{}{"f"}, received); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMemStoreBatches(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0")
+	mustMutate(t, ms, insert, 1, 1, "new")
+	old := time.Now().Add(-time.Hour).UnixNano() / 1000
+	mustMutate(t, ms, qb.Batch().UnLogged().TimestampNamed("ts").Add(insert).Add(insert), old, 1, 1, "old", 2, 1, "old")
+
+	col0 := func(pk int) []interface{} {
+		var values []interface{}
+		for _, row := range mustLoad(t, ms, qb.Select("ks.tbl").Where(qb.Eq("pk0")), pk) {
+			values = append(values, row["col0"])
+		}
+		return values
+	}
+	if diff := cmp.Diff([]interface{}{"new"}, col0(1)); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]interface{}{"old"}, col0(2)); diff != "" {
+		t.Error(diff)
+	}
+
+	increment := rawBuilder("UPDATE ks.cnt SET col0=col0+1 WHERE pk0=?")
+	mustMutate(t, ms, qb.Batch().Counter().Add(increment).Add(increment), 7, 7)
+	rows := mustLoad(t, ms, qb.Select("ks.cnt").Where(qb.Eq("pk0")), 7)
+	if len(rows) != 1 || rows[0]["col0"] != int64(2) {
+		t.Errorf("expected counter to be 2, got %v", rows)
+	}
+}
+
+func TestMemStoreTTLAndWriteTime(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0").TTLNamed("ttl").TimestampNamed("ts")
+	check := rawBuilder("SELECT pk0, ck0, TTL(col0) AS ttl_col0, WRITETIME(col0) FROM ks.tbl WHERE pk0=?")
+	ts := nextTimestamp().UnixMicro()
+
+	steps := []struct {
+		value     string
+		ttl       int32
+		expected  string
+		remaining int32
+	}{
+		{value: "a", ttl: 100, expected: "a", remaining: 100},
+		// On timestamp ties the bigger value wins.
+		{value: "b", expected: "b"},
+		{value: "a", ttl: 100, expected: "b"},
+		// Of equal values the expiring one wins.
+		{value: "b", ttl: 50, expected: "b", remaining: 50},
+		{value: "b", ttl: 10, expected: "b", remaining: 50},
+	}
+	for i, step := range steps {
+		mustMutate(t, ms, insert, 1, 1, step.value, step.ttl, ts)
+		if rows := mustLoad(t, ms, rawBuilder("SELECT * FROM ks.tbl WHERE pk0=?"), 1); len(rows) != 1 || rows[0]["col0"] != step.expected {
+			t.Errorf("step %d: expected col0 %s, got %v", i, step.expected, rows)
+		}
+		rows := mustLoad(t, ms, check, 1)
+		if len(rows) != 1 || rows[0]["writetime(col0)"] != ts || len(rows[0]) != 4 {
+			t.Fatalf("step %d: expected write time %d, got %v", i, ts, rows)
+		}
+		// The remaining TTL is truncated to seconds.
+		if remaining := rows[0]["ttl_col0"].(int); remaining > int(step.remaining) || remaining < int(step.remaining)-1 {
+			t.Errorf("step %d: expected ttl %d, got %d", i, step.remaining, remaining)
+		}
+	}
+}
+
+func TestMemStoreAggregates(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	insert := qb.Insert("ks.fn").Columns("pk0", "ck0", "col0", "col1")
+	mustMutate(t, ms, insert, 1, 1, int8(100), big.NewInt(-5))
+	mustMutate(t, ms, insert, 1, 2, int8(100), big.NewInt(8))
+	mustMutate(t, ms, insert, 1, 3, nil, nil)
+	mustMutate(t, ms, insert, 2, 1, int8(1), big.NewInt(1))
+
+	query := rawBuilder("SELECT count(*) AS row_count, min(col0), max(col1) AS max_col1, sum(col0) AS sum_col0, " +
+		"avg(col0) AS avg_col0, sum(col1) AS sum_col1, avg(col1) AS avg_col1 FROM ks.fn WHERE pk0=?")
+	expected := []map[string]interface{}{
+		{
+			// The tinyint sum wraps around and null values are skipped.
+			"row_count": int64(3), "min(col0)": int8(100), "max_col1": big.NewInt(8),
+			"sum_col0": int8(-56), "avg_col0": int8(100), "sum_col1": big.NewInt(3), "avg_col1": big.NewInt(1),
+		},
+	}
+	if diff := cmp.Diff(expected, mustLoad(t, ms, query, 1), cmp.Comparer(bigIntEqual)); diff != "" {
+		t.Error(diff)
+	}
+	// Aggregates of no rows are a single row of zeros and nulls.
+	expected = []map[string]interface{}{
+		{
+			"row_count": int64(0), "min(col0)": int8(0), "max_col1": (*big.Int)(nil),
+			"sum_col0": int8(0), "avg_col0": int8(0), "sum_col1": big.NewInt(0), "avg_col1": big.NewInt(0),
+		},
+	}
+	if diff := cmp.Diff(expected, mustLoad(t, ms, query, 3), cmp.Comparer(bigIntEqual)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMemStoreFunctions(t *testing.T) {
+	t.Parallel()
+	ms := newTestMemStore()
+	// 2020-02-01 01:02:03.004 UTC
+	id, err := gocql.ParseUUID("752b53c0-448e-11ea-8080-808080808080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)
+	mustMutate(t, ms, qb.Insert("ks.fn").Columns("pk0", "ck0", "col0", "col1", "col2", "col3", "col4"),
+		1, 2, int8(-3), big.NewInt(40), id, `a"<b>`, date)
+	mustMutate(t, ms, qb.Insert("ks.fn").Columns("pk0", "ck0"), 1, 3)
+
+	query := rawBuilder("SELECT ck0, tojson(col3) AS json_col3, tojson(col1), cast(col0 AS int) AS cast_col0, " +
+		"cast(col1 as text), cast(col2 as timestamp) AS cast_col2, cast(col4 as text) AS cast_col4, " +
+		"todate(col2) AS todate_col2, tounixtimestamp(col4) AS tounixtimestamp_col4 FROM ks.fn WHERE pk0=?")
+	expected := []map[string]interface{}{
+		{
+			"ck0": 2, "json_col3": `"a\"<b>"`, "tojson(col1)": "40", "cast_col0": -3, "cast(col1 as text)": "40",
+			"cast_col2": time.Date(2020, 2, 1, 1, 2, 3, 4000000, time.UTC), "cast_col4": "1969-12-31",
+			"todate_col2": time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), "tounixtimestamp_col4": int64(-86400000),
+		},
+		{
+			"ck0": 3, "json_col3": "null", "tojson(col1)": "null", "cast_col0": 0, "cast(col1 as text)": "",
+			"cast_col2": time.Time{}, "cast_col4": "", "todate_col2": time.Time{}, "tounixtimestamp_col4": int64(0),
+		},
+	}
+	if diff := cmp.Diff(expected, mustLoad(t, ms, query, 1)); diff != "" {
+		t.Error(diff)
+	}
+
+	rows := mustLoad(t, ms, rawBuilder("SELECT JSON pk0, col0, col2, col3 AS c FROM ks.fn WHERE pk0=? AND ck0=?"), 1, 2)
+	expected = []map[string]interface{}{
+		{"[json]": `{"pk0": 1, "col0": -3, "col2": "752b53c0-448e-11ea-8080-808080808080", "c": "a\"<b>"}`},
+	}
+	if diff := cmp.Diff(expected, rows); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func bigIntEqual(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
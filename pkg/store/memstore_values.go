@@ -0,0 +1,850 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"gopkg.in/inf.v0"
+
+	"github.com/scylladb/gemini/pkg/murmur"
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+func nativeType(typ gocql.Type) gocql.NativeType {
+	return gocql.NewNativeType(typedef.GoCQLProtoVersion4, typ, "")
+}
+
+// typeInfo returns the full gocql type information of the column type,
+// the typedef types only expose the outer type which is not enough to
+// marshal and unmarshal collections, tuples and UDTs.
+func typeInfo(t typedef.Type) gocql.TypeInfo {
+	switch tt := t.(type) {
+	case typedef.SimpleType:
+		return tt.CQLType()
+	case *typedef.BagType:
+		kind := gocql.TypeList
+		if tt.ComplexType == typedef.TYPE_SET {
+			kind = gocql.TypeSet
+		}
+		return gocql.CollectionType{NativeType: nativeType(kind), Elem: typeInfo(tt.ValueType)}
+	case *typedef.MapType:
+		return gocql.CollectionType{NativeType: nativeType(gocql.TypeMap), Key: tt.KeyType.CQLType(), Elem: typeInfo(tt.ValueType)}
+	case *typedef.TupleType:
+		elems := make([]gocql.TypeInfo, len(tt.ValueTypes))
+		for i, vt := range tt.ValueTypes {
+			elems[i] = typeInfo(vt)
+		}
+		return gocql.TupleTypeInfo{NativeType: nativeType(gocql.TypeTuple), Elems: elems}
+	case *typedef.UDTType:
+		names := udtFieldNames(tt)
+		fields := make([]gocql.UDTField, len(names))
+		for i, name := range names {
+			fields[i] = gocql.UDTField{Name: name, Type: typeInfo(tt.ValueTypes[name])}
+		}
+		return gocql.UDTTypeInfo{NativeType: nativeType(gocql.TypeUDT), Name: tt.TypeName, Elements: fields}
+	case *typedef.CounterType:
+		return nativeType(gocql.TypeCounter)
+	case *typedef.VectorType:
+		return tt.CQLType()
+	default:
+		return nil
+	}
+}
+
+// newValue returns a pointer to a new value of the Go type gocql reads the
+// type into, the vectors gocql does not know are read into typedef.Vector.
+func newValue(info gocql.TypeInfo) interface{} {
+	if typedef.IsVector(info) {
+		return new(typedef.Vector)
+	}
+	return info.New()
+}
+
+func udtFieldNames(t *typedef.UDTType) []string {
+	names := make([]string, 0, len(t.ValueTypes))
+	for name := range t.ValueTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isMultiCell reports whether the column type is stored element by element,
+// the fields of a non frozen user defined type are its elements.
+func isMultiCell(t typedef.Type) bool {
+	switch tt := t.(type) {
+	case *typedef.BagType:
+		return !tt.Frozen
+	case *typedef.MapType:
+		return !tt.Frozen
+	case *typedef.UDTType:
+		return !tt.Frozen
+	default:
+		return false
+	}
+}
+
+// marshalValue serializes a bound value, tuple values are given element by element.
+func marshalValue(t typedef.Type, value interface{}) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := gocql.Marshal(typeInfo(t), value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to marshal %v as %s", value, t.CQLDef())
+	}
+	return normalize(t, data)
+}
+
+// normalize puts a serialized value into the form the clusters store it in,
+// the elements of sets are sorted and deduplicated and the entries of maps
+// are sorted by their keys, in the values nested in the value as well.
+func normalize(t typedef.Type, data []byte) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+	switch tt := t.(type) {
+	case *typedef.BagType:
+		elems, err := splitCollection(data, false)
+		if err != nil || len(elems) == 0 {
+			return data, err
+		}
+		for i := range elems {
+			if elems[i], err = normalize(tt.ValueType, elems[i]); err != nil {
+				return nil, err
+			}
+		}
+		if tt.ComplexType == typedef.TYPE_SET {
+			sort.SliceStable(elems, func(i, j int) bool {
+				return compareValues(tt.ValueType, elems[i], elems[j]) < 0
+			})
+			unique := elems[:1]
+			for _, e := range elems[1:] {
+				if compareValues(tt.ValueType, unique[len(unique)-1], e) != 0 {
+					unique = append(unique, e)
+				}
+			}
+			elems = unique
+		}
+		return joinCollection(elems, false), nil
+	case *typedef.MapType:
+		elems, err := splitCollection(data, true)
+		if err != nil || len(elems) == 0 {
+			return data, err
+		}
+		entries := make([][2][]byte, 0, len(elems)/2)
+		for i := 0; i < len(elems); i += 2 {
+			value, err := normalize(tt.ValueType, elems[i+1])
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, [2][]byte{elems[i], value})
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			return compareValues(tt.KeyType, entries[i][0], entries[j][0]) < 0
+		})
+		elems = elems[:0]
+		for i, e := range entries {
+			if i > 0 && compareValues(tt.KeyType, entries[i-1][0], e[0]) == 0 {
+				continue
+			}
+			elems = append(elems, e[0], e[1])
+		}
+		return joinCollection(elems, true), nil
+	case *typedef.TupleType:
+		return normalizeFields(tt.ValueTypes, data)
+	case *typedef.UDTType:
+		names := udtFieldNames(tt)
+		types := make([]typedef.Type, len(names))
+		for i, name := range names {
+			types[i] = tt.ValueTypes[name]
+		}
+		return normalizeFields(types, data)
+	default:
+		return data, nil
+	}
+}
+
+// normalizeFields normalizes the elements of a tuple or the fields of a
+// user defined type, the missing trailing ones stay missing.
+func normalizeFields(types []typedef.Type, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, ft := range types {
+		if len(data) == 0 {
+			break
+		}
+		var field []byte
+		field, data = readElement(data)
+		field, err := normalize(ft, field)
+		if err != nil {
+			return nil, err
+		}
+		writeBytes(&buf, field)
+	}
+	return buf.Bytes(), nil
+}
+
+// isUnset reports whether the expression binds an unset value, which leaves
+// the column as it was.
+func isUnset(e cqlExpr, values []interface{}) bool {
+	return e.bind >= 0 && e.bind < len(values) && values[e.bind] == gocql.UnsetValue
+}
+
+// marshalExpr serializes a parsed expression of the given type.
+func marshalExpr(t typedef.Type, e cqlExpr, values []interface{}) ([]byte, error) {
+	switch {
+	case e.isNull:
+		return nil, nil
+	case e.isTuple:
+		tt, ok := t.(*typedef.TupleType)
+		if !ok || len(tt.ValueTypes) != len(e.tuple) {
+			return nil, errors.Errorf("unexpected tuple value for %s", t.CQLDef())
+		}
+		var buf bytes.Buffer
+		for i, elem := range e.tuple {
+			data, err := marshalExpr(tt.ValueTypes[i], elem, values)
+			if err != nil {
+				return nil, err
+			}
+			writeBytes(&buf, data)
+		}
+		return buf.Bytes(), nil
+	case e.bind >= 0:
+		if e.bind >= len(values) {
+			return nil, errors.Errorf("missing value for bind marker %d", e.bind)
+		}
+		if tt, ok := t.(*typedef.TupleType); ok {
+			// a single bind marker for the whole tuple
+			if vs, isSlice := values[e.bind].([]interface{}); isSlice && len(vs) == len(tt.ValueTypes) {
+				return marshalValue(t, vs)
+			}
+		}
+		return marshalValue(t, values[e.bind])
+	default:
+		return marshalLiteral(t, e.literal)
+	}
+}
+
+func marshalLiteral(t typedef.Type, literal string) ([]byte, error) {
+	if _, ok := t.(*typedef.CounterType); ok {
+		v, err := strconv.ParseInt(literal, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid counter literal %s", literal)
+		}
+		return marshalValue(t, v)
+	}
+	st, ok := t.(typedef.SimpleType)
+	if !ok {
+		return nil, errors.Errorf("literal %s is not supported for %s", literal, t.CQLDef())
+	}
+	v, err := jsonToValue(st, literal)
+	if err != nil {
+		return nil, err
+	}
+	return marshalValue(st, v)
+}
+
+func writeBytes(buf *bytes.Buffer, data []byte) {
+	var size [4]byte
+	if data == nil {
+		binary.BigEndian.PutUint32(size[:], math.MaxUint32)
+		buf.Write(size[:])
+		return
+	}
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+	buf.Write(size[:])
+	buf.Write(data)
+}
+
+// splitCollection splits a serialized list, set or map into its elements,
+// maps yield key and value pairs.
+func splitCollection(data []byte, pairs bool) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if len(data) < 4 {
+		return nil, errors.New("malformed collection")
+	}
+	n := int(int32(binary.BigEndian.Uint32(data)))
+	data = data[4:]
+	if pairs {
+		n *= 2
+	}
+	out := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		if len(data) < 4 {
+			return nil, errors.New("malformed collection")
+		}
+		size := int(int32(binary.BigEndian.Uint32(data)))
+		data = data[4:]
+		if size < 0 {
+			out = append(out, nil)
+			continue
+		}
+		if len(data) < size {
+			return nil, errors.New("malformed collection")
+		}
+		out = append(out, data[:size])
+		data = data[size:]
+	}
+	return out, nil
+}
+
+func joinCollection(elems [][]byte, pairs bool) []byte {
+	if len(elems) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	n := len(elems)
+	if pairs {
+		n /= 2
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(n))
+	buf.Write(size[:])
+	for _, e := range elems {
+		writeBytes(&buf, e)
+	}
+	return buf.Bytes()
+}
+
+// compositeKey encodes the serialized key components the same way
+// composite routing keys are built.
+func compositeKey(parts [][]byte) string {
+	if len(parts) == 1 {
+		return string(parts[0])
+	}
+	var buf bytes.Buffer
+	for _, p := range parts {
+		var size [2]byte
+		binary.BigEndian.PutUint16(size[:], uint16(len(p)))
+		buf.Write(size[:])
+		buf.Write(p)
+		buf.WriteByte(0x00)
+	}
+	return buf.String()
+}
+
+func token(parts [][]byte) int64 {
+	return murmur.Murmur3H1([]byte(compositeKey(parts)))
+}
+
+// compareValues orders serialized values of a type the way the database does.
+func compareValues(t typedef.Type, a, b []byte) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	st, ok := t.(typedef.SimpleType)
+	if !ok {
+		return compareComplex(t, a, b)
+	}
+	switch st {
+	case typedef.TYPE_BIGINT, typedef.TYPE_INT, typedef.TYPE_SMALLINT, typedef.TYPE_TINYINT,
+		typedef.TYPE_TIME, typedef.TYPE_TIMESTAMP:
+		return compareSigned(a, b)
+	case typedef.TYPE_FLOAT:
+		if len(a) != 4 || len(b) != 4 {
+			return bytes.Compare(a, b)
+		}
+		return compareFloats(float64(math.Float32frombits(binary.BigEndian.Uint32(a))), float64(math.Float32frombits(binary.BigEndian.Uint32(b))))
+	case typedef.TYPE_DOUBLE:
+		if len(a) != 8 || len(b) != 8 {
+			return bytes.Compare(a, b)
+		}
+		return compareFloats(math.Float64frombits(binary.BigEndian.Uint64(a)), math.Float64frombits(binary.BigEndian.Uint64(b)))
+	case typedef.TYPE_VARINT:
+		return varint(a).Cmp(varint(b))
+	case typedef.TYPE_DECIMAL:
+		if len(a) < 4 || len(b) < 4 {
+			return bytes.Compare(a, b)
+		}
+		da := inf.NewDecBig(varint(a[4:]), inf.Scale(int32(binary.BigEndian.Uint32(a))))
+		db := inf.NewDecBig(varint(b[4:]), inf.Scale(int32(binary.BigEndian.Uint32(b))))
+		return da.Cmp(db)
+	case typedef.TYPE_UUID, typedef.TYPE_TIMEUUID:
+		return compareUUIDs(a, b)
+	default:
+		return bytes.Compare(a, b)
+	}
+}
+
+func compareSigned(a, b []byte) int {
+	if len(a) != len(b) || len(a) == 0 {
+		return bytes.Compare(a, b)
+	}
+	if (a[0]^b[0])&0x80 != 0 {
+		// different signs, the negative one is smaller
+		if a[0]&0x80 != 0 {
+			return -1
+		}
+		return 1
+	}
+	return bytes.Compare(a, b)
+}
+
+// compareFloats orders NaN after all the other values, as the clusters do.
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return 1
+	case math.IsNaN(b):
+		return -1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func varint(data []byte) *big.Int {
+	v := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(data))*8))
+	}
+	return v
+}
+
+func compareUUIDs(a, b []byte) int {
+	if len(a) != 16 || len(b) != 16 {
+		return bytes.Compare(a, b)
+	}
+	if va, vb := a[6]>>4, b[6]>>4; va != vb {
+		if va < vb {
+			return -1
+		}
+		return 1
+	}
+	if a[6]>>4 == 1 {
+		ta, tb := uuidTime(a), uuidTime(b)
+		switch {
+		case ta < tb:
+			return -1
+		case ta > tb:
+			return 1
+		}
+	}
+	return bytes.Compare(a, b)
+}
+
+func uuidTime(u []byte) uint64 {
+	low := uint64(binary.BigEndian.Uint32(u[0:4]))
+	mid := uint64(binary.BigEndian.Uint16(u[4:6]))
+	high := uint64(binary.BigEndian.Uint16(u[6:8]) & 0x0fff)
+	return high<<48 | mid<<32 | low
+}
+
+// compareComplex orders frozen values the way the clusters do: collections
+// element by element and then by their size, tuples and user defined types
+// field by field.
+func compareComplex(t typedef.Type, a, b []byte) int {
+	switch tt := t.(type) {
+	case *typedef.TupleType:
+		return compareFields(tt.ValueTypes, a, b)
+	case *typedef.UDTType:
+		names := udtFieldNames(tt)
+		types := make([]typedef.Type, len(names))
+		for i, name := range names {
+			types[i] = tt.ValueTypes[name]
+		}
+		return compareFields(types, a, b)
+	case *typedef.BagType:
+		return compareCollections([]typedef.Type{tt.ValueType}, a, b)
+	case *typedef.MapType:
+		return compareCollections([]typedef.Type{tt.KeyType, tt.ValueType}, a, b)
+	case *typedef.VectorType:
+		for len(a) >= 4 && len(b) >= 4 {
+			fa, fb := math.Float32frombits(binary.BigEndian.Uint32(a)), math.Float32frombits(binary.BigEndian.Uint32(b))
+			if c := compareFloats(float64(fa), float64(fb)); c != 0 {
+				return c
+			}
+			a, b = a[4:], b[4:]
+		}
+		return bytes.Compare(a, b)
+	default:
+		return bytes.Compare(a, b)
+	}
+}
+
+func compareFields(types []typedef.Type, a, b []byte) int {
+	for _, vt := range types {
+		var ea, eb []byte
+		ea, a = readElement(a)
+		eb, b = readElement(b)
+		if c := compareValues(vt, ea, eb); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareCollections compares serialized collections, the types are the
+// types of the parts of each element, the key and value of map entries.
+func compareCollections(types []typedef.Type, a, b []byte) int {
+	ea, errA := splitCollection(a, len(types) == 2)
+	eb, errB := splitCollection(b, len(types) == 2)
+	if errA != nil || errB != nil {
+		return bytes.Compare(a, b)
+	}
+	for i := 0; i < len(ea) && i < len(eb); i++ {
+		if c := compareValues(types[i%len(types)], ea[i], eb[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(ea) < len(eb):
+		return -1
+	case len(ea) > len(eb):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func readElement(data []byte) (elem, rest []byte) {
+	if len(data) < 4 {
+		return nil, nil
+	}
+	size := int(int32(binary.BigEndian.Uint32(data)))
+	data = data[4:]
+	if size < 0 || size > len(data) {
+		return nil, data
+	}
+	return data[:size], data[size:]
+}
+
+// unmarshalColumn puts the value of the column into the row exactly the way
+// gocql MapScan does, tuples are split into one entry per element.
+func unmarshalColumn(row map[string]interface{}, col *typedef.ColumnDef, data []byte) error {
+	info := typeInfo(col.Type)
+	if info == nil {
+		return errors.Errorf("unsupported type %s of column %s", col.Type.CQLDef(), col.Name)
+	}
+	if tuple, ok := info.(gocql.TupleTypeInfo); ok {
+		dest := make([]interface{}, len(tuple.Elems))
+		for i, elem := range tuple.Elems {
+			dest[i] = newValue(elem)
+		}
+		if err := gocql.Unmarshal(tuple, data, dest); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal column %s", col.Name)
+		}
+		for i := range dest {
+			row[gocql.TupleColumnName(col.Name, i)] = copySlice(reflect.Indirect(reflect.ValueOf(dest[i])).Interface())
+		}
+		return nil
+	}
+	dest := newValue(info)
+	if err := gocql.Unmarshal(info, data, dest); err != nil {
+		return errors.Wrapf(err, "unable to unmarshal column %s", col.Name)
+	}
+	row[col.Name] = copySlice(reflect.Indirect(reflect.ValueOf(dest)).Interface())
+	return nil
+}
+
+// copySlice copies the slice out of the buffer it was read into, a nil slice,
+// the value of a null collection, stays nil.
+func copySlice(val interface{}) interface{} {
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Slice || v.IsNil() {
+		return val
+	}
+	out := reflect.MakeSlice(v.Type(), v.Len(), v.Cap())
+	reflect.Copy(out, v)
+	return out.Interface()
+}
+
+// jsonColumnValue converts a decoded INSERT JSON value into a value gocql can marshal.
+func jsonColumnValue(t typedef.Type, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch tt := t.(type) {
+	case typedef.SimpleType:
+		return jsonToValue(tt, value)
+	case *typedef.CounterType:
+		return jsonToValue(typedef.TYPE_BIGINT, value)
+	case *typedef.BagType:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, errors.Errorf("expected list for %s, got %T", tt.CQLDef(), value)
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := jsonColumnValue(tt.ValueType, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case *typedef.MapType:
+		items, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("expected map for %s, got %T", tt.CQLDef(), value)
+		}
+		out := make(map[interface{}]interface{}, len(items))
+		for k, item := range items {
+			key, err := jsonToValue(tt.KeyType, k)
+			if err != nil {
+				return nil, err
+			}
+			if out[key], err = jsonColumnValue(tt.ValueType, item); err != nil {
+				return nil, err
+			}
+		}
+		return mapOf(tt, out)
+	case *typedef.TupleType:
+		items, ok := value.([]interface{})
+		if !ok || len(items) != len(tt.ValueTypes) {
+			return nil, errors.Errorf("expected tuple for %s, got %v", tt.CQLDef(), value)
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := jsonColumnValue(tt.ValueTypes[i], item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case *typedef.UDTType:
+		items, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("expected object for %s, got %T", tt.CQLDef(), value)
+		}
+		out := make(map[string]interface{}, len(items))
+		for k, item := range items {
+			ft, exists := tt.ValueTypes[k]
+			if !exists {
+				return nil, errors.Errorf("unknown field %s of %s", k, tt.TypeName)
+			}
+			v, err := jsonColumnValue(ft, item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = v
+		}
+		return out, nil
+	case *typedef.VectorType:
+		items, ok := value.([]interface{})
+		if !ok || len(items) != tt.Dimensions {
+			return nil, errors.Errorf("expected %d floats for %s, got %v", tt.Dimensions, tt.CQLDef(), value)
+		}
+		out := make(typedef.Vector, len(items))
+		for i, item := range items {
+			v, err := jsonToValue(typedef.TYPE_FLOAT, item)
+			if err != nil {
+				return nil, err
+			}
+			switch f := v.(type) {
+			case float32:
+				out[i] = f
+			case float64:
+				out[i] = float32(f)
+			default:
+				return nil, errors.Errorf("expected float for %s, got %v", tt.CQLDef(), item)
+			}
+		}
+		return out, nil
+	default:
+		return nil, errors.Errorf("unsupported type %s", t.CQLDef())
+	}
+}
+
+// mapOf builds a typed map since gocql can not marshal map[interface{}]interface{}.
+func mapOf(t *typedef.MapType, items map[interface{}]interface{}) (interface{}, error) {
+	info := typeInfo(t).(gocql.CollectionType)
+	var buf bytes.Buffer
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(items)))
+	buf.Write(size[:])
+	for k, v := range items {
+		kd, err := gocql.Marshal(info.Key, k)
+		if err != nil {
+			return nil, err
+		}
+		vd, err := gocql.Marshal(info.Elem, v)
+		if err != nil {
+			return nil, err
+		}
+		writeBytes(&buf, kd)
+		writeBytes(&buf, vd)
+	}
+	return rawValue(buf.Bytes()), nil
+}
+
+// rawValue is an already serialized value.
+type rawValue []byte
+
+func (r rawValue) MarshalCQL(gocql.TypeInfo) ([]byte, error) {
+	return r, nil
+}
+
+func jsonToValue(t typedef.SimpleType, value interface{}) (interface{}, error) {
+	str, isString := value.(string)
+	if n, ok := value.(json.Number); ok {
+		str, isString = n.String(), true
+	}
+	if !isString {
+		return value, nil
+	}
+	switch t {
+	case typedef.TYPE_BLOB:
+		decoded, err := hex.DecodeString(strings.TrimPrefix(str, "0x"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid blob %s", str)
+		}
+		return decoded, nil
+	case typedef.TYPE_BIGINT, typedef.TYPE_INT, typedef.TYPE_SMALLINT, typedef.TYPE_TINYINT:
+		v, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s %s", t, str)
+		}
+		return v, nil
+	case typedef.TYPE_VARINT:
+		v, ok := new(big.Int).SetString(str, 10)
+		if !ok {
+			return nil, errors.Errorf("invalid varint %s", str)
+		}
+		return v, nil
+	case typedef.TYPE_DECIMAL:
+		v, ok := new(inf.Dec).SetString(str)
+		if !ok {
+			return nil, errors.Errorf("invalid decimal %s", str)
+		}
+		return v, nil
+	case typedef.TYPE_FLOAT:
+		v, err := strconv.ParseFloat(str, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid float %s", str)
+		}
+		return float32(v), nil
+	case typedef.TYPE_DOUBLE:
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid double %s", str)
+		}
+		return v, nil
+	case typedef.TYPE_BOOLEAN:
+		return strconv.ParseBool(str)
+	case typedef.TYPE_TIMESTAMP:
+		if v, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return v, nil
+		}
+		v, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid timestamp %s", str)
+		}
+		return v, nil
+	case typedef.TYPE_TIME:
+		if v, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return v, nil
+		}
+		v, err := time.Parse("15:04:05.999999999", str)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid time %s", str)
+		}
+		return int64(v.Sub(time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC))), nil
+	case typedef.TYPE_DURATION:
+		return parseDuration(str)
+	default:
+		return str, nil
+	}
+}
+
+// durationUnits are the units of duration literals, the longer ones first
+// so that "ms" is not read as minutes followed by a stray "s".
+var durationUnits = []struct {
+	unit   string
+	months int64
+	days   int64
+	nanos  int64
+}{
+	{unit: "y", months: 12},
+	{unit: "mo", months: 1},
+	{unit: "w", days: 7},
+	{unit: "d", days: 1},
+	{unit: "h", nanos: int64(time.Hour)},
+	{unit: "ms", nanos: int64(time.Millisecond)},
+	{unit: "m", nanos: int64(time.Minute)},
+	{unit: "s", nanos: int64(time.Second)},
+	{unit: "us", nanos: int64(time.Microsecond)},
+	{unit: "µs", nanos: int64(time.Microsecond)},
+	{unit: "ns", nanos: 1},
+}
+
+// parseDuration parses duration literals such as -1mo2d3h4m5s6ms7us8ns, the
+// form the clusters print durations in.
+func parseDuration(literal string) (gocql.Duration, error) {
+	s := strings.ToLower(literal)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return gocql.Duration{}, errors.Errorf("invalid duration %s", literal)
+	}
+	var months, days, nanos int64
+	for s != "" {
+		end := 0
+		for end < len(s) && isDigit(s[end]) {
+			end++
+		}
+		n, err := strconv.ParseInt(s[:end], 10, 64)
+		if err != nil {
+			return gocql.Duration{}, errors.Wrapf(err, "invalid duration %s", literal)
+		}
+		s = s[end:]
+		found := false
+		for _, u := range durationUnits {
+			if strings.HasPrefix(s, u.unit) {
+				months, days, nanos = months+n*u.months, days+n*u.days, nanos+n*u.nanos
+				s, found = s[len(u.unit):], true
+				break
+			}
+		}
+		if !found {
+			return gocql.Duration{}, errors.Errorf("invalid duration %s", literal)
+		}
+	}
+	if negative {
+		months, days, nanos = -months, -days, -nanos
+	}
+	if months < math.MinInt32 || months > math.MaxInt32 || days < math.MinInt32 || days > math.MaxInt32 {
+		return gocql.Duration{}, errors.Errorf("duration %s out of range", literal)
+	}
+	return gocql.Duration{Months: int32(months), Days: int32(days), Nanoseconds: nanos}, nil
+}
@@ -0,0 +1,285 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2/qb"
+
+	"github.com/scylladb/gemini/pkg/joberror"
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+// TokenColumn is the name the queries passed to CheckScan select the token
+// of the partition key as, for example token(pk0,pk1) AS scan_token.
+const TokenColumn = "scan_token"
+
+// rowIterator returns the rows of a query one at a time, pages reports how
+// many pages of the result were fetched so far.
+type rowIterator interface {
+	next() (map[string]interface{}, bool)
+	pages() int
+	close() error
+}
+
+type cqlRowIterator struct {
+	iter      *gocql.Iter
+	system    string
+	query     string
+	pageCount int
+}
+
+func (it *cqlRowIterator) next() (map[string]interface{}, bool) {
+	if it.iter.WillSwitchPage() {
+		it.pageCount++
+	}
+	row := make(map[string]interface{})
+	if !mapScan(it.iter, row) {
+		return nil, false
+	}
+	return row, true
+}
+
+// mapScan reads the next row into the map as gocql MapScan does, which can
+// not read the vectors, tuples are split into one entry per element.
+func mapScan(iter *gocql.Iter, row map[string]interface{}) bool {
+	var names []string
+	var dest []interface{}
+	for _, col := range iter.Columns() {
+		if tuple, ok := col.TypeInfo.(gocql.TupleTypeInfo); ok {
+			for i, elem := range tuple.Elems {
+				names = append(names, gocql.TupleColumnName(col.Name, i))
+				dest = append(dest, newValue(elem))
+			}
+			continue
+		}
+		names = append(names, col.Name)
+		dest = append(dest, newValue(col.TypeInfo))
+	}
+	if !iter.Scan(dest...) {
+		return false
+	}
+	for i, name := range names {
+		row[name] = copySlice(reflect.Indirect(reflect.ValueOf(dest[i])).Interface())
+	}
+	return true
+}
+
+func (it *cqlRowIterator) pages() int {
+	return it.pageCount
+}
+
+func (it *cqlRowIterator) close() error {
+	if err := it.iter.Close(); err != nil {
+		return errors.Wrapf(err, "[cluster = %s, query = '%s']", it.system, it.query)
+	}
+	return nil
+}
+
+// resumingRowIterator fetches every page of a query with a new query that
+// resumes from the paging state of the page before, as clients that page
+// through a result on demand do.
+type resumingRowIterator struct {
+	iter      *gocql.Iter
+	err       error
+	page      func(state []byte) *gocql.Iter
+	system    string
+	query     string
+	pageCount int
+}
+
+func (it *resumingRowIterator) next() (map[string]interface{}, bool) {
+	for it.iter != nil {
+		row := make(map[string]interface{})
+		if mapScan(it.iter, row) {
+			return row, true
+		}
+		state := it.iter.PageState()
+		it.err = it.iter.Close()
+		it.iter = nil
+		if it.err == nil && len(state) > 0 {
+			it.iter = it.page(state)
+			it.pageCount++
+		}
+	}
+	return nil, false
+}
+
+func (it *resumingRowIterator) pages() int {
+	return it.pageCount
+}
+
+func (it *resumingRowIterator) close() error {
+	if it.iter != nil {
+		it.err = it.iter.Close()
+		it.iter = nil
+	}
+	if it.err != nil {
+		return errors.Wrapf(it.err, "[cluster = %s, query = '%s', page = %d]", it.system, it.query, it.pageCount)
+	}
+	return nil
+}
+
+// sliceRowIterator returns rows that were already loaded, they are counted
+// in pages of pageSize rows as they are read, like a paged query would.
+type sliceRowIterator struct {
+	err      error
+	rows     []map[string]interface{}
+	pageSize int
+	read     int
+}
+
+func (it *sliceRowIterator) next() (map[string]interface{}, bool) {
+	if len(it.rows) == 0 {
+		return nil, false
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	it.read++
+	return row, true
+}
+
+func (it *sliceRowIterator) pages() int {
+	if it.pageSize <= 0 || it.read == 0 {
+		return 1
+	}
+	return (it.read + it.pageSize - 1) / it.pageSize
+}
+
+func (it *sliceRowIterator) close() error {
+	return it.err
+}
+
+// partitionReader groups the rows of an iterator, ordered by token, into
+// the partitions they belong to. Partitions whose keys collide on the same
+// token are returned together.
+type partitionReader struct {
+	iter    rowIterator
+	pending map[string]interface{}
+	token   int64
+	done    bool
+}
+
+func newPartitionReader(iter rowIterator) *partitionReader {
+	return &partitionReader{iter: iter}
+}
+
+// next returns the token and the rows of the next partition, the rows are
+// nil once the iterator is exhausted.
+func (r *partitionReader) next() (int64, []map[string]interface{}, error) {
+	if r.pending == nil {
+		if err := r.read(); err != nil || r.done {
+			return 0, nil, err
+		}
+	}
+	token := r.token
+	var rows []map[string]interface{}
+	for r.pending != nil && r.token == token {
+		rows = append(rows, r.pending)
+		if err := r.read(); err != nil {
+			return 0, nil, err
+		}
+	}
+	return token, rows, nil
+}
+
+// read moves the next row of the iterator, without its token, to pending.
+func (r *partitionReader) read() error {
+	row, ok := r.iter.next()
+	if !ok {
+		r.pending, r.done = nil, true
+		return nil
+	}
+	token, ok := row[TokenColumn].(int64)
+	if !ok {
+		return errors.Errorf("row has no %s column", TokenColumn)
+	}
+	delete(row, TokenColumn)
+	r.pending, r.token = row, token
+	return nil
+}
+
+// CheckScan compares the rows the query returns on both stores. The rows are
+// read page by page and merged partition by partition, so that only a single
+// partition of each store is kept in memory. The query has to select the
+// token of the partition key as TokenColumn and return the rows in token
+// order, as a token range scan does.
+func (ds delegatingStore) CheckScan(ctx context.Context, table *typedef.Table, builder qb.Builder, values ...interface{}) (CheckStats, error) {
+	var stats CheckStats
+	if !ds.validations {
+		return stats, nil
+	}
+	read := typedef.ReadOptions{PageSize: ds.scanPageSize}
+	testIter := ds.testStore.scan(ctx, builder, values, read)
+	oracleIter := ds.oracleStore.scan(ctx, builder, values, read)
+	var err error
+	stats.Rows, err = compareScans(table, newPartitionReader(oracleIter), newPartitionReader(testIter))
+	stats.OraclePages, stats.TestPages = oracleIter.pages(), testIter.pages()
+	if closeErr := oracleIter.close(); closeErr != nil {
+		return stats, errors.Wrap(closeErr, "unable to scan the oracle store")
+	}
+	if closeErr := testIter.close(); closeErr != nil {
+		return stats, errors.Wrap(closeErr, "unable to scan the test store")
+	}
+	return stats, err
+}
+
+func compareScans(table *typedef.Table, oracle, test *partitionReader) (int, error) {
+	opts := compareOptions(table, typedef.ReadOptions{})
+	var compared int
+	for {
+		var testToken int64
+		var testRows []map[string]interface{}
+		var testErr error
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			testToken, testRows, testErr = test.next()
+			wg.Done()
+		}()
+		oracleToken, oracleRows, oracleErr := oracle.next()
+		wg.Wait()
+		switch {
+		case oracleErr != nil:
+			return compared, errors.Wrap(oracleErr, "unable to scan the oracle store")
+		case testErr != nil:
+			return compared, errors.Wrap(testErr, "unable to scan the test store")
+		case oracleRows == nil && testRows == nil:
+			return compared, nil
+		case testRows == nil || (oracleRows != nil && oracleToken < testToken):
+			missing := pks(table, oracleRows)
+			return compared, &joberror.RowDiffError{
+				Message: fmt.Sprintf("partition with token %d is missing in the test store, test is missing rows: %s", oracleToken, missing),
+				Diff:    &joberror.RowDiff{MissingInTest: missing},
+			}
+		case oracleRows == nil || testToken < oracleToken:
+			missing := pks(table, testRows)
+			return compared, &joberror.RowDiffError{
+				Message: fmt.Sprintf("partition with token %d is missing in the oracle store, oracle is missing rows: %s", testToken, missing),
+				Diff:    &joberror.RowDiff{MissingInOracle: missing},
+			}
+		}
+		if err := compareRows(table, oracleRows, testRows, true, opts); err != nil {
+			return compared, err
+		}
+		compared += len(oracleRows)
+	}
+}
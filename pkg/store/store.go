@@ -21,6 +21,8 @@ import (
 	"os"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -37,20 +39,38 @@ import (
 	"go.uber.org/multierr"
 	"gopkg.in/inf.v0"
 
+	"github.com/scylladb/gemini/pkg/joberror"
+	"github.com/scylladb/gemini/pkg/stmtlog"
 	"github.com/scylladb/gemini/pkg/typedef"
 )
 
 type loader interface {
-	load(context.Context, qb.Builder, []interface{}) ([]map[string]interface{}, error)
+	scan(context.Context, qb.Builder, []interface{}, typedef.ReadOptions) rowIterator
 }
 
 type storer interface {
-	mutate(context.Context, qb.Builder, ...interface{}) error
+	mutate(context.Context, qb.Builder, time.Time, ...interface{}) error
+	mutateLWT(context.Context, qb.Builder, time.Time, ...interface{}) (*lwtResult, error)
+}
+
+// lwtResult is the outcome of a conditional statement. previous holds the
+// current values a store returned along with it, it is nil for stores that
+// do not report them.
+type lwtResult struct {
+	previous map[string]interface{}
+	applied  bool
+}
+
+// builder tells whether a store finished building a materialized view or a
+// secondary index created on a table that already had rows.
+type builder interface {
+	built(ctx context.Context, keyspace, name string, index bool) (bool, error)
 }
 
 type storeLoader interface {
 	storer
 	loader
+	builder
 	close() error
 	name() string
 }
@@ -58,14 +78,32 @@ type storeLoader interface {
 type Store interface {
 	Create(context.Context, qb.Builder, qb.Builder) error
 	Mutate(context.Context, qb.Builder, ...interface{}) error
-	Check(context.Context, *typedef.Table, qb.Builder, bool, ...interface{}) error
+	MutateLWT(context.Context, *typedef.Table, qb.Builder, []string, ...interface{}) error
+	Check(context.Context, *typedef.Table, qb.Builder, typedef.ReadOptions, bool, ...interface{}) (CheckStats, error)
+	CheckScan(context.Context, *typedef.Table, qb.Builder, ...interface{}) (CheckStats, error)
+	AwaitBuilt(ctx context.Context, keyspace, name string, index bool) error
 	Close() error
 }
 
+// buildPollInterval is how often the build status of a view or an index is
+// polled while AwaitBuilt waits for it.
+const buildPollInterval = time.Second
+
+// CheckStats tells how much of the results of a check was read and compared
+// before it completed or diverged.
+type CheckStats struct {
+	OraclePages int
+	TestPages   int
+	Rows        int
+}
+
 type Config struct {
 	MaxRetriesMutate        int
 	MaxRetriesMutateSleep   time.Duration
+	StatementLogFile        string
+	ScanPageSize            int
 	UseServerSideTimestamps bool
+	UseInMemoryOracle       bool
 }
 
 func New(schema *typedef.Schema, testCluster, oracleCluster *gocql.ClusterConfig, cfg Config, traceOut *os.File, logger *zap.Logger) (Store, error) {
@@ -93,6 +131,9 @@ func New(schema *typedef.Schema, testCluster, oracleCluster *gocql.ClusterConfig
 			logger:                  logger,
 		}
 		validations = true
+	} else if cfg.UseInMemoryOracle {
+		oracleStore = newMemStore(schema, ops, "oracle")
+		validations = true
 	} else {
 		oracleStore = &noOpStore{
 			system: "oracle",
@@ -104,6 +145,13 @@ func New(schema *typedef.Schema, testCluster, oracleCluster *gocql.ClusterConfig
 		return nil, errors.Wrapf(err, "failed to connect to oracle cluster")
 	}
 
+	var statementLogger *stmtlog.Logger
+	if cfg.StatementLogFile != "" {
+		if statementLogger, err = stmtlog.NewLogger(cfg.StatementLogFile); err != nil {
+			return nil, err
+		}
+	}
+
 	return &delegatingStore{
 		testStore: &cqlStore{
 			session:                 testSession,
@@ -115,9 +163,12 @@ func New(schema *typedef.Schema, testCluster, oracleCluster *gocql.ClusterConfig
 			useServerSideTimestamps: cfg.UseServerSideTimestamps,
 			logger:                  logger,
 		},
-		oracleStore: oracleStore,
-		validations: validations,
-		logger:      logger.Named("delegating_store"),
+		oracleStore:             oracleStore,
+		statementLogger:         statementLogger,
+		scanPageSize:            cfg.ScanPageSize,
+		validations:             validations,
+		useServerSideTimestamps: cfg.UseServerSideTimestamps,
+		logger:                  logger.Named("delegating_store"),
 	}, nil
 }
 
@@ -125,14 +176,22 @@ type noOpStore struct {
 	system string
 }
 
-func (n *noOpStore) mutate(context.Context, qb.Builder, ...interface{}) error {
+func (n *noOpStore) mutate(context.Context, qb.Builder, time.Time, ...interface{}) error {
 	return nil
 }
 
-func (n *noOpStore) load(context.Context, qb.Builder, []interface{}) ([]map[string]interface{}, error) {
+func (n *noOpStore) mutateLWT(context.Context, qb.Builder, time.Time, ...interface{}) (*lwtResult, error) {
 	return nil, nil
 }
 
+func (n *noOpStore) scan(context.Context, qb.Builder, []interface{}, typedef.ReadOptions) rowIterator {
+	return &sliceRowIterator{}
+}
+
+func (n *noOpStore) built(context.Context, string, string, bool) (bool, error) {
+	return true, nil
+}
+
 func (n *noOpStore) Close() error {
 	return nil
 }
@@ -146,36 +205,46 @@ func (n *noOpStore) close() error {
 }
 
 type delegatingStore struct {
-	oracleStore storeLoader
-	testStore   storeLoader
-	logger      *zap.Logger
-	validations bool
+	oracleStore             storeLoader
+	testStore               storeLoader
+	statementLogger         *stmtlog.Logger
+	logger                  *zap.Logger
+	scanPageSize            int
+	validations             bool
+	useServerSideTimestamps bool
 }
 
 func (ds delegatingStore) Create(ctx context.Context, testBuilder, oracleBuilder qb.Builder) error {
-	if err := mutate(ctx, ds.oracleStore, oracleBuilder, []interface{}{}); err != nil {
+	ts := time.Now()
+	if err := mutate(ctx, ds.oracleStore, oracleBuilder, ts, []interface{}{}); err != nil {
+		ds.logStatement(testBuilder, oracleBuilder, ts, nil, errNotAttempted, err)
 		return errors.Wrap(err, "oracle failed store creation")
 	}
-	if err := mutate(ctx, ds.testStore, testBuilder, []interface{}{}); err != nil {
+	err := mutate(ctx, ds.testStore, testBuilder, ts, []interface{}{})
+	ds.logStatement(testBuilder, oracleBuilder, ts, nil, err, nil)
+	if err != nil {
 		return errors.Wrap(err, "test failed store creation")
 	}
 	return nil
 }
 
 func (ds delegatingStore) Mutate(ctx context.Context, builder qb.Builder, values ...interface{}) error {
+	ts := time.Now()
 	var testErr error
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
-		testErr = mutate(ctx, ds.testStore, builder, values...)
+		testErr = mutate(ctx, ds.testStore, builder, ts, values...)
 		wg.Done()
 	}()
-	if oracleErr := mutate(ctx, ds.oracleStore, builder, values...); oracleErr != nil {
+	oracleErr := mutate(ctx, ds.oracleStore, builder, ts, values...)
+	wg.Wait()
+	ds.logStatement(builder, nil, ts, values, testErr, oracleErr)
+	if oracleErr != nil {
 		// Oracle failed, transition cannot take place
 		ds.logger.Info("oracle store failed mutation, transition to next state impossible so continuing with next mutation", zap.Error(oracleErr))
 		return oracleErr
 	}
-	wg.Wait()
 	if testErr != nil {
 		// Test store failed, transition cannot take place
 		ds.logger.Info("test store failed mutation, transition to next state impossible so continuing with next mutation", zap.Error(testErr))
@@ -184,33 +253,362 @@ func (ds delegatingStore) Mutate(ctx context.Context, builder qb.Builder, values
 	return nil
 }
 
-func mutate(ctx context.Context, s storeLoader, builder qb.Builder, values ...interface{}) error {
-	if err := s.mutate(ctx, builder, values...); err != nil {
+// MutateLWT applies a conditional statement to both stores. Whether it was
+// applied, and the current values of the condition columns returned with it
+// when both stores report them, have to be the same, otherwise a
+// *joberror.RowDiffError is returned. The other returned columns are not
+// compared, which of them a cluster returns is up to it.
+func (ds delegatingStore) MutateLWT(
+	ctx context.Context,
+	table *typedef.Table,
+	builder qb.Builder,
+	conditions []string,
+	values ...interface{},
+) error {
+	ts := time.Now()
+	var testResult *lwtResult
+	var testErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		testResult, testErr = mutateLWT(ctx, ds.testStore, builder, ts, values...)
+		wg.Done()
+	}()
+	oracleResult, oracleErr := mutateLWT(ctx, ds.oracleStore, builder, ts, values...)
+	wg.Wait()
+	ds.logStatement(builder, nil, ts, values, testErr, oracleErr)
+	if oracleErr != nil {
+		ds.logger.Info("oracle store failed mutation, transition to next state impossible so continuing with next mutation", zap.Error(oracleErr))
+		return oracleErr
+	}
+	if testErr != nil {
+		ds.logger.Info("test store failed mutation, transition to next state impossible so continuing with next mutation", zap.Error(testErr))
+		return testErr
+	}
+	if !ds.validations || oracleResult == nil || testResult == nil {
+		return nil
+	}
+	return compareLWT(table, conditions, oracleResult, testResult)
+}
+
+func compareLWT(table *typedef.Table, conditions []string, oracleResult, testResult *lwtResult) error {
+	if oracleResult.applied != testResult.applied {
+		return &joberror.RowDiffError{
+			Message: fmt.Sprintf("lwt applied differ (oracle applied: %t, test applied: %t)", oracleResult.applied, testResult.applied),
+			Diff: &joberror.RowDiff{
+				Columns: []joberror.ColumnDiff{{
+					Name:   "[applied]",
+					Oracle: strconv.FormatBool(oracleResult.applied),
+					Test:   strconv.FormatBool(testResult.applied),
+				}},
+			},
+		}
+	}
+	if oracleResult.previous == nil || testResult.previous == nil {
+		return nil
+	}
+	oracleValues := make(map[string]interface{}, len(conditions))
+	testValues := make(map[string]interface{}, len(conditions))
+	for _, name := range conditions {
+		oracleValues[name] = oracleResult.previous[name]
+		testValues[name] = testResult.previous[name]
+	}
+	opts := compareOptions(table, typedef.ReadOptions{})
+	if diff := cmp.Diff(oracleValues, testValues, opts...); diff != "" {
+		rowDiff := diffRows(table, oracleValues, testValues, opts)
+		rowDiff.PrimaryKey = pks(table, []map[string]interface{}{oracleResult.previous})[0]
+		return &joberror.RowDiffError{
+			Message: fmt.Sprintf("lwt current values differ (-%v +%v): %v", oracleValues, testValues, diff),
+			Diff:    rowDiff,
+		}
+	}
+	return nil
+}
+
+// AwaitBuilt waits until both stores finished building the materialized view,
+// or the secondary index if index is set, of the given name. Until then the
+// stores return different rows for it, or none at all.
+func (ds delegatingStore) AwaitBuilt(ctx context.Context, keyspace, name string, index bool) error {
+	pending := []storeLoader{ds.oracleStore, ds.testStore}
+	for {
+		waiting := pending[:0]
+		for _, s := range pending {
+			built, err := s.built(ctx, keyspace, name, index)
+			if err != nil {
+				return errors.Wrapf(err, "unable to get the build status of %s.%s from the %s store", keyspace, name, s.name())
+			}
+			if !built {
+				waiting = append(waiting, s)
+			}
+		}
+		if len(waiting) == 0 {
+			return nil
+		}
+		pending = waiting
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(buildPollInterval):
+		}
+	}
+}
+
+// errNotAttempted marks the side of a statement that was not applied
+// because the other side failed first.
+var errNotAttempted = errors.New("not attempted")
+
+// logStatement appends the statement to the statement log, if one is configured,
+// with the outcome of each store. A failure to record is logged but does not
+// fail the mutation itself.
+func (ds delegatingStore) logStatement(builder, oracleBuilder qb.Builder, ts time.Time, values []interface{}, testErr, oracleErr error) {
+	if ds.statementLogger == nil {
+		return
+	}
+	rec := stmtlog.Record{
+		Values: values,
+	}
+	rec.Query, _ = builder.ToCql()
+	if oracleBuilder != nil {
+		if oracleQuery, _ := oracleBuilder.ToCql(); oracleQuery != rec.Query {
+			rec.OracleQuery = oracleQuery
+		}
+	}
+	if !ds.useServerSideTimestamps {
+		rec.Timestamp = ts.UnixNano() / 1000
+	}
+	switch {
+	case errors.Is(testErr, errNotAttempted):
+		rec.Outcome = stmtlog.OutcomeSkipped
+	case testErr != nil:
+		rec.Error = testErr.Error()
+	}
+	if oracleErr != nil {
+		rec.OracleError = oracleErr.Error()
+	}
+	if logErr := ds.statementLogger.Log(&rec); logErr != nil {
+		ds.logger.Error("unable to record statement", zap.String("query", rec.Query), zap.Error(logErr))
+	}
+}
+
+func mutate(ctx context.Context, s storeLoader, builder qb.Builder, ts time.Time, values ...interface{}) error {
+	if err := s.mutate(ctx, builder, ts, values...); err != nil {
 		return errors.Wrapf(err, "unable to apply mutations to the %s store", s.name())
 	}
 	return nil
 }
 
-func (ds delegatingStore) Check(ctx context.Context, table *typedef.Table, builder qb.Builder, detailedDiff bool, values ...interface{}) error {
-	var testRows, oracleRows []map[string]interface{}
-	var testErr, oracleErr error
+func mutateLWT(ctx context.Context, s storeLoader, builder qb.Builder, ts time.Time, values ...interface{}) (*lwtResult, error) {
+	result, err := s.mutateLWT(ctx, builder, ts, values...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to apply conditional mutation to the %s store", s.name())
+	}
+	return result, nil
+}
+
+// Check compares the rows the query returns on both stores. Both results are
+// paged through in the order the stores return them, clustering order within
+// a partition, and compared row by row, so that only the current pages are
+// kept in memory. The comparison stops at the first row that differs. When
+// the primary keys of the rows diverge, a store misses rows or returns them
+// in another order, and the remaining rows are compared a partition at a
+// time to tell which. If the rows are read.Ordered the stores also have to
+// return them in the same order.
+func (ds delegatingStore) Check(
+	ctx context.Context,
+	table *typedef.Table,
+	builder qb.Builder,
+	read typedef.ReadOptions,
+	detailedDiff bool,
+	values ...interface{},
+) (CheckStats, error) {
+	var testIter rowIterator
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
-		testRows, testErr = ds.testStore.load(ctx, builder, values)
+		testIter = ds.testStore.scan(ctx, builder, values, read)
 		wg.Done()
 	}()
-	oracleRows, oracleErr = ds.oracleStore.load(ctx, builder, values)
-	if oracleErr != nil {
-		return errors.Wrapf(oracleErr, "unable to load check data from the oracle store")
-	}
+	oracleIter := ds.oracleStore.scan(ctx, builder, values, read)
 	wg.Wait()
-	if testErr != nil {
-		return errors.Wrapf(testErr, "unable to load check data from the test store")
+
+	var stats CheckStats
+	var err error
+	if ds.validations {
+		stats.Rows, err = compareIters(table, oracleIter, testIter, read, detailedDiff)
+	} else {
+		for _, ok := testIter.next(); ok; _, ok = testIter.next() {
+		}
+	}
+	stats.OraclePages, stats.TestPages = oracleIter.pages(), testIter.pages()
+	if closeErr := oracleIter.close(); closeErr != nil {
+		return stats, errors.Wrapf(closeErr, "unable to load check data from the oracle store")
+	}
+	if closeErr := testIter.close(); closeErr != nil {
+		return stats, errors.Wrapf(closeErr, "unable to load check data from the test store")
+	}
+	return stats, err
+}
+
+// compareIters compares the rows of the iterators in lockstep and returns the
+// number of rows that were equal on both stores. Unless the rows are
+// read.Ordered, the same rows returned in another order are equal.
+func compareIters(table *typedef.Table, oracle, test rowIterator, read typedef.ReadOptions, detailedDiff bool) (int, error) {
+	opts := compareOptions(table, read)
+	var compared int
+	for {
+		oracleRow, oracleOk := oracle.next()
+		testRow, testOk := test.next()
+		switch {
+		case !oracleOk && !testOk:
+			return compared, nil
+		case oracleOk && testOk && samePrimaryKey(table, oracleRow, testRow):
+			if diff := cmp.Diff(oracleRow, testRow, opts...); diff != "" {
+				if !detailedDiff {
+					return compared, fmt.Errorf("test and oracle store have difference, detailed information will be at last attempt")
+				}
+				return compared, &joberror.RowDiffError{
+					Message: fmt.Sprintf("rows differ (-%v +%v): %v", oracleRow, testRow, diff),
+					Diff:    diffRows(table, oracleRow, testRow, opts),
+				}
+			}
+			compared++
+			continue
+		}
+		oraclePartitions := &partitionRows{iter: oracle, table: table}
+		if oracleOk {
+			oraclePartitions.pending = oracleRow
+		}
+		testPartitions := &partitionRows{iter: test, table: table}
+		if testOk {
+			testPartitions.pending = testRow
+		}
+		n, err := comparePartitions(table, oraclePartitions, testPartitions, read.Ordered, detailedDiff, opts)
+		return compared + n, err
 	}
-	if !ds.validations {
+}
+
+// partitionRows reads the rows of an iterator a partition at a time, the
+// rows of a partition are always returned one after the other.
+type partitionRows struct {
+	iter    rowIterator
+	table   *typedef.Table
+	pending map[string]interface{}
+}
+
+// next returns the rows of the next partition, starting with the pending
+// row, or nil once the iterator is exhausted.
+func (p *partitionRows) next() []map[string]interface{} {
+	if p.pending == nil {
 		return nil
 	}
+	rows := []map[string]interface{}{p.pending}
+	key := partitionKey(p.table, p.pending)
+	for {
+		row, ok := p.iter.next()
+		if !ok {
+			p.pending = nil
+			return rows
+		}
+		if partitionKey(p.table, row) != key {
+			p.pending = row
+			return rows
+		}
+		rows = append(rows, row)
+	}
+}
+
+func partitionKey(table *typedef.Table, row map[string]interface{}) string {
+	return strings.Join(extractRowValues(nil, table.PartitionKeys, row), ", \t")
+}
+
+// comparePartitions compares the rows of the stores a partition at a time and
+// returns the number of rows that were equal on both. The partitions a store
+// returned that the other one did not yet are kept until it does, the ones
+// left once both stores are exhausted are missing in the other store. As
+// long as the stores return the same partitions in the same order, a single
+// partition of each store is kept in memory.
+func comparePartitions(table *typedef.Table, oracle, test *partitionRows, ordered, detailedDiff bool, opts []cmp.Option) (int, error) {
+	var compared int
+	var misordered bool
+	oraclePending := make(map[string][]map[string]interface{})
+	testPending := make(map[string][]map[string]interface{})
+	compare := func(oracleRows, testRows []map[string]interface{}) error {
+		oracleKeys, testKeys := pks(table, oracleRows), pks(table, testRows)
+		// compareRows sorts the rows, the order they were returned in is
+		// taken before.
+		if err := compareRows(table, oracleRows, testRows, detailedDiff, opts); err != nil {
+			return err
+		}
+		misordered = misordered || !reflect.DeepEqual(oracleKeys, testKeys)
+		compared += len(oracleRows)
+		return nil
+	}
+	for {
+		oracleRows, testRows := oracle.next(), test.next()
+		if oracleRows == nil && testRows == nil {
+			break
+		}
+		if oracleRows != nil && testRows != nil && partitionKey(table, oracleRows[0]) == partitionKey(table, testRows[0]) {
+			if err := compare(oracleRows, testRows); err != nil {
+				return compared, err
+			}
+			continue
+		}
+		misordered = true
+		if oracleRows != nil {
+			key := partitionKey(table, oracleRows[0])
+			if rows, ok := testPending[key]; ok {
+				delete(testPending, key)
+				if err := compare(oracleRows, rows); err != nil {
+					return compared, err
+				}
+			} else {
+				oraclePending[key] = oracleRows
+			}
+		}
+		if testRows != nil {
+			key := partitionKey(table, testRows[0])
+			if rows, ok := oraclePending[key]; ok {
+				delete(oraclePending, key)
+				if err := compare(rows, testRows); err != nil {
+					return compared, err
+				}
+			} else {
+				testPending[key] = testRows
+			}
+		}
+	}
+	if err := compareRows(table, flattenPartitions(oraclePending), flattenPartitions(testPending), detailedDiff, opts); err != nil {
+		return compared, err
+	}
+	if ordered && misordered {
+		return compared, errors.Errorf("test and oracle store return the rows in a different order")
+	}
+	return compared, nil
+}
+
+func flattenPartitions(partitions map[string][]map[string]interface{}) []map[string]interface{} {
+	var rows []map[string]interface{}
+	for _, partition := range partitions {
+		rows = append(rows, partition...)
+	}
+	return rows
+}
+
+func samePrimaryKey(table *typedef.Table, oracleRow, testRow map[string]interface{}) bool {
+	for _, cols := range []typedef.Columns{table.PartitionKeys, table.ClusteringKeys} {
+		for _, col := range cols {
+			if !cmp.Equal(oracleRow[col.Name], testRow[col.Name], rowCompareOptions...) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compareRows compares the rows the stores returned for the same query.
+// Unless detailedDiff is set, differences are reported without details.
+func compareRows(table *typedef.Table, oracleRows, testRows []map[string]interface{}, detailedDiff bool, opts []cmp.Option) error {
 	if len(testRows) == 0 && len(oracleRows) == 0 {
 		return nil
 	}
@@ -222,42 +620,149 @@ func (ds delegatingStore) Check(ctx context.Context, table *typedef.Table, build
 		oracleSet := strset.New(pks(table, oracleRows)...)
 		missingInTest := strset.Difference(oracleSet, testSet).List()
 		missingInOracle := strset.Difference(testSet, oracleSet).List()
-		return fmt.Errorf("row count differ (test has %d rows, oracle has %d rows, test is missing rows: %s, oracle is missing rows: %s)",
-			len(testRows), len(oracleRows), missingInTest, missingInOracle)
+		sort.Strings(missingInTest)
+		sort.Strings(missingInOracle)
+		return &joberror.RowDiffError{
+			Message: fmt.Sprintf("row count differ (test has %d rows, oracle has %d rows, test is missing rows: %s, oracle is missing rows: %s)",
+				len(testRows), len(oracleRows), missingInTest, missingInOracle),
+			Diff: &joberror.RowDiff{
+				MissingInTest:   missingInTest,
+				MissingInOracle: missingInOracle,
+			},
+		}
 	}
 	if reflect.DeepEqual(testRows, oracleRows) {
 		return nil
 	}
-	if !detailedDiff {
-		return fmt.Errorf("test and oracle store have difference, detailed information will be at last attempt")
-	}
+	// The rows may only differ in their order, within the TTL tolerance or
+	// by values the options equate, such as NaNs.
 	sort.SliceStable(testRows, func(i, j int) bool {
-		return lt(testRows[i], testRows[j])
+		return compareRowKeys(table, testRows[i], testRows[j]) < 0
 	})
 	sort.SliceStable(oracleRows, func(i, j int) bool {
-		return lt(oracleRows[i], oracleRows[j])
+		return compareRowKeys(table, oracleRows[i], oracleRows[j]) < 0
 	})
 	for i, oracleRow := range oracleRows {
 		testRow := testRows[i]
-		cmp.AllowUnexported()
-		diff := cmp.Diff(oracleRow, testRow,
-			cmpopts.SortMaps(func(x, y *inf.Dec) bool {
-				return x.Cmp(y) < 0
-			}),
-			cmp.Comparer(func(x, y *inf.Dec) bool {
-				return x.Cmp(y) == 0
-			}), cmp.Comparer(func(x, y *big.Int) bool {
-				return x.Cmp(y) == 0
-			}))
-		if diff != "" {
-			return fmt.Errorf("rows differ (-%v +%v): %v", oracleRow, testRow, diff)
+		if cmp.Equal(oracleRow, testRow, opts...) {
+			continue
+		}
+		if !detailedDiff {
+			return fmt.Errorf("test and oracle store have difference, detailed information will be at last attempt")
+		}
+		return &joberror.RowDiffError{
+			Message: fmt.Sprintf("rows differ (-%v +%v): %v", oracleRow, testRow, cmp.Diff(oracleRow, testRow, opts...)),
+			Diff:    diffRows(table, oracleRow, testRow, opts),
 		}
 	}
 	return nil
 }
 
+var rowCompareOptions = []cmp.Option{
+	cmpopts.EquateNaNs(),
+	cmpopts.SortMaps(func(x, y *inf.Dec) bool {
+		return x.Cmp(y) < 0
+	}),
+	cmp.Comparer(func(x, y *inf.Dec) bool {
+		return x.Cmp(y) == 0
+	}),
+	cmp.Comparer(func(x, y *big.Int) bool {
+		return x.Cmp(y) == 0
+	}),
+}
+
+// ttlTolerance is the difference in seconds up to which the TTLs of a cell
+// are equal. TTL() returns the seconds left at the time a cluster reads the
+// cell, the reads of both clusters are not at the same instant and can fall
+// on both sides of a second boundary, on every retry of the check again.
+const ttlTolerance = 1
+
+// compareOptions returns the options the rows of the table read with the
+// options are compared with. An empty non frozen collection is stored as
+// null, the empty and the null values of these columns are equal, but not
+// the ones of frozen collections.
+func compareOptions(table *typedef.Table, read typedef.ReadOptions) []cmp.Option {
+	emptyAsNull := strset.New()
+	for _, col := range table.Columns {
+		switch tt := col.Type.(type) {
+		case *typedef.BagType:
+			if !tt.Frozen {
+				emptyAsNull.Add(col.Name)
+			}
+		case *typedef.MapType:
+			if !tt.Frozen {
+				emptyAsNull.Add(col.Name)
+			}
+		}
+	}
+	ttls := strset.New(read.TTLColumns...)
+	opts := make([]cmp.Option, 0, len(rowCompareOptions)+2)
+	opts = append(opts, rowCompareOptions...)
+	if !emptyAsNull.IsEmpty() {
+		opts = append(opts, cmp.FilterPath(columnIn(emptyAsNull), cmpopts.EquateEmpty()))
+	}
+	if !ttls.IsEmpty() {
+		opts = append(opts, cmp.FilterPath(columnIn(ttls), cmp.Comparer(func(x, y int) bool {
+			return x-y <= ttlTolerance && y-x <= ttlTolerance
+		})))
+	}
+	return opts
+}
+
+// columnIn returns a path filter that matches the values of the columns of a
+// row, but not the elements of these values.
+func columnIn(columns *strset.Set) func(cmp.Path) bool {
+	return func(path cmp.Path) bool {
+		for i, step := range path {
+			index, ok := step.(cmp.MapIndex)
+			if !ok {
+				continue
+			}
+			for _, rest := range path[i+1:] {
+				if _, ok = rest.(cmp.TypeAssertion); !ok {
+					return false
+				}
+			}
+			return index.Key().Kind() == reflect.String && columns.Has(index.Key().String())
+		}
+		return false
+	}
+}
+
+// diffRows lists the columns whose values differ between the rows.
+func diffRows(table *typedef.Table, oracleRow, testRow map[string]interface{}, opts []cmp.Option) *joberror.RowDiff {
+	names := make([]string, 0, len(oracleRow))
+	for name := range oracleRow {
+		names = append(names, name)
+	}
+	for name := range testRow {
+		if _, ok := oracleRow[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	diff := &joberror.RowDiff{
+		PrimaryKey: pks(table, []map[string]interface{}{oracleRow})[0],
+	}
+	for _, name := range names {
+		oracleValue, testValue := oracleRow[name], testRow[name]
+		if cmp.Equal(oracleValue, testValue, opts...) {
+			continue
+		}
+		diff.Columns = append(diff.Columns, joberror.ColumnDiff{
+			Name:   name,
+			Oracle: fmt.Sprintf("%v", oracleValue),
+			Test:   fmt.Sprintf("%v", testValue),
+		})
+	}
+	return diff
+}
+
 func (ds delegatingStore) Close() (err error) {
 	err = multierr.Append(err, ds.testStore.close())
 	err = multierr.Append(err, ds.oracleStore.close())
+	if ds.statementLogger != nil {
+		err = multierr.Append(err, ds.statementLogger.Close())
+	}
 	return
 }
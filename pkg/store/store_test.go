@@ -0,0 +1,550 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/scylladb/gocqlx/v2/qb"
+	"go.uber.org/zap"
+	"gopkg.in/inf.v0"
+
+	"github.com/scylladb/gemini/pkg/joberror"
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+func TestCheckRowDiff(t *testing.T) {
+	t.Parallel()
+	insert := qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0")
+	check := qb.Select("ks.tbl").Where(qb.Eq("pk0"))
+	tests := map[string]struct {
+		oracle   [][]interface{}
+		test     [][]interface{}
+		expected *joberror.RowDiff
+	}{
+		"missing rows": {
+			oracle: [][]interface{}{{1, 1, "a"}, {1, 2, "b"}},
+			test:   [][]interface{}{{1, 1, "a"}},
+			expected: &joberror.RowDiff{
+				MissingInTest: []string{"pk0=1, \tck0=2"},
+			},
+		},
+		"different values": {
+			oracle: [][]interface{}{{1, 1, "a"}, {1, 2, "b"}},
+			test:   [][]interface{}{{1, 1, "a"}, {1, 2, "c"}},
+			expected: &joberror.RowDiff{
+				PrimaryKey: "pk0=1, \tck0=2",
+				Columns:    []joberror.ColumnDiff{{Name: "col0", Oracle: "b", Test: "c"}},
+			},
+		},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			oracleStore, testStore := newTestMemStore(), newTestMemStore()
+			for _, values := range test.oracle {
+				mustMutate(t, oracleStore, insert, values...)
+			}
+			for _, values := range test.test {
+				mustMutate(t, testStore, insert, values...)
+			}
+			ds := delegatingStore{
+				oracleStore: oracleStore,
+				testStore:   testStore,
+				validations: true,
+				logger:      zap.NewNop(),
+			}
+			table := oracleStore.schema.Tables[0]
+			_, err := ds.Check(context.Background(), table, check, typedef.ReadOptions{}, true, 1)
+			var diffErr *joberror.RowDiffError
+			if !errors.As(err, &diffErr) {
+				t.Fatalf("expected a row diff, got %v", err)
+			}
+			if diff := cmp.Diff(test.expected, diffErr.Diff, cmpopts.EquateEmpty()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestCompareIters(t *testing.T) {
+	t.Parallel()
+	table := newTestMemStore().schema.Tables[0]
+	row := func(pk, ck int32, col string) map[string]interface{} {
+		return map[string]interface{}{"pk0": pk, "ck0": ck, "col0": col}
+	}
+	rows := []map[string]interface{}{row(1, 1, "a"), row(1, 2, "b"), row(2, 1, "c"), row(2, 2, "d"), row(3, 1, "e")}
+	tests := map[string]struct {
+		oracle     []map[string]interface{}
+		test       []map[string]interface{}
+		expected   *joberror.RowDiff
+		compared   int
+		pages      int
+		ordered    bool
+		misordered bool
+	}{
+		"same": {
+			oracle:   rows,
+			test:     rows,
+			compared: 5,
+			pages:    3,
+		},
+		"different values": {
+			oracle: rows,
+			test:   []map[string]interface{}{rows[0], row(1, 2, "x"), rows[2], rows[3], rows[4]},
+			expected: &joberror.RowDiff{
+				PrimaryKey: "pk0=1, \tck0=2",
+				Columns:    []joberror.ColumnDiff{{Name: "col0", Oracle: "b", Test: "x"}},
+			},
+			compared: 1,
+			pages:    1,
+		},
+		"null and empty collections": {
+			oracle:   []map[string]interface{}{{"pk0": int32(1), "ck0": int32(1), "col1": []int(nil), "col3": map[int]string{}}},
+			test:     []map[string]interface{}{{"pk0": int32(1), "ck0": int32(1), "col1": []int{}, "col3": map[int]string(nil)}},
+			compared: 1,
+			pages:    1,
+		},
+		"partitions in another order": {
+			oracle:   rows,
+			test:     []map[string]interface{}{rows[2], rows[3], rows[0], rows[1], rows[4]},
+			compared: 5,
+			pages:    3,
+		},
+		"rows in another order": {
+			oracle:     rows,
+			test:       []map[string]interface{}{rows[1], rows[0], rows[2], rows[3], rows[4]},
+			compared:   5,
+			pages:      3,
+			ordered:    true,
+			misordered: true,
+		},
+		"partitions in another order when ordered": {
+			oracle:     rows,
+			test:       []map[string]interface{}{rows[2], rows[3], rows[0], rows[1], rows[4]},
+			compared:   5,
+			pages:      3,
+			ordered:    true,
+			misordered: true,
+		},
+		"missing row": {
+			oracle: rows,
+			test:   []map[string]interface{}{rows[0], rows[1], rows[3], rows[4]},
+			expected: &joberror.RowDiff{
+				MissingInTest: []string{"pk0=2, \tck0=1"},
+			},
+			compared: 2,
+			pages:    3,
+		},
+		"missing partition": {
+			oracle: rows,
+			test:   []map[string]interface{}{rows[0], rows[1], rows[4]},
+			expected: &joberror.RowDiff{
+				MissingInTest: []string{"pk0=2, \tck0=1", "pk0=2, \tck0=2"},
+			},
+			compared: 3,
+			pages:    3,
+		},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			oracle := &sliceRowIterator{rows: test.oracle, pageSize: 2}
+			compared, err := compareIters(table, oracle, &sliceRowIterator{rows: test.test, pageSize: 2}, typedef.ReadOptions{Ordered: test.ordered}, true)
+			var received *joberror.RowDiff
+			var diffErr *joberror.RowDiffError
+			if errors.As(err, &diffErr) {
+				received = diffErr.Diff
+			} else if (err != nil) != test.misordered {
+				t.Fatalf("expected misordered %t, got %v", test.misordered, err)
+			}
+			if diff := cmp.Diff(test.expected, received, cmpopts.EquateEmpty()); diff != "" {
+				t.Error(diff)
+			}
+			if compared != test.compared {
+				t.Errorf("expected %d compared rows, got %d", test.compared, compared)
+			}
+			if oracle.pages() != test.pages {
+				t.Errorf("expected %d oracle pages to be read, got %d", test.pages, oracle.pages())
+			}
+		})
+	}
+}
+
+func TestCompareEmptyCollections(t *testing.T) {
+	t.Parallel()
+	table := &typedef.Table{
+		Name:          "tbl",
+		PartitionKeys: typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+		Columns: typedef.Columns{
+			{Name: "col0", Type: &typedef.BagType{ComplexType: typedef.TYPE_LIST, ValueType: typedef.TYPE_INT}},
+			{Name: "col1", Type: &typedef.BagType{ComplexType: typedef.TYPE_LIST, ValueType: typedef.TYPE_INT, Frozen: true}},
+		},
+	}
+	tests := map[string]struct {
+		oracle, test map[string]interface{}
+		expected     *joberror.RowDiff
+	}{
+		"non frozen": {
+			oracle: map[string]interface{}{"pk0": int32(1), "col0": []int(nil), "col1": []int{1}},
+			test:   map[string]interface{}{"pk0": int32(1), "col0": []int{}, "col1": []int{1}},
+		},
+		"frozen": {
+			oracle: map[string]interface{}{"pk0": int32(1), "col0": []int(nil), "col1": []int{}},
+			test:   map[string]interface{}{"pk0": int32(1), "col0": []int(nil), "col1": []int(nil)},
+			expected: &joberror.RowDiff{
+				PrimaryKey: "pk0=1",
+				Columns:    []joberror.ColumnDiff{{Name: "col1", Oracle: "[]", Test: "[]"}},
+			},
+		},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			oracle := &sliceRowIterator{rows: []map[string]interface{}{test.oracle}}
+			_, err := compareIters(table, oracle, &sliceRowIterator{rows: []map[string]interface{}{test.test}}, typedef.ReadOptions{}, true)
+			var received *joberror.RowDiff
+			var diffErr *joberror.RowDiffError
+			if errors.As(err, &diffErr) {
+				received = diffErr.Diff
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.expected, received); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestCompareTTLs(t *testing.T) {
+	t.Parallel()
+	table := &typedef.Table{
+		Name:          "tbl",
+		PartitionKeys: typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+	}
+	read := typedef.ReadOptions{Ordered: true, TTLColumns: []string{"ttl_col0"}}
+	row := func(ttl interface{}) map[string]interface{} {
+		return map[string]interface{}{"pk0": int32(1), "ttl_col0": ttl, "col1": 100}
+	}
+	tests := map[string]struct {
+		oracle, test interface{}
+		equal        bool
+	}{
+		"same":                  {oracle: 100, test: 100, equal: true},
+		"a second apart":        {oracle: 100, test: 99, equal: true},
+		"two seconds apart":     {oracle: 100, test: 98},
+		"no ttl in one cluster": {oracle: 100, test: nil},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			oracle := &sliceRowIterator{rows: []map[string]interface{}{row(test.oracle)}}
+			testIter := &sliceRowIterator{rows: []map[string]interface{}{row(test.test)}}
+			_, err := compareIters(table, oracle, testIter, read, true)
+			if (err == nil) != test.equal {
+				t.Errorf("expected equal %t, got %v", test.equal, err)
+			}
+		})
+	}
+
+	// Only the TTL columns are compared with a tolerance.
+	oracle := &sliceRowIterator{rows: []map[string]interface{}{row(100)}}
+	testRow := row(100)
+	testRow["col1"] = 99
+	if _, err := compareIters(table, oracle, &sliceRowIterator{rows: []map[string]interface{}{testRow}}, read, true); err == nil {
+		t.Error("expected the regular columns to be compared exactly")
+	}
+}
+
+func TestCompareRowsWithoutDetails(t *testing.T) {
+	t.Parallel()
+	table := &typedef.Table{
+		Name:           "tbl",
+		PartitionKeys:  typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+		ClusteringKeys: typedef.Columns{{Name: "ck0", Type: typedef.TYPE_INT}},
+	}
+	opts := compareOptions(table, typedef.ReadOptions{TTLColumns: []string{"ttl_col0"}})
+	row := func(ck int32, ttl int, col1 float64) map[string]interface{} {
+		return map[string]interface{}{"pk0": int32(1), "ck0": ck, "ttl_col0": ttl, "col1": col1}
+	}
+	tests := map[string]struct {
+		oracle, test []map[string]interface{}
+		equal        bool
+	}{
+		"other order":       {oracle: []map[string]interface{}{row(1, 10, 1), row(2, 10, 2)}, test: []map[string]interface{}{row(2, 10, 2), row(1, 10, 1)}, equal: true},
+		"ttl a second off":  {oracle: []map[string]interface{}{row(1, 10, 1)}, test: []map[string]interface{}{row(1, 9, 1)}, equal: true},
+		"nan":               {oracle: []map[string]interface{}{row(1, 10, math.NaN())}, test: []map[string]interface{}{row(1, 10, math.NaN())}, equal: true},
+		"ttl seconds off":   {oracle: []map[string]interface{}{row(1, 10, 1)}, test: []map[string]interface{}{row(1, 7, 1)}},
+		"different columns": {oracle: []map[string]interface{}{row(1, 10, 1), row(2, 10, 2)}, test: []map[string]interface{}{row(2, 10, 1), row(1, 10, 1)}},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := compareRows(table, test.oracle, test.test, false, opts)
+			if (err == nil) != test.equal {
+				t.Errorf("expected equal %t, got %v", test.equal, err)
+			}
+		})
+	}
+}
+
+// buildingStore is a memory store that reports views and indexes built only
+// after it was asked for the given number of times.
+type buildingStore struct {
+	*memStore
+	polls atomic.Int32
+	after int32
+}
+
+func (bs *buildingStore) built(context.Context, string, string, bool) (bool, error) {
+	return bs.polls.Add(1) > bs.after, nil
+}
+
+func TestAwaitBuilt(t *testing.T) {
+	t.Parallel()
+	oracleStore := &buildingStore{memStore: newTestMemStore()}
+	testStore := &buildingStore{memStore: newTestMemStore(), after: 1}
+	ds := delegatingStore{oracleStore: oracleStore, testStore: testStore, logger: zap.NewNop()}
+	if err := ds.AwaitBuilt(context.Background(), "ks1", "table1_mv_0", false); err != nil {
+		t.Fatal(err)
+	}
+	if oracleStore.polls.Load() != 1 || testStore.polls.Load() != 2 {
+		t.Errorf("expected the stores to be polled until they are built, got %d and %d polls", oracleStore.polls.Load(), testStore.polls.Load())
+	}
+
+	never := &buildingStore{memStore: newTestMemStore(), after: math.MaxInt32}
+	ds.testStore = never
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ds.AwaitBuilt(ctx, "ks1", "table1_mv_0", false); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the wait to end with the context, got %v", err)
+	}
+}
+
+func TestCompareRowKeys(t *testing.T) {
+	t.Parallel()
+	earlier := time.Date(1969, 7, 20, 20, 17, 0, 0, time.UTC)
+	later := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		typ        typedef.SimpleType
+		less, more interface{}
+		descending bool
+	}{
+		"bigint":     {typ: typedef.TYPE_BIGINT, less: int64(-1), more: int64(1)},
+		"float":      {typ: typedef.TYPE_FLOAT, less: float32(-1.5), more: float32(0.5)},
+		"double":     {typ: typedef.TYPE_DOUBLE, less: -2.0, more: -1.0},
+		"decimal":    {typ: typedef.TYPE_DECIMAL, less: inf.NewDec(15, 1), more: inf.NewDec(2, 0)},
+		"varint":     {typ: typedef.TYPE_VARINT, less: big.NewInt(-5), more: big.NewInt(3)},
+		"inet":       {typ: typedef.TYPE_INET, less: net.ParseIP("10.0.0.2"), more: net.ParseIP("10.0.0.10")},
+		"boolean":    {typ: typedef.TYPE_BOOLEAN, less: false, more: true},
+		"date":       {typ: typedef.TYPE_DATE, less: earlier, more: later},
+		"timestamp":  {typ: typedef.TYPE_TIMESTAMP, less: earlier, more: later},
+		"timeuuid":   {typ: typedef.TYPE_TIMEUUID, less: gocql.UUIDFromTime(earlier), more: gocql.UUIDFromTime(later)},
+		"text":       {typ: typedef.TYPE_TEXT, less: "", more: "a"},
+		"unselected": {typ: typedef.TYPE_INT, less: nil, more: 1},
+		"descending": {typ: typedef.TYPE_INT, less: 2, more: 1, descending: true},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			table := &typedef.Table{
+				Name:           "tbl",
+				PartitionKeys:  typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+				ClusteringKeys: typedef.Columns{{Name: "ck0", Type: test.typ, Descending: test.descending}},
+			}
+			less := map[string]interface{}{"pk0": 1, "ck0": test.less}
+			more := map[string]interface{}{"pk0": 1, "ck0": test.more}
+			if c := compareRowKeys(table, less, more); c != -1 {
+				t.Errorf("expected %v to sort before %v, got %d", test.less, test.more, c)
+			}
+			if c := compareRowKeys(table, more, less); c != 1 {
+				t.Errorf("expected %v to sort after %v, got %d", test.more, test.less, c)
+			}
+			if c := compareRowKeys(table, less, less); c != 0 {
+				t.Errorf("expected %v to be equal to itself, got %d", test.less, c)
+			}
+			// The partition key is compared first.
+			first := map[string]interface{}{"pk0": 0, "ck0": test.more}
+			if c := compareRowKeys(table, first, less); c != -1 {
+				t.Errorf("expected the partition key to be compared first, got %d", c)
+			}
+		})
+	}
+}
+
+func TestCheckScan(t *testing.T) {
+	t.Parallel()
+	insert := qb.Insert("ks.tbl").Columns("pk0", "ck0", "col0")
+	scan := rawBuilder("SELECT token(pk0) AS scan_token,pk0,ck0,col0 FROM ks.tbl WHERE token(pk0)>? AND token(pk0)<=?")
+	rows := [][]interface{}{{1, 1, "a"}, {1, 2, "b"}, {2, 1, "c"}, {3, 1, "d"}}
+	tests := map[string]struct {
+		oracle   [][]interface{}
+		test     [][]interface{}
+		expected *joberror.RowDiff
+		compared int
+	}{
+		"same": {
+			oracle:   rows,
+			test:     rows,
+			compared: 4,
+		},
+		"missing partition": {
+			oracle: rows,
+			test:   rows[:3],
+			expected: &joberror.RowDiff{
+				MissingInTest: []string{"pk0=3, \tck0=1"},
+			},
+		},
+		"extra partition": {
+			oracle: [][]interface{}{rows[0], rows[1], rows[3]},
+			test:   rows,
+			expected: &joberror.RowDiff{
+				MissingInOracle: []string{"pk0=2, \tck0=1"},
+			},
+		},
+		"extra row": {
+			oracle: rows[1:],
+			test:   rows,
+			expected: &joberror.RowDiff{
+				MissingInOracle: []string{"pk0=1, \tck0=1"},
+			},
+		},
+		"different values": {
+			oracle: rows,
+			test:   [][]interface{}{rows[0], rows[1], rows[2], {3, 1, "e"}},
+			expected: &joberror.RowDiff{
+				PrimaryKey: "pk0=3, \tck0=1",
+				Columns:    []joberror.ColumnDiff{{Name: "col0", Oracle: "d", Test: "e"}},
+			},
+		},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			oracleStore, testStore := newTestMemStore(), newTestMemStore()
+			for _, values := range test.oracle {
+				mustMutate(t, oracleStore, insert, values...)
+			}
+			for _, values := range test.test {
+				mustMutate(t, testStore, insert, values...)
+			}
+			ds := delegatingStore{
+				oracleStore:  oracleStore,
+				testStore:    testStore,
+				validations:  true,
+				scanPageSize: 2,
+				logger:       zap.NewNop(),
+			}
+			table := oracleStore.schema.Tables[0]
+			stats, err := ds.CheckScan(context.Background(), table, scan, int64(math.MinInt64), int64(math.MaxInt64))
+			var received *joberror.RowDiff
+			var diffErr *joberror.RowDiffError
+			if errors.As(err, &diffErr) {
+				received = diffErr.Diff
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.expected, received, cmpopts.EquateEmpty()); diff != "" {
+				t.Error(diff)
+			}
+			if test.expected == nil && stats.Rows != test.compared {
+				t.Errorf("expected %d compared rows, got %d", test.compared, stats.Rows)
+			}
+		})
+	}
+}
+
+func TestCompareLWT(t *testing.T) {
+	t.Parallel()
+	table := &typedef.Table{
+		Name:          "tbl",
+		PartitionKeys: typedef.Columns{{Name: "pk0", Type: typedef.TYPE_INT}},
+	}
+	tests := map[string]struct {
+		oracle     *lwtResult
+		test       *lwtResult
+		expected   *joberror.RowDiff
+		conditions []string
+	}{
+		"same": {
+			oracle: &lwtResult{applied: false, previous: map[string]interface{}{"pk0": 1, "col0": "a"}},
+			test:   &lwtResult{applied: false, previous: map[string]interface{}{"pk0": 1, "col0": "a"}},
+		},
+		"previous values not reported": {
+			oracle: &lwtResult{applied: true},
+			test:   &lwtResult{applied: true, previous: map[string]interface{}{"pk0": 1, "col0": "a"}},
+		},
+		"applied": {
+			oracle: &lwtResult{applied: true},
+			test:   &lwtResult{applied: false, previous: map[string]interface{}{"pk0": 1, "col0": "a"}},
+			expected: &joberror.RowDiff{
+				Columns: []joberror.ColumnDiff{{Name: "[applied]", Oracle: "true", Test: "false"}},
+			},
+		},
+		"previous values": {
+			oracle:     &lwtResult{applied: false, previous: map[string]interface{}{"pk0": 1, "col0": "a"}},
+			test:       &lwtResult{applied: false, previous: map[string]interface{}{"pk0": 1, "col0": "b"}},
+			conditions: []string{"col0"},
+			expected: &joberror.RowDiff{
+				PrimaryKey: "pk0=1",
+				Columns:    []joberror.ColumnDiff{{Name: "col0", Oracle: "a", Test: "b"}},
+			},
+		},
+		"values of columns not in the condition": {
+			oracle:     &lwtResult{applied: false, previous: map[string]interface{}{"pk0": 1, "col0": "a", "col1": "a"}},
+			test:       &lwtResult{applied: false, previous: map[string]interface{}{"pk0": 1, "col0": "a", "col1": "b"}},
+			conditions: []string{"col0"},
+		},
+		"existence condition": {
+			oracle: &lwtResult{applied: false, previous: map[string]interface{}{"pk0": 1, "col0": "a"}},
+			test:   &lwtResult{applied: false, previous: map[string]interface{}{"pk0": 1, "col0": "b"}},
+		},
+	}
+	for name := range tests {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := compareLWT(table, test.conditions, test.oracle, test.test)
+			var received *joberror.RowDiff
+			var diffErr *joberror.RowDiffError
+			if errors.As(err, &diffErr) {
+				received = diffErr.Diff
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.expected, received); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -23,6 +23,9 @@ import (
 )
 
 type Option interface {
+	// Key is the name of the option, an option replaces the option of
+	// the same name when a table is altered.
+	Key() string
 	ToCQL() string
 }
 type SimpleOption struct {
@@ -30,6 +33,10 @@ type SimpleOption struct {
 	val string
 }
 
+func (o *SimpleOption) Key() string {
+	return o.key
+}
+
 func (o *SimpleOption) ToCQL() string {
 	return o.key + " = " + o.val
 }
@@ -39,6 +46,10 @@ type MapOption struct {
 	key string
 }
 
+func (o *MapOption) Key() string {
+	return o.key
+}
+
 func (o *MapOption) ToCQL() string {
 	b, _ := json.Marshal(o.val)
 	return o.key + " = " + strings.ReplaceAll(string(b), "\"", "'")
@@ -67,6 +67,7 @@ var (
 		"col1":   {typedef.TYPE_DATE},
 		"col5":   {typedef.TYPE_ASCII, typedef.TYPE_DATE, typedef.TYPE_BLOB, typedef.TYPE_BIGINT, typedef.TYPE_FLOAT},
 		"col5c":  {typedef.TYPE_ASCII, &mapType, typedef.TYPE_BLOB, &tupleType, typedef.TYPE_FLOAT},
+		"col4nf": {&listType, &setType, &nonFrozenMapType, &udtType},
 		"col1cr": {&counterType},
 		"col3cr": {&counterType, &counterType, &counterType},
 		"colAll": {
@@ -77,9 +78,17 @@ var (
 		},
 	}
 
-	counterType typedef.CounterType
-	tupleType   typedef.TupleType
-	mapType     typedef.MapType
+	counterType      typedef.CounterType
+	tupleType        typedef.TupleType
+	mapType          typedef.MapType
+	listType         = typedef.BagType{ComplexType: typedef.TYPE_LIST, ValueType: typedef.TYPE_INT}
+	setType          = typedef.BagType{ComplexType: typedef.TYPE_SET, ValueType: typedef.TYPE_TEXT}
+	nonFrozenMapType = typedef.MapType{ComplexType: typedef.TYPE_MAP, KeyType: typedef.TYPE_INT, ValueType: typedef.TYPE_TEXT}
+	udtType          = typedef.UDTType{
+		ComplexType: typedef.TYPE_UDT,
+		TypeName:    "udt_1",
+		ValueTypes:  map[string]typedef.Type{"udt_1_0": typedef.TYPE_INT, "udt_1_1": typedef.TYPE_TEXT},
+	}
 
 	UpdateExpectedFlag = flag.Bool("update-expected", false, "make test to update expected results")
 )
@@ -202,6 +211,8 @@ func GetTableFromName(t testInterface, testCaseName string) *typedef.Table {
 			table.Indexes = createIndexForColumns(t, table.Columns[0])
 		case chunk == "idxAll":
 			table.Indexes = createIndexForColumns(t, table.Columns...)
+		case chunk == "idxMix":
+			table.Indexes = createMixedIndexes(t, &table)
 		case chunk == "mv":
 			table.MaterializedViews = append(table.MaterializedViews, *createMv(t, &table, false))
 		case chunk == "mvNp":
@@ -272,6 +283,29 @@ func createIndexForColumns(t testInterface, columns ...*typedef.ColumnDef) (inde
 	return indexes
 }
 
+// createMixedIndexes indexes the values of lists and sets, the entries of
+// maps, the simple columns with local indexes and the first clustering key.
+func createMixedIndexes(t testInterface, table *typedef.Table) (indexes []typedef.IndexDef) {
+	for _, col := range table.Columns {
+		index := createIndexForColumns(t, col)[0]
+		switch col.Type.(type) {
+		case *typedef.BagType:
+			index.Target = typedef.IndexTargetValues
+		case *typedef.MapType:
+			index.Target = typedef.IndexTargetEntries
+		case typedef.SimpleType:
+			index.Local = true
+		default:
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	if len(table.ClusteringKeys) > 0 {
+		indexes = append(indexes, createIndexForColumns(t, table.ClusteringKeys[0])...)
+	}
+	return indexes
+}
+
 func genColumnsFromCase(t testInterface, typeCases map[string][]typedef.Type, caseName, prefix string) typedef.Columns {
 	typeCase, ok := typeCases[caseName]
 	if !ok {
@@ -27,9 +27,9 @@ import (
 )
 
 type BagType struct {
-	ComplexType string     `json:"complex_type"` // We need to differentiate between sets and lists
-	ValueType   SimpleType `json:"value_type"`
-	Frozen      bool       `json:"frozen"`
+	ComplexType string `json:"complex_type"` // We need to differentiate between sets and lists
+	ValueType   Type   `json:"value_type"`
+	Frozen      bool   `json:"frozen"`
 }
 
 func (ct *BagType) CQLType() gocql.TypeInfo {
@@ -50,9 +50,9 @@ func (ct *BagType) Name() string {
 
 func (ct *BagType) CQLDef() string {
 	if ct.Frozen {
-		return "frozen<" + ct.ComplexType + "<" + ct.ValueType.Name() + ">>"
+		return "frozen<" + ct.ComplexType + "<" + ct.ValueType.CQLDef() + ">>"
 	}
-	return ct.ComplexType + "<" + ct.ValueType.Name() + ">"
+	return ct.ComplexType + "<" + ct.ValueType.CQLDef() + ">"
 }
 
 func (ct *BagType) CQLHolder() string {
@@ -76,7 +76,7 @@ func (ct *BagType) CQLPretty(query string, value []interface{}) (string, int) {
 	vv = strings.TrimRight(vv, ",")
 	vv += cl
 	for i := 0; i < s.Len(); i++ {
-		vv, _ = ct.ValueType.CQLPretty(vv, []interface{}{s.Index(i).Interface()})
+		vv = prettyElement(ct.ValueType, vv, s.Index(i).Interface())
 	}
 	return strings.Replace(query, "?", vv, 1), 1
 }
@@ -85,7 +85,7 @@ func (ct *BagType) GenValue(r *rand.Rand, p *PartitionRangeConfig) []interface{}
 	count := utils.RandInt2(r, 1, maxBagSize+1)
 	out := make([]interface{}, count)
 	for i := 0; i < count; i++ {
-		out[i] = ct.ValueType.GenValue(r, p)[0]
+		out[i] = genElementValue(ct.ValueType, r, p)
 	}
 	return []interface{}{out}
 }
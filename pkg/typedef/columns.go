@@ -25,10 +25,26 @@ import (
 type ColumnDef struct {
 	Type Type   `json:"type"`
 	Name string `json:"name"`
+	// Descending reverses the clustering order of a clustering key.
+	Descending bool `json:"descending,omitempty"`
+	// Static makes a regular column a static column, one value shared by
+	// all the rows of a partition.
+	Static bool `json:"static,omitempty"`
 }
 
 var ErrSchemaValidation = errors.New("validation failed")
 
+// GenValue generates a value of the regular column, it is null with the
+// probability the value distribution of its type sets.
+func (cd *ColumnDef) GenValue(r *rand.Rand, p *PartitionRangeConfig) []interface{} {
+	if st, ok := cd.Type.(SimpleType); ok {
+		if d := p.ValueDistributions.For(st); d != nil && d.Null > 0 && r.Float64() < d.Null {
+			return []interface{}{nil}
+		}
+	}
+	return cd.Type.GenValue(r, p)
+}
+
 func (cd *ColumnDef) IsValidForPrimaryKey() bool {
 	for _, pkType := range PkTypes {
 		if cd.Type.Name() == pkType.Name() {
@@ -66,6 +82,11 @@ func (cd *ColumnDef) UnmarshalJSON(data []byte) error {
 			t, err = GetTupleTypeColumn(dataMap)
 		case TYPE_UDT:
 			t, err = GetUDTTypeColumn(dataMap)
+		case TYPE_VECTOR:
+			t, err = GetVectorTypeColumn(dataMap)
+		case TYPE_COUNTER:
+			name, _ := dataMap["name"].(string)
+			t, err = &ColumnDef{Name: name, Type: &CounterType{}}, nil
 		default:
 			return errors.Wrapf(ErrSchemaValidation, "unknown 'complex_type': [%T]%+[1]v", complexType)
 		}
@@ -73,9 +94,13 @@ func (cd *ColumnDef) UnmarshalJSON(data []byte) error {
 			return err
 		}
 	}
+	descending, _ := dataMap["descending"].(bool)
+	static, _ := dataMap["static"].(bool)
 	*cd = ColumnDef{
-		Name: t.Name,
-		Type: t.Type,
+		Name:       t.Name,
+		Type:       t.Type,
+		Descending: descending,
+		Static:     static,
 	}
 	return nil
 }
@@ -181,9 +206,9 @@ func GetMapTypeColumn(data map[string]interface{}) (out *ColumnDef, err error) {
 	if err = mapstructure.Decode(st.Type["frozen"], &frozen); err != nil {
 		return nil, errors.Wrapf(err, "can't decode bool value for MapType::Frozen, value=%v", st)
 	}
-	var valueType SimpleType
-	if err = mapstructure.Decode(st.Type["value_type"], &valueType); err != nil {
-		return nil, errors.Wrapf(err, "can't decode SimpleType value for MapType::ValueType, value=%v", st)
+	valueType, err := getElementType(st.Type["value_type"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't decode Type value for MapType::ValueType, value=%v", st)
 	}
 	var keyType SimpleType
 	if err = mapstructure.Decode(st.Type["key_type"], &keyType); err != nil {
@@ -217,9 +242,9 @@ func GetBagTypeColumn(data map[string]interface{}) (out *ColumnDef, err error) {
 	if err = mapstructure.Decode(st.Type["frozen"], &frozen); err != nil {
 		return nil, errors.Wrapf(err, "can't decode bool value for BagType::Frozen, value=%v", st)
 	}
-	var typ SimpleType
-	if err = mapstructure.Decode(st.Type["value_type"], &typ); err != nil {
-		return nil, errors.Wrapf(err, "can't decode SimpleType value for BagType::ValueType, value=%v", st)
+	typ, err := getElementType(st.Type["value_type"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't decode Type value for BagType::ValueType, value=%v", st)
 	}
 	return &ColumnDef{
 		Name: st.Name,
@@ -245,9 +270,15 @@ func GetTupleTypeColumn(data map[string]interface{}) (out *ColumnDef, err error)
 		return nil, errors.Errorf("not a tuple type, value=%v", st)
 	}
 
-	var dbTypes []SimpleType
-	if err = mapstructure.Decode(st.Type["value_types"], &dbTypes); err != nil {
-		return nil, errors.Wrapf(err, "can't decode []SimpleType value for TupleType::ValueTypes, value=%v", st)
+	var elements []interface{}
+	if err = mapstructure.Decode(st.Type["value_types"], &elements); err != nil {
+		return nil, errors.Wrapf(err, "can't decode []Type value for TupleType::ValueTypes, value=%v", st)
+	}
+	dbTypes := make([]Type, len(elements))
+	for i := range elements {
+		if dbTypes[i], err = getElementType(elements[i]); err != nil {
+			return nil, errors.Wrapf(err, "can't decode Type value for TupleType::ValueTypes, value=%v", st)
+		}
 	}
 	var frozen bool
 	if err = mapstructure.Decode(st.Type["frozen"], &frozen); err != nil {
@@ -280,9 +311,15 @@ func GetUDTTypeColumn(data map[string]interface{}) (out *ColumnDef, err error) {
 		return nil, errors.Errorf("not a UDT type, value=%v", st)
 	}
 
-	var dbTypes map[string]SimpleType
-	if err = mapstructure.Decode(st.Type["value_types"], &dbTypes); err != nil {
-		return nil, errors.Wrapf(err, "can't decode []SimpleType value for UDTType::ValueTypes, value=%v", st)
+	var fields map[string]interface{}
+	if err = mapstructure.Decode(st.Type["value_types"], &fields); err != nil {
+		return nil, errors.Wrapf(err, "can't decode map[string]Type value for UDTType::ValueTypes, value=%v", st)
+	}
+	dbTypes := make(map[string]Type, len(fields))
+	for name := range fields {
+		if dbTypes[name], err = getElementType(fields[name]); err != nil {
+			return nil, errors.Wrapf(err, "can't decode Type value for UDTType::ValueTypes, value=%v", st)
+		}
 	}
 	var frozen bool
 	if err = mapstructure.Decode(st.Type["frozen"], &frozen); err != nil {
@@ -303,6 +340,64 @@ func GetUDTTypeColumn(data map[string]interface{}) (out *ColumnDef, err error) {
 	}, nil
 }
 
+func GetVectorTypeColumn(data map[string]interface{}) (out *ColumnDef, err error) {
+	st := struct {
+		Type map[string]interface{}
+		Name string
+	}{}
+
+	if err = mapstructure.Decode(data, &st); err != nil {
+		return nil, errors.Wrapf(err, "can't decode VectorType value, value=%+v", data)
+	}
+	var dimensions float64
+	if err = mapstructure.Decode(st.Type["dimensions"], &dimensions); err != nil || dimensions < 1 {
+		return nil, errors.Errorf("not a vector type, value=%v", st)
+	}
+	return &ColumnDef{
+		Name: st.Name,
+		Type: &VectorType{
+			ComplexType: TYPE_VECTOR,
+			Dimensions:  int(dimensions),
+		},
+	}, nil
+}
+
+// getElementType decodes the type of an element of a complex type, either a
+// simple type or a nested complex type.
+func getElementType(data interface{}) (Type, error) {
+	switch v := data.(type) {
+	case string:
+		if !AllTypes.Contains(SimpleType(v)) {
+			return nil, errors.Wrapf(ErrSchemaValidation, "unknown simple type %q", v)
+		}
+		return SimpleType(v), nil
+	case map[string]interface{}:
+		column := map[string]interface{}{"type": v}
+		var (
+			out *ColumnDef
+			err error
+		)
+		switch v["complex_type"] {
+		case TYPE_LIST, TYPE_SET:
+			out, err = GetBagTypeColumn(column)
+		case TYPE_MAP:
+			out, err = GetMapTypeColumn(column)
+		case TYPE_TUPLE:
+			out, err = GetTupleTypeColumn(column)
+		case TYPE_UDT:
+			out, err = GetUDTTypeColumn(column)
+		default:
+			return nil, errors.Wrapf(ErrSchemaValidation, "unknown 'complex_type': [%T]%+[1]v", v["complex_type"])
+		}
+		if err != nil {
+			return nil, err
+		}
+		return out.Type, nil
+	default:
+		return nil, errors.Wrapf(ErrSchemaValidation, "unknown element type: [%T]%+[1]v", data)
+	}
+}
+
 func GetSimpleTypeColumn(data map[string]interface{}) (*ColumnDef, error) {
 	st := struct {
 		Name string
@@ -64,12 +64,21 @@ func TestColumnMarshalUnmarshal(t *testing.T) {
 			Type: simpleType,
 		}, expected: fmt.Sprintf("{\"type\":\"%s\",\"name\":\"%s\"}", simpleType.Name(), simpleType.Name())})
 	}
-	udtTypes := map[string]typedef.SimpleType{}
+	udtTypes := map[string]typedef.Type{}
 
 	for _, simpleType := range allSimpleTypes {
 		udtTypes["col_"+simpleType.Name()] = simpleType
 	}
 
+	testCases = append(testCases, testCase{
+		def: typedef.ColumnDef{
+			Name:       "descending",
+			Type:       typedef.TYPE_INT,
+			Descending: true,
+		},
+		expected: "{\"type\":\"int\",\"name\":\"descending\",\"descending\":true}",
+	})
+
 	testCases = append(testCases, testCase{
 		def: typedef.ColumnDef{
 			Type: &typedef.UDTType{
@@ -83,6 +92,47 @@ func TestColumnMarshalUnmarshal(t *testing.T) {
 		expected: "{\"type\":{\"complex_type\":\"udt\",\"value_types\":{\"col_ascii\":\"ascii\",\"col_bigint\":\"bigint\",\"col_blob\":\"blob\",\"col_boolean\":\"boolean\",\"col_date\":\"date\",\"col_decimal\":\"decimal\",\"col_double\":\"double\",\"col_duration\":\"duration\",\"col_float\":\"float\",\"col_inet\":\"inet\",\"col_int\":\"int\",\"col_smallint\":\"smallint\",\"col_text\":\"text\",\"col_time\":\"time\",\"col_timestamp\":\"timestamp\",\"col_timeuuid\":\"timeuuid\",\"col_tinyint\":\"tinyint\",\"col_uuid\":\"uuid\",\"col_varchar\":\"varchar\",\"col_varint\":\"varint\"},\"type_name\":\"udt1\",\"frozen\":false},\"name\":\"udt1\"}",
 	})
 
+	testCases = append(testCases, testCase{
+		def: typedef.ColumnDef{
+			Type: &typedef.TupleType{
+				ComplexType: typedef.TYPE_TUPLE,
+				ValueTypes: []typedef.Type{
+					typedef.TYPE_INT,
+					&typedef.BagType{
+						ComplexType: typedef.TYPE_LIST,
+						ValueType: &typedef.UDTType{
+							ComplexType: typedef.TYPE_UDT,
+							TypeName:    "udt2",
+							ValueTypes:  map[string]typedef.Type{"a": typedef.TYPE_INT},
+							Frozen:      true,
+						},
+						Frozen: true,
+					},
+				},
+			},
+			Name: "nested",
+		},
+		//nolint:lll
+		expected: "{\"type\":{\"complex_type\":\"tuple\",\"value_types\":[\"int\",{\"complex_type\":\"list\",\"value_type\":{\"complex_type\":\"udt\",\"value_types\":{\"a\":\"int\"},\"type_name\":\"udt2\",\"frozen\":true},\"frozen\":true}],\"frozen\":false},\"name\":\"nested\"}",
+	})
+
+	testCases = append(testCases, testCase{
+		def: typedef.ColumnDef{
+			Type: &typedef.VectorType{ComplexType: typedef.TYPE_VECTOR, Dimensions: 3},
+			Name: "vector",
+		},
+		expected: "{\"type\":{\"complex_type\":\"vector\",\"dimensions\":3},\"name\":\"vector\"}",
+	})
+
+	testCases = append(testCases, testCase{
+		def: typedef.ColumnDef{
+			Type:   &typedef.CounterType{},
+			Name:   "counter",
+			Static: true,
+		},
+		expected: "{\"type\":{\"complex_type\":\"counter\"},\"name\":\"counter\",\"static\":true}",
+	})
+
 	for id := range testCases {
 		tcase := testCases[id]
 		t.Run(tcase.def.Name, func(t *testing.T) {
@@ -117,7 +167,7 @@ func TestMarshalUnmarshal(t *testing.T) {
 
 	opts := cmp.Options{
 		cmp.AllowUnexported(typedef.Table{}, typedef.MaterializedView{}),
-		cmpopts.IgnoreUnexported(typedef.Table{}, typedef.MaterializedView{}),
+		cmpopts.IgnoreUnexported(typedef.Schema{}, typedef.Table{}, typedef.MaterializedView{}),
 	}
 
 	b, err := json.MarshalIndent(s1, "  ", "  ")
@@ -36,6 +36,35 @@ const (
 	AlterColumnStatementType
 	DropColumnStatementType
 	AddColumnStatementType
+	InsertIfNotExistsStatementType
+	UpdateIfStatementType
+	DeleteIfStatementType
+	BatchIfStatementType
+	BatchStatementType
+	UnloggedBatchStatementType
+	CounterBatchStatementType
+	SelectWriteTimeStatementType
+	PartialUpdateStatementType
+	CollectionUpdateStatementType
+	UDTFieldUpdateStatementType
+	DeletePartitionStatementType
+	DeleteRowStatementType
+	DeleteColumnsStatementType
+	DeleteElementStatementType
+	DeleteRangeStatementType
+	ScanStatementType
+	SelectAggregateStatementType
+	SelectFunctionStatementType
+	SelectJSONStatementType
+	SelectFilteringStatementType
+	CreateIndexStatementType
+	DropIndexStatementType
+	CreateMaterializedViewStatementType
+	DropMaterializedViewStatementType
+	AlterTableOptionsStatementType
+	AlterTypeStatementType
+	TruncateStatementType
+	CreateTableStatementType
 )
 
 //nolint:revive
@@ -45,6 +74,15 @@ const (
 	CQL_FEATURE_ALL
 )
 
+const (
+	IndexTargetKeys    IndexTarget = "keys"
+	IndexTargetValues  IndexTarget = "values"
+	IndexTargetEntries IndexTarget = "entries"
+)
+
+// MaxKnownRows is the number of rows that are remembered per partition key.
+const MaxKnownRows = 32
+
 const (
 	KnownIssuesJSONWithTuples = "https://github.com/scylladb/scylla/issues/3708"
 )
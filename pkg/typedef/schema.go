@@ -17,6 +17,7 @@ package typedef
 import (
 	"encoding/json"
 	"strconv"
+	"sync"
 
 	"github.com/pkg/errors"
 
@@ -24,20 +25,52 @@ import (
 )
 
 type MaterializedView struct {
-	NonPrimaryKey          *ColumnDef
-	Name                   string  `json:"name"`
-	PartitionKeys          Columns `json:"partition_keys"`
-	ClusteringKeys         Columns `json:"clustering_keys"`
+	NonPrimaryKey  *ColumnDef
+	Name           string  `json:"name"`
+	PartitionKeys  Columns `json:"partition_keys"`
+	ClusteringKeys Columns `json:"clustering_keys"`
+	// Columns are the regular columns the view selects besides its primary
+	// key, the view selects every column of the base table when it is empty.
+	Columns Columns `json:"columns,omitempty"`
+	// Filters restrict the base rows the view holds.
+	Filters                []ViewFilter `json:"filters,omitempty"`
 	partitionKeysLenValues int
 }
 
+// ViewFilter restricts a materialized view to the base rows whose column
+// compares to the CQL literal with the operator.
+type ViewFilter struct {
+	Column *ColumnDef `json:"column"`
+	Op     string     `json:"op"`
+	Value  string     `json:"value"`
+}
+
 type Schema struct {
 	Keyspace Keyspace     `json:"keyspace"`
 	Tables   []*Table     `json:"tables"`
 	Config   SchemaConfig `json:"-"`
+	// mu protects Tables against the tables added while the jobs run
+	mu sync.RWMutex
+}
+
+// GetTables returns the tables of the schema, the tables that are added
+// later are not part of the returned slice.
+func (s *Schema) GetTables() []*Table {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Tables
+}
+
+// AddTable adds the table to the schema, concurrently with GetTables.
+func (s *Schema) AddTable(t *Table) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Tables = append(s.Tables, t)
 }
 
 func (s *Schema) GetHash() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	out, err := json.Marshal(s)
 	if err != nil {
 		panic(err)
@@ -68,6 +101,38 @@ func (m *MaterializedView) HaveNonPrimaryKey() bool {
 	return m.NonPrimaryKey != nil
 }
 
+// SelectsColumn tells whether the view holds the values of the regular column.
+func (m *MaterializedView) SelectsColumn(name string) bool {
+	if len(m.Columns) == 0 || (m.HaveNonPrimaryKey() && m.NonPrimaryKey.Name == name) {
+		return true
+	}
+	for _, col := range m.Columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesColumn tells whether the regular column is part of the definition of
+// the view, as a key, a selected column or a filter.
+func (m *MaterializedView) UsesColumn(name string) bool {
+	if m.HaveNonPrimaryKey() && m.NonPrimaryKey.Name == name {
+		return true
+	}
+	for _, f := range m.Filters {
+		if f.Column.Name == name {
+			return true
+		}
+	}
+	for _, col := range m.Columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *MaterializedView) PartitionKeysLenValues() int {
 	if m.partitionKeysLenValues == 0 && m.PartitionKeys != nil {
 		m.partitionKeysLenValues = m.PartitionKeys.LenValues()
@@ -119,7 +119,7 @@ func TestSchemaMarshalUnmarshalNotChanged(t *testing.T) {
 		t.Fatalf("failed to open schema json file %s, error:%s", expectedFilePath, err)
 	}
 
-	fullSchemaMarshaled, err := json.MarshalIndent(fullSchema, "", "  ")
+	fullSchemaMarshaled, err := json.MarshalIndent(&fullSchema, "", "  ")
 	if err != nil {
 		t.Fatalf("unable to marshal schema json, error=%s\n", err)
 	}
@@ -138,12 +138,12 @@ func TestSchemaMarshalUnmarshalNotChanged(t *testing.T) {
 
 	opts := cmp.Options{
 		cmp.AllowUnexported(Table{}, MaterializedView{}),
-		cmpopts.IgnoreUnexported(Table{}, MaterializedView{}),
+		cmpopts.IgnoreUnexported(Schema{}, Table{}, MaterializedView{}),
 		cmpopts.EquateEmpty(),
 	}
 
 	fullSchema.Config = SchemaConfig{}
-	if diff := cmp.Diff(fullSchema, fullSchemaExpectedUnmarshalled, opts); diff != "" {
+	if diff := cmp.Diff(&fullSchema, &fullSchemaExpectedUnmarshalled, opts); diff != "" {
 		t.Errorf("schema not the same after unmarshal, diff=%s", diff)
 		t.Error("if you sure that this changes really needed - you should notify QA about this changes by create new issue https://github.com/scylladb/scylla-cluster-tests/issues/new\n" +
 			"Only then you can rewrite expected data file")
@@ -326,7 +326,7 @@ var fullSchema = Schema{
 			Columns: Columns{
 				{Name: "col0", Type: &UDTType{
 					ComplexType: "udt",
-					ValueTypes:  map[string]SimpleType{"udt_10.1": TYPE_BIGINT, "udt_10.2": TYPE_DATE, "udt_10.3": TYPE_BLOB},
+					ValueTypes:  map[string]Type{"udt_10.1": TYPE_BIGINT, "udt_10.2": TYPE_DATE, "udt_10.3": TYPE_BLOB},
 					TypeName:    "udt_10",
 					Frozen:      false,
 				}},
@@ -338,7 +338,7 @@ var fullSchema = Schema{
 				}},
 				{Name: "col2", Type: &TupleType{
 					ComplexType: "tuple",
-					ValueTypes:  []SimpleType{TYPE_FLOAT, TYPE_DATE, TYPE_VARCHAR},
+					ValueTypes:  []Type{TYPE_FLOAT, TYPE_DATE, TYPE_VARCHAR},
 					Frozen:      false,
 				}},
 				{Name: "col3", Type: &BagType{
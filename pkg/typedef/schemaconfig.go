@@ -25,6 +25,7 @@ type SchemaConfig struct {
 	ReplicationStrategy              *replication.Replication
 	OracleReplicationStrategy        *replication.Replication
 	TableOptions                     []tableopts.Option
+	ValueDistributions               *ValueDistributions
 	MaxTables                        int
 	MaxPartitionKeys                 int
 	MinPartitionKeys                 int
@@ -39,10 +40,15 @@ type SchemaConfig struct {
 	MinBlobLength                    int
 	MinStringLength                  int
 	UseCounters                      bool
+	UseVectors                       bool
 	UseLWT                           bool
 	CQLFeature                       CQLFeature
 	AsyncObjectStabilizationAttempts int
 	AsyncObjectStabilizationDelay    time.Duration
+	MinimizeFailures                 bool
+	MaxTTL                           time.Duration
+	UseWriteTimestamps               bool
+	UseServerSideTimestamps          bool
 }
 
 func (sc *SchemaConfig) Valid() error {
@@ -88,10 +94,16 @@ func (sc *SchemaConfig) GetMinColumns() int {
 
 func (sc *SchemaConfig) GetPartitionRangeConfig() PartitionRangeConfig {
 	return PartitionRangeConfig{
-		MaxBlobLength:   sc.MaxBlobLength,
-		MinBlobLength:   sc.MinBlobLength,
-		MaxStringLength: sc.MaxStringLength,
-		MinStringLength: sc.MinStringLength,
-		UseLWT:          sc.UseLWT,
+		ValueDistributions: sc.ValueDistributions,
+		MaxBlobLength:      sc.MaxBlobLength,
+		MinBlobLength:      sc.MinBlobLength,
+		MaxStringLength:    sc.MaxStringLength,
+		MinStringLength:    sc.MinStringLength,
+		MaxTTL:             int(sc.MaxTTL / time.Second),
+		UseLWT:             sc.UseLWT,
+		UseWriteTimestamps: sc.UseWriteTimestamps,
+		// Write times only agree between the clusters if gemini sets them,
+		// conditional writes are always timestamped by the cluster.
+		CompareWriteTimes: !sc.UseServerSideTimestamps && !sc.UseLWT,
 	}
 }
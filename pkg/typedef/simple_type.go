@@ -71,6 +71,9 @@ func (st SimpleType) CQLPretty(query string, value []interface{}) (string, int)
 	if len(value) == 0 {
 		return query, 0
 	}
+	if value[0] == nil {
+		return strings.Replace(query, "?", "null", 1), 1
+	}
 	var replacement string
 	switch st {
 	case TYPE_ASCII, TYPE_TEXT, TYPE_VARCHAR, TYPE_INET, TYPE_DATE:
@@ -86,6 +89,9 @@ func (st SimpleType) CQLPretty(query string, value []interface{}) (string, int)
 		replacement = fmt.Sprintf("%d", value[0])
 	case TYPE_DECIMAL, TYPE_DOUBLE, TYPE_FLOAT:
 		replacement = fmt.Sprintf("%.2f", value[0])
+		if !isFinite(value[0]) {
+			replacement = floatLiteral(value[0])
+		}
 	case TYPE_BOOLEAN:
 		if v, ok := value[0].(bool); ok {
 			replacement = fmt.Sprintf("%t", v)
@@ -94,7 +100,12 @@ func (st SimpleType) CQLPretty(query string, value []interface{}) (string, int)
 		if v, ok := value[0].(time.Time); ok {
 			replacement = "'" + v.Format(time.RFC3339) + "'"
 		}
-	case TYPE_DURATION, TYPE_TIMEUUID, TYPE_UUID:
+	case TYPE_DURATION:
+		replacement = fmt.Sprintf("%s", value[0])
+		if d, ok := value[0].(gocql.Duration); ok {
+			replacement = durationLiteral(d)
+		}
+	case TYPE_TIMEUUID, TYPE_UUID:
 		replacement = fmt.Sprintf("%s", value[0])
 	case TYPE_VARINT:
 		if s, ok := value[0].(*big.Int); ok {
@@ -165,7 +176,15 @@ func (st SimpleType) GenJSONValue(r *rand.Rand, p *PartitionRangeConfig) interfa
 	case TYPE_TIME:
 		return time.Unix(0, utils.RandTime(r)).UTC().Format("15:04:05.000000000")
 	}
-	return st.genValue(r, p)
+	v := st.genValue(r, p)
+	if d, ok := v.(gocql.Duration); ok {
+		return durationLiteral(d)
+	}
+	if !isFinite(v) {
+		// JSON has no literals for NaN and the infinities.
+		return st.genUniformValue(r, p)
+	}
+	return v
 }
 
 func (st SimpleType) GenValue(r *rand.Rand, p *PartitionRangeConfig) []interface{} {
@@ -173,6 +192,13 @@ func (st SimpleType) GenValue(r *rand.Rand, p *PartitionRangeConfig) []interface
 }
 
 func (st SimpleType) genValue(r *rand.Rand, p *PartitionRangeConfig) interface{} {
+	if d := p.ValueDistributions.For(st); d != nil {
+		return d.genValue(st, r, p)
+	}
+	return st.genUniformValue(r, p)
+}
+
+func (st SimpleType) genUniformValue(r *rand.Rand, p *PartitionRangeConfig) interface{} {
 	switch st {
 	case TYPE_ASCII, TYPE_TEXT, TYPE_VARCHAR:
 		ln := r.Intn(p.MaxStringLength) + p.MinStringLength
@@ -195,7 +221,7 @@ func (st SimpleType) genValue(r *rand.Rand, p *PartitionRangeConfig) interface{}
 	case TYPE_DOUBLE:
 		return r.Float64()
 	case TYPE_DURATION:
-		return (time.Minute * time.Duration(r.Intn(100))).String()
+		return genDuration(r)
 	case TYPE_FLOAT:
 		return r.Float32()
 	case TYPE_INET:
@@ -256,3 +282,88 @@ func (st SimpleType) ValueVariationsNumber(p *PartitionRangeConfig) float64 {
 		panic(fmt.Sprintf("generate value: not supported type %s", st))
 	}
 }
+
+// genDuration generates a duration of months, days and nanoseconds. Any of
+// them can be missing and they are either all positive or all negative.
+func genDuration(r *rand.Rand) gocql.Duration {
+	var d gocql.Duration
+	if r.Intn(2) == 0 {
+		d.Months = r.Int31n(1200)
+	}
+	if r.Intn(2) == 0 {
+		d.Days = r.Int31n(1000)
+	}
+	if r.Intn(2) == 0 {
+		d.Nanoseconds = r.Int63n(int64(24 * time.Hour))
+	}
+	if r.Intn(4) == 0 {
+		d = gocql.Duration{Months: -d.Months, Days: -d.Days, Nanoseconds: -d.Nanoseconds}
+	}
+	return d
+}
+
+// durationUnits are the units of the nanoseconds of a duration literal.
+var durationUnits = []struct {
+	name string
+	size int64
+}{
+	{"h", int64(time.Hour)},
+	{"m", int64(time.Minute)},
+	{"s", int64(time.Second)},
+	{"ms", int64(time.Millisecond)},
+	{"us", int64(time.Microsecond)},
+	{"ns", 1},
+}
+
+// durationLiteral formats the duration as a CQL duration literal, such as
+// -1mo2d3h4m5s6ns, its components have to have the same sign.
+func durationLiteral(d gocql.Duration) string {
+	var b strings.Builder
+	if d.Months < 0 || d.Days < 0 || d.Nanoseconds < 0 {
+		b.WriteByte('-')
+		d = gocql.Duration{Months: -d.Months, Days: -d.Days, Nanoseconds: -d.Nanoseconds}
+	}
+	if d.Months != 0 {
+		fmt.Fprintf(&b, "%dmo", d.Months)
+	}
+	if d.Days != 0 {
+		fmt.Fprintf(&b, "%dd", d.Days)
+	}
+	for _, unit := range durationUnits {
+		if n := d.Nanoseconds / unit.size; n != 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit.name)
+			d.Nanoseconds -= n * unit.size
+		}
+	}
+	if b.Len() == 0 {
+		return "0s"
+	}
+	return b.String()
+}
+
+func isFinite(v interface{}) bool {
+	switch f := v.(type) {
+	case float64:
+		return !math.IsNaN(f) && !math.IsInf(f, 0)
+	case float32:
+		return isFinite(float64(f))
+	default:
+		return true
+	}
+}
+
+// floatLiteral formats NaN and the infinities as CQL literals.
+func floatLiteral(v interface{}) string {
+	f, _ := v.(float64)
+	if f32, ok := v.(float32); ok {
+		f = float64(f32)
+	}
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	default:
+		return "NaN"
+	}
+}
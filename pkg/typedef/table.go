@@ -50,12 +50,10 @@ func (t *Table) PartitionKeysLenValues() int {
 	return t.partitionKeysLenValues
 }
 
+// IsCounterTable reports whether the columns of the table are counters, some
+// of them may be static.
 func (t *Table) IsCounterTable() bool {
-	if len(t.Columns) != 1 {
-		return false
-	}
-	_, ok := t.Columns[0].Type.(*CounterType)
-	return ok
+	return len(t.Columns) > 0 && len(t.Columns.NonCounters()) == 0
 }
 
 func (t *Table) Lock() {
@@ -105,15 +103,11 @@ func (t *Table) ValidColumnsForDelete() Columns {
 			}
 		}
 	}
-	if len(t.MaterializedViews) != 0 {
-		for _, mv := range t.MaterializedViews {
-			if mv.HaveNonPrimaryKey() {
-				for j := range validCols {
-					if validCols[j].Name == mv.NonPrimaryKey.Name {
-						validCols = append(validCols[:j], validCols[j+1:]...)
-						break
-					}
-				}
+	for i := range t.MaterializedViews {
+		for j := 0; j < len(validCols); j++ {
+			if t.MaterializedViews[i].UsesColumn(validCols[j].Name) {
+				validCols = append(validCols[:j], validCols[j+1:]...)
+				j--
 			}
 		}
 	}
@@ -122,9 +116,11 @@ func (t *Table) ValidColumnsForDelete() Columns {
 
 func (t *Table) LinkIndexAndColumns() {
 	for i, index := range t.Indexes {
-		for c, column := range t.Columns {
+		// Clustering keys can be indexed as well as regular columns.
+		columns := make(Columns, 0, len(t.ClusteringKeys)+len(t.Columns))
+		for _, column := range append(append(columns, t.ClusteringKeys...), t.Columns...) {
 			if index.ColumnName == column.Name {
-				t.Indexes[i].Column = t.Columns[c]
+				t.Indexes[i].Column = column
 				break
 			}
 		}
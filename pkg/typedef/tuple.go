@@ -22,9 +22,9 @@ import (
 )
 
 type TupleType struct {
-	ComplexType string       `json:"complex_type"`
-	ValueTypes  []SimpleType `json:"value_types"`
-	Frozen      bool         `json:"frozen"`
+	ComplexType string `json:"complex_type"`
+	ValueTypes  []Type `json:"value_types"`
+	Frozen      bool   `json:"frozen"`
 }
 
 func (t *TupleType) CQLType() gocql.TypeInfo {
@@ -58,17 +58,20 @@ func (t *TupleType) CQLPretty(query string, value []interface{}) (string, int) {
 	if len(value) == 0 {
 		return query, 0
 	}
-	var cnt, tmp int
+	cnt := 0
 	for i, tp := range t.ValueTypes {
-		query, tmp = tp.CQLPretty(query, value[i:])
-		cnt += tmp
+		if i >= len(value) {
+			break
+		}
+		query = prettyElement(tp, query, value[i])
+		cnt++
 	}
 	return query, cnt
 }
 
 func (t *TupleType) Indexable() bool {
 	for _, t := range t.ValueTypes {
-		if t == TYPE_DURATION {
+		if !t.Indexable() {
 			return false
 		}
 	}
@@ -86,17 +89,15 @@ func (t *TupleType) GenJSONValue(r *rand.Rand, p *PartitionRangeConfig) interfac
 func (t *TupleType) GenValue(r *rand.Rand, p *PartitionRangeConfig) []interface{} {
 	out := make([]interface{}, 0, len(t.ValueTypes))
 	for _, tp := range t.ValueTypes {
-		out = append(out, tp.GenValue(r, p)...)
+		out = append(out, genElementValue(tp, r, p))
 	}
 	return out
 }
 
+// LenValue returns the number of elements, a tuple column is bound element
+// by element.
 func (t *TupleType) LenValue() int {
-	out := 0
-	for _, tp := range t.ValueTypes {
-		out += tp.LenValue()
-	}
-	return out
+	return len(t.ValueTypes)
 }
 
 // ValueVariationsNumber returns number of bytes generated value holds
@@ -16,7 +16,9 @@ package typedef
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/gocql/gocql"
 	"github.com/scylladb/gocqlx/v2/qb"
 
 	"github.com/scylladb/gemini/pkg/replication"
@@ -25,7 +27,16 @@ import (
 type (
 	ValueWithToken struct {
 		Value Values
-		Token uint64
+		// Rows are the clustering keys of the rows of the partition that
+		// mutations were generated for. They are handed out and given back
+		// to the generator together with the partition key, so only the
+		// holder of the partition key accesses them.
+		Rows []Values
+		// ColumnValues are the values mutations were generated with for the
+		// regular columns that are in the partition key of a view, by column.
+		// They are held the same way the rows are.
+		ColumnValues map[string]Values
+		Token        uint64
 	}
 	Keyspace struct {
 		Replication       *replication.Replication `json:"replication"`
@@ -37,14 +48,31 @@ type (
 		Column     *ColumnDef
 		IndexName  string `json:"index_name"`
 		ColumnName string `json:"column_name"`
+		// Target is the part of a collection column that is indexed, it is
+		// empty for columns that are indexed as a whole.
+		Target IndexTarget `json:"target,omitempty"`
+		// Local indexes are scoped to a partition, they serve only the
+		// queries that restrict the whole partition key.
+		Local bool `json:"local,omitempty"`
 	}
 
+	IndexTarget string
+
 	PartitionRangeConfig struct {
-		MaxBlobLength   int
-		MinBlobLength   int
-		MaxStringLength int
-		MinStringLength int
-		UseLWT          bool
+		// ValueDistributions configure how the values of the simple types
+		// are drawn, they are drawn uniformly if it is nil.
+		ValueDistributions *ValueDistributions
+		MaxBlobLength      int
+		MinBlobLength      int
+		MaxStringLength    int
+		MinStringLength    int
+		MaxTTL             int
+		UseLWT             bool
+		UseWriteTimestamps bool
+		CompareWriteTimes  bool
+		// keys leaves the boundary values that can not be ordered out of
+		// the value distributions, see ForKeys.
+		keys bool
 	}
 
 	CQLFeature int
@@ -52,8 +80,11 @@ type (
 
 type Stmts struct {
 	PostStmtHook func()
-	List         []*Stmt
-	QueryType    StatementType
+	// Builds names the materialized view or the index the statements
+	// create, they are only read once the clusters finished building it.
+	Builds    string
+	List      []*Stmt
+	QueryType StatementType
 }
 
 type StmtCache struct {
@@ -61,12 +92,30 @@ type StmtCache struct {
 	Types     Types
 	QueryType StatementType
 	LenValue  int
+	// Conditions holds the columns compared by the IF clause of a
+	// conditional statement, existence conditions name no column.
+	Conditions []string
+}
+
+// ReadOptions tell how the rows of a check statement are read and compared.
+// A zero PageSize fetches the rows in pages of the driver default size. With
+// ResumePaging every page is fetched by a new query that resumes from the
+// paging state of the page before. Ordered rows are compared in the order
+// they are returned, CQL guarantees it for the rows of a single partition.
+// The columns in TTLColumns hold the TTL of a cell, they are equal within
+// a second.
+type ReadOptions struct {
+	PageSize     int
+	ResumePaging bool
+	Ordered      bool
+	TTLColumns   []string
 }
 
 type Stmt struct {
 	*StmtCache
 	ValuesWithToken []*ValueWithToken
 	Values          Values
+	Read            ReadOptions
 }
 
 func (s *Stmt) PrettyCQL() string {
@@ -77,7 +126,11 @@ func (s *Stmt) PrettyCQL() string {
 		return query
 	}
 	for _, typ := range s.Types {
-		query, replaced = typ.CQLPretty(query, values)
+		if _, isTuple := typ.(*TupleType); !isTuple && len(values) > 0 && (values[0] == nil || values[0] == gocql.UnsetValue) {
+			query, replaced = prettyMissing(query, values[0]), 1
+		} else {
+			query, replaced = typ.CQLPretty(query, values)
+		}
 		if len(values) >= replaced {
 			values = values[replaced:]
 		} else {
@@ -87,6 +140,15 @@ func (s *Stmt) PrettyCQL() string {
 	return query
 }
 
+// prettyMissing replaces the bind marker of a null or unset value, an unset
+// value has no literal and is shown as a comment.
+func prettyMissing(query string, value interface{}) string {
+	if value == nil {
+		return strings.Replace(query, "?", "null", 1)
+	}
+	return strings.Replace(query, "?", "/* unset */", 1)
+}
+
 type StatementType uint8
 
 func (st StatementType) ToString() string {
@@ -113,11 +175,80 @@ func (st StatementType) ToString() string {
 		return "DropColumnStatement"
 	case AddColumnStatementType:
 		return "AddColumnStatement"
+	case InsertIfNotExistsStatementType:
+		return "InsertIfNotExistsStatement"
+	case UpdateIfStatementType:
+		return "UpdateIfStatement"
+	case DeleteIfStatementType:
+		return "DeleteIfStatement"
+	case BatchIfStatementType:
+		return "BatchIfStatement"
+	case BatchStatementType:
+		return "BatchStatement"
+	case UnloggedBatchStatementType:
+		return "UnloggedBatchStatement"
+	case CounterBatchStatementType:
+		return "CounterBatchStatement"
+	case SelectWriteTimeStatementType:
+		return "SelectWriteTimeStatement"
+	case PartialUpdateStatementType:
+		return "PartialUpdateStatement"
+	case CollectionUpdateStatementType:
+		return "CollectionUpdateStatement"
+	case UDTFieldUpdateStatementType:
+		return "UDTFieldUpdateStatement"
+	case DeletePartitionStatementType:
+		return "DeletePartitionStatement"
+	case DeleteRowStatementType:
+		return "DeleteRowStatement"
+	case DeleteColumnsStatementType:
+		return "DeleteColumnsStatement"
+	case DeleteElementStatementType:
+		return "DeleteElementStatement"
+	case DeleteRangeStatementType:
+		return "DeleteRangeStatement"
+	case ScanStatementType:
+		return "ScanStatement"
+	case SelectAggregateStatementType:
+		return "SelectAggregateStatement"
+	case SelectFunctionStatementType:
+		return "SelectFunctionStatement"
+	case SelectJSONStatementType:
+		return "SelectJSONStatement"
+	case SelectFilteringStatementType:
+		return "SelectFilteringStatement"
+	case CreateIndexStatementType:
+		return "CreateIndexStatement"
+	case DropIndexStatementType:
+		return "DropIndexStatement"
+	case CreateMaterializedViewStatementType:
+		return "CreateMaterializedViewStatement"
+	case DropMaterializedViewStatementType:
+		return "DropMaterializedViewStatement"
+	case AlterTableOptionsStatementType:
+		return "AlterTableOptionsStatement"
+	case AlterTypeStatementType:
+		return "AlterTypeStatement"
+	case TruncateStatementType:
+		return "TruncateStatement"
+	case CreateTableStatementType:
+		return "CreateTableStatement"
 	default:
 		panic(fmt.Sprintf("unknown statement type %d", st))
 	}
 }
 
+// IsLWT reports whether statements of the type are conditional, their
+// outcome then depends on the current data and is compared between clusters.
+func (st StatementType) IsLWT() bool {
+	switch st {
+	case InsertIfNotExistsStatementType, UpdateIfStatementType, DeleteIfStatementType, BatchIfStatementType:
+		return true
+	default:
+		return false
+	}
+}
+
 func (st StatementType) PossibleAsyncOperation() bool {
 	switch st {
 	case SelectByIndexStatementType, SelectFromMaterializedViewStatementType:
@@ -127,6 +258,28 @@ func (st StatementType) PossibleAsyncOperation() bool {
 	}
 }
 
+// AddRow remembers the clustering key of a row of the partition. Only the
+// last MaxKnownRows rows are remembered.
+func (v *ValueWithToken) AddRow(row Values) {
+	if len(v.Rows) >= MaxKnownRows {
+		v.Rows = append(v.Rows[:0:0], v.Rows[len(v.Rows)-MaxKnownRows+1:]...)
+	}
+	v.Rows = append(v.Rows, row)
+}
+
+// AddColumnValue remembers a value of a regular column of the partition.
+// Only the last MaxKnownRows values of every column are remembered.
+func (v *ValueWithToken) AddColumnValue(name string, value interface{}) {
+	if v.ColumnValues == nil {
+		v.ColumnValues = make(map[string]Values)
+	}
+	known := v.ColumnValues[name]
+	if len(known) >= MaxKnownRows {
+		known = append(known[:0:0], known[len(known)-MaxKnownRows+1:]...)
+	}
+	v.ColumnValues[name] = append(known, value)
+}
+
 type Values []interface{}
 
 func (v Values) Copy() Values {
@@ -153,6 +306,10 @@ func (t StatementCacheType) ToString() string {
 		return "CacheUpdate"
 	case CacheDelete:
 		return "CacheDelete"
+	case CacheUpdateIfExists:
+		return "CacheUpdateIfExists"
+	case CacheDeleteIfExists:
+		return "CacheDeleteIfExists"
 	default:
 		panic(fmt.Sprintf("unknown statement cache type %d", t))
 	}
@@ -163,5 +320,7 @@ const (
 	CacheInsertIfNotExists
 	CacheUpdate
 	CacheDelete
+	CacheUpdateIfExists
+	CacheDeleteIfExists
 	CacheArrayLen
 )
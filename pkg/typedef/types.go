@@ -29,11 +29,13 @@ import (
 
 // nolint:revive
 const (
-	TYPE_UDT   = "udt"
-	TYPE_MAP   = "map"
-	TYPE_LIST  = "list"
-	TYPE_SET   = "set"
-	TYPE_TUPLE = "tuple"
+	TYPE_UDT     = "udt"
+	TYPE_MAP     = "map"
+	TYPE_LIST    = "list"
+	TYPE_SET     = "set"
+	TYPE_TUPLE   = "tuple"
+	TYPE_VECTOR  = "vector"
+	TYPE_COUNTER = "counter"
 )
 
 // nolint:revive
@@ -83,6 +85,41 @@ var (
 		TYPE_TINYINT, TYPE_UUID, TYPE_VARCHAR, TYPE_VARINT,
 	}
 	AllTypes = append(append(SimpleTypes{}, PkTypes...), TYPE_BOOLEAN, TYPE_DURATION)
+
+	// Aggregates and functions are only validated on the types their result
+	// is exact for, the clusters have to agree on it to the last bit.
+	TypesForMinMax = SimpleTypes{
+		TYPE_ASCII, TYPE_BIGINT, TYPE_BLOB, TYPE_DATE, TYPE_DECIMAL, TYPE_DOUBLE, TYPE_FLOAT,
+		TYPE_INET, TYPE_INT, TYPE_SMALLINT, TYPE_TEXT, TYPE_TIME, TYPE_TIMESTAMP, TYPE_TINYINT, TYPE_VARCHAR, TYPE_VARINT,
+	}
+	TypesForSum  = SimpleTypes{TYPE_BIGINT, TYPE_DECIMAL, TYPE_INT, TYPE_SMALLINT, TYPE_TINYINT, TYPE_VARINT}
+	TypesForAvg  = SimpleTypes{TYPE_BIGINT, TYPE_INT, TYPE_SMALLINT, TYPE_TINYINT, TYPE_VARINT}
+	TypesForJSON = SimpleTypes{
+		TYPE_ASCII, TYPE_BIGINT, TYPE_BLOB, TYPE_BOOLEAN, TYPE_DATE, TYPE_INET, TYPE_INT,
+		TYPE_SMALLINT, TYPE_TEXT, TYPE_TIMEUUID, TYPE_TINYINT, TYPE_UUID, TYPE_VARCHAR, TYPE_VARINT,
+	}
+	// TypesForCast lists the types the values of a type can be cast to.
+	TypesForCast = map[SimpleType]SimpleTypes{
+		TYPE_ASCII:    {TYPE_TEXT},
+		TYPE_BIGINT:   {TYPE_DOUBLE, TYPE_TEXT, TYPE_VARINT},
+		TYPE_BOOLEAN:  {TYPE_TEXT},
+		TYPE_DATE:     {TYPE_TEXT, TYPE_TIMESTAMP},
+		TYPE_INET:     {TYPE_TEXT},
+		TYPE_INT:      {TYPE_BIGINT, TYPE_DOUBLE, TYPE_TEXT, TYPE_VARINT},
+		TYPE_SMALLINT: {TYPE_BIGINT, TYPE_DOUBLE, TYPE_INT, TYPE_TEXT, TYPE_VARINT},
+		TYPE_TIMEUUID: {TYPE_DATE, TYPE_TEXT, TYPE_TIMESTAMP},
+		TYPE_TINYINT:  {TYPE_BIGINT, TYPE_DOUBLE, TYPE_INT, TYPE_SMALLINT, TYPE_TEXT, TYPE_VARINT},
+		TYPE_UUID:     {TYPE_TEXT},
+		TYPE_VARINT:   {TYPE_TEXT},
+	}
+	// TimeFunctions lists the time conversion functions applicable to a type.
+	// Timestamps are generated far beyond the range of dates, so they are
+	// not converted to one.
+	TimeFunctions = map[SimpleType][]string{
+		TYPE_DATE:      {"totimestamp", "tounixtimestamp"},
+		TYPE_TIMESTAMP: {"tounixtimestamp"},
+		TYPE_TIMEUUID:  {"todate", "totimestamp", "tounixtimestamp"},
+	}
 )
 
 var goCQLTypeMap = map[gocql.Type]gocql.TypeInfo{
@@ -121,7 +158,7 @@ var goCQLTypeMap = map[gocql.Type]gocql.TypeInfo{
 type MapType struct {
 	ComplexType string     `json:"complex_type"`
 	KeyType     SimpleType `json:"key_type"`
-	ValueType   SimpleType `json:"value_type"`
+	ValueType   Type       `json:"value_type"`
 	Frozen      bool       `json:"frozen"`
 }
 
@@ -148,7 +185,7 @@ func (mt *MapType) CQLPretty(query string, value []interface{}) (string, int) {
 	vv := "{"
 	for s.Next() {
 		vv += fmt.Sprintf("%v:?,", s.Key().Interface())
-		vv, _ = mt.ValueType.CQLPretty(vv, []interface{}{s.Value().Interface()})
+		vv = prettyElement(mt.ValueType, vv, s.Value().Interface())
 	}
 	vv = strings.TrimSuffix(vv, ",")
 	vv += "}"
@@ -166,9 +203,9 @@ func (mt *MapType) GenJSONValue(r *rand.Rand, p *PartitionRangeConfig) interface
 
 func (mt *MapType) GenValue(r *rand.Rand, p *PartitionRangeConfig) []interface{} {
 	count := utils.RandInt2(r, 1, maxMapSize+1)
-	vals := reflect.MakeMap(reflect.MapOf(reflect.TypeOf(mt.KeyType.GenValue(r, p)[0]), reflect.TypeOf(mt.ValueType.GenValue(r, p)[0])))
+	vals := reflect.MakeMap(reflect.MapOf(reflect.TypeOf(mt.KeyType.GenValue(r, p)[0]), reflect.TypeOf(genElementValue(mt.ValueType, r, p))))
 	for i := 0; i < count; i++ {
-		vals.SetMapIndex(reflect.ValueOf(mt.KeyType.GenValue(r, p)[0]), reflect.ValueOf(mt.ValueType.GenValue(r, p)[0]))
+		vals.SetMapIndex(reflect.ValueOf(mt.KeyType.GenValue(r, p)[0]), reflect.ValueOf(genElementValue(mt.ValueType, r, p)))
 	}
 	return []interface{}{vals.Interface()}
 }
@@ -198,7 +235,13 @@ type CounterType struct {
 }
 
 func (ct *CounterType) CQLType() gocql.TypeInfo {
-	return goCQLTypeMap[gocql.TypeMap]
+	return goCQLTypeMap[gocql.TypeCounter]
+}
+
+// MarshalJSON describes the counter as a complex type so that schemas with
+// counters can be read back, the current value is not part of the schema.
+func (ct *CounterType) MarshalJSON() ([]byte, error) {
+	return []byte(`{"complex_type":"` + TYPE_COUNTER + `"}`), nil
 }
 
 func (ct *CounterType) Name() string {
@@ -244,3 +287,28 @@ func (ct *CounterType) ValueVariationsNumber(_ *PartitionRangeConfig) float64 {
 	// As a type, counters are a 64-bit signed integer
 	return 2 ^ 64
 }
+
+// genElementValue generates the value of a type nested in another type. A
+// tuple is bound element by element as a column but is a single value there.
+func genElementValue(t Type, r *rand.Rand, p *PartitionRangeConfig) interface{} {
+	if tt, ok := t.(*TupleType); ok {
+		return tt.GenValue(r, p)
+	}
+	return t.GenValue(r, p)[0]
+}
+
+// prettyElement replaces the next bind marker of the query with the value of
+// a type nested in another type.
+func prettyElement(t Type, query string, value interface{}) string {
+	if value == nil {
+		return strings.Replace(query, "?", "null", 1)
+	}
+	if tt, ok := t.(*TupleType); ok {
+		if values, isSlice := value.([]interface{}); isSlice {
+			query, _ = tt.CQLPretty(strings.Replace(query, "?", tt.CQLHolder(), 1), values)
+			return query
+		}
+	}
+	query, _ = t.CQLPretty(query, []interface{}{value})
+	return query
+}
@@ -15,11 +15,15 @@
 package typedef_test
 
 import (
+	"math"
 	"math/big"
 	"net"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/gocql/gocql"
+	"golang.org/x/exp/rand"
 	"gopkg.in/inf.v0"
 
 	"github.com/scylladb/gemini/pkg/typedef"
@@ -81,12 +85,54 @@ var prettytests = []struct {
 		values:   []interface{}{10 * time.Minute},
 		expected: "SELECT * FROM tbl WHERE pk0=10m0s",
 	},
+	{
+		typ:      typedef.TYPE_DURATION,
+		query:    "SELECT * FROM tbl WHERE pk0=?",
+		values:   []interface{}{gocql.Duration{Months: -14, Days: -3, Nanoseconds: -int64(time.Hour + 5*time.Second + 6)}},
+		expected: "SELECT * FROM tbl WHERE pk0=-14mo3d1h5s6ns",
+	},
+	{
+		typ:      typedef.TYPE_DURATION,
+		query:    "SELECT * FROM tbl WHERE pk0=?",
+		values:   []interface{}{gocql.Duration{}},
+		expected: "SELECT * FROM tbl WHERE pk0=0s",
+	},
+	{
+		typ:      &typedef.VectorType{ComplexType: typedef.TYPE_VECTOR, Dimensions: 3},
+		query:    "SELECT * FROM tbl WHERE pk0=?",
+		values:   []interface{}{typedef.Vector{0.5, -1, 2}},
+		expected: "SELECT * FROM tbl WHERE pk0=[0.50,-1.00,2.00]",
+	},
+	{
+		typ:      &typedef.VectorType{ComplexType: typedef.TYPE_VECTOR, Dimensions: 3},
+		query:    "SELECT * FROM tbl WHERE pk0=?",
+		values:   []interface{}{[]float32{0.5, -1, 2}},
+		expected: "SELECT * FROM tbl WHERE pk0=[0.50,-1.00,2.00]",
+	},
+	{
+		typ:      &typedef.VectorType{ComplexType: typedef.TYPE_VECTOR, Dimensions: 3},
+		query:    "SELECT * FROM tbl WHERE pk0=?",
+		values:   []interface{}{[]float64{0.5, -1, 2}},
+		expected: "SELECT * FROM tbl WHERE pk0=[0.5 -1 2]",
+	},
 	{
 		typ:      typedef.TYPE_FLOAT,
 		query:    "SELECT * FROM tbl WHERE pk0=?",
 		values:   []interface{}{10.0},
 		expected: "SELECT * FROM tbl WHERE pk0=10.00",
 	},
+	{
+		typ:      typedef.TYPE_DOUBLE,
+		query:    "SELECT * FROM tbl WHERE pk0=?",
+		values:   []interface{}{math.Inf(-1)},
+		expected: "SELECT * FROM tbl WHERE pk0=-Infinity",
+	},
+	{
+		typ:      typedef.TYPE_FLOAT,
+		query:    "SELECT * FROM tbl WHERE pk0=?",
+		values:   []interface{}{float32(math.NaN())},
+		expected: "SELECT * FROM tbl WHERE pk0=NaN",
+	},
 	{
 		typ:      typedef.TYPE_INET,
 		query:    "SELECT * FROM tbl WHERE pk0=?",
@@ -195,7 +241,7 @@ var prettytests = []struct {
 	},
 	{
 		typ: &typedef.TupleType{
-			ValueTypes: []typedef.SimpleType{typedef.TYPE_ASCII},
+			ValueTypes: []typedef.Type{typedef.TYPE_ASCII},
 			Frozen:     false,
 		},
 		query:    "SELECT * FROM tbl WHERE pk0=?",
@@ -204,13 +250,39 @@ var prettytests = []struct {
 	},
 	{
 		typ: &typedef.TupleType{
-			ValueTypes: []typedef.SimpleType{typedef.TYPE_ASCII, typedef.TYPE_ASCII},
+			ValueTypes: []typedef.Type{typedef.TYPE_ASCII, typedef.TYPE_ASCII},
 			Frozen:     false,
 		},
 		query:    "SELECT * FROM tbl WHERE pk0={?,?}",
 		values:   []interface{}{"a", "b"},
 		expected: "SELECT * FROM tbl WHERE pk0={'a','b'}",
 	},
+	{
+		typ: &typedef.TupleType{
+			ValueTypes: []typedef.Type{typedef.TYPE_INT, &typedef.BagType{ComplexType: typedef.TYPE_LIST, ValueType: typedef.TYPE_INT, Frozen: true}},
+			Frozen:     false,
+		},
+		query:    "SELECT * FROM tbl WHERE pk0=(?,?)",
+		values:   []interface{}{1, []int{2, 3}},
+		expected: "SELECT * FROM tbl WHERE pk0=(1,[2,3])",
+	},
+	{
+		typ: &typedef.BagType{
+			ComplexType: typedef.TYPE_LIST,
+			ValueType: &typedef.MapType{
+				KeyType: typedef.TYPE_INT,
+				ValueType: &typedef.UDTType{
+					TypeName:   "udt_1",
+					ValueTypes: map[string]typedef.Type{"udt_1_0": &typedef.BagType{ComplexType: typedef.TYPE_SET, ValueType: typedef.TYPE_TEXT, Frozen: true}},
+					Frozen:     true,
+				},
+				Frozen: true,
+			},
+		},
+		query:    "SELECT * FROM tbl WHERE pk0=?",
+		values:   []interface{}{[]interface{}{map[int]map[string]interface{}{1: {"udt_1_0": []string{"a"}}}}},
+		expected: "SELECT * FROM tbl WHERE pk0=[{1:{udt_1_0:{'a'}}}]",
+	},
 }
 
 func TestCQLPretty(t *testing.T) {
@@ -223,3 +295,98 @@ func TestCQLPretty(t *testing.T) {
 		}
 	}
 }
+
+func TestUDTNewFieldName(t *testing.T) {
+	t.Parallel()
+	udt := &typedef.UDTType{
+		ComplexType: typedef.TYPE_UDT,
+		TypeName:    "udt_1",
+		ValueTypes: map[string]typedef.Type{
+			"udt_1_0": typedef.TYPE_INT,
+			"udt_1_9": typedef.TYPE_TEXT,
+		},
+		Frozen: true,
+	}
+	for i := 0; i < 10; i++ {
+		name := udt.NewFieldName()
+		for existing := range udt.ValueTypes {
+			if existing >= name {
+				t.Fatalf("new field %s does not sort after the field %s", name, existing)
+			}
+		}
+		udt.ValueTypes[name] = typedef.TYPE_INT
+	}
+}
+
+func TestNestedTypes(t *testing.T) {
+	t.Parallel()
+	udt := &typedef.UDTType{
+		ComplexType: typedef.TYPE_UDT,
+		TypeName:    "udt_1",
+		ValueTypes: map[string]typedef.Type{
+			"udt_1_0": &typedef.BagType{ComplexType: typedef.TYPE_SET, ValueType: typedef.TYPE_INT, Frozen: true},
+			"udt_1_1": typedef.TYPE_TEXT,
+		},
+		Frozen: true,
+	}
+	typ := &typedef.BagType{
+		ComplexType: typedef.TYPE_LIST,
+		ValueType:   &typedef.MapType{ComplexType: typedef.TYPE_MAP, KeyType: typedef.TYPE_INT, ValueType: udt, Frozen: true},
+	}
+	if def := typ.CQLDef(); def != "list<frozen<map<int,frozen<udt_1>>>>" {
+		t.Errorf("unexpected definition %s", def)
+	}
+
+	p := &typedef.PartitionRangeConfig{MaxStringLength: 10}
+	r := rand.New(rand.NewSource(1))
+	values := typ.GenValue(r, p)
+	if len(values) != 1 {
+		t.Fatalf("expected a single value, got %v", values)
+	}
+	for _, elem := range values[0].([]interface{}) {
+		m := reflect.ValueOf(elem)
+		if m.Kind() != reflect.Map || m.Len() == 0 {
+			t.Fatalf("expected a non empty map, got %v", elem)
+		}
+		for iter := m.MapRange(); iter.Next(); {
+			fields, ok := iter.Value().Interface().(map[string]interface{})
+			if !ok || len(fields) != 2 {
+				t.Fatalf("expected the fields of %s, got %v", udt.TypeName, iter.Value())
+			}
+			if _, ok = fields["udt_1_0"].([]interface{}); !ok {
+				t.Fatalf("expected a set field, got %v", fields["udt_1_0"])
+			}
+		}
+	}
+}
+
+func TestVectorType(t *testing.T) {
+	t.Parallel()
+	typ := &typedef.VectorType{ComplexType: typedef.TYPE_VECTOR, Dimensions: 4}
+	if def := typ.CQLDef(); def != "vector<float, 4>" {
+		t.Errorf("unexpected definition %s", def)
+	}
+	if !typedef.IsVector(typ.CQLType()) || typedef.IsVector(typedef.TYPE_FLOAT.CQLType()) {
+		t.Error("expected only the vector type to be reported as a vector")
+	}
+
+	r := rand.New(rand.NewSource(1))
+	value := typ.GenValue(r, &typedef.PartitionRangeConfig{})[0].(typedef.Vector)
+	if len(value) != typ.Dimensions {
+		t.Fatalf("expected %d components, got %v", typ.Dimensions, value)
+	}
+	data, err := gocql.Marshal(typ.CQLType(), value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded typedef.Vector
+	if err = gocql.Unmarshal(typ.CQLType(), data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(value, decoded) {
+		t.Errorf("vector %v was read back as %v", value, decoded)
+	}
+	if err = gocql.Unmarshal(typ.CQLType(), data[1:], &decoded); err == nil {
+		t.Error("expected a truncated vector to be rejected")
+	}
+}
@@ -16,6 +16,7 @@ package typedef
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/gocql/gocql"
@@ -23,10 +24,10 @@ import (
 )
 
 type UDTType struct {
-	ComplexType string                `json:"complex_type"`
-	ValueTypes  map[string]SimpleType `json:"value_types"`
-	TypeName    string                `json:"type_name"`
-	Frozen      bool                  `json:"frozen"`
+	ComplexType string          `json:"complex_type"`
+	ValueTypes  map[string]Type `json:"value_types"`
+	TypeName    string          `json:"type_name"`
+	Frozen      bool            `json:"frozen"`
 }
 
 func (t *UDTType) CQLType() gocql.TypeInfo {
@@ -54,9 +55,9 @@ func (t *UDTType) CQLPretty(query string, value []interface{}) (string, int) {
 	}
 	if s, ok := value[0].(map[string]interface{}); ok {
 		vv := "{"
-		for k, v := range t.ValueTypes {
+		for _, k := range t.fieldNames() {
 			vv += fmt.Sprintf("%s:?,", k)
-			vv, _ = v.CQLPretty(vv, []interface{}{s[k]})
+			vv = prettyElement(t.ValueTypes[k], vv, s[k])
 		}
 		vv = strings.TrimSuffix(vv, ",")
 		vv += "}"
@@ -67,7 +68,7 @@ func (t *UDTType) CQLPretty(query string, value []interface{}) (string, int) {
 
 func (t *UDTType) Indexable() bool {
 	for _, t := range t.ValueTypes {
-		if t == TYPE_DURATION {
+		if !t.Indexable() {
 			return false
 		}
 	}
@@ -76,20 +77,43 @@ func (t *UDTType) Indexable() bool {
 
 func (t *UDTType) GenJSONValue(r *rand.Rand, p *PartitionRangeConfig) interface{} {
 	vals := make(map[string]interface{})
-	for name, typ := range t.ValueTypes {
-		vals[name] = typ.GenJSONValue(r, p)
+	for _, name := range t.fieldNames() {
+		vals[name] = t.ValueTypes[name].GenJSONValue(r, p)
 	}
 	return vals
 }
 
 func (t *UDTType) GenValue(r *rand.Rand, p *PartitionRangeConfig) []interface{} {
 	vals := make(map[string]interface{})
-	for name, typ := range t.ValueTypes {
-		vals[name] = typ.GenValue(r, p)[0]
+	for _, name := range t.fieldNames() {
+		vals[name] = genElementValue(t.ValueTypes[name], r, p)
 	}
 	return []interface{}{vals}
 }
 
+// fieldNames returns the names of the fields in a stable order, so that the
+// values generated from the same source do not depend on map iteration.
+func (t *UDTType) fieldNames() []string {
+	names := make([]string, 0, len(t.ValueTypes))
+	for name := range t.ValueTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewFieldName returns the name of a field added to the type. Values are
+// serialized with the fields in the order of their names, the name sorts
+// after the existing ones so that the values written before the field was
+// added keep their layout.
+func (t *UDTType) NewFieldName() string {
+	names := t.fieldNames()
+	if len(names) == 0 {
+		return t.TypeName + "_0"
+	}
+	return fmt.Sprintf("%s_%d", names[len(names)-1], len(names))
+}
+
 func (t *UDTType) LenValue() int {
 	return 1
 }
@@ -0,0 +1,216 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typedef
+
+import (
+	"hash/fnv"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/rand"
+	"gopkg.in/inf.v0"
+)
+
+// ValueDistribution configures how the values of a simple type are drawn.
+// The values that none of the options apply to are drawn uniformly.
+type ValueDistribution struct {
+	// Boundary is the probability to draw an edge value of the type, such as
+	// its minimum and maximum, NaN, the empty string or the epoch.
+	Boundary float64
+	// Null is the probability that a regular column is set to null.
+	Null float64
+	// Pool restricts the values to a pool of that many constant values, 0
+	// leaves them unrestricted.
+	Pool uint64
+	// Zipf skews the draws from the pool towards its first values, so that
+	// a few values are hot. The draws are uniform unless it is above 1.
+	Zipf float64
+}
+
+// ValueDistributions holds the value distributions of the simple types,
+// Default applies to the types without a distribution of their own.
+type ValueDistributions struct {
+	Types   map[SimpleType]*ValueDistribution
+	Default *ValueDistribution
+}
+
+// For returns the value distribution of the type or nil if its values are
+// drawn uniformly.
+func (d *ValueDistributions) For(t SimpleType) *ValueDistribution {
+	if d == nil {
+		return nil
+	}
+	if dist, ok := d.Types[t]; ok {
+		return dist
+	}
+	return d.Default
+}
+
+// ParseValueDistributions parses distributions of the form
+// TYPE:OPTION=VALUE,... where TYPE is a simple type or "all" and the options
+// are boundary, null, pool and zipf. It returns nil if there are none.
+func ParseValueDistributions(specs []string) (*ValueDistributions, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	out := &ValueDistributions{Types: make(map[SimpleType]*ValueDistribution)}
+	for _, spec := range specs {
+		typ, options, found := strings.Cut(spec, ":")
+		if !found {
+			return nil, errors.Errorf("value distribution %q does not name a type", spec)
+		}
+		dist, err := parseValueDistribution(options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value distribution %q", spec)
+		}
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if typ == "all" {
+			out.Default = dist
+			continue
+		}
+		if !AllTypes.Contains(SimpleType(typ)) {
+			return nil, errors.Errorf("value distribution %q is not for a simple type", spec)
+		}
+		out.Types[SimpleType(typ)] = dist
+	}
+	return out, nil
+}
+
+func parseValueDistribution(options string) (*ValueDistribution, error) {
+	dist := &ValueDistribution{}
+	for _, option := range strings.Split(options, ",") {
+		name, value, _ := strings.Cut(option, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		var err error
+		switch name {
+		case "boundary":
+			dist.Boundary, err = parseProbability(value)
+		case "null":
+			dist.Null, err = parseProbability(value)
+		case "pool":
+			dist.Pool, err = strconv.ParseUint(value, 10, 64)
+		case "zipf":
+			dist.Zipf, err = strconv.ParseFloat(value, 64)
+		default:
+			return nil, errors.Errorf("unknown option %q", name)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", name)
+		}
+	}
+	return dist, nil
+}
+
+func parseProbability(value string) (float64, error) {
+	p, err := strconv.ParseFloat(value, 64)
+	if err == nil && (p < 0 || p > 1) {
+		err = errors.Errorf("%s is not a probability", value)
+	}
+	return p, err
+}
+
+// ForKeys returns the config the clustering keys are drawn with. The rows of
+// a partition are ordered by their clustering keys, so NaN and the empty
+// values are left out of the boundary values.
+func (p *PartitionRangeConfig) ForKeys() *PartitionRangeConfig {
+	if p.ValueDistributions == nil || p.keys {
+		return p
+	}
+	kp := *p
+	kp.keys = true
+	return &kp
+}
+
+func (d *ValueDistribution) genValue(st SimpleType, r *rand.Rand, p *PartitionRangeConfig) interface{} {
+	if d.Boundary > 0 && r.Float64() < d.Boundary {
+		values := boundaryValues[st]
+		if p.keys {
+			values = keyBoundaryValues[st]
+		}
+		if len(values) > 0 {
+			return values[r.Intn(len(values))]
+		}
+	}
+	if d.Pool == 0 {
+		return st.genUniformValue(r, p)
+	}
+	idx := r.Uint64n(d.Pool)
+	if d.Zipf > 1 {
+		idx = rand.NewZipf(r, d.Zipf, 1, d.Pool-1).Uint64()
+	}
+	// The values of the pool are drawn from a source of their own, so that
+	// they are the same wherever they are drawn.
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(st))
+	return st.genUniformValue(rand.New(rand.NewSource(h.Sum64()+idx)), p)
+}
+
+// boundaryValues are the edge values of the types, where serialization and
+// comparison bugs are most likely to hide.
+var boundaryValues = map[SimpleType][]interface{}{
+	TYPE_ASCII:     {""},
+	TYPE_TEXT:      {""},
+	TYPE_VARCHAR:   {""},
+	TYPE_BLOB:      {""},
+	TYPE_BIGINT:    {int64(math.MinInt64), int64(math.MaxInt64), int64(0)},
+	TYPE_INT:       {int32(math.MinInt32), int32(math.MaxInt32), int32(0)},
+	TYPE_SMALLINT:  {int16(math.MinInt16), int16(math.MaxInt16), int16(0)},
+	TYPE_TINYINT:   {int8(math.MinInt8), int8(math.MaxInt8), int8(0)},
+	TYPE_VARINT:    {new(big.Int).Lsh(big.NewInt(-1), 64), new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(0)},
+	TYPE_DECIMAL:   {inf.NewDec(0, 0), inf.NewDec(math.MinInt64, 0), inf.NewDec(1, 100)},
+	TYPE_DOUBLE:    {math.NaN(), math.Inf(1), math.Inf(-1), math.MaxFloat64, math.SmallestNonzeroFloat64, float64(0)},
+	TYPE_FLOAT:     {float32(math.NaN()), float32(math.Inf(1)), float32(math.Inf(-1)), float32(math.MaxFloat32), float32(math.SmallestNonzeroFloat32), float32(0)},
+	TYPE_DATE:      {"1970-01-01", "0001-01-01", "9999-12-31"},
+	TYPE_TIME:      {int64(0), int64(86399999999999)},
+	TYPE_TIMESTAMP: {int64(0), int64(-62135596800000), int64(253402300799999)},
+	TYPE_INET:      {"0.0.0.0", "255.255.255.255", "::1"},
+	TYPE_UUID:      {"00000000-0000-0000-0000-000000000000"},
+	TYPE_TIMEUUID:  {gocql.TimeUUIDWith(0, 0, []byte{0, 0, 0, 0, 0, 0}).String()},
+	TYPE_DURATION: {
+		gocql.Duration{},
+		gocql.Duration{Months: math.MaxInt32, Days: math.MaxInt32, Nanoseconds: math.MaxInt64},
+		gocql.Duration{Months: -math.MaxInt32, Days: -math.MaxInt32, Nanoseconds: -math.MaxInt64},
+	},
+}
+
+// keyBoundaryValues are the boundary values that clustering keys can take,
+// which are all of them but NaN and the empty values.
+var keyBoundaryValues = func() map[SimpleType][]interface{} {
+	out := make(map[SimpleType][]interface{}, len(boundaryValues))
+	for st, values := range boundaryValues {
+		for _, v := range values {
+			switch v := v.(type) {
+			case string:
+				if v == "" {
+					continue
+				}
+			case float64:
+				if math.IsNaN(v) {
+					continue
+				}
+			case float32:
+				if math.IsNaN(float64(v)) {
+					continue
+				}
+			}
+			out[st] = append(out[st], v)
+		}
+	}
+	return out
+}()
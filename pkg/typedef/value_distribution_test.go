@@ -0,0 +1,135 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typedef_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/rand"
+
+	"github.com/scylladb/gemini/pkg/typedef"
+)
+
+func TestParseValueDistributions(t *testing.T) {
+	t.Parallel()
+	received, err := typedef.ParseValueDistributions([]string{"all:boundary=0.1", "text:pool=10,zipf=1.5,null=0.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &typedef.ValueDistributions{
+		Types: map[typedef.SimpleType]*typedef.ValueDistribution{
+			typedef.TYPE_TEXT: {Pool: 10, Zipf: 1.5, Null: 0.2},
+		},
+		Default: &typedef.ValueDistribution{Boundary: 0.1},
+	}
+	if diff := cmp.Diff(expected, received); diff != "" {
+		t.Error(diff)
+	}
+	if received.For(typedef.TYPE_INT) != received.Default {
+		t.Error("expected the types without a distribution to use the default")
+	}
+
+	for _, spec := range []string{"text", "list:pool=1", "int:null=2", "int:skew=1", "int:pool=-1"} {
+		if _, err = typedef.ParseValueDistributions([]string{spec}); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestValueDistributionPool(t *testing.T) {
+	t.Parallel()
+	p := &typedef.PartitionRangeConfig{
+		MaxStringLength: 10,
+		ValueDistributions: &typedef.ValueDistributions{
+			Types: map[typedef.SimpleType]*typedef.ValueDistribution{
+				typedef.TYPE_TEXT:   {Pool: 5, Zipf: 2},
+				typedef.TYPE_BIGINT: {Pool: 5},
+			},
+		},
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, typ := range []typedef.SimpleType{typedef.TYPE_TEXT, typedef.TYPE_BIGINT} {
+		counts := make(map[string]int)
+		for i := 0; i < 1000; i++ {
+			counts[fmt.Sprint(typ.GenValue(r, p)[0])]++
+		}
+		if len(counts) > 5 {
+			t.Errorf("expected at most 5 distinct %s values, got %d", typ, len(counts))
+		}
+	}
+}
+
+func TestValueDistributionBoundaryAndNull(t *testing.T) {
+	t.Parallel()
+	p := &typedef.PartitionRangeConfig{
+		ValueDistributions: &typedef.ValueDistributions{
+			Default: &typedef.ValueDistribution{Boundary: 1, Null: 1},
+		},
+	}
+	r := rand.New(rand.NewSource(1))
+	nonFinite := false
+	for i := 0; i < 100; i++ {
+		v := typedef.TYPE_DOUBLE.GenValue(r, p)[0].(float64)
+		nonFinite = nonFinite || math.IsNaN(v) || math.IsInf(v, 0)
+		if f := typedef.TYPE_DOUBLE.GenJSONValue(r, p).(float64); math.IsNaN(f) || math.IsInf(f, 0) {
+			t.Fatalf("expected only finite JSON values, got %v", f)
+		}
+		if v := typedef.TYPE_INT.GenValue(r, p)[0]; v != int32(math.MinInt32) && v != int32(math.MaxInt32) && v != int32(0) {
+			t.Fatalf("expected a boundary int, got %v", v)
+		}
+	}
+	if !nonFinite {
+		t.Error("expected NaN or infinite doubles")
+	}
+
+	col := &typedef.ColumnDef{Name: "col0", Type: typedef.TYPE_INT}
+	if v := col.GenValue(r, p); len(v) != 1 || v[0] != nil {
+		t.Errorf("expected a null column value, got %v", v)
+	}
+}
+
+func TestValueDistributionForKeys(t *testing.T) {
+	t.Parallel()
+	p := &typedef.PartitionRangeConfig{
+		MaxStringLength: 10,
+		MinStringLength: 1,
+		MaxBlobLength:   10,
+		MinBlobLength:   1,
+		ValueDistributions: &typedef.ValueDistributions{
+			Default: &typedef.ValueDistribution{Boundary: 1},
+		},
+	}
+	kp := p.ForKeys()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if v := typedef.TYPE_DOUBLE.GenValue(r, kp)[0].(float64); math.IsNaN(v) {
+			t.Fatal("expected no NaN clustering keys")
+		}
+		if v := typedef.TYPE_FLOAT.GenValue(r, kp)[0].(float32); math.IsNaN(float64(v)) {
+			t.Fatal("expected no NaN clustering keys")
+		}
+		for _, typ := range []typedef.SimpleType{typedef.TYPE_TEXT, typedef.TYPE_BLOB} {
+			if v := typ.GenValue(r, kp)[0]; v == "" {
+				t.Fatalf("expected no empty %s clustering keys", typ)
+			}
+		}
+	}
+	if kp.ForKeys() != kp {
+		t.Error("expected the key config to be reused")
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 ScyllaDB
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typedef
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/rand"
+)
+
+// vectorClass is the class the clusters describe vector types with in the
+// metadata of results, gocql reads them as custom types.
+const vectorClass = "org.apache.cassandra.db.marshal.VectorType"
+
+// VectorType is a vector of a fixed number of floats, vector<float, N>.
+type VectorType struct {
+	ComplexType string `json:"complex_type"`
+	Dimensions  int    `json:"dimensions"`
+}
+
+func (vt *VectorType) CQLType() gocql.TypeInfo {
+	return gocql.NewNativeType(GoCQLProtoVersion4, gocql.TypeCustom,
+		fmt.Sprintf("%s(org.apache.cassandra.db.marshal.FloatType, %d)", vectorClass, vt.Dimensions))
+}
+
+func (vt *VectorType) Name() string {
+	return vt.CQLDef()
+}
+
+func (vt *VectorType) CQLDef() string {
+	return fmt.Sprintf("vector<float, %d>", vt.Dimensions)
+}
+
+func (vt *VectorType) CQLHolder() string {
+	return "?"
+}
+
+func (vt *VectorType) CQLPretty(query string, value []interface{}) (string, int) {
+	if len(value) == 0 {
+		return query, 0
+	}
+	var v Vector
+	switch tv := value[0].(type) {
+	case nil:
+		return strings.Replace(query, "?", "null", 1), 1
+	case Vector:
+		v = tv
+	case []float32:
+		v = tv
+	default:
+		// A value of another type is printed as is.
+		return strings.Replace(query, "?", fmt.Sprintf("%v", tv), 1), 1
+	}
+	vv := "[" + strings.TrimRight(strings.Repeat("?,", len(v)), ",") + "]"
+	for _, f := range v {
+		vv = prettyElement(TYPE_FLOAT, vv, f)
+	}
+	return strings.Replace(query, "?", vv, 1), 1
+}
+
+func (vt *VectorType) GenValue(r *rand.Rand, _ *PartitionRangeConfig) []interface{} {
+	return []interface{}{vt.genValue(r)}
+}
+
+func (vt *VectorType) GenJSONValue(r *rand.Rand, _ *PartitionRangeConfig) interface{} {
+	return []float32(vt.genValue(r))
+}
+
+// genValue generates finite components, the value distributions of floats do
+// not apply to them.
+func (vt *VectorType) genValue(r *rand.Rand) Vector {
+	out := make(Vector, vt.Dimensions)
+	for i := range out {
+		out[i] = float32(r.NormFloat64())
+	}
+	return out
+}
+
+func (vt *VectorType) LenValue() int {
+	return 1
+}
+
+func (vt *VectorType) Indexable() bool {
+	return false
+}
+
+// ValueVariationsNumber returns number of bytes generated value holds
+func (vt *VectorType) ValueVariationsNumber(p *PartitionRangeConfig) float64 {
+	return math.Pow(TYPE_FLOAT.ValueVariationsNumber(p), float64(vt.Dimensions))
+}
+
+// Vector is the value of a vector column. gocql neither marshals vectors nor
+// has a Go type to read them into, so the value does it itself.
+type Vector []float32
+
+// MarshalCQL serializes the components one after the other, a vector of
+// fixed size components carries no lengths.
+func (v Vector) MarshalCQL(gocql.TypeInfo) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	out := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.BigEndian.PutUint32(out[4*i:], math.Float32bits(f))
+	}
+	return out, nil
+}
+
+func (v *Vector) UnmarshalCQL(_ gocql.TypeInfo, data []byte) error {
+	if data == nil {
+		*v = nil
+		return nil
+	}
+	if len(data)%4 != 0 {
+		return errors.Errorf("invalid vector of %d bytes", len(data))
+	}
+	out := make(Vector, len(data)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.BigEndian.Uint32(data[4*i:]))
+	}
+	*v = out
+	return nil
+}
+
+// IsVector reports whether the type information the driver read of a result
+// describes a vector.
+func IsVector(info gocql.TypeInfo) bool {
+	nt, ok := info.(gocql.NativeType)
+	return ok && nt.Type() == gocql.TypeCustom && strings.HasPrefix(nt.Custom(), vectorClass+"(")
+}